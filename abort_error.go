@@ -0,0 +1,79 @@
+package getter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// AbortReason is a machine-readable code for why a download was stopped
+// before it finished, so that UIs can explain it without parsing error
+// messages. It is found with AbortReasonOf.
+type AbortReason string
+
+const (
+	// AbortCanceled is a download whose Client.Ctx was canceled.
+	AbortCanceled AbortReason = "canceled"
+
+	// AbortDeadline is a download that ran out of time, because the
+	// Client's Deadline passed or the deadline of its Ctx did.
+	AbortDeadline AbortReason = "deadline"
+
+	// AbortSizeLimit is a download that was larger than it was allowed to
+	// be, such as a GetMemory download over the Client's MemoryLimit.
+	AbortSizeLimit AbortReason = "size_limit"
+
+	// AbortPolicy is a download that the Client's settings forbid, such
+	// as one that needs network access from an Offline client.
+	AbortPolicy AbortReason = "policy"
+
+	// AbortRateLimit is a download refused by a host that is overloaded,
+	// either with a 429 (Too Many Requests) response or by the Client's
+	// CircuitBreaker.
+	AbortRateLimit AbortReason = "rate_limit"
+)
+
+// AbortError is an error that stopped a download for the given reason,
+// for the reasons that don't have an error type of their own. It has the
+// same message as the error it wraps.
+type AbortError struct {
+	Reason AbortReason
+	Err    error
+}
+
+func (e *AbortError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AbortError) Unwrap() error {
+	return e.Err
+}
+
+// AbortReasonOf returns why err stopped a download, or "" if it isn't an
+// error that a download is aborted with. The error may be wrapped.
+func AbortReasonOf(err error) AbortReason {
+	var (
+		abortErr     *AbortError
+		offlineErr   *OfflineError
+		unhealthyErr *HostUnhealthyError
+		responseErr  *BadResponseCodeError
+	)
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &abortErr):
+		return abortErr.Reason
+	case errors.Is(err, context.Canceled):
+		return AbortCanceled
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, errDeadlineExceeded):
+		return AbortDeadline
+	case errors.As(err, &offlineErr):
+		return AbortPolicy
+	case errors.As(err, &unhealthyErr):
+		return AbortRateLimit
+	case errors.As(err, &responseErr) && responseErr.Code == http.StatusTooManyRequests:
+		return AbortRateLimit
+	}
+
+	return ""
+}
@@ -0,0 +1,61 @@
+package getter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAbortReasonOf(t *testing.T) {
+	cases := []struct {
+		Err    error
+		Reason AbortReason
+	}{
+		{nil, ""},
+		{errors.New("nope"), ""},
+		{context.Canceled, AbortCanceled},
+		{fmt.Errorf("error copying: %w", context.DeadlineExceeded), AbortDeadline},
+		{&PartialError{Err: errDeadlineExceeded}, AbortDeadline},
+		{&AbortError{Reason: AbortSizeLimit, Err: errors.New("too big")}, AbortSizeLimit},
+		{&OfflineError{}, AbortPolicy},
+		{&HostUnhealthyError{}, AbortRateLimit},
+		{&BadResponseCodeError{Code: http.StatusTooManyRequests}, AbortRateLimit},
+		{&BadResponseCodeError{Code: http.StatusNotFound}, ""},
+	}
+
+	for _, tc := range cases {
+		if actual := AbortReasonOf(tc.Err); actual != tc.Reason {
+			t.Fatalf("%v: bad: %q", tc.Err, actual)
+		}
+	}
+}
+
+func TestClient_abortReasons(t *testing.T) {
+	dst := tempDir(t)
+
+	cases := []struct {
+		Client *Client
+		Reason AbortReason
+	}{
+		{&Client{Src: testModule("basic"), Deadline: time.Now().Add(-time.Second)}, AbortDeadline},
+		{&Client{Src: "https://example.com/foo.zip", Offline: true}, AbortPolicy},
+	}
+
+	for _, tc := range cases {
+		tc.Client.Dst = dst
+		tc.Client.Mode = ClientModeDir
+		err := tc.Client.Get()
+		if actual := AbortReasonOf(err); actual != tc.Reason {
+			t.Fatalf("%s: bad: %q (%v)", tc.Client.Src, actual, err)
+		}
+	}
+
+	client := &Client{MemoryLimit: 1}
+	_, err := client.GetMemory(testModule("basic"))
+	if actual := AbortReasonOf(err); actual != AbortSizeLimit {
+		t.Fatalf("bad: %q (%v)", actual, err)
+	}
+}
@@ -0,0 +1,308 @@
+package getter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// AddressPolicy restricts the IP addresses that a Client connects to, so
+// that services downloading sources given by their users can't be made to
+// fetch from themselves, the networks they are on, or the metadata servers
+// of cloud providers such as 169.254.169.254, which hand out credentials.
+// See Client.Addresses and DefaultAddressPolicy.
+//
+// HTTP requests, including those of getters built on them and of the S3
+// and GCS SDKs, are checked as they connect, after host names have been
+// resolved, so redirects and host names that resolve to a denied address
+// are caught too. The address that was checked is the one connected to, so
+// a host name can't be rebound to another address in between. A request
+// sent through a proxy, such as one from HTTP_PROXY or a TorProxy, is
+// checked against the host of its URL instead, which is resolved locally
+// unless it is an onion service, since the proxy is what connects to it;
+// the proxies themselves are configured rather than given by sources, so
+// connections to them aren't checked. The hosts of Git, Mercurial and
+// SFTP sources are resolved and checked before they are downloaded, since
+// the programs those getters run make their own connections. SFTP is told
+// to connect to the address that was checked, but Git and Mercurial
+// resolve host names again themselves, and may connect to other hosts such
+// as those of submodules, so they shouldn't be used with sources that
+// can't be trusted. Requests to a PeerCache aren't checked.
+type AddressPolicy struct {
+	// Deny is the networks that may not be connected to.
+	Deny []*net.IPNet
+
+	// Allow is the networks that may be connected to even though they are
+	// within Deny, such as the network that an internal mirror is on.
+	Allow []*net.IPNet
+}
+
+// defaultDeniedNetworks are the networks denied by DefaultAddressPolicy.
+var defaultDeniedNetworks = []string{
+	"0.0.0.0/8",      // "This" network
+	"10.0.0.0/8",     // Private
+	"100.64.0.0/10",  // Carrier-grade NAT
+	"127.0.0.0/8",    // Loopback
+	"169.254.0.0/16", // Link-local, including cloud metadata servers
+	"172.16.0.0/12",  // Private
+	"192.0.0.0/24",   // IETF protocol assignments
+	"192.168.0.0/16", // Private
+	"198.18.0.0/15",  // Benchmarking
+	"224.0.0.0/4",    // Multicast
+	"240.0.0.0/4",    // Reserved, and broadcast
+	"::/128",         // Unspecified
+	"::1/128",        // Loopback
+	"64:ff9b::/96",   // IPv4 translation, which may reach any of the above
+	"fc00::/7",       // Unique local, including AWS's fd00:ec2::254
+	"fe80::/10",      // Link-local
+	"ff00::/8",       // Multicast
+}
+
+// DefaultAddressPolicy returns a policy that denies loopback, link-local,
+// private and other addresses that aren't on the public internet, which
+// can be added to or have exceptions made with Allow.
+func DefaultAddressPolicy() *AddressPolicy {
+	p := new(AddressPolicy)
+	for _, cidr := range defaultDeniedNetworks {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		p.Deny = append(p.Deny, network)
+	}
+
+	return p
+}
+
+// Check returns an error if ip may not be connected to. The error is an
+// *AbortError with the reason AbortPolicy.
+func (p *AddressPolicy) Check(ip net.IP) error {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, network := range p.Allow {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+	for _, network := range p.Deny {
+		if network.Contains(ip) {
+			return &AbortError{
+				Reason: AbortPolicy,
+				Err:    fmt.Errorf("connecting to %s is denied by the address policy", ip),
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkHost resolves host, unless it is an IP address, and checks each of
+// its addresses.
+func (p *AddressPolicy) checkHost(ctx context.Context, host string) error {
+	_, err := p.resolve(ctx, host)
+	return err
+}
+
+// checkSource checks the hosts of a source at u that g downloads without
+// going through an HTTP client that checks its connections.
+func (p *AddressPolicy) checkSource(ctx context.Context, g Getter, u *url.URL) error {
+	switch g.(type) {
+	case *GitGetter, *HgGetter, *SftpGetter, *GCSGetter:
+	case *S3Getter:
+		if v := u.Query().Get("endpoint"); v != "" {
+			endpoint, err := url.Parse(v)
+			if err == nil && endpoint.Hostname() != "" {
+				if err := p.checkHost(ctx, endpoint.Hostname()); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		return nil
+	}
+
+	if u.Hostname() == "" {
+		return nil
+	}
+	return p.checkHost(ctx, u.Hostname())
+}
+
+// resolve returns the addresses of host, which may be an IP address,
+// failing if any of them are denied.
+func (p *AddressPolicy) resolve(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, p.Check(ip)
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		if err := p.Check(addr.IP); err != nil {
+			return nil, fmt.Errorf("%s: %w", host, err)
+		}
+		ips = append(ips, addr.IP)
+	}
+
+	return ips, nil
+}
+
+// policyClient returns a copy of client whose connections are checked
+// against p. If its transport is an *http.Transport, host names are
+// resolved and checked as connections are made, and the addresses that
+// were checked are connected to, so a host name can't resolve differently
+// the second time, except for requests sent through a proxy, whose hosts
+// are checked as the proxy is chosen. Otherwise the host of each request
+// is resolved and checked before the request is sent.
+func policyClient(client *http.Client, p *AddressPolicy) *http.Client {
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	c := *client
+	t, ok := transport.(*http.Transport)
+	if !ok {
+		c.Transport = &policyTransport{transport: transport, policy: p}
+		return &c
+	}
+
+	t = t.Clone()
+
+	// The proxies that requests are sent through are dialed as they are
+	var proxies sync.Map
+	if proxy := t.Proxy; proxy != nil {
+		t.Proxy = func(req *http.Request) (*url.URL, error) {
+			u, err := proxy(req)
+			if err != nil || u == nil {
+				return u, err
+			}
+			if host := req.URL.Hostname(); !isOnion(host) {
+				if err := p.checkHost(req.Context(), host); err != nil {
+					return nil, err
+				}
+			}
+
+			proxies.Store(proxyAddress(u), true)
+			return u, nil
+		}
+	}
+
+	dial := t.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+	t.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if _, ok := proxies.Load(address); ok {
+			return dial(ctx, network, address)
+		}
+
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := p.resolve(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ip := range ips {
+			var conn net.Conn
+			conn, err = dial(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+	c.Transport = t
+	return &c
+}
+
+// proxyAddress returns the address that the proxy at u is dialed at.
+func proxyAddress(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// policyTransport checks the host of each request against policy before
+// sending it with transport.
+type policyTransport struct {
+	transport http.RoundTripper
+	policy    *AddressPolicy
+}
+
+func (t *policyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.policy.checkHost(req.Context(), req.URL.Hostname()); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	return t.transport.RoundTrip(req)
+}
+
+// pinnedAddress returns the address of host, checked against the client's
+// Addresses, for programs that resolve host names themselves to connect to
+// in its place, so that host can't resolve to another address by the time
+// they connect. It returns "" if the client has no Addresses.
+func (g *getter) pinnedAddress(host string) (string, error) {
+	if g.client == nil || g.client.Addresses == nil {
+		return "", nil
+	}
+
+	ips, err := g.client.Addresses.resolve(g.Context(), host)
+	if err != nil {
+		return "", err
+	}
+	if len(ips) == 0 {
+		return "", fmt.Errorf("%s has no addresses", host)
+	}
+	return ips[0].String(), nil
+}
+
+// addressClient returns client with the client's Addresses applied, if it
+// has any.
+func (g *getter) addressClient(client *http.Client) *http.Client {
+	if g.client == nil || g.client.Addresses == nil {
+		return client
+	}
+
+	return policyClient(client, g.client.Addresses)
+}
+
+// checkAddresses checks the source at u that g downloads against the
+// client's Addresses, if it has any.
+func (c *Client) checkAddresses(g Getter, u *url.URL) error {
+	if c.Addresses == nil {
+		return nil
+	}
+
+	ctx := c.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return c.Addresses.checkSource(ctx, g, u)
+}
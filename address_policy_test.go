@@ -0,0 +1,200 @@
+package getter
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestAddressPolicy_Check(t *testing.T) {
+	p := DefaultAddressPolicy()
+
+	denied := []string{
+		"127.0.0.1",
+		"10.1.2.3",
+		"169.254.169.254",
+		"192.168.0.1",
+		"::1",
+		"fd00:ec2::254",
+		"::ffff:169.254.169.254",
+	}
+	for _, s := range denied {
+		err := p.Check(net.ParseIP(s))
+		if err == nil {
+			t.Fatalf("%s should be denied", s)
+		}
+		if reason := AbortReasonOf(err); reason != AbortPolicy {
+			t.Fatalf("%s: bad reason: %q", s, reason)
+		}
+	}
+
+	for _, s := range []string{"8.8.8.8", "2001:4860:4860::8888"} {
+		if err := p.Check(net.ParseIP(s)); err != nil {
+			t.Fatalf("%s: err: %s", s, err)
+		}
+	}
+
+	_, network, _ := net.ParseCIDR("10.1.0.0/16")
+	p.Allow = append(p.Allow, network)
+	if err := p.Check(net.ParseIP("10.1.2.3")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := p.Check(net.ParseIP("10.2.0.1")); err == nil {
+		t.Fatal("should be denied")
+	}
+}
+
+func TestClient_Addresses_http(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello\n"))
+	}))
+	defer server.Close()
+
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	dst := filepath.Join(td, "file")
+	client := &Client{
+		Src:       server.URL + "/file",
+		Dst:       dst,
+		Mode:      ClientModeFile,
+		Getters:   map[string]Getter{"http": new(HttpGetter)},
+		Addresses: DefaultAddressPolicy(),
+	}
+	err := client.Get()
+	if err == nil {
+		t.Fatal("should fail")
+	}
+	if reason := AbortReasonOf(err); reason != AbortPolicy {
+		t.Fatalf("bad reason %q: %s", reason, err)
+	}
+
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	client.Addresses.Allow = []*net.IPNet{loopback}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "hello\n")
+}
+
+func TestClient_Addresses_proxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("proxied " + r.URL.Host + "\n"))
+	}))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	// The proxy is connected to, though it is on loopback, but the hosts
+	// it connects to are checked
+	g := &HttpGetter{Client: &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}}
+	g.client = &Client{Addresses: DefaultAddressPolicy()}
+	dst := filepath.Join(td, "file")
+	if err := g.GetFile(dst, testURL("http://93.184.216.34/file")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "proxied 93.184.216.34\n")
+
+	for _, src := range []string{"http://127.0.0.1:1/file", "http://169.254.169.254/latest/meta-data", "http://localhost/file"} {
+		err := g.GetFile(dst, testURL(src))
+		if reason := AbortReasonOf(err); reason != AbortPolicy {
+			t.Fatalf("%s: bad reason %q: %v", src, reason, err)
+		}
+	}
+}
+
+func TestClient_Addresses_sharedGetter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello\n"))
+	}))
+	defer server.Close()
+
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	// Clients sharing a getter each download with their own policy
+	getters := map[string]Getter{"http": new(HttpGetter)}
+	var wg sync.WaitGroup
+	errs := make([]error, 20)
+	for i := range errs {
+		client := &Client{
+			Src:     server.URL + "/file",
+			Dst:     filepath.Join(td, strconv.Itoa(i)),
+			Mode:    ClientModeFile,
+			Getters: getters,
+		}
+		if i%2 == 0 {
+			client.Addresses = DefaultAddressPolicy()
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = client.Get()
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if i%2 == 0 {
+			if reason := AbortReasonOf(err); reason != AbortPolicy {
+				t.Fatalf("%d: bad reason %q: %v", i, reason, err)
+			}
+		} else if err != nil {
+			t.Fatalf("%d: err: %s", i, err)
+		}
+	}
+}
+
+func TestClient_Addresses_manifest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"sources": []}`))
+	}))
+	defer server.Close()
+
+	// The manifest itself is got with the client's policy
+	client := &Client{
+		Src:       "manifest::" + server.URL + "/manifest.json",
+		Dst:       tempDir(t),
+		Mode:      ClientModeDir,
+		Addresses: DefaultAddressPolicy(),
+	}
+	defer os.RemoveAll(client.Dst)
+	err := client.Get()
+	if reason := AbortReasonOf(err); reason != AbortPolicy {
+		t.Fatalf("bad reason %q: %v", reason, err)
+	}
+
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	client.Addresses.Allow = []*net.IPNet{loopback}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestClient_Addresses_git(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	client := &Client{
+		Src:       "git::https://169.254.169.254/repo.git",
+		Dst:       filepath.Join(td, "repo"),
+		Dir:       true,
+		Addresses: DefaultAddressPolicy(),
+	}
+	err := client.Get()
+	if reason := AbortReasonOf(err); reason != AbortPolicy {
+		t.Fatalf("bad reason %q: %v", reason, err)
+	}
+}
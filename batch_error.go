@@ -0,0 +1,122 @@
+package getter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BatchError is returned by getters that download from several sources,
+// such as manifests and HTTP directory downloads offering mirrors, when
+// the download fails. It reports what happened to each of the sources,
+// and can be marshalled as JSON so that orchestration systems can record
+// and display it.
+type BatchError struct {
+	// Sources is the result of each of the sources, in the order they
+	// were listed.
+	Sources []SourceResult `json:"sources"`
+}
+
+// SourceStatus is what happened to one of the sources of a BatchError.
+type SourceStatus string
+
+const (
+	// SourceSucceeded is a source that was downloaded.
+	SourceSucceeded SourceStatus = "succeeded"
+
+	// SourceFailed is a source whose download failed.
+	SourceFailed SourceStatus = "failed"
+
+	// SourceSkipped is a source that wasn't tried, for example because an
+	// earlier source of a manifest failed.
+	SourceSkipped SourceStatus = "skipped"
+)
+
+// SourceResult is the result of one of the sources of a BatchError.
+type SourceResult struct {
+	// Source is the source URL, with any credentials redacted.
+	Source string
+
+	// Status is what happened to the source.
+	Status SourceStatus
+
+	// Attempts is how many times the source was tried and Duration how
+	// long that took altogether.
+	Attempts int
+	Duration time.Duration
+
+	// Err is why the source failed, if it did. Its AbortReasonOf is
+	// marshalled along with it.
+	Err error
+
+	// Optional is whether the source was optional, in which case its
+	// failure didn't stop the rest of the sources from being downloaded.
+	Optional bool
+}
+
+// MarshalJSON marshals the result with its duration in seconds and its
+// error as a string.
+func (r SourceResult) MarshalJSON() ([]byte, error) {
+	v := struct {
+		Source   string       `json:"source"`
+		Status   SourceStatus `json:"status"`
+		Attempts int          `json:"attempts"`
+		Duration float64      `json:"duration_seconds"`
+		Err      string       `json:"error,omitempty"`
+		Reason   AbortReason  `json:"reason,omitempty"`
+		Optional bool         `json:"optional,omitempty"`
+	}{
+		Source:   r.Source,
+		Status:   r.Status,
+		Attempts: r.Attempts,
+		Duration: r.Duration.Seconds(),
+		Optional: r.Optional,
+	}
+	if r.Err != nil {
+		v.Err = redactURLCredentials(r.Err.Error())
+		v.Reason = AbortReasonOf(r.Err)
+	}
+
+	return json.Marshal(v)
+}
+
+func (e *BatchError) Error() string {
+	var failed []string
+	for _, r := range e.Sources {
+		if r.Status == SourceFailed {
+			failed = append(failed, fmt.Sprintf("%s: %s", r.Source, r.Err))
+		}
+	}
+
+	switch {
+	case len(failed) == 1:
+		return fmt.Sprintf("error downloading %s", failed[0])
+	case len(failed) == len(e.Sources):
+		return fmt.Sprintf(
+			"error downloading from all %d sources:\n  %s",
+			len(failed), strings.Join(failed, "\n  "))
+	}
+
+	return fmt.Sprintf(
+		"error downloading %d of %d sources:\n  %s",
+		len(failed), len(e.Sources), strings.Join(failed, "\n  "))
+}
+
+// tryResult runs get, returning the result of the source it downloads.
+func tryResult(source string, get func() error) SourceResult {
+	start := time.Now()
+	err := get()
+	r := SourceResult{
+		Source:   redactURLCredentials(source),
+		Status:   SourceSucceeded,
+		Attempts: 1,
+		Duration: time.Since(start),
+		Err:      err,
+	}
+	if err != nil {
+		r.Status = SourceFailed
+	}
+
+	return r
+}
@@ -0,0 +1,118 @@
+// Package bench is a harness for benchmarking go-getter.
+//
+// It writes reproducible fixtures to disk (large synthetic files and
+// archives, and trees of many small files), serves them over HTTP with
+// optional bandwidth throttling, and runs a Client against them under a
+// Go benchmark. The package's own benchmarks cover each of the getters and
+// decompressors that don't need network access or external tools, and can
+// be run with:
+//
+//	go test -bench . github.com/hashicorp/go-getter/bench
+//
+// The Memory benchmarks download artifacts that are generated as they are
+// served, and report the most heap they needed. Their size is set with
+// GO_GETTER_BENCH_STREAM_SIZE, such as to "64G", to check that it stays
+// the same however large the artifact is:
+//
+//	GO_GETTER_BENCH_STREAM_SIZE=64G go test -bench Memory github.com/hashicorp/go-getter/bench
+//
+// The same helpers can be used to benchmark other Client configurations,
+// such as custom getters or decompressors:
+//
+//	func BenchmarkMyGetter(b *testing.B) {
+//		dir, _ := ioutil.TempDir("", "bench")
+//		defer os.RemoveAll(dir)
+//		bench.WriteFile(filepath.Join(dir, "large"), 64<<20)
+//
+//		b.SetBytes(64 << 20)
+//		bench.Run(b, &getter.Client{
+//			Src:     "my://" + filepath.Join(dir, "large"),
+//			Mode:    getter.ClientModeFile,
+//			Getters: map[string]getter.Getter{"my": new(MyGetter)},
+//		})
+//	}
+package bench
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-getter"
+)
+
+// Run benchmarks downloading with the given client. Every iteration
+// downloads into a new destination, so the client's Dst is ignored, and
+// the time spent removing the previous destination isn't counted.
+func Run(b *testing.B, c *getter.Client) {
+	td, err := ioutil.TempDir("", "go-getter-bench")
+	if err != nil {
+		b.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		client := *c
+		client.Dst = filepath.Join(td, "dst")
+		if err := client.Get(); err != nil {
+			b.Fatalf("err: %s", err)
+		}
+
+		b.StopTimer()
+		if err := os.RemoveAll(client.Dst); err != nil {
+			b.Fatalf("err: %s", err)
+		}
+		b.StartTimer()
+	}
+}
+
+// ReportPeakHeap samples the heap in use while a benchmark runs until the
+// function it returns is called, which reports the most that was in use
+// beyond what was in use to start with as the "peak-heap-B" metric. It is
+// for checking that the memory a download needs is bounded however large
+// it is.
+func ReportPeakHeap(b *testing.B) (stop func()) {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	base := m.HeapInuse
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	var peak uint64
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		t := time.NewTicker(10 * time.Millisecond)
+		defer t.Stop()
+		for {
+			var m runtime.MemStats
+			runtime.ReadMemStats(&m)
+			if m.HeapInuse > peak {
+				peak = m.HeapInuse
+			}
+
+			select {
+			case <-t.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+
+		if peak < base {
+			peak = base
+		}
+		b.ReportMetric(float64(peak-base), "peak-heap-B")
+	}
+}
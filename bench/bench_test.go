@@ -0,0 +1,226 @@
+package bench
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-getter"
+)
+
+const (
+	// benchLargeSize is the size of the large file fixture.
+	benchLargeSize = 4 * 1024 * 1024
+
+	// benchTreeFiles and benchTreeSize are the number and size of the
+	// files in the many small files fixture.
+	benchTreeFiles = 1000
+	benchTreeSize  = 1024
+
+	// benchThrottle is the rate of the throttled HTTP server.
+	benchThrottle = 64 * 1024 * 1024
+)
+
+// benchArchiveTypes are the archive types fixtures are written for. The
+// single file types are of the large file, the rest of the tree.
+var benchArchiveTypes = []string{"gz", "xz", "tar.gz", "tar.xz", "zip"}
+
+// fixtureDir is where the fixtures are written by TestMain.
+var fixtureDir string
+
+func TestMain(m *testing.M) {
+	var err error
+	fixtureDir, err = ioutil.TempDir("", "go-getter-bench")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	err = writeFixtures(fixtureDir)
+	code := 1
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	} else {
+		code = m.Run()
+	}
+
+	os.RemoveAll(fixtureDir)
+	os.Exit(code)
+}
+
+func writeFixtures(dir string) error {
+	if err := WriteFile(filepath.Join(dir, "large"), benchLargeSize); err != nil {
+		return err
+	}
+	if err := WriteTree(filepath.Join(dir, "tree"), benchTreeFiles, benchTreeSize); err != nil {
+		return err
+	}
+
+	for _, t := range benchArchiveTypes {
+		src := filepath.Join(dir, "tree")
+		if t == "gz" || t == "xz" {
+			src = filepath.Join(dir, "large")
+		}
+
+		if err := WriteArchive(filepath.Join(dir, "archive."+t), src, t); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestWriteFile(t *testing.T) {
+	td, err := ioutil.TempDir("", "go-getter-bench")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	// Fixtures must be the same every time
+	for _, name := range []string{"a", "b"} {
+		if err := WriteFile(filepath.Join(td, name), 1024); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	a, err := ioutil.ReadFile(filepath.Join(td, "a"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(td, "b"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(a) != 1024 || string(a) != string(b) {
+		t.Fatal("fixtures should be identical")
+	}
+}
+
+func TestFixtures(t *testing.T) {
+	// Every archive fixture must be readable by go-getter
+	for _, typ := range benchArchiveTypes {
+		td, err := ioutil.TempDir("", "go-getter-bench")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		defer os.RemoveAll(td)
+
+		mode := getter.ClientModeDir
+		check := filepath.Join("dir9", "file999.txt")
+		if typ == "gz" || typ == "xz" {
+			mode, check = getter.ClientModeFile, ""
+		}
+
+		dst := filepath.Join(td, "dst")
+		client := &getter.Client{
+			Src:  filepath.Join(fixtureDir, "archive."+typ),
+			Dst:  dst,
+			Mode: mode,
+		}
+		if err := client.Get(); err != nil {
+			t.Fatalf("%s: err: %s", typ, err)
+		}
+
+		fi, err := os.Stat(filepath.Join(dst, check))
+		if err != nil {
+			t.Fatalf("%s: err: %s", typ, err)
+		}
+		if check == "" && fi.Size() != benchLargeSize {
+			t.Fatalf("%s: bad size: %d", typ, fi.Size())
+		}
+	}
+}
+
+func BenchmarkFileGetter_file(b *testing.B) {
+	b.SetBytes(benchLargeSize)
+	Run(b, &getter.Client{
+		Src:     filepath.Join(fixtureDir, "large"),
+		Mode:    getter.ClientModeFile,
+		Getters: map[string]getter.Getter{"file": &getter.FileGetter{Copy: true}},
+	})
+}
+
+func BenchmarkHttpGetter(b *testing.B) {
+	server := NewServer(fixtureDir, 0)
+	defer server.Close()
+
+	b.SetBytes(benchLargeSize)
+	Run(b, &getter.Client{
+		Src:  server.URL + "/large",
+		Mode: getter.ClientModeFile,
+	})
+}
+
+func BenchmarkHttpGetter_throttled(b *testing.B) {
+	server := NewServer(fixtureDir, benchThrottle)
+	defer server.Close()
+
+	b.SetBytes(benchLargeSize)
+	Run(b, &getter.Client{
+		Src:  server.URL + "/large",
+		Mode: getter.ClientModeFile,
+	})
+}
+
+func BenchmarkHttpGetter_checksum(b *testing.B) {
+	server := NewServer(fixtureDir, 0)
+	defer server.Close()
+
+	f, err := os.Open(filepath.Join(fixtureDir, "archive.tar.gz"))
+	if err != nil {
+		b.Fatalf("err: %s", err)
+	}
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	f.Close()
+	if err != nil {
+		b.Fatalf("err: %s", err)
+	}
+
+	Run(b, &getter.Client{
+		Src:  server.URL + "/archive.tar.gz?checksum=sha256:" + hex.EncodeToString(h.Sum(nil)),
+		Mode: getter.ClientModeDir,
+	})
+}
+
+func BenchmarkDecompressors(b *testing.B) {
+	for _, typ := range benchArchiveTypes {
+		mode := getter.ClientModeDir
+		if typ == "gz" || typ == "xz" {
+			mode = getter.ClientModeFile
+		}
+
+		b.Run(typ, func(b *testing.B) {
+			Run(b, &getter.Client{
+				Src:     filepath.Join(fixtureDir, "archive."+typ),
+				Mode:    mode,
+				Getters: map[string]getter.Getter{"file": &getter.FileGetter{Copy: true}},
+			})
+		})
+	}
+}
+
+func BenchmarkStrategy(b *testing.B) {
+	server := NewServer(fixtureDir, 0)
+	defer server.Close()
+
+	strategies := map[string]getter.Strategy{
+		"tempfile": getter.StrategyTempFile,
+		"stream":   getter.StrategyStream,
+	}
+	for name, strategy := range strategies {
+		b.Run(name, func(b *testing.B) {
+			Run(b, &getter.Client{
+				Src:      server.URL + "/archive.tar.gz",
+				Mode:     getter.ClientModeDir,
+				Strategy: strategy,
+			})
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package bench
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+)
+
+// fixtureSeed seeds the contents of generated files so that fixtures are
+// the same on every run.
+const fixtureSeed = 42
+
+// fixtureAlphabet is what generated files are made of. Using a small
+// alphabet rather than purely random bytes makes them compress roughly as
+// well as source code does, so decompression benchmarks are realistic.
+const fixtureAlphabet = "abcdefghijklmnopqrstuvwxyz     \n"
+
+// WriteFile writes a file of the given size in bytes to path, creating any
+// parent directories. The contents only depend on the size.
+func WriteFile(path string, size int64) error {
+	return writeFile(path, size, fixtureSeed)
+}
+
+// WriteTree writes a directory tree of the given number of files, each of
+// the given size in bytes, to dir. Files are spread over subdirectories of
+// up to 100 files each.
+func WriteTree(dir string, files int, size int64) error {
+	for i := 0; i < files; i++ {
+		path := filepath.Join(dir,
+			fmt.Sprintf("dir%d", i/100), fmt.Sprintf("file%d.txt", i))
+		if err := writeFile(path, size, fixtureSeed+int64(i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteArchive writes an archive of the given type of src to dst. The
+// type is one of "tar", "tar.gz", "tar.xz", "zip" or, if src is a single
+// file, "gz" or "xz". There's no bzip2 writer in the standard library, so
+// bzip2 archives aren't supported.
+func WriteArchive(dst, src, archiveType string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	err = writeArchive(f, src, archiveType)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+func writeArchive(w io.Writer, src, archiveType string) error {
+	switch archiveType {
+	case "tar":
+		return writeTar(w, src)
+	case "tar.gz", "gz":
+		gzipW := gzip.NewWriter(w)
+		if err := writeArchiveContents(gzipW, src, archiveType); err != nil {
+			return err
+		}
+		return gzipW.Close()
+	case "tar.xz", "xz":
+		xzW, err := xz.NewWriter(w)
+		if err != nil {
+			return err
+		}
+		if err := writeArchiveContents(xzW, src, archiveType); err != nil {
+			return err
+		}
+		return xzW.Close()
+	case "zip":
+		return writeZip(w, src)
+	default:
+		return fmt.Errorf("unsupported archive type: %s", archiveType)
+	}
+}
+
+// writeArchiveContents writes what goes inside a compressed archive,
+// which is either a tar archive of src or src itself.
+func writeArchiveContents(w io.Writer, src, archiveType string) error {
+	if strings.HasPrefix(archiveType, "tar.") {
+		return writeTar(w, src)
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func writeTar(w io.Writer, src string) error {
+	tarW := tar.NewWriter(w)
+	err := walkFiles(src, func(path, name string, info os.FileInfo) error {
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if err := tarW.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		return copyFile(tarW, path)
+	})
+	if err != nil {
+		return err
+	}
+
+	return tarW.Close()
+}
+
+func writeZip(w io.Writer, src string) error {
+	zipW := zip.NewWriter(w)
+	err := walkFiles(src, func(path, name string, info os.FileInfo) error {
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		hdr.Method = zip.Deflate
+		fw, err := zipW.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+
+		return copyFile(fw, path)
+	})
+	if err != nil {
+		return err
+	}
+
+	return zipW.Close()
+}
+
+// walkFiles calls fn for every regular file in the tree at root, along
+// with its slash separated path relative to root.
+func walkFiles(root string, fn func(path, name string, info os.FileInfo) error) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		return fn(path, filepath.ToSlash(rel), info)
+	})
+}
+
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func writeFile(path string, size, seed int64) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.CopyN(f, &fixtureReader{rand: rand.New(rand.NewSource(seed))}, size)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+// fixtureReader is an endless reader of pseudo-random characters from
+// fixtureAlphabet.
+type fixtureReader struct {
+	rand *rand.Rand
+}
+
+func (r *fixtureReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = fixtureAlphabet[r.rand.Intn(len(fixtureAlphabet))]
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,170 @@
+package bench
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-getter"
+)
+
+// benchStreamSize is the size of the artifacts of the memory benchmarks,
+// which are generated as they are served. It can be set with the
+// GO_GETTER_BENCH_STREAM_SIZE environment variable, such as to "64G", to
+// check that the peak-heap-B they report stays the same for the largest
+// artifacts. Benchmarks that download to disk need that much space free.
+func benchStreamSize(b *testing.B) int64 {
+	v := os.Getenv("GO_GETTER_BENCH_STREAM_SIZE")
+	if v == "" {
+		return 256 << 20
+	}
+
+	shift := 0
+	switch v[len(v)-1] {
+	case 'K', 'k':
+		shift = 10
+	case 'M', 'm':
+		shift = 20
+	case 'G', 'g':
+		shift = 30
+	case 'T', 't':
+		shift = 40
+	}
+	if shift > 0 {
+		v = v[:len(v)-1]
+	}
+	size, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || size <= 0 {
+		b.Fatalf("invalid GO_GETTER_BENCH_STREAM_SIZE: %s", os.Getenv("GO_GETTER_BENCH_STREAM_SIZE"))
+	}
+
+	return size << shift
+}
+
+func BenchmarkMemory_httpStream(b *testing.B) {
+	size := benchStreamSize(b)
+	server := NewStreamServer(size)
+	defer server.Close()
+
+	u, err := url.Parse(server.URL + "/large")
+	if err != nil {
+		b.Fatalf("err: %s", err)
+	}
+
+	b.SetBytes(size)
+	stop := ReportPeakHeap(b)
+	for i := 0; i < b.N; i++ {
+		r, _, err := new(getter.HttpGetter).GetReader(u)
+		if err != nil {
+			b.Fatalf("err: %s", err)
+		}
+		n, err := io.Copy(ioutil.Discard, r)
+		r.Close()
+		if err != nil {
+			b.Fatalf("err: %s", err)
+		}
+		if n != size {
+			b.Fatalf("bad size: %d", n)
+		}
+	}
+	stop()
+}
+
+func BenchmarkMemory_httpChecksum(b *testing.B) {
+	size := benchStreamSize(b)
+	server := NewStreamServer(size)
+	defer server.Close()
+
+	h := sha256.New()
+	if _, err := io.CopyN(h, &fixtureReader{rand: rand.New(rand.NewSource(fixtureSeed))}, size); err != nil {
+		b.Fatalf("err: %s", err)
+	}
+
+	b.SetBytes(size)
+	stop := ReportPeakHeap(b)
+	Run(b, &getter.Client{
+		Src:      server.URL + "/large?checksum=sha256:" + hex.EncodeToString(h.Sum(nil)),
+		Mode:     getter.ClientModeFile,
+		Strategy: getter.StrategyStream,
+	})
+	stop()
+}
+
+func BenchmarkMemory_checksumFile(b *testing.B) {
+	size := benchStreamSize(b)
+	target := "Hello\n"
+	sum := sha256.Sum256([]byte(target))
+
+	// A checksum file of size bytes, listing the target last
+	mux := http.NewServeMux()
+	mux.HandleFunc("/target", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, target)
+	})
+	mux.HandleFunc("/SHA256SUMS", func(w http.ResponseWriter, r *http.Request) {
+		var written int64
+		for i := 0; written < size; i++ {
+			n, err := fmt.Fprintf(w, "%064x  dist/file%d.zip\n", i, i)
+			if err != nil {
+				return
+			}
+			written += int64(n)
+		}
+		fmt.Fprintf(w, "%x  target\n", sum)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	b.SetBytes(size)
+	stop := ReportPeakHeap(b)
+	Run(b, &getter.Client{
+		Src:  server.URL + "/target?checksum=file:" + server.URL + "/SHA256SUMS",
+		Mode: getter.ClientModeFile,
+	})
+	stop()
+}
+
+func BenchmarkMemory_terraformGet(b *testing.B) {
+	size := benchStreamSize(b)
+
+	// A directory endpoint whose page goes on and on without a source
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "<html><head>")
+		chunk := strings.Repeat("<!-- padding -->", 4096)
+		for written := int64(0); written < size; written += int64(len(chunk)) {
+			if _, err := io.WriteString(w, chunk); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	td, err := ioutil.TempDir("", "go-getter-bench")
+	if err != nil {
+		b.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	stop := ReportPeakHeap(b)
+	for i := 0; i < b.N; i++ {
+		client := &getter.Client{
+			Src:  server.URL + "/module/",
+			Dst:  filepath.Join(td, "dst"),
+			Mode: getter.ClientModeDir,
+		}
+		if err := client.Get(); err == nil {
+			b.Fatal("should error")
+		}
+	}
+	stop()
+}
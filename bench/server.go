@@ -0,0 +1,72 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// NewServer starts an HTTP server serving the files in dir. If
+// bytesPerSecond is greater than zero, every response is limited to that
+// rate to simulate a slow link. The caller must close the server.
+func NewServer(dir string, bytesPerSecond int64) *httptest.Server {
+	var handler http.Handler = http.FileServer(http.Dir(dir))
+	if bytesPerSecond > 0 {
+		handler = &throttledHandler{Handler: handler, Rate: bytesPerSecond}
+	}
+
+	return httptest.NewServer(handler)
+}
+
+// NewStreamServer starts an HTTP server that responds to every request with
+// a file of the given size in bytes, the same as WriteFile writes, which is
+// generated as it is sent rather than read from disk. This lets downloads
+// of artifacts larger than the disk, or memory, be benchmarked. The caller
+// must close the server.
+func NewStreamServer(size int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(size))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if r.Method == "HEAD" {
+			return
+		}
+
+		io.CopyN(w, &fixtureReader{rand: rand.New(rand.NewSource(fixtureSeed))}, size)
+	}))
+}
+
+// throttledHandler is an http.Handler that limits responses to Rate
+// bytes per second.
+type throttledHandler struct {
+	Handler http.Handler
+	Rate    int64
+}
+
+func (h *throttledHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.Handler.ServeHTTP(&throttledWriter{
+		ResponseWriter: w,
+		rate:           h.Rate,
+		start:          time.Now(),
+	}, r)
+}
+
+// throttledWriter delays every write until the response is no faster
+// than rate bytes per second.
+type throttledWriter struct {
+	http.ResponseWriter
+	rate    int64
+	start   time.Time
+	written int64
+}
+
+func (w *throttledWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.written += int64(n)
+
+	due := w.start.Add(time.Duration(float64(w.written) / float64(w.rate) * float64(time.Second)))
+	time.Sleep(time.Until(due))
+	return n, err
+}
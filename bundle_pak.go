@@ -0,0 +1,49 @@
+package getter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// pakEntrySize is the size of an entry in the directory of a pak file.
+const pakEntrySize = 64
+
+// readPak calls fn with the name and contents of each file in the pak file
+// r of the given size, in the format used by Quake and the many tools and
+// engines that followed it: a "PACK" header giving the offset and length of
+// a directory of 56 byte names, each with the offset and size of its file.
+func readPak(r io.ReaderAt, size int64, fn func(name string, r io.Reader) error) error {
+	hdr := make([]byte, 12)
+	if _, err := r.ReadAt(hdr, 0); err != nil || string(hdr[:4]) != "PACK" {
+		return fmt.Errorf("not a pak file")
+	}
+	dirOffset := int64(binary.LittleEndian.Uint32(hdr[4:8]))
+	dirLength := int64(binary.LittleEndian.Uint32(hdr[8:12]))
+	if dirLength%pakEntrySize != 0 || dirOffset+dirLength > size {
+		return fmt.Errorf("pak file directory is corrupt")
+	}
+
+	dir := make([]byte, dirLength)
+	if _, err := r.ReadAt(dir, dirOffset); err != nil {
+		return err
+	}
+	for entry := dir; len(entry) > 0; entry = entry[pakEntrySize:] {
+		name := entry[:56]
+		if i := bytes.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+		offset := int64(binary.LittleEndian.Uint32(entry[56:60]))
+		length := int64(binary.LittleEndian.Uint32(entry[60:64]))
+		if offset+length > size {
+			return fmt.Errorf("pak file entry is out of range: %s", name)
+		}
+
+		if err := fn(string(name), io.NewSectionReader(r, offset, length)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
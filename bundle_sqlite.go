@@ -0,0 +1,433 @@
+package getter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// sqliteDB reads the tables of a SQLite database file. It only reads what
+// BundleGetter needs: the rows of ordinary tables, in databases whose text
+// is UTF-8. Tables created WITHOUT ROWID and the write-ahead log aren't
+// read.
+type sqliteDB struct {
+	r        io.ReaderAt
+	size     int64
+	pageSize int
+	usable   int
+}
+
+// sqliteMaxDepth is how deep a table's b-tree may be, which no database
+// that isn't corrupt comes close to.
+const sqliteMaxDepth = 64
+
+func openSQLite(r io.ReaderAt, size int64) (*sqliteDB, error) {
+	hdr := make([]byte, 100)
+	if _, err := r.ReadAt(hdr, 0); err != nil {
+		return nil, fmt.Errorf("not a SQLite database: %s", err)
+	}
+	if string(hdr[:16]) != "SQLite format 3\x00" {
+		return nil, fmt.Errorf("not a SQLite database")
+	}
+
+	pageSize := int(binary.BigEndian.Uint16(hdr[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("invalid SQLite page size: %d", pageSize)
+	}
+	if enc := binary.BigEndian.Uint32(hdr[56:60]); enc > 1 {
+		return nil, fmt.Errorf("only SQLite databases with UTF-8 text are supported")
+	}
+
+	return &sqliteDB{
+		r:        r,
+		size:     size,
+		pageSize: pageSize,
+		usable:   pageSize - int(hdr[20]),
+	}, nil
+}
+
+// page reads the page numbered n, counting from 1.
+func (db *sqliteDB) page(n uint32) ([]byte, error) {
+	off := int64(n-1) * int64(db.pageSize)
+	if n == 0 || off+int64(db.pageSize) > db.size {
+		return nil, fmt.Errorf("SQLite page %d is out of range", n)
+	}
+
+	buf := make([]byte, db.pageSize)
+	if _, err := db.r.ReadAt(buf, off); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// rows calls fn with the rowid and columns of each row of the table whose
+// b-tree has its root at the page root.
+func (db *sqliteDB) rows(root uint32, fn func(rowid int64, values []interface{}) error) error {
+	visited := make(map[uint32]bool)
+	return db.walk(root, 0, visited, func(rowid int64, payload []byte) error {
+		values, err := sqliteRecord(payload)
+		if err != nil {
+			return err
+		}
+		return fn(rowid, values)
+	})
+}
+
+// walk calls fn with the rowid and payload of each cell of the table
+// b-tree page n and the pages below it. visited is the pages walked so far,
+// since a page that is reached twice means the b-tree has a cycle, which
+// would otherwise be walked an exponential number of times.
+func (db *sqliteDB) walk(n uint32, depth int, visited map[uint32]bool, fn func(rowid int64, payload []byte) error) error {
+	if depth > sqliteMaxDepth {
+		return fmt.Errorf("SQLite table is nested too deeply, the database may be corrupt")
+	}
+	if visited[n] {
+		return fmt.Errorf("SQLite page %d is in a table more than once, the database may be corrupt", n)
+	}
+	visited[n] = true
+
+	data, err := db.page(n)
+	if err != nil {
+		return err
+	}
+
+	// The first page starts with the database header
+	off := 0
+	if n == 1 {
+		off = 100
+	}
+
+	var interior bool
+	hdrLen := 8
+	switch data[off] {
+	case 0x0d:
+	case 0x05:
+		interior = true
+		hdrLen = 12
+	default:
+		return fmt.Errorf("SQLite page %d isn't part of a table with rowids", n)
+	}
+
+	cells := int(binary.BigEndian.Uint16(data[off+3:]))
+	pointers := off + hdrLen
+	if pointers+2*cells > len(data) {
+		return fmt.Errorf("SQLite page %d is corrupt", n)
+	}
+	for i := 0; i < cells; i++ {
+		cell := int(binary.BigEndian.Uint16(data[pointers+2*i:]))
+		if cell+4 > db.usable {
+			return fmt.Errorf("SQLite page %d is corrupt", n)
+		}
+
+		if interior {
+			child := binary.BigEndian.Uint32(data[cell:])
+			if err := db.walk(child, depth+1, visited, fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		rowid, payload, err := db.payload(data, cell)
+		if err != nil {
+			return err
+		}
+		if err := fn(rowid, payload); err != nil {
+			return err
+		}
+	}
+
+	if interior {
+		return db.walk(binary.BigEndian.Uint32(data[off+8:]), depth+1, visited, fn)
+	}
+	return nil
+}
+
+// payload returns the rowid and payload of the table leaf cell at off in
+// the page data, reading the rest of the payload from overflow pages if it
+// doesn't fit on the page.
+func (db *sqliteDB) payload(data []byte, off int) (int64, []byte, error) {
+	size, n := sqliteVarint(data[off:])
+	off += n
+	rowid, n := sqliteVarint(data[off:])
+	off += n
+	if size > uint64(db.size) {
+		return 0, nil, fmt.Errorf("SQLite cell is corrupt")
+	}
+
+	// How much of the payload is on the page itself, as the file format
+	// documentation describes
+	total := int(size)
+	maxLocal := db.usable - 35
+	local := total
+	if total > maxLocal {
+		minLocal := (db.usable-12)*32/255 - 23
+		local = minLocal + (total-minLocal)%(db.usable-4)
+		if local > maxLocal {
+			local = minLocal
+		}
+	}
+	if off+local > db.usable || (local < total && off+local+4 > db.usable) {
+		return 0, nil, fmt.Errorf("SQLite cell is corrupt")
+	}
+	if local == total {
+		return int64(rowid), data[off : off+total], nil
+	}
+
+	payload := make([]byte, 0, total)
+	payload = append(payload, data[off:off+local]...)
+	next := binary.BigEndian.Uint32(data[off+local:])
+	for pages := int64(0); len(payload) < total; pages++ {
+		if next == 0 || pages > db.size/int64(db.pageSize) {
+			return 0, nil, fmt.Errorf("SQLite overflow pages are corrupt")
+		}
+		page, err := db.page(next)
+		if err != nil {
+			return 0, nil, err
+		}
+		next = binary.BigEndian.Uint32(page)
+
+		chunk := page[4:db.usable]
+		if rest := total - len(payload); rest < len(chunk) {
+			chunk = chunk[:rest]
+		}
+		payload = append(payload, chunk...)
+	}
+
+	return int64(rowid), payload, nil
+}
+
+// sqliteVarint decodes the big-endian variable length integer at the start
+// of b, returning it and how many bytes it took.
+func sqliteVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9 && i < len(b); i++ {
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+
+	return v, len(b)
+}
+
+// sqliteRecord decodes the values of a record: nil, int64, float64, string
+// or []byte.
+func sqliteRecord(payload []byte) ([]interface{}, error) {
+	hdrSize, n := sqliteVarint(payload)
+	if hdrSize > uint64(len(payload)) {
+		return nil, fmt.Errorf("SQLite record is corrupt")
+	}
+
+	var values []interface{}
+	body := payload[hdrSize:]
+	for pos := n; pos < int(hdrSize); {
+		t, n := sqliteVarint(payload[pos:int(hdrSize)])
+		pos += n
+
+		var size int
+		switch {
+		case t >= 1 && t <= 6:
+			size = []int{1, 2, 3, 4, 6, 8}[t-1]
+		case t == 7:
+			size = 8
+		case t >= 12:
+			size = int((t - 12) / 2)
+		}
+		if size > len(body) {
+			return nil, fmt.Errorf("SQLite record is corrupt")
+		}
+		v := body[:size]
+		body = body[size:]
+
+		switch {
+		case t == 0:
+			values = append(values, nil)
+		case t <= 6:
+			// A big-endian two's complement integer
+			i := int64(int8(v[0]))
+			for _, b := range v[1:] {
+				i = i<<8 | int64(b)
+			}
+			values = append(values, i)
+		case t == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(v)))
+		case t == 8 || t == 9:
+			values = append(values, int64(t-8))
+		case t >= 12 && t%2 == 0:
+			values = append(values, v)
+		case t >= 13:
+			values = append(values, string(v))
+		default:
+			return nil, fmt.Errorf("SQLite record has an invalid type: %d", t)
+		}
+	}
+
+	return values, nil
+}
+
+// sqliteTable is a table found in the schema of a database.
+type sqliteTable struct {
+	root    uint32
+	columns []string
+
+	// rowid is the column that is an alias of the rowid, which is stored
+	// as NULL, or -1 if there isn't one.
+	rowid int
+}
+
+// table finds the table called name in the database's schema, which is
+// itself a table rooted at the first page.
+func (db *sqliteDB) table(name string) (*sqliteTable, error) {
+	var table *sqliteTable
+	err := db.rows(1, func(_ int64, values []interface{}) error {
+		if len(values) < 5 || values[0] != "table" {
+			return nil
+		}
+		tableName, _ := values[1].(string)
+		root, _ := values[3].(int64)
+		sql, _ := values[4].(string)
+		if table != nil || !strings.EqualFold(tableName, name) {
+			return nil
+		}
+		if root <= 0 || root > math.MaxUint32 {
+			return fmt.Errorf("SQLite table %s has no rows", tableName)
+		}
+
+		columns, rowid := sqliteColumns(sql)
+		table = &sqliteTable{root: uint32(root), columns: columns, rowid: rowid}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if table == nil {
+		return nil, fmt.Errorf("SQLite database has no table %s", name)
+	}
+
+	return table, nil
+}
+
+// column returns the index of the column called name.
+func (t *sqliteTable) column(name string) (int, error) {
+	for i, c := range t.columns {
+		if strings.EqualFold(c, name) {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("SQLite table has no column %s", name)
+}
+
+// value returns the value of column i in a row of the table.
+func (t *sqliteTable) value(rowid int64, values []interface{}, i int) interface{} {
+	if i == t.rowid {
+		return rowid
+	}
+	if i >= len(values) {
+		// Columns added after the row was written
+		return nil
+	}
+
+	return values[i]
+}
+
+// sqliteColumns returns the names of the columns defined by a CREATE TABLE
+// statement, and the index of the INTEGER PRIMARY KEY column that is an
+// alias of the rowid, or -1.
+func sqliteColumns(sql string) ([]string, int) {
+	start, end := strings.Index(sql, "("), strings.LastIndex(sql, ")")
+	if start < 0 || end < start {
+		return nil, -1
+	}
+
+	var columns []string
+	rowid := -1
+	for _, def := range sqliteSplit(sql[start+1 : end]) {
+		name, rest := sqliteToken(def)
+		switch strings.ToUpper(name) {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			// A table constraint rather than a column
+			continue
+		}
+
+		fields := strings.Fields(strings.ToUpper(rest))
+		if len(fields) >= 3 && fields[0] == "INTEGER" && strings.Contains(strings.Join(fields, " "), "PRIMARY KEY") {
+			rowid = len(columns)
+		}
+		columns = append(columns, name)
+	}
+
+	return columns, rowid
+}
+
+// sqliteSplit splits the definitions in a CREATE TABLE statement at the
+// commas that aren't quoted or in parentheses.
+func sqliteSplit(s string) []string {
+	var defs []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '[':
+			quote = ']'
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			defs = append(defs, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+
+	return append(defs, strings.TrimSpace(s[start:]))
+}
+
+// sqliteToken returns the first, possibly quoted, identifier in s without
+// its quotes, and the rest of s.
+func sqliteToken(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", ""
+	}
+
+	closing := map[byte]byte{'"': '"', '`': '`', '[': ']', '\'': '\''}
+	if c, ok := closing[s[0]]; ok {
+		var b bytes.Buffer
+		for i := 1; i < len(s); i++ {
+			if s[i] != c {
+				b.WriteByte(s[i])
+				continue
+			}
+			// A doubled quote is a quote in the identifier
+			if c != ']' && i+1 < len(s) && s[i+1] == c {
+				b.WriteByte(c)
+				i++
+				continue
+			}
+			return b.String(), s[i+1:]
+		}
+		return b.String(), ""
+	}
+
+	if i := strings.IndexAny(s, " \t\r\n("); i >= 0 {
+		return s[:i], s[i:]
+	}
+	return s, ""
+}
@@ -0,0 +1,248 @@
+package getter
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CacheGC removes the entries of cache directories, such as the CacheDir
+// of an HttpGetter or GitGetter, that haven't been used for a while or
+// that don't fit in a size limit, so that long-lived processes don't need
+// to clean them up some other way.
+//
+// A cache can be collected while clients in this or other processes are
+// downloading with it. Getters lock an entry while they change it and hold
+// a reference to it while they use it, and entries that are locked or
+// referenced are left for a later collection. An entry was last used when
+// its newest file, including its lock files, was modified.
+type CacheGC struct {
+	// Dirs are the cache directories to collect.
+	Dirs []string
+
+	// MaxAge is how long an entry may go unused before it is removed. If
+	// it is zero, entries are never too old.
+	MaxAge time.Duration
+
+	// MaxSize is the number of bytes that the entries of each directory
+	// may take up altogether, beyond which the least recently used are
+	// removed. If it is zero, there is no limit.
+	MaxSize int64
+
+	// OnError, if set, is called with the error of each collection made by
+	// Run that fails.
+	OnError func(error)
+}
+
+// CacheGCStats are what a collection found and removed.
+type CacheGCStats struct {
+	// Entries and Size are the number of entries and bytes that were in
+	// the directories before they were collected.
+	Entries int
+	Size    int64
+
+	// Removed and Freed are the number of entries and bytes removed.
+	Removed int
+	Freed   int64
+
+	// InUse is the number of entries that would have been removed but
+	// were in use.
+	InUse int
+}
+
+// cacheGCEntry is an entry of a cache directory: the files whose names
+// are the same once the suffixes of its metadata and locks are removed.
+type cacheGCEntry struct {
+	path     string
+	names    []string
+	size     int64
+	lastUsed time.Time
+}
+
+// cacheGCSuffixes are the suffixes of the files that belong to the entry
+// named by the rest of their name.
+var cacheGCSuffixes = []string{".json", ".lock", ".ref"}
+
+// Collect removes the entries of each directory that are older than
+// MaxAge, and then the least recently used until the rest fit in MaxSize.
+// Directories that don't exist are skipped. An entry that can't be removed
+// doesn't stop the others from being removed, and the first such error is
+// returned with the stats of the whole collection.
+func (gc *CacheGC) Collect() (*CacheGCStats, error) {
+	stats := new(CacheGCStats)
+	var firstErr error
+	for _, dir := range gc.Dirs {
+		if err := gc.collect(dir, stats); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return stats, firstErr
+}
+
+// Run collects the directories every interval, starting straight away,
+// until ctx is done, and then returns ctx.Err(). It is meant to be run in
+// its own goroutine for as long as the process is.
+func (gc *CacheGC) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := gc.Collect(); err != nil && gc.OnError != nil {
+			gc.OnError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (gc *CacheGC) collect(dir string, stats *CacheGCStats) error {
+	entries, err := cacheGCEntries(dir)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+	stats.Entries += len(entries)
+	stats.Size += total
+
+	// Oldest first, so that the stale entries are removed, and then the
+	// least recently used until the rest fit.
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastUsed.Before(entries[j].lastUsed)
+	})
+
+	var firstErr error
+	now := time.Now()
+	for _, e := range entries {
+		stale := gc.MaxAge > 0 && now.Sub(e.lastUsed) > gc.MaxAge
+		tooBig := gc.MaxSize > 0 && total > gc.MaxSize
+		if !stale && !tooBig {
+			continue
+		}
+
+		removed, err := e.remove()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if !removed {
+			stats.InUse++
+			continue
+		}
+		stats.Removed++
+		stats.Freed += e.size
+		total -= e.size
+	}
+
+	return firstErr
+}
+
+// cacheGCEntries returns the entries of the cache directory dir, or none
+// if it doesn't exist.
+func cacheGCEntries(dir string) ([]*cacheGCEntry, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*cacheGCEntry)
+	var entries []*cacheGCEntry
+	for _, fi := range fis {
+		key := fi.Name()
+		for _, suffix := range cacheGCSuffixes {
+			key = strings.TrimSuffix(key, suffix)
+		}
+
+		e, ok := byName[key]
+		if !ok {
+			e = &cacheGCEntry{path: filepath.Join(dir, key)}
+			byName[key] = e
+			entries = append(entries, e)
+		}
+		e.names = append(e.names, fi.Name())
+		if fi.ModTime().After(e.lastUsed) {
+			e.lastUsed = fi.ModTime()
+		}
+
+		size, err := diskUsage(filepath.Join(dir, fi.Name()), fi)
+		if err != nil {
+			return nil, err
+		}
+		e.size += size
+	}
+
+	return entries, nil
+}
+
+// diskUsage returns the size of the file at path, or of all of the files
+// in it if it is a directory, such as a Git mirror.
+func diskUsage(path string, fi os.FileInfo) (int64, error) {
+	if !fi.IsDir() {
+		return fi.Size(), nil
+	}
+
+	var size int64
+	err := filepath.Walk(path, func(_ string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// Files removed by a concurrent update don't count
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// remove removes the entry and its lock files unless it is in use, in
+// which case it returns false.
+func (e *cacheGCEntry) remove() (bool, error) {
+	ref, err := tryLock(e.path+".ref", true)
+	if err != nil || ref == nil {
+		return false, err
+	}
+	l, err := tryLock(e.path+".lock", true)
+	if err != nil || l == nil {
+		ref.remove()
+		return false, err
+	}
+
+	for _, name := range e.names {
+		if strings.HasSuffix(name, ".lock") || strings.HasSuffix(name, ".ref") {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(filepath.Dir(e.path), name)); err != nil {
+			l.Unlock()
+			ref.Unlock()
+			return false, err
+		}
+	}
+
+	// The lock files go last, while they are still held, so that anything
+	// waiting for them finds the entry gone. One that another process has
+	// opened may be left behind on Windows, which is harmless.
+	l.remove()
+	ref.remove()
+	return true, nil
+}
@@ -0,0 +1,188 @@
+package getter
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCacheEntry writes an entry of size bytes and its metadata to dir,
+// last used age ago.
+func testCacheEntry(t *testing.T, dir, name string, size int, age time.Duration) string {
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(path+".json", []byte("{}"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	when := time.Now().Add(-age)
+	for _, p := range []string{path, path + ".json"} {
+		if err := os.Chtimes(p, when, when); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	return path
+}
+
+func assertCached(t *testing.T, path string, want bool) {
+	t.Helper()
+	_, err := os.Stat(path)
+	if want && err != nil {
+		t.Fatalf("%s should be cached: %s", path, err)
+	}
+	if !want && !os.IsNotExist(err) {
+		t.Fatalf("%s should be removed: %v", path, err)
+	}
+}
+
+func TestCacheGC_maxAge(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	old := testCacheEntry(t, td, "old", 10, 2*time.Hour)
+	recent := testCacheEntry(t, td, "recent", 10, time.Minute)
+
+	gc := &CacheGC{Dirs: []string{td, filepath.Join(td, "missing")}, MaxAge: time.Hour}
+	stats, err := gc.Collect()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if stats.Entries != 2 || stats.Removed != 1 || stats.Freed != 12 || stats.InUse != 0 {
+		t.Fatalf("bad: %#v", stats)
+	}
+
+	for _, p := range []string{old, old + ".json", old + ".lock", old + ".ref"} {
+		assertCached(t, p, false)
+	}
+	assertCached(t, recent, true)
+	assertCached(t, recent+".json", true)
+}
+
+func TestCacheGC_maxSize(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	a := testCacheEntry(t, td, "a", 100, 3*time.Minute)
+	b := testCacheEntry(t, td, "b", 100, 2*time.Minute)
+	c := testCacheEntry(t, td, "c", 100, time.Minute)
+
+	// Using an entry makes it the most recently used
+	l, err := lockPath(a, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	l.Unlock()
+
+	gc := &CacheGC{Dirs: []string{td}, MaxSize: 250}
+	stats, err := gc.Collect()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if stats.Removed != 1 || stats.Size != 306 {
+		t.Fatalf("bad: %#v", stats)
+	}
+	assertCached(t, a, true)
+	assertCached(t, b, false)
+	assertCached(t, c, true)
+}
+
+func TestCacheGC_inUse(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	referenced := testCacheEntry(t, td, "referenced", 10, 2*time.Hour)
+	locked := testCacheEntry(t, td, "locked", 10, 2*time.Hour)
+
+	ref, err := refPath(referenced, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	l, err := lockPath(locked, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Taking them counts as using them, so make them stale again
+	when := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(referenced+".ref", when, when)
+	os.Chtimes(locked+".lock", when, when)
+
+	gc := &CacheGC{Dirs: []string{td}, MaxAge: time.Hour}
+	stats, err := gc.Collect()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if stats.Removed != 0 || stats.InUse != 2 {
+		t.Fatalf("bad: %#v", stats)
+	}
+	assertCached(t, referenced, true)
+	assertCached(t, locked, true)
+
+	ref.Unlock()
+	l.Unlock()
+	os.Chtimes(referenced+".ref", when, when)
+	os.Chtimes(locked+".lock", when, when)
+	stats, err = gc.Collect()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if stats.Removed != 2 || stats.InUse != 0 {
+		t.Fatalf("bad: %#v", stats)
+	}
+	assertCached(t, referenced, false)
+	assertCached(t, locked, false)
+}
+
+func TestCacheGC_httpCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("Hello\n"))
+	}))
+	defer server.Close()
+
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	cacheDir := filepath.Join(td, "cache")
+	u, err := url.Parse(server.URL + "/file")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Collect as often as possible while downloading
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		gc := &CacheGC{Dirs: []string{cacheDir}, MaxSize: 1}
+		done <- gc.Run(ctx, time.Millisecond)
+	}()
+
+	for i := 0; i < 50; i++ {
+		dst := filepath.Join(td, "dst")
+		g := &HttpGetter{CacheDir: cacheDir}
+		if err := g.GetFile(dst, u); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		assertContents(t, dst, "Hello\n")
+		os.Remove(dst)
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("err: %v", err)
+	}
+}
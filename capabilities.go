@@ -0,0 +1,338 @@
+package getter
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	urlhelper "github.com/hashicorp/go-getter/helper/url"
+)
+
+// Describer is an optional interface implemented by getters, decompressors
+// and detectors that describe themselves, so that applications can
+// generate help text for them and check sources before downloading them.
+// See Client.Capabilities.
+type Describer interface {
+	// Describe returns the description of the getter, decompressor or
+	// detector.
+	Describe() Description
+}
+
+// Description is what a Describer says about itself.
+type Description struct {
+	// Summary is a sentence or two on what is downloaded or unarchived,
+	// or which sources are detected, for help text.
+	Summary string `json:"summary,omitempty"`
+
+	// Options are the query parameters that a getter takes, besides the
+	// ones every source takes.
+	Options []Option `json:"options,omitempty"`
+
+	// Strict is whether a getter's sources can't have other query
+	// parameters. Those of HTTP sources, and of sources that wrap another
+	// source, are part of the URL instead, so they can have any.
+	Strict bool `json:"strict,omitempty"`
+}
+
+// Option is a query parameter of a source.
+type Option struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Capability is something that a getter or decompressor can do besides
+// downloading or unarchiving, because it implements an optional interface.
+type Capability string
+
+const (
+	// CapabilityStream is of a StreamGetter or StreamDecompressor.
+	CapabilityStream Capability = "stream"
+
+	// CapabilityOpen is of an FSGetter.
+	CapabilityOpen Capability = "open"
+
+	// CapabilityLocal is of a LocalGetter.
+	CapabilityLocal Capability = "local"
+
+	// CapabilityPut is of a Putter.
+	CapabilityPut Capability = "put"
+)
+
+// Capabilities are the getters, decompressors and detectors of a client,
+// with what each of them takes and can do. They are plain data that
+// marshals to JSON, for applications that generate documentation of the
+// sources they accept.
+type Capabilities struct {
+	// Options are the query parameters that every source takes.
+	Options []Option `json:"options"`
+
+	// Getters are sorted by their first scheme, and Decompressors by
+	// their first extension. Detectors are in the order they are tried.
+	Getters       []GetterInfo       `json:"getters"`
+	Decompressors []DecompressorInfo `json:"decompressors"`
+	Detectors     []DetectorInfo     `json:"detectors"`
+}
+
+// GetterInfo is a getter and the schemes it is registered for, which are
+// several for a getter registered as more than one, such as "http" and
+// "https".
+type GetterInfo struct {
+	Schemes      []string     `json:"schemes"`
+	Type         string       `json:"type"`
+	Capabilities []Capability `json:"capabilities,omitempty"`
+	Description
+}
+
+// DecompressorInfo is a decompressor and the extensions, or archive
+// parameters, it is registered for.
+type DecompressorInfo struct {
+	Extensions   []string     `json:"extensions"`
+	Type         string       `json:"type"`
+	Capabilities []Capability `json:"capabilities,omitempty"`
+	Description
+}
+
+// DetectorInfo is a detector.
+type DetectorInfo struct {
+	Type string `json:"type"`
+	Description
+}
+
+// generalOptions are the query parameters that the client itself takes
+// from every source.
+var generalOptions = []Option{
+	{"archive", "The archive format to unarchive the download as, or false to not unarchive it."},
+	{"checksum", "The checksum of the file or archive, type:value, or file: and the URL of a checksum file."},
+	{"checksums", "file: and the URL of a checksum file to verify every file of a directory against."},
+	{"filename", "The name of the file downloaded in file mode."},
+	{"gpgsig", "The URL of a detached GPG signature to verify the file or archive with."},
+	{"mirrors", "A comma separated list of other sources to try in order."},
+}
+
+// Capabilities returns the getters, decompressors and detectors that the
+// client uses, which are the defaults unless it sets its own. Those that
+// don't implement Describer have an empty Description.
+func (c *Client) Capabilities() *Capabilities {
+	getters := c.Getters
+	if getters == nil {
+		getters = defaultGetters()
+	}
+	decompressors := c.Decompressors
+	if decompressors == nil {
+		decompressors = Decompressors
+	}
+	detectors := c.Detectors
+	if detectors == nil {
+		detectors = Detectors
+	}
+
+	caps := &Capabilities{
+		Options:       append([]Option(nil), generalOptions...),
+		Getters:       []GetterInfo{},
+		Decompressors: []DecompressorInfo{},
+		Detectors:     []DetectorInfo{},
+	}
+
+	for _, g := range uniqueGetters(getters) {
+		info := GetterInfo{
+			Schemes:     g.keys,
+			Type:        fmt.Sprintf("%T", g.value),
+			Description: describe(g.value),
+		}
+		if _, ok := g.value.(StreamGetter); ok {
+			info.Capabilities = append(info.Capabilities, CapabilityStream)
+		}
+		if _, ok := g.value.(FSGetter); ok {
+			info.Capabilities = append(info.Capabilities, CapabilityOpen)
+		}
+		if _, ok := g.value.(LocalGetter); ok {
+			info.Capabilities = append(info.Capabilities, CapabilityLocal)
+		}
+		if _, ok := g.value.(Putter); ok {
+			info.Capabilities = append(info.Capabilities, CapabilityPut)
+		}
+		caps.Getters = append(caps.Getters, info)
+	}
+
+	for _, d := range uniqueDecompressors(decompressors) {
+		info := DecompressorInfo{
+			Extensions:  d.keys,
+			Type:        fmt.Sprintf("%T", d.value),
+			Description: describe(d.value),
+		}
+		if _, ok := d.value.(StreamDecompressor); ok {
+			info.Capabilities = append(info.Capabilities, CapabilityStream)
+		}
+		caps.Decompressors = append(caps.Decompressors, info)
+	}
+
+	for _, d := range detectors {
+		caps.Detectors = append(caps.Detectors, DetectorInfo{
+			Type:        fmt.Sprintf("%T", d),
+			Description: describe(d),
+		})
+	}
+
+	return caps
+}
+
+// Validate checks src, and each of its mirrors, the way the client would
+// before downloading it, without downloading anything: that it is detected
+// as a source with a registered getter, that its archive and checksum
+// parameters are valid, and that a getter whose Description is Strict
+// takes each of its query parameters. The client's Src is ignored.
+func (c *Client) Validate(src string) error {
+	sources, err := splitMirrors(src)
+	if err != nil {
+		return err
+	}
+	if sources == nil {
+		sources = []string{src}
+	}
+
+	for _, s := range sources {
+		if err := c.validate(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) validate(src string) error {
+	getters := c.Getters
+	if getters == nil {
+		getters = defaultGetters()
+	}
+	decompressors := c.Decompressors
+	if decompressors == nil {
+		decompressors = Decompressors
+	}
+	detectors := c.Detectors
+	if detectors == nil {
+		detectors = Detectors
+	}
+
+	detected, err := Detect(src, c.Pwd, detectors)
+	if err != nil {
+		return err
+	}
+	force, detected := getForcedGetter(detected)
+	detected, subDir := SourceDirSubdir(detected)
+
+	u, err := urlhelper.Parse(detected)
+	if err != nil {
+		return err
+	}
+	scheme := force
+	if scheme == "" {
+		scheme = u.Scheme
+	}
+	g, ok := getters[scheme]
+	if !ok {
+		return fmt.Errorf("download not supported for scheme '%s'", scheme)
+	}
+	if err := checkKeepGitSubdir(g, u, subDir); err != nil {
+		return err
+	}
+
+	q := u.Query()
+	archiveURL := *u
+	if _, err := getArchiveType(&archiveURL, decompressors); err != nil {
+		return err
+	}
+	if v := q.Get("checksum"); v != "" && !strings.HasPrefix(v, "file:") {
+		if _, _, err := parseChecksum(v); err != nil {
+			return err
+		}
+	}
+	if v := q.Get("checksums"); v != "" && !strings.HasPrefix(v, "file:") {
+		return fmt.Errorf(
+			"checksums must be a checksum file in the form file:<url>: %s", v)
+	}
+
+	desc := describe(g)
+	if !desc.Strict {
+		return nil
+	}
+	known := make(map[string]bool)
+	for _, o := range generalOptions {
+		known[o.Name] = true
+	}
+	for _, o := range desc.Options {
+		known[o.Name] = true
+	}
+	var unknown []string
+	for k := range q {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unsupported parameters for scheme '%s': %s",
+			scheme, strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
+
+// describe returns the Description of v, or an empty one if it isn't a
+// Describer.
+func describe(v interface{}) Description {
+	if d, ok := v.(Describer); ok {
+		return d.Describe()
+	}
+	return Description{}
+}
+
+// registered is a value of a map and the keys it is registered for.
+type registered struct {
+	keys  []string
+	value interface{}
+}
+
+// uniqueGetters returns each of the getters once, with the schemes it is
+// registered for, sorted by the first of them.
+func uniqueGetters(m map[string]Getter) []registered {
+	values := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		values[k] = v
+	}
+	return uniqueValues(values)
+}
+
+// uniqueDecompressors is uniqueGetters for decompressors.
+func uniqueDecompressors(m map[string]Decompressor) []registered {
+	values := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		values[k] = v
+	}
+	return uniqueValues(values)
+}
+
+func uniqueValues(m map[string]interface{}) []registered {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var result []registered
+	index := make(map[interface{}]int)
+	for _, k := range keys {
+		// Values that can't be map keys are listed once for each key
+		v := m[k]
+		if !reflect.TypeOf(v).Comparable() {
+			result = append(result, registered{keys: []string{k}, value: v})
+			continue
+		}
+		if i, ok := index[v]; ok {
+			result[i].keys = append(result[i].keys, k)
+			continue
+		}
+		index[v] = len(result)
+		result = append(result, registered{keys: []string{k}, value: v})
+	}
+	return result
+}
@@ -0,0 +1,131 @@
+package getter
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClient_Capabilities(t *testing.T) {
+	caps := new(Client).Capabilities()
+
+	getters := make(map[string]GetterInfo)
+	for _, g := range caps.Getters {
+		if g.Summary == "" {
+			t.Errorf("getter %s has no summary", g.Type)
+		}
+		for _, s := range g.Schemes {
+			getters[s] = g
+		}
+	}
+	if len(getters) != len(Getters) {
+		t.Fatalf("bad schemes: %d", len(getters))
+	}
+
+	http := getters["https"]
+	if !reflect.DeepEqual(http.Schemes, []string{"http", "https"}) {
+		t.Fatalf("bad: %#v", http.Schemes)
+	}
+	if http.Type != "*getter.HttpGetter" || http.Strict {
+		t.Fatalf("bad: %#v", http)
+	}
+	if !reflect.DeepEqual(http.Capabilities, []Capability{CapabilityStream, CapabilityPut}) {
+		t.Fatalf("bad: %#v", http.Capabilities)
+	}
+	if !reflect.DeepEqual(getters["file"].Capabilities, []Capability{CapabilityStream, CapabilityOpen, CapabilityLocal}) {
+		t.Fatalf("bad: %#v", getters["file"].Capabilities)
+	}
+	if !getters["s3"].Strict || getters["s3"].Options[0].Name != "aws_access_key_id" {
+		t.Fatalf("bad: %#v", getters["s3"])
+	}
+
+	var tgz *DecompressorInfo
+	for i, d := range caps.Decompressors {
+		if d.Summary == "" {
+			t.Errorf("decompressor %s has no summary", d.Type)
+		}
+		if d.Type == "*getter.TarGzipDecompressor" {
+			tgz = &caps.Decompressors[i]
+		}
+	}
+	if tgz == nil || !reflect.DeepEqual(tgz.Extensions, []string{"tar.gz", "tgz"}) {
+		t.Fatalf("bad: %#v", tgz)
+	}
+	if !reflect.DeepEqual(tgz.Capabilities, []Capability{CapabilityStream}) {
+		t.Fatalf("bad: %#v", tgz.Capabilities)
+	}
+
+	if len(caps.Detectors) != len(Detectors) {
+		t.Fatalf("bad detectors: %d", len(caps.Detectors))
+	}
+	for i, d := range caps.Detectors {
+		if d.Type != reflect.TypeOf(Detectors[i]).String() || d.Summary == "" {
+			t.Fatalf("bad: %#v", d)
+		}
+	}
+
+	// It is plain data, with the descriptions flattened into each entry
+	b, err := json.Marshal(caps)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(string(b), `"schemes":["http","https"],"type":"*getter.HttpGetter","capabilities":["stream","put"],"summary":`) {
+		t.Fatalf("bad: %s", b)
+	}
+}
+
+func TestClient_Capabilities_custom(t *testing.T) {
+	mock := new(MockGetter)
+	caps := (&Client{
+		Getters:       map[string]Getter{"mock": mock, "other": mock},
+		Decompressors: map[string]Decompressor{},
+		Detectors:     []Detector{},
+	}).Capabilities()
+
+	expected := []GetterInfo{{Schemes: []string{"mock", "other"}, Type: "*getter.MockGetter"}}
+	if !reflect.DeepEqual(caps.Getters, expected) {
+		t.Fatalf("bad: %#v", caps.Getters)
+	}
+	if len(caps.Decompressors) != 0 || len(caps.Detectors) != 0 {
+		t.Fatalf("bad: %#v", caps)
+	}
+	if len(caps.Options) == 0 {
+		t.Fatal("should have the general options")
+	}
+}
+
+func TestClient_Validate(t *testing.T) {
+	cases := []struct {
+		Src string
+		Err string
+	}{
+		{"https://example.com/foo.zip?checksum=md5:b7d96c89d09d9e204f5fedc4d5d55b21", ""},
+		{"https://example.com/foo?anything=goes", ""},
+		{"https://example.com/foo?archive=tgz&checksum=file:https://example.com/SHA256SUMS", ""},
+		{"github.com/hashicorp/foo//bar?ref=v1", ""},
+		{"s3::https://s3.amazonaws.com/bucket/foo?region=us-east-1&archive=false", ""},
+		{"./foo", ""},
+		{"nope://example.com/foo", "download not supported for scheme 'nope'"},
+		{"https://example.com/foo?archive=rar", "unsupported archive type: rar"},
+		{"https://example.com/foo?checksum=crc:abcd", "unsupported checksum type: crc"},
+		{"https://example.com/foo?checksum=md5:xyz", "invalid checksum"},
+		{"https://example.com/foo?checksums=sha256:abcd", "checksums must be a checksum file"},
+		{"s3::https://s3.amazonaws.com/bucket/foo?regoin=us-east-1&ref=v1", "unsupported parameters for scheme 's3': ref, regoin"},
+		{"https://example.com/foo?mirrors=" + "nope%3A%2F%2Fexample.com", "download not supported for scheme 'nope'"},
+	}
+
+	client := &Client{Pwd: tempDir(t)}
+	for _, tc := range cases {
+		err := client.Validate(tc.Src)
+		if tc.Err == "" {
+			if err != nil {
+				t.Errorf("%s: err: %s", tc.Src, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.Err) {
+			t.Errorf("%s: bad err: %v", tc.Src, err)
+		}
+	}
+}
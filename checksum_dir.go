@@ -0,0 +1,123 @@
+package getter
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ChecksumDir computes the checksum of the directory tree at path in the
+// format expected by the checksum query parameter for directory sources,
+// e.g. "sha256:...". checksumType is any type supported by the checksum
+// query parameter.
+//
+// The checksum is the hash of a listing of every file in the tree, sorted
+// by its slash separated path relative to path. Each line of the listing
+// is the hex encoded hash of the file's contents (or of its target for
+// symlinks), two spaces and the path. File modes and times aren't part of
+// the checksum, and neither are ".git" and ".hg" since their contents
+// differ between otherwise identical checkouts.
+func ChecksumDir(path, checksumType string) (string, error) {
+	h, err := checksumHashForType(checksumType)
+	if err != nil {
+		return "", err
+	}
+
+	sum, err := dirHash(path, h)
+	if err != nil {
+		return "", err
+	}
+
+	return checksumType + ":" + hex.EncodeToString(sum), nil
+}
+
+// checksumDir computes the checksum of the directory tree at source and
+// compares it to the given expected value.
+func checksumDir(source string, h hash.Hash, v []byte) error {
+	actual, err := dirHash(source, h)
+	if err != nil {
+		return fmt.Errorf("Failed to hash directory: %s", err)
+	}
+
+	if !bytes.Equal(actual, v) {
+		return &ChecksumError{Path: source, Expected: v, Actual: actual}
+	}
+
+	return nil
+}
+
+// dirHash returns the hash of the directory tree at root as documented by
+// ChecksumDir.
+func dirHash(root string, h hash.Hash) ([]byte, error) {
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	walkFn := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+
+		if name := info.Name(); name == ".git" || name == ".hg" {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		h.Reset()
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			io.WriteString(h, filepath.ToSlash(target))
+		} else {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(h, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+
+		lines = append(lines, fmt.Sprintf(
+			"%s  %s\n", hex.EncodeToString(h.Sum(nil)), filepath.ToSlash(rel)))
+		return nil
+	}
+	if err := filepath.Walk(root, walkFn); err != nil {
+		return nil, err
+	}
+
+	// Sort by path, which is where each line's path starts
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i][h.Size()*2+2:] < lines[j][h.Size()*2+2:]
+	})
+
+	h.Reset()
+	for _, line := range lines {
+		io.WriteString(h, line)
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,70 @@
+package getter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testBasicDirChecksum is the checksum of the "basic" fixture, which is
+// the same as: find . -type f | cut -c3- | sort | xargs sha256sum | sha256sum
+const testBasicDirChecksum = "sha256:4004e4f853e117e133caa09bccecac8f3669746bc2e2f400a9f7e91ec57a75a2"
+
+func TestChecksumDir(t *testing.T) {
+	actual, err := ChecksumDir(filepath.Join(fixtureDir, "basic"), "sha256")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual != testBasicDirChecksum {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestChecksumDir_badType(t *testing.T) {
+	if _, err := ChecksumDir(filepath.Join(fixtureDir, "basic"), "nope"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestGet_checksumDir(t *testing.T) {
+	cases := []struct {
+		Append string
+		Err    bool
+	}{
+		{
+			"?checksum=" + testBasicDirChecksum,
+			false,
+		},
+		{
+			"?checksum=sha256:4004e4f853e117e133caa09bccecac8f3669746bc2e2f400a9f7e91ec57a75a3",
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		func() {
+			dst := tempDir(t)
+			defer os.RemoveAll(dst)
+
+			u := testModule("basic") + tc.Append
+			if err := Get(dst, u); (err != nil) != tc.Err {
+				t.Fatalf("append: %s\n\nerr: %s", tc.Append, err)
+			}
+		}()
+	}
+}
+
+func TestGet_checksumDirSubdir(t *testing.T) {
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	expected, err := ChecksumDir(filepath.Join(fixtureDir, "basic", "subdir"), "sha256")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	u := testModule("basic//subdir") + "?checksum=" + expected
+	if err := Get(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -0,0 +1,26 @@
+package getter
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// ChecksumError is returned when a download doesn't match its checksum,
+// whether given with the checksum query parameter or in a checksum file.
+type ChecksumError struct {
+	// Path is the file or directory that was checked. It may have been
+	// removed since, for example if it was streamed into the destination.
+	Path string
+
+	// Expected is the checksum the download should have had, and Actual
+	// the one that it had.
+	Expected []byte
+	Actual   []byte
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf(
+		"Checksums did not match.\nExpected: %s\nGot: %s",
+		hex.EncodeToString(e.Expected),
+		hex.EncodeToString(e.Actual))
+}
@@ -0,0 +1,259 @@
+package getter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-safetemp"
+)
+
+// fileChecksum is a single entry of a checksum file.
+type fileChecksum struct {
+	Type     string
+	Value    []byte
+	Filename string
+}
+
+// checksumTypesByLength maps the length of a hex encoded checksum to its
+// type, for checksum files that don't state the type.
+var checksumTypesByLength = map[int]string{
+	32:  "md5",
+	40:  "sha1",
+	64:  "sha256",
+	128: "sha512",
+}
+
+// checksumTypeAliases maps the names that BSD style checksum files and
+// OpenSSL give hashes, lower cased and without dashes, to their types.
+var checksumTypeAliases = map[string]string{
+	"sha2256":    "sha256",
+	"sha2512":    "sha512",
+	"sha512256":  "sha512-256",
+	"sha3256":    "sha3-256",
+	"blake2b256": "blake2b-256",
+	"blake2s256": "blake2s",
+}
+
+// bsdChecksumLine matches a line of a checksum file in BSD format, such as
+// "SHA256 (foo.txt) = <hex>", or as written by "openssl dgst", such as
+// "SHA2-256(foo.txt)= <hex>".
+var bsdChecksumLine = regexp.MustCompile(`^([A-Za-z0-9-]+) ?\((.*)\) ?= ?([0-9A-Za-z]+)$`)
+
+// getChecksumFile downloads the checksum file at the given source and
+// returns its entries keyed by slash separated file name, or only those
+// whose names keep returns true for if it isn't nil. It is got with the
+// client's settings, so a relative source is relative to its Pwd.
+func (c *Client) getChecksumFile(src string, keep func(name string) bool) (map[string]*fileChecksum, error) {
+	td, tdcloser, err := safetemp.Dir("", "getter")
+	if err != nil {
+		return nil, err
+	}
+	defer tdcloser.Close()
+
+	path := filepath.Join(td, "checksums")
+	if err := c.subClient(src, path, ClientModeFile).Get(); err != nil {
+		return nil, fmt.Errorf("error downloading checksum file: %s", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return filterChecksumFile(f, keep)
+}
+
+// parseChecksumFile parses a checksum file in the format written by
+// sha256sum, "shasum -a" and friends ("<hex>  <file>", or "<hex> *<file>"
+// in binary mode), or in the BSD format written by "shasum --tag", "sha256"
+// and "openssl dgst" ("SHA256 (<file>) = <hex>").
+func parseChecksumFile(r io.Reader) (map[string]*fileChecksum, error) {
+	return filterChecksumFile(r, nil)
+}
+
+// filterChecksumFile is parseChecksumFile, returning only the entries whose
+// names keep returns true for if it isn't nil, so that only those are held
+// in memory however large the checksum file is.
+func filterChecksumFile(r io.Reader, keep func(name string) bool) (map[string]*fileChecksum, error) {
+	result := make(map[string]*fileChecksum)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		c, err := parseChecksumLine(line)
+		if err != nil {
+			return nil, err
+		}
+
+		if keep == nil || keep(c.Filename) {
+			result[c.Filename] = c
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// parseChecksumLine parses a single line of a checksum file.
+func parseChecksumLine(line string) (*fileChecksum, error) {
+	var c fileChecksum
+	var value string
+
+	// sha256sum escapes file names with backslashes or newlines in them,
+	// and marks the line with a leading backslash.
+	escaped := strings.HasPrefix(line, "\\")
+	if escaped {
+		line = line[1:]
+	}
+
+	var m []string
+	if strings.Contains(line, "(") {
+		m = bsdChecksumLine.FindStringSubmatch(line)
+	}
+	if m != nil {
+		c.Type = strings.Replace(strings.ToLower(m[1]), "-", "", -1)
+		if alias, ok := checksumTypeAliases[c.Type]; ok {
+			c.Type = alias
+		}
+		c.Filename = m[2]
+		value = m[3]
+	} else {
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid checksum line: %q", line)
+		}
+
+		value = fields[0]
+		c.Filename = strings.TrimPrefix(strings.TrimLeft(fields[1], " "), "*")
+		c.Type = checksumTypesByLength[len(value)]
+	}
+	if escaped {
+		c.Filename = strings.NewReplacer("\\\\", "\\", "\\n", "\n").Replace(c.Filename)
+	}
+
+	if _, err := checksumHashForType(c.Type); err != nil {
+		return nil, fmt.Errorf("invalid checksum line: %q: %s", line, err)
+	}
+
+	b, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checksum line: %q: %s", line, err)
+	}
+	c.Value = b
+	c.Filename = strings.TrimPrefix(filepath.ToSlash(c.Filename), "./")
+
+	return &c, nil
+}
+
+// checksumFromFile returns the checksum, in the form "type:value", of the
+// file called filename in the checksum file at src, which is given to the
+// checksum query parameter as "file:<src>". The file's entry may also be
+// under a directory, as long as no other entry has the same base name.
+func (c *Client) checksumFromFile(src, filename string) (string, error) {
+	base := path.Base(filename)
+	sums, err := c.getChecksumFile(src, func(name string) bool {
+		return path.Base(name) == base
+	})
+	if err != nil {
+		return "", err
+	}
+
+	entry, ok := sums[filename]
+	if !ok {
+		for name, e := range sums {
+			if path.Base(name) != base {
+				continue
+			}
+			if entry != nil {
+				return "", fmt.Errorf(
+					"checksum file has more than one entry for %s: %s and %s",
+					base, entry.Filename, name)
+			}
+			entry = e
+		}
+	}
+	if entry == nil {
+		return "", fmt.Errorf("checksum file has no entry for %s", filename)
+	}
+
+	return entry.Type + ":" + hex.EncodeToString(entry.Value), nil
+}
+
+// checksumDirFiles verifies every file in the directory tree at root
+// against its entry in sums. Files that don't have an entry are an
+// error too.
+func checksumDirFiles(root string, sums map[string]*fileChecksum) error {
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	walkFn := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if name := info.Name(); name == ".git" || name == ".hg" {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		c, ok := sums[rel]
+		if !ok {
+			missing = append(missing, rel)
+			return nil
+		}
+
+		h, err := checksumHashForType(c.Type)
+		if err != nil {
+			return err
+		}
+		if err := checksum(path, h, c.Value); err != nil {
+			return fmt.Errorf("%s: %s", rel, err)
+		}
+
+		return nil
+	}
+	if err := filepath.Walk(root, walkFn); err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		var buf bytes.Buffer
+		for _, m := range missing {
+			fmt.Fprintf(&buf, "\n  %s", m)
+		}
+		return fmt.Errorf("files missing from checksum file:%s", buf.String())
+	}
+
+	return nil
+}
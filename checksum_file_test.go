@@ -0,0 +1,222 @@
+package getter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseChecksumFile(t *testing.T) {
+	cases := []struct {
+		Input  string
+		Output map[string]*fileChecksum
+		Err    bool
+	}{
+		{
+			"",
+			map[string]*fileChecksum{},
+			false,
+		},
+		{
+			"09f7e02f1290be211da707a266f153b3  foo.txt\n",
+			map[string]*fileChecksum{
+				"foo.txt": {
+					Type:     "md5",
+					Value:    []byte{0x09, 0xf7, 0xe0, 0x2f, 0x12, 0x90, 0xbe, 0x21, 0x1d, 0xa7, 0x07, 0xa2, 0x66, 0xf1, 0x53, 0xb3},
+					Filename: "foo.txt",
+				},
+			},
+			false,
+		},
+		{
+			"09f7e02f1290be211da707a266f153b3 *./dir/foo.txt\n",
+			map[string]*fileChecksum{
+				"dir/foo.txt": {
+					Type:     "md5",
+					Value:    []byte{0x09, 0xf7, 0xe0, 0x2f, 0x12, 0x90, 0xbe, 0x21, 0x1d, 0xa7, 0x07, 0xa2, 0x66, 0xf1, 0x53, 0xb3},
+					Filename: "dir/foo.txt",
+				},
+			},
+			false,
+		},
+		{
+			"# comment\nMD5 (foo bar.txt) = 09f7e02f1290be211da707a266f153b3\n",
+			map[string]*fileChecksum{
+				"foo bar.txt": {
+					Type:     "md5",
+					Value:    []byte{0x09, 0xf7, 0xe0, 0x2f, 0x12, 0x90, 0xbe, 0x21, 0x1d, 0xa7, 0x07, 0xa2, 0x66, 0xf1, 0x53, 0xb3},
+					Filename: "foo bar.txt",
+				},
+			},
+			false,
+		},
+		{
+			"BLAKE2b-256 (foo.txt) = f2187273852d04b1d1ff550ca489fc4ced0e5197c7438b688a29737359073c30\n",
+			map[string]*fileChecksum{
+				"foo.txt": {
+					Type:     "blake2b-256",
+					Value:    []byte{0xf2, 0x18, 0x72, 0x73, 0x85, 0x2d, 0x04, 0xb1, 0xd1, 0xff, 0x55, 0x0c, 0xa4, 0x89, 0xfc, 0x4c, 0xed, 0x0e, 0x51, 0x97, 0xc7, 0x43, 0x8b, 0x68, 0x8a, 0x29, 0x73, 0x73, 0x59, 0x07, 0x3c, 0x30},
+					Filename: "foo.txt",
+				},
+			},
+			false,
+		},
+		{
+			"SHA2-256(foo.txt)= 66a045b452102c59d840ec097d59d9467e13a3f34f6494e539ffd32c1bb35f18\n",
+			map[string]*fileChecksum{
+				"foo.txt": {
+					Type:     "sha256",
+					Value:    []byte{0x66, 0xa0, 0x45, 0xb4, 0x52, 0x10, 0x2c, 0x59, 0xd8, 0x40, 0xec, 0x09, 0x7d, 0x59, 0xd9, 0x46, 0x7e, 0x13, 0xa3, 0xf3, 0x4f, 0x64, 0x94, 0xe5, 0x39, 0xff, 0xd3, 0x2c, 0x1b, 0xb3, 0x5f, 0x18},
+					Filename: "foo.txt",
+				},
+			},
+			false,
+		},
+		{
+			"\\09f7e02f1290be211da707a266f153b3  foo\\nbar\\\\.txt\n",
+			map[string]*fileChecksum{
+				"foo\nbar\\.txt": {
+					Type:     "md5",
+					Value:    []byte{0x09, 0xf7, 0xe0, 0x2f, 0x12, 0x90, 0xbe, 0x21, 0x1d, 0xa7, 0x07, 0xa2, 0x66, 0xf1, 0x53, 0xb3},
+					Filename: "foo\nbar\\.txt",
+				},
+			},
+			false,
+		},
+		{
+			"09f7e02f1290be211da707a266f153  foo.txt\n",
+			nil,
+			true,
+		},
+		{
+			"SHA3 (foo.txt) = 09f7e02f1290be211da707a266f153b3\n",
+			nil,
+			true,
+		},
+		{
+			"zzf7e02f1290be211da707a266f153b3  foo.txt\n",
+			nil,
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		actual, err := parseChecksumFile(strings.NewReader(tc.Input))
+		if (err != nil) != tc.Err {
+			t.Fatalf("%q: err: %s", tc.Input, err)
+		}
+		if !tc.Err && !reflect.DeepEqual(actual, tc.Output) {
+			t.Fatalf("%q: bad: %#v", tc.Input, actual)
+		}
+	}
+}
+
+func TestGet_checksumFiles(t *testing.T) {
+	cases := []struct {
+		File string
+		Err  string
+	}{
+		{"SHA256SUMS", ""},
+		{"SHA256SUMS-bad", "main.tf"},
+		{"SHA256SUMS-missing", "subdir/sub.tf"},
+	}
+
+	for _, tc := range cases {
+		func() {
+			dst := tempDir(t)
+			defer os.RemoveAll(dst)
+
+			u := testModule("basic") + "?checksums=file:" + testModule("checksum-file/"+tc.File)
+			err := Get(dst, u)
+			if (err != nil) != (tc.Err != "") {
+				t.Fatalf("%s: err: %s", tc.File, err)
+			}
+			if err != nil && !strings.Contains(err.Error(), tc.Err) {
+				t.Fatalf("%s: err: %s", tc.File, err)
+			}
+		}()
+	}
+}
+
+func TestGetFile_checksumFiles(t *testing.T) {
+	dst := tempFile(t)
+	u := testModule("basic-file/foo.txt") + "?checksums=file:" + testModule("checksum-file/SHA256SUMS")
+
+	if err := GetFile(dst, u); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestGetFile_checksumFromFilePwd(t *testing.T) {
+	pwd, err := filepath.Abs(fixtureDir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The checksum file is relative to the Pwd too, not the process's
+	// working directory
+	dst := tempFile(t)
+	defer os.Remove(dst)
+	client := &Client{
+		Src:  "./basic/main.tf?checksum=file:./checksum-file/SHA256SUMS",
+		Dst:  dst,
+		Pwd:  pwd,
+		Mode: ClientModeFile,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	client.Src = "./basic/main.tf?checksum=file:./checksum-file/SHA256SUMS-bad"
+	if err := client.Get(); err == nil || !strings.Contains(err.Error(), "Checksums did not match") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestGetFile_checksumFromFileGetters(t *testing.T) {
+	dst := tempFile(t)
+	defer os.Remove(dst)
+
+	// The checksum file is got with the client's getters too
+	client := &Client{
+		Src:     testModule("basic/main.tf") + "?checksum=file:http://127.0.0.1/SHA256SUMS",
+		Dst:     dst,
+		Mode:    ClientModeFile,
+		Getters: map[string]Getter{"file": new(FileGetter)},
+	}
+	err := client.Get()
+	if err == nil || !strings.Contains(err.Error(), "download not supported for scheme 'http'") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestGetFile_checksumFromFile(t *testing.T) {
+	cases := []struct {
+		Source string
+		File   string
+		Err    string
+	}{
+		{"basic/main.tf", "SHA256SUMS", ""},
+		{"basic/subdir/sub.tf", "SHA256SUMS", ""},
+		{"basic/main.tf", "SHA256SUMS-bad", "Checksums did not match"},
+		{"basic-file/foo.txt", "SHA256SUMS", "no entry for foo.txt"},
+	}
+
+	for _, tc := range cases {
+		func() {
+			dst := tempFile(t)
+			defer os.Remove(dst)
+
+			u := testModule(tc.Source) + "?checksum=file:" + testModule("checksum-file/"+tc.File)
+			err := GetFile(dst, u)
+			if (err != nil) != (tc.Err != "") {
+				t.Fatalf("%s: err: %s", tc.Source, err)
+			}
+			if err != nil && !strings.Contains(err.Error(), tc.Err) {
+				t.Fatalf("%s: err: %s", tc.Source, err)
+			}
+		}()
+	}
+}
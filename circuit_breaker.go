@@ -0,0 +1,123 @@
+package getter
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CircuitBreaker stops HTTP requests to a host for a while once it has
+// responded with too many 429 (Too Many Requests) or 503 (Service
+// Unavailable) responses in a row, so that a host that is struggling isn't
+// made worse by clients that keep retrying it.
+//
+// Requests to a host whose circuit is open fail with a *HostUnhealthyError
+// without being sent. Once the cooldown has passed a request is let
+// through again, and the circuit is closed if it succeeds. A single
+// CircuitBreaker is meant to be shared by every Client in a program.
+type CircuitBreaker struct {
+	// Threshold is the number of 429 or 503 responses in a row after which
+	// the circuit for a host is opened. This defaults to 5 if left unset.
+	Threshold int
+
+	// Cooldown is how long the circuit stays open. If the host sent a
+	// Retry-After header that is later, that is used instead. This
+	// defaults to 30 seconds if left unset.
+	Cooldown time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*circuitState
+}
+
+// circuitState is the state of a CircuitBreaker for one host.
+type circuitState struct {
+	failures int
+	until    time.Time
+}
+
+// HostUnhealthyError is returned for requests that weren't sent because
+// the CircuitBreaker's circuit for their host is open.
+type HostUnhealthyError struct {
+	// Host is the host that requests were sent to.
+	Host string
+
+	// RetryAfter is when requests to the host will be allowed again.
+	RetryAfter time.Time
+}
+
+func (e *HostUnhealthyError) Error() string {
+	return fmt.Sprintf(
+		"host %s is unhealthy after repeated 429 or 503 responses, retry after %s",
+		e.Host, e.RetryAfter.Format(time.RFC3339))
+}
+
+// allow returns a *HostUnhealthyError if the circuit for host is open.
+func (b *CircuitBreaker) allow(host string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.hosts[host]
+	if ok && time.Now().Before(s.until) {
+		return &HostUnhealthyError{Host: host, RetryAfter: s.until}
+	}
+
+	return nil
+}
+
+// record records the response to a request to host.
+func (b *CircuitBreaker) record(host string, resp *http.Response) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if resp.StatusCode != http.StatusTooManyRequests &&
+		resp.StatusCode != http.StatusServiceUnavailable {
+		delete(b.hosts, host)
+		return
+	}
+
+	if b.hosts == nil {
+		b.hosts = make(map[string]*circuitState)
+	}
+	s, ok := b.hosts[host]
+	if !ok {
+		s = new(circuitState)
+		b.hosts[host] = s
+	}
+
+	s.failures++
+	threshold := b.Threshold
+	if threshold <= 0 {
+		threshold = 5
+	}
+	if s.failures < threshold {
+		return
+	}
+
+	cooldown := b.Cooldown
+	if cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+	s.until = time.Now().Add(cooldown)
+	if retryAfter := parseRetryAfter(resp.Header.Get("Retry-After")); retryAfter.After(s.until) {
+		s.until = retryAfter
+	}
+}
+
+// parseRetryAfter returns the time given by a Retry-After header, which is
+// either a number of seconds or an HTTP date. It returns the zero time if
+// the header is empty or invalid.
+func parseRetryAfter(v string) time.Time {
+	if v == "" {
+		return time.Time{}
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Now().Add(time.Duration(secs) * time.Second)
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return t
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,98 @@
+package getter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var requests, status int32 = 0, http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer server.Close()
+
+	breaker := &CircuitBreaker{Threshold: 2, Cooldown: 50 * time.Millisecond}
+	g := new(HttpGetter)
+	g.client = &Client{CircuitBreaker: breaker}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	u, err := url.Parse(server.URL + "/file")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	get := func() error {
+		return g.GetFile(filepath.Join(dst, "file"), u)
+	}
+
+	// The first failures reach the server
+	for i := 0; i < 2; i++ {
+		if err := get(); err == nil {
+			t.Fatal("should error")
+		} else if _, ok := err.(*HostUnhealthyError); ok {
+			t.Fatalf("circuit opened too early: %s", err)
+		}
+	}
+
+	// Then the circuit is open
+	err = get()
+	if _, ok := err.(*HostUnhealthyError); !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Fatalf("bad requests: %d", n)
+	}
+
+	// Once the cooldown has passed a successful request closes it
+	time.Sleep(50 * time.Millisecond)
+	atomic.StoreInt32(&status, http.StatusOK)
+	if err := get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestCircuitBreaker_retryAfter(t *testing.T) {
+	breaker := &CircuitBreaker{Threshold: 1, Cooldown: time.Millisecond}
+	resp := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": []string{"60"}},
+	}
+	breaker.record("example.com", resp)
+
+	err, ok := breaker.allow("example.com").(*HostUnhealthyError)
+	if !ok {
+		t.Fatal("should be unhealthy")
+	}
+	if time.Until(err.RetryAfter) < 50*time.Second {
+		t.Fatalf("bad retry after: %s", err.RetryAfter)
+	}
+
+	// Other hosts aren't affected
+	if err := breaker.allow("example.org"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	date := time.Date(2030, 1, 2, 15, 4, 5, 0, time.UTC)
+	if actual := parseRetryAfter(date.Format(http.TimeFormat)); !actual.Equal(date) {
+		t.Fatalf("bad: %s", actual)
+	}
+	if actual := parseRetryAfter("10"); time.Until(actual) < 5*time.Second {
+		t.Fatalf("bad: %s", actual)
+	}
+	if actual := parseRetryAfter("soon"); !actual.IsZero() {
+		t.Fatalf("bad: %s", actual)
+	}
+}
@@ -2,6 +2,7 @@ package getter
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
@@ -11,13 +12,23 @@ import (
 	"hash"
 	"io"
 	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	urlhelper "github.com/hashicorp/go-getter/helper/url"
 	"github.com/hashicorp/go-safetemp"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/sha3"
 )
 
 // Client is a client for downloading things.
@@ -32,8 +43,12 @@ type Client struct {
 	// true, then this should be a directory. If the directory doesn't exist,
 	// it will be created for you.
 	//
-	// Pwd is the working directory for detection. If this isn't set, some
-	// detection may fail. Client will not default pwd to the current
+	// Pwd is the working directory for detection, which relative local
+	// paths such as "./foo" and "../foo" are relative to, including those
+	// of checksum files, signatures, mirrors and the files uploaded by Put.
+	// A relative Pwd is itself relative to the process's working
+	// directory, so daemons should set an absolute one. If this isn't set,
+	// some detection may fail. Client will not default pwd to the current
 	// working directory for security reasons.
 	Src string
 	Dst string
@@ -44,17 +59,202 @@ type Client struct {
 	Mode ClientMode
 
 	// Detectors is the list of detectors that are tried on the source.
-	// If this is nil, then the default Detectors will be used.
+	// If this is nil, then the default Detectors will be used. To change
+	// the defaults for this client alone, start with DefaultDetectors.
 	Detectors []Detector
 
 	// Decompressors is the map of decompressors supported by this client.
 	// If this is nil, then the default value is the Decompressors global.
+	// To change the defaults for this client alone, start with
+	// DefaultDecompressors.
 	Decompressors map[string]Decompressor
 
 	// Getters is the map of protocols supported by this client. If this
-	// is nil, then the default Getters variable will be used.
+	// is nil, then the default Getters variable will be used. To add to
+	// the defaults for this client alone, start with DefaultGetters.
 	Getters map[string]Getter
 
+	// Ctx, if set, is the context of the download. Getters abort whatever
+	// they are doing once it is done, and a destination that didn't exist
+	// before Get is removed rather than being left partly written.
+	Ctx context.Context
+
+	// Deadline, if non-zero, is the time by which the download must have
+	// finished. Getters that download many files, such as S3 directories
+	// and manifests, stop once it has passed and return a *PartialError
+	// describing what was and wasn't downloaded.
+	Deadline time.Time
+
+	// Timeout, if positive, limits how long each call to Get, GetAny,
+	// GetMemory or Put may take, including the downloads that getters make
+	// for it, as if Ctx had this deadline. Getters have timeouts of their
+	// own, such as HttpGetter's Timeout, for servers that stop responding
+	// partway through a download.
+	Timeout time.Duration
+
+	// StartJitter, if positive, makes Get wait for a random time of up to
+	// this long before downloading anything, so that a fleet of machines
+	// that are all told to download the same source at the same time,
+	// such as from a cron job at the top of the hour, are spread out
+	// rather than all hitting the origin at once. The wait ends early if
+	// Ctx is done, and doesn't go past Deadline.
+	StartJitter time.Duration
+
+	// UserAgent is the User-Agent header sent with HTTP requests, including
+	// those to S3. If this is empty, DefaultUserAgent is used.
+	//
+	// Header is added to every HTTP request, for example to send a
+	// correlation ID that servers can log.
+	UserAgent string
+	Header    http.Header
+
+	// CircuitBreaker, if set, stops HTTP requests to hosts that keep
+	// responding that they are overloaded. See CircuitBreaker for more
+	// details.
+	CircuitBreaker *CircuitBreaker
+
+	// RetryPolicy, if set, is how HTTP and S3 requests that fail for
+	// reasons that may be transient are retried. See RetryPolicy for
+	// more details.
+	RetryPolicy *RetryPolicy
+
+	// RefreshURL, if set, is called by the HTTP getter for a new URL to
+	// replace a signed URL, such as an S3 presigned URL or an Azure SAS
+	// URL, that has expired or is refused. This lets downloads that have
+	// waited a long time to start, or to be retried, carry on rather than
+	// failing. See SignedURLExpiry for the URLs that are recognized.
+	RefreshURL func(*url.URL) (*url.URL, error)
+
+	// Lock, if true, takes an advisory lock on Dst for the duration of Get
+	// so that other processes downloading into the same path with Lock
+	// set wait for it rather than corrupting each other's downloads. The
+	// lock is held on a file next to Dst with ".lock" appended to its
+	// name, which is left behind.
+	//
+	// LockTimeout is how long to wait for another process to release the
+	// lock. If this is zero Get waits for as long as it takes, and if it
+	// is negative Get fails straight away.
+	Lock        bool
+	LockTimeout time.Duration
+
+	// Atomic, if true, downloads into a staging path next to Dst, which
+	// only replaces Dst once the download, its checksums and unarchiving
+	// have all succeeded, so that anything reading Dst at the same time
+	// never sees it partly written and a failed download leaves it as it
+	// was. Dst is replaced as a whole, so downloads that would otherwise
+	// update or resume what is already there start afresh. A file is
+	// replaced in a single rename, and a directory is moved aside and
+	// replaced by the new one, so it is missing for only a moment.
+	Atomic bool
+
+	// Inflight, if set, is checked for a download of the same source that
+	// is already in progress, in which case that is waited for and copied
+	// rather than downloading the source again. See InflightGroup for
+	// more details.
+	Inflight *InflightGroup
+
+	// PeerCache, if set, is asked for file downloads that have a checksum
+	// before the getter is used, and is told about any such file once it
+	// has been downloaded and verified. See PeerCache for more details.
+	PeerCache PeerCache
+
+	// Strategy is how files that are verified against a checksum or
+	// decompressed are downloaded, trading disk space against being able
+	// to verify the file before anything is written. See Strategy for
+	// the options.
+	//
+	// StreamThreshold is the size in bytes above which StrategyAuto
+	// streams files. If this is zero, DefaultStreamThreshold is used.
+	Strategy        Strategy
+	StreamThreshold int64
+
+	// MemoryLimit is the most bytes that GetMemory holds in memory for a
+	// download, counting both archives and what is unpacked from them.
+	// If this is zero, DefaultMemoryLimit is used.
+	MemoryLimit int64
+
+	// ProgressListener, if set, is told about each file that getters
+	// download and how much of it has been read. See ProgressTracker.
+	ProgressListener ProgressTracker
+
+	// OnOptionalFailure, if set, is called with the result of each
+	// optional source of a batch download, such as a manifest, that
+	// fails. The failure of an optional source doesn't fail the download.
+	OnOptionalFailure func(SourceResult)
+
+	// VerifySample, if positive, is how many files of a directory download
+	// are chosen at random and checked against a second download of the
+	// source, made with caches asked to go back to the origin, to detect
+	// proxies and caches that silently corrupt what they serve. Any file
+	// that differs is reported to OnVerifyMismatch, or fails the download
+	// if that isn't set.
+	VerifySample     int
+	OnVerifyMismatch func(VerifyMismatch)
+
+	// Credentials, if set, provides the credentials that HTTP requests are
+	// authenticated with, for hosts that the source doesn't give
+	// credentials for itself with a username and password in its URL, an
+	// entry in a netrc file or an Authorization header. See
+	// SystemdCredentials.
+	Credentials CredentialProvider
+
+	// Metrics, if set, counts the downloads made with each getter. See
+	// Metrics for more details.
+	Metrics *Metrics
+
+	// Trace, if set, records the decisions made while downloading, for
+	// debugging. See Trace for more details.
+	Trace *Trace
+
+	// References, if true, downloads the sources listed in a ReferencesFile
+	// in the root of a directory that is downloaded into the directory
+	// too, and so on for any that they list in turn, to build a bundle out
+	// of artifacts from several places. The file has the same format as a
+	// manifest for ManifestGetter. A source that refers back to one that
+	// lists it is an error, as are references nested more deeply than
+	// MaxReferenceDepth, or DefaultMaxReferenceDepth if that is zero.
+	References        bool
+	MaxReferenceDepth int
+
+	// Addresses, if set, restricts the addresses that are connected to,
+	// to protect services that download sources given by their users
+	// from server-side request forgery. See DefaultAddressPolicy.
+	Addresses *AddressPolicy
+
+	// Stdin is what the source "-", or stdin://, is read from. If this is
+	// nil, os.Stdin is used. See StdinGetter.
+	Stdin io.Reader
+
+	// Offline, if true, forbids network access. Only getters that implement
+	// LocalGetter are used, for the sources they can get locally: local
+	// files, manifests of them and Git repositories with a mirror in the
+	// Git getter's CacheDir, which is cloned without being updated. Any
+	// other source fails with an *OfflineError listing what is missing.
+	Offline bool
+
+	// GPGKeyring is the keyring that files downloaded with a "gpgsig"
+	// query parameter, the URL of a detached signature of the file, are
+	// verified against. A file or archive that isn't signed by a key in it
+	// is removed rather than being left in Dst or decompressed.
+	GPGKeyring openpgp.KeyRing
+
+	// SpecialFiles is what is done with sockets, named pipes and device
+	// files when directories, or local files, are copied into Dst.
+	SpecialFiles SpecialFilePolicy
+
+	// Symlinks is what is done with symlinks in archives that are
+	// unarchived, directories that are copied into Dst and local files
+	// that are copied. It applies to the default decompressors unless
+	// they have a policy of their own. See SymlinkPolicy.
+	Symlinks SymlinkPolicy
+
+	// DecompressLimits, if set, are limits on what is extracted from the
+	// archives that are unarchived, for clients that download archives
+	// they can't trust. They apply to the default decompressors, and to
+	// GitHub archives, OCI layers and the blobs of bundles, unless the
+	// decompressors have limits of their own. See DecompressLimits.
+	DecompressLimits *DecompressLimits
+
 	// Dir, if true, tells the Client it is downloading a directory (versus
 	// a single file). This distinction is necessary since filenames and
 	// directory names follow the same format so disambiguating is impossible
@@ -62,10 +262,75 @@ type Client struct {
 	//
 	// WARNING: deprecated. If Mode is set, that will take precedence.
 	Dir bool
+
+	// metricsGetter is the name of the getter being used, set on the copy
+	// of the client that is given to it when there are Metrics.
+	metricsGetter string
+
+	// referenceChain is the canonical sources whose references led to
+	// this download, to detect cycles.
+	referenceChain []string
+
+	// retryBudget is what is left of the retries of the RetryPolicy for
+	// the download that this client is part of.
+	retryBudget *retryBudget
 }
 
 // Get downloads the configured source to the destination.
 func (c *Client) Get() error {
+	if c.Timeout > 0 {
+		c, cancel := c.withTimeout()
+		defer cancel()
+		return c.Get()
+	}
+	c = c.withRetryBudget()
+
+	// A source with mirrors is downloaded from the first of them that
+	// succeeds.
+	mirrors, err := splitMirrors(c.Src)
+	if err != nil {
+		return err
+	}
+	if mirrors != nil {
+		return c.GetAny(c.Dst, mirrors)
+	}
+
+	if err := c.staggerStart(); err != nil {
+		return err
+	}
+
+	if c.Lock {
+		l, err := lockPath(c.Dst, c.LockTimeout)
+		if err != nil {
+			return err
+		}
+		defer l.Unlock()
+	}
+
+	var created bool
+	if c.Ctx != nil {
+		if err := c.Ctx.Err(); err != nil {
+			return err
+		}
+
+		_, err := os.Lstat(c.Dst)
+		created = os.IsNotExist(err)
+	}
+
+	if c.Inflight != nil {
+		err = c.Inflight.get(c)
+	} else {
+		err = c.getStaged()
+	}
+	if err != nil && created && c.Ctx.Err() != nil {
+		os.RemoveAll(c.Dst)
+	}
+
+	return err
+}
+
+// get is Get without the Lock, Ctx and Inflight checks.
+func (c *Client) get() (err error) {
 	// Store this locally since there are cases we swap this
 	mode := c.Mode
 	if mode == ClientModeInvalid {
@@ -87,13 +352,19 @@ func (c *Client) Get() error {
 	if detectors == nil {
 		detectors = Detectors
 	}
-	src, err := Detect(c.Src, c.Pwd, detectors)
+	src, detector, err := detect(c.Src, c.Pwd, detectors)
 	if err != nil {
 		return err
 	}
+	if detector != nil {
+		c.trace("detect", c.Src, "%T detected %s", detector, redactURLCredentials(src))
+	}
 
 	// Determine if we have a forced protocol, i.e. "git::http://..."
 	force, src := getForcedGetter(src)
+	if force != "" {
+		c.trace("force", c.Src, "getter forced to %s", force)
+	}
 
 	// If there is a subdir component, then we download the root separately
 	// and then copy over the proper subdir.
@@ -109,6 +380,7 @@ func (c *Client) Get() error {
 
 		realDst = dst
 		dst = td
+		c.trace("subdir", c.Src, "subdirectory %s is copied out of %s", subDir, redactURLCredentials(src))
 	}
 
 	u, err := urlhelper.Parse(src)
@@ -121,7 +393,7 @@ func (c *Client) Get() error {
 
 	getters := c.Getters
 	if getters == nil {
-		getters = Getters
+		getters = defaultGetters()
 	}
 
 	g, ok := getters[force]
@@ -129,42 +401,53 @@ func (c *Client) Get() error {
 		return fmt.Errorf(
 			"download not supported for scheme '%s'", force)
 	}
+	if err := checkKeepGitSubdir(g, u, subDir); err != nil {
+		return err
+	}
+	c.trace("getter", c.Src, "using the %s getter (%T)", force, g)
+	if c.Metrics != nil {
+		// The getter is given a copy of the client that knows the
+		// getter's name, so that what it reads is counted against it
+		client := *c
+		client.metricsGetter = force
+		c = &client
+
+		end := c.Metrics.start(force)
+		defer func() { end(err) }()
+	}
+	g = getterForClient(g, c)
+	if err := c.checkAddresses(g, u); err != nil {
+		return err
+	}
 
-	// We have magic query parameters that we use to signal different features
-	q := u.Query()
-
-	// Determine if we have an archive type
-	archiveV := q.Get("archive")
-	if archiveV != "" {
-		// Delete the paramter since it is a magic parameter we don't
-		// want to pass on to the Getter
-		q.Del("archive")
-		u.RawQuery = q.Encode()
-
-		// If we can parse the value as a bool and it is false, then
-		// set the archive to "-" which should never map to a decompressor
-		if b, err := strconv.ParseBool(archiveV); err == nil && !b {
-			archiveV = "-"
+	if c.deadlineExceeded() {
+		return &AbortError{
+			Reason: AbortDeadline,
+			Err:    fmt.Errorf("deadline exceeded before downloading '%s'", src),
 		}
 	}
-	if archiveV == "" {
-		// We don't appear to... but is it part of the filename?
-		matchingLen := 0
-		for k, _ := range decompressors {
-			if strings.HasSuffix(u.Path, "."+k) && len(k) > matchingLen {
-				archiveV = k
-				matchingLen = len(k)
-			}
-		}
+
+	// Determine if we have an archive type
+	archiveV, err := getArchiveType(u, decompressors)
+	if err != nil {
+		return err
 	}
 
+	// We have magic query parameters that we use to signal different features
+	q := u.Query()
+
 	// If we have a decompressor, then we need to change the destination
 	// to download to a temporary path. We unarchive this into the final,
 	// real path.
 	var decompressDst string
 	var decompressDir bool
-	decompressor := decompressors[archiveV]
+	decompressor := withLimits(withSymlinks(decompressors[archiveV], c.Symlinks), c.DecompressLimits)
+	if archiveV == "-" {
+		c.trace("decompress", c.Src, "unarchiving is disabled")
+	}
 	if decompressor != nil {
+		c.trace("decompress", c.Src, "unarchiving as %s with %T", archiveV, decompressor)
+
 		// Create a temporary directory to store our archive. We delete
 		// this at the end of everything.
 		td, err := ioutil.TempDir("", "getter")
@@ -185,39 +468,65 @@ func (c *Client) Get() error {
 	// Determine if we have a checksum
 	var checksumHash hash.Hash
 	var checksumValue []byte
+	var checksumDigest string
 	if v := q.Get("checksum"); v != "" {
 		// Delete the query parameter if we have it.
 		q.Del("checksum")
 		u.RawQuery = q.Encode()
 
-		// Determine the checksum hash type
-		checksumType := ""
-		idx := strings.Index(v, ":")
-		if idx > -1 {
-			checksumType = v[:idx]
-		}
-		switch checksumType {
-		case "md5":
-			checksumHash = md5.New()
-		case "sha1":
-			checksumHash = sha1.New()
-		case "sha256":
-			checksumHash = sha256.New()
-		case "sha512":
-			checksumHash = sha512.New()
-		default:
+		// The checksum may be looked up in a checksum file
+		if strings.HasPrefix(v, "file:") {
+			v, err = c.checksumFromFile(strings.TrimPrefix(v, "file:"), path.Base(u.Path))
+			if err != nil {
+				return err
+			}
+		}
+
+		checksumHash, checksumValue, err = parseChecksum(v)
+		if err != nil {
+			return err
+		}
+		checksumDigest = v
+	}
+
+	// Determine if we have a file listing the checksum of each file
+	var checksumFiles map[string]*fileChecksum
+	if v := q.Get("checksums"); v != "" {
+		// Delete the query parameter if we have it.
+		q.Del("checksums")
+		u.RawQuery = q.Encode()
+
+		if !strings.HasPrefix(v, "file:") {
 			return fmt.Errorf(
-				"unsupported checksum type: %s", checksumType)
+				"checksums must be a checksum file in the form file:<url>: %s", v)
+		}
+
+		checksumFiles, err = c.getChecksumFile(strings.TrimPrefix(v, "file:"), nil)
+		if err != nil {
+			return err
 		}
+	}
+
+	// Determine if we have a signature to verify the file against
+	var signature []byte
+	if v := q.Get("gpgsig"); v != "" {
+		// Delete the query parameter if we have it.
+		q.Del("gpgsig")
+		u.RawQuery = q.Encode()
 
-		// Get the remainder of the value and parse it into bytes
-		b, err := hex.DecodeString(v[idx+1:])
+		if c.GPGKeyring == nil {
+			return fmt.Errorf("gpgsig requires the client to have a GPGKeyring")
+		}
+		signature, err = c.getSignature(v)
 		if err != nil {
-			return fmt.Errorf("invalid checksum: %s", err)
+			return err
 		}
+	}
 
-		// Set our value
-		checksumValue = b
+	if c.Offline {
+		if lg, ok := g.(LocalGetter); !ok || !lg.Local(u) {
+			return &OfflineError{Sources: []string{redactURLCredentials(src)}}
+		}
 	}
 
 	if mode == ClientModeAny {
@@ -226,6 +535,7 @@ func (c *Client) Get() error {
 		if err != nil {
 			return err
 		}
+		c.trace("mode", c.Src, "getter chose mode %s", modeName(mode))
 
 		// Destination is the base name of the URL path in "any" mode when
 		// a file source is detected.
@@ -245,6 +555,10 @@ func (c *Client) Get() error {
 		}
 	}
 
+	if signature != nil && mode == ClientModeDir {
+		return fmt.Errorf("gpgsig can only be specified for files and archives")
+	}
+
 	// If we're not downloading a directory, then just download the file
 	// and return.
 	if mode == ClientModeFile {
@@ -254,24 +568,119 @@ func (c *Client) Get() error {
 			dst = realDst
 		}
 
-		err := g.GetFile(dst, &uClone)
-		if err != nil {
-			return err
+		// A signed file is downloaded next to its destination and only
+		// moved into place once it has been verified.
+		var signedDst string
+		if signature != nil && decompressor == nil {
+			if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+				return err
+			}
+			f, err := ioutil.TempFile(filepath.Dir(dst), ".getter")
+			if err != nil {
+				return err
+			}
+			f.Close()
+			os.Remove(f.Name())
+			defer os.Remove(f.Name())
+
+			signedDst = dst
+			dst = f.Name()
 		}
 
-		if checksumHash != nil {
-			if err := checksum(dst, checksumHash, checksumValue); err != nil {
+		// If we know what we're expecting to download, see if one of our
+		// peers already has it.
+		var fromPeer bool
+		if checksumHash != nil && c.PeerCache != nil {
+			fromPeer, err = c.PeerCache.Fetch(dst, checksumDigest)
+			if err != nil {
 				return err
 			}
+
+			// Never trust a peer, fall back to the getter if the
+			// artifact isn't what we expected.
+			if fromPeer && checksum(dst, checksumHash, checksumValue) != nil {
+				fromPeer = false
+			}
+			if fromPeer && c.Metrics != nil {
+				atomic.AddInt64(&c.Metrics.counters(force).cacheHits, 1)
+			}
+			if fromPeer {
+				c.trace("peer", c.Src, "fetched %s from a peer", checksumDigest)
+			}
 		}
 
-		if decompressor != nil {
-			// We have a decompressor, so decompress the current destination
-			// into the final destination with the proper mode.
-			err := decompressor.Decompress(decompressDst, dst, decompressDir)
+		// Decide whether to verify and decompress the file as it is
+		// downloaded rather than once it is on disk.
+		var streamed bool
+		var stream io.ReadCloser
+		if !fromPeer && signature == nil && (checksumHash != nil || decompressor != nil) {
+			stream, streamed, err = c.openStream(g, &uClone, decompressor)
 			if err != nil {
 				return err
 			}
+		}
+
+		if streamed {
+			streamDst, streamDir := dst, false
+			if decompressor != nil {
+				streamDst, streamDir = decompressDst, decompressDir
+			}
+
+			err := streamFile(streamDst, streamDir, stream, decompressor, checksumHash, checksumValue)
+			stream.Close()
+			if err != nil {
+				return err
+			}
+		} else if !fromPeer {
+			if stream != nil {
+				err = writeFile(dst, stream)
+				stream.Close()
+			} else {
+				err = g.GetFile(dst, &uClone)
+			}
+			if err != nil {
+				return err
+			}
+
+			if checksumHash != nil {
+				if err := checksum(dst, checksumHash, checksumValue); err != nil {
+					return err
+				}
+
+				if c.PeerCache != nil {
+					if err := c.PeerCache.Advertise(dst, checksumDigest); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		if signature != nil {
+			if err := verifySignature(dst, signature, c.GPGKeyring); err != nil {
+				return err
+			}
+
+			if signedDst != "" {
+				if err := os.RemoveAll(signedDst); err != nil {
+					return err
+				}
+				if err := os.Rename(dst, signedDst); err != nil {
+					return err
+				}
+				dst = signedDst
+			}
+		}
+
+		if decompressor != nil {
+			// We have a decompressor, so decompress the current destination
+			// into the final destination with the proper mode, unless
+			// that was done as it was downloaded.
+			if !streamed {
+				err := decompressor.Decompress(decompressDst, dst, decompressDir)
+				if err != nil {
+					return err
+				}
+			}
 
 			// Swap the information back
 			dst = decompressDst
@@ -286,6 +695,11 @@ func (c *Client) Get() error {
 		// if we were unarchiving. If we're still only Get-ing a file, then
 		// we're done.
 		if mode == ClientModeFile {
+			if checksumFiles != nil {
+				return fmt.Errorf(
+					"checksums can only be specified for directory downloads")
+			}
+
 			return nil
 		}
 	}
@@ -295,24 +709,31 @@ func (c *Client) Get() error {
 	// In the case we have a decompressor we don't Get because it was Get
 	// above.
 	if decompressor == nil {
-		// If we're getting a directory, then this is an error. You cannot
-		// checksum a directory. TODO: test
-		if checksumHash != nil {
-			return fmt.Errorf(
-				"checksum cannot be specified for directory download")
-		}
-
 		// We're downloading a directory, which might require a bit more work
 		// if we're specifying a subdir.
 		err := g.Get(dst, u)
 		if err != nil {
-			err = fmt.Errorf("error downloading '%s': %s", src, err)
+			// Partial and batch errors are returned as is so the
+			// caller can inspect them.
+			switch err.(type) {
+			case *PartialError, *BatchError, *OfflineError:
+				return err
+			}
+
+			err = fmt.Errorf("error downloading '%s': %w", src, err)
 			return err
 		}
 	}
 
 	// If we have a subdir, copy that over
 	if subDir != "" {
+		// Process any globs, before anything is removed in case the
+		// subdir isn't there
+		subDir, err := SubdirGlob(dst, subDir)
+		if err != nil {
+			return err
+		}
+
 		if err := os.RemoveAll(realDst); err != nil {
 			return err
 		}
@@ -320,21 +741,231 @@ func (c *Client) Get() error {
 			return err
 		}
 
-		// Process any globs
-		subDir, err := SubdirGlob(dst, subDir)
-		if err != nil {
+		if err := copySubdir(realDst, subDir, dst, false, c.SpecialFiles, c.Symlinks); err != nil {
+			return err
+		}
+
+		dst = realDst
+	}
+
+	// If we downloaded a directory (rather than an archive, which was
+	// checked above) then the checksum is of the resulting tree.
+	if checksumHash != nil && decompressor == nil {
+		if err := checksumDir(dst, checksumHash, checksumValue); err != nil {
+			return err
+		}
+	}
+
+	if checksumFiles != nil {
+		if err := checksumDirFiles(dst, checksumFiles); err != nil {
 			return err
 		}
+	}
 
-		return copyDir(realDst, subDir, false)
+	if c.VerifySample > 0 {
+		if err := c.verifySample(dst); err != nil {
+			return err
+		}
+	}
+
+	if c.References {
+		if err := c.getReferences(dst, u); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// withTimeout returns a copy of the client whose Ctx and Deadline end once
+// its Timeout has passed, and the function that releases the context.
+func (c *Client) withTimeout() (*Client, context.CancelFunc) {
+	ctx := c.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+
+	client := *c
+	client.Ctx = ctx
+	client.Timeout = 0
+	if deadline, _ := ctx.Deadline(); client.Deadline.IsZero() || deadline.Before(client.Deadline) {
+		client.Deadline = deadline
+	}
+	return &client, cancel
+}
+
+// subClient returns a client that downloads src into dst on behalf of c,
+// with the same settings, for downloads that a source refers to, such as
+// checksum files, signatures and the sources of manifests. It uses the
+// same getters, detectors and decompressors, so that those a client has
+// removed can't be used through a source that refers to another. If c is
+// nil it has the defaults.
+func (c *Client) subClient(src, dst string, mode ClientMode) *Client {
+	var sub Client
+	if c != nil {
+		sub = *c
+	}
+	if sub.Getters == nil {
+		sub.Getters = defaultGetters()
+	}
+	sub.Src = src
+	sub.Dst = dst
+	sub.Mode = mode
+	sub.Dir = false
+	sub.StartJitter = 0
+
+	return &sub
+}
+
+// deadlineExceeded returns true if the client has a deadline and it has
+// passed.
+func (c *Client) deadlineExceeded() bool {
+	return !c.Deadline.IsZero() && !time.Now().Before(c.Deadline)
+}
+
+// staggerStart waits for a random time of up to the client's StartJitter,
+// returning early with the context's error if it is done first. The wait
+// is cut short by the client's Deadline, which is then left to fail the
+// download as usual.
+func (c *Client) staggerStart() error {
+	if c.StartJitter <= 0 {
+		return nil
+	}
+
+	d := time.Duration(rand.Int63n(int64(c.StartJitter)))
+	if !c.Deadline.IsZero() {
+		if until := time.Until(c.Deadline); until < d {
+			d = until
+		}
+	}
+	if d <= 0 {
+		return nil
+	}
+
+	var done <-chan struct{}
+	if c.Ctx != nil {
+		done = c.Ctx.Done()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-done:
+		return c.Ctx.Err()
+	}
+}
+
+// getArchiveType returns the decompressor key for the archive at u,
+// removing the magic archive query parameter from u. It returns "" if u
+// doesn't appear to be an archive, and "-" if unarchiving is disabled.
+func getArchiveType(u *url.URL, decompressors map[string]Decompressor) (string, error) {
+	q := u.Query()
+	archiveV := q.Get("archive")
+	if archiveV != "" {
+		// Delete the paramter since it is a magic parameter we don't
+		// want to pass on to the Getter
+		q.Del("archive")
+		u.RawQuery = q.Encode()
+
+		// If we can parse the value as a bool and it is false, then
+		// set the archive to "-" which should never map to a decompressor.
+		// If it is true then we detect the type below as if it wasn't set.
+		if b, err := strconv.ParseBool(archiveV); err == nil {
+			archiveV = ""
+			if !b {
+				archiveV = "-"
+			}
+		} else {
+			// Be lenient about how the type is written, so "TGZ" and
+			// ".tar.gz" both work.
+			archiveV = strings.TrimPrefix(strings.ToLower(archiveV), ".")
+			if _, ok := decompressors[archiveV]; !ok {
+				return "", fmt.Errorf("unsupported archive type: %s", archiveV)
+			}
+		}
+	}
+	if archiveV == "" {
+		// We don't appear to... but is it part of the filename? URLs that
+		// serve archives from query parameters often don't have an
+		// extension in the path, but might set a filename.
+		archiveV = archiveType(u.Path, decompressors)
+		if archiveV == "" {
+			archiveV = archiveType(q.Get("filename"), decompressors)
+		}
+	}
+
+	return archiveV, nil
+}
+
+// archiveType returns the longest decompressor key that is an extension
+// of the given file name, or "" if none are.
+func archiveType(name string, decompressors map[string]Decompressor) string {
+	var result string
+	for k := range decompressors {
+		if strings.HasSuffix(name, "."+k) && len(k) > len(result) {
+			result = k
+		}
+	}
+
+	return result
+}
+
+// parseChecksum parses a checksum in the form "type:value", as given with
+// the checksum query parameter, into a new hash of its type and the value
+// it should have.
+func parseChecksum(v string) (hash.Hash, []byte, error) {
+	// Determine the checksum hash type
+	checksumType := ""
+	idx := strings.Index(v, ":")
+	if idx > -1 {
+		checksumType = v[:idx]
+	}
+	h, err := checksumHashForType(checksumType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Get the remainder of the value and parse it into bytes
+	b, err := hex.DecodeString(v[idx+1:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid checksum: %s", err)
+	}
+
+	return h, b, nil
+}
+
+// checksumHashForType returns a new hash for the given checksum type.
+func checksumHashForType(checksumType string) (hash.Hash, error) {
+	switch checksumType {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	case "sha512-256":
+		return sha512.New512_256(), nil
+	case "sha3-256":
+		return sha3.New256(), nil
+	case "blake2b-256":
+		return blake2b.New256(nil)
+	case "blake2s":
+		return blake2s.New256(nil)
+	default:
+		return nil, fmt.Errorf(
+			"unsupported checksum type: %s", checksumType)
+	}
+}
+
 // checksum is a simple method to compute the checksum of a source file
 // and compare it to the given expected value.
 func checksum(source string, h hash.Hash, v []byte) error {
+	h.Reset()
+
 	f, err := os.Open(source)
 	if err != nil {
 		return fmt.Errorf("Failed to open file for checksum: %s", err)
@@ -346,10 +977,7 @@ func checksum(source string, h hash.Hash, v []byte) error {
 	}
 
 	if actual := h.Sum(nil); !bytes.Equal(actual, v) {
-		return fmt.Errorf(
-			"Checksums did not match.\nExpected: %s\nGot: %s",
-			hex.EncodeToString(v),
-			hex.EncodeToString(actual))
+		return &ChecksumError{Path: source, Expected: v, Actual: actual}
 	}
 
 	return nil
@@ -0,0 +1,82 @@
+package getter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// getStaged is get, staged if the client is Atomic.
+func (c *Client) getStaged() error {
+	if !c.Atomic {
+		return c.get()
+	}
+
+	return c.staged(func(dst string) error {
+		client := *c
+		client.Dst = dst
+		return client.get()
+	})
+}
+
+// staged calls fn with the path to download Dst into. That is Dst itself,
+// unless the client is Atomic, in which case it is a staging path next to
+// Dst that replaces Dst once fn has succeeded.
+func (c *Client) staged(fn func(dst string) error) error {
+	if !c.Atomic {
+		return fn(c.Dst)
+	}
+
+	// The staging directory is beside Dst so that it is on the same file
+	// system, which it can only be moved within.
+	parent := filepath.Dir(c.Dst)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return err
+	}
+	td, err := ioutil.TempDir(parent, "."+filepath.Base(c.Dst)+".getter")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(td)
+
+	staged := filepath.Join(td, "new")
+	if err := fn(staged); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(staged); err != nil {
+		return fmt.Errorf("nothing was downloaded to replace %s: %s", c.Dst, err)
+	}
+
+	return replacePath(c.Dst, staged, filepath.Join(td, "old"))
+}
+
+// replacePath moves src to dst, replacing whatever is there. A file that
+// replaces a file does so in a single rename. Otherwise what is there is
+// first moved to aside, and moved back if src can't take its place.
+func replacePath(dst, src, aside string) error {
+	old, err := os.Lstat(dst)
+	if os.IsNotExist(err) {
+		return os.Rename(src, dst)
+	}
+	if err != nil {
+		return err
+	}
+	fi, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if !old.IsDir() && !fi.IsDir() {
+		return os.Rename(src, dst)
+	}
+
+	if err := os.Rename(dst, aside); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		os.Rename(aside, dst)
+		return err
+	}
+
+	return nil
+}
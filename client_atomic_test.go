@@ -0,0 +1,131 @@
+package getter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// assertNoStaging fails if anything but dst, and its lock file, is left in
+// the directory it is in.
+func assertNoStaging(t *testing.T, dst string) {
+	t.Helper()
+	fis, err := ioutil.ReadDir(filepath.Dir(dst))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, fi := range fis {
+		if name := fi.Name(); name != filepath.Base(dst) && name != filepath.Base(dst)+".lock" {
+			t.Fatalf("left behind: %s", name)
+		}
+	}
+}
+
+func TestGet_atomic(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	dst := filepath.Join(td, "dst")
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dst, "old.txt"), []byte("old"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A failed download leaves the destination as it was
+	client := &Client{
+		Src:    testModule("basic") + "?checksum=md5:00000000000000000000000000000000",
+		Dst:    dst,
+		Mode:   ClientModeDir,
+		Atomic: true,
+	}
+	if err := client.Get(); err == nil {
+		t.Fatal("should error")
+	}
+	assertContents(t, filepath.Join(dst, "old.txt"), "old")
+	assertNoStaging(t, dst)
+
+	// A successful one replaces it
+	client.Src = testModule("basic")
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "main.tf")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "old.txt")); !os.IsNotExist(err) {
+		t.Fatalf("should be replaced: %v", err)
+	}
+	assertNoStaging(t, dst)
+}
+
+func TestGet_atomicFile(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	dst := filepath.Join(td, "dst")
+	if err := os.MkdirAll(td, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(dst, []byte("old"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	client := &Client{
+		Src:    testModule("basic-file/foo.txt") + "?checksum=md5:00000000000000000000000000000000",
+		Dst:    dst,
+		Mode:   ClientModeFile,
+		Atomic: true,
+		Lock:   true,
+	}
+	if err := client.Get(); err == nil {
+		t.Fatal("should error")
+	}
+	assertContents(t, dst, "old")
+	assertNoStaging(t, dst)
+
+	client.Src = testModule("basic-file/foo.txt")
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello\n")
+	assertNoStaging(t, dst)
+}
+
+func TestGet_subdirMissing(t *testing.T) {
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+	if err := Get(dst, testModule("basic")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A subdir that isn't there doesn't remove what was downloaded before
+	if err := Get(dst, testModule("basic//missing")); err == nil {
+		t.Fatal("should error")
+	}
+	if _, err := os.Stat(filepath.Join(dst, "main.tf")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestReplacePath(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	if err := os.MkdirAll(filepath.Join(td, "new"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(td, "new", "a"), []byte("new"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	dst := filepath.Join(td, "dst")
+	if err := ioutil.WriteFile(dst, []byte("a file"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A directory can replace a file
+	if err := replacePath(dst, filepath.Join(td, "new"), filepath.Join(td, "old")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "a"), "new")
+	assertContents(t, filepath.Join(td, "old"), "a file")
+}
@@ -0,0 +1,133 @@
+package getter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// InflightGroup coalesces concurrent downloads of the same source. When a
+// Client with an InflightGroup is asked for a source that another Client
+// in the group is already downloading, it waits for that download to
+// finish and then copies its result, or returns its error, rather than
+// downloading the source again.
+//
+// Sources are the same if they have the same canonical form, as returned
+// by CanonicalizeSource, and are downloaded in the same mode. Local files
+// aren't coalesced since they aren't downloaded in the first place. The
+// destination of the first download must not be changed until the others
+// have finished copying it, which is when every Client.Get using it has
+// returned.
+type InflightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*inflightCall
+}
+
+// inflightCall is a download in progress in an InflightGroup.
+type inflightCall struct {
+	dst  string
+	done chan struct{}
+	err  error
+}
+
+// get runs c.get, unless a download of the same source is already in
+// progress in which case that is waited for and copied.
+func (g *InflightGroup) get(c *Client) error {
+	key, ok := c.inflightKey()
+	if !ok {
+		return c.getStaged()
+	}
+
+	g.mu.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-call.done
+		if call.err != nil {
+			return call.err
+		}
+
+		return c.staged(func(dst string) error {
+			return copyDownload(dst, call.dst, c.SpecialFiles, c.Symlinks)
+		})
+	}
+
+	call := &inflightCall{dst: c.Dst, done: make(chan struct{})}
+	if g.calls == nil {
+		g.calls = make(map[string]*inflightCall)
+	}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	call.err = c.getStaged()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(call.done)
+
+	return call.err
+}
+
+// inflightKey returns the key identifying the client's download in an
+// InflightGroup. It returns false if the download shouldn't be coalesced.
+func (c *Client) inflightKey() (string, bool) {
+	mode := c.Mode
+	if mode == ClientModeInvalid {
+		if c.Dir {
+			mode = ClientModeDir
+		} else {
+			mode = ClientModeFile
+		}
+	}
+
+	detectors := c.Detectors
+	if detectors == nil {
+		detectors = Detectors
+	}
+	src, err := canonicalizeSource(c.Src, c.Pwd, detectors)
+	if err != nil {
+		// Get will return the error
+		return "", false
+	}
+
+	force, rest := getForcedGetter(src)
+	if force == "file" || strings.HasPrefix(rest, "file:") {
+		return "", false
+	}
+
+	return fmt.Sprintf("%d:%s", mode, src), true
+}
+
+// copyDownload copies what was downloaded to src, which is either a file
+// or a directory, to dst.
+func copyDownload(dst, src string, special SpecialFilePolicy, symlinks SymlinkPolicy) error {
+	if filepath.Clean(dst) == filepath.Clean(src) {
+		return nil
+	}
+
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+
+		return copyDir(dst, src, false, special, symlinks)
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := writeFile(dst, f); err != nil {
+		return err
+	}
+
+	return os.Chmod(dst, fi.Mode())
+}
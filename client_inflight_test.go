@@ -0,0 +1,105 @@
+package getter
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInflightGroup(t *testing.T) {
+	var requests int32
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		started <- struct{}{}
+		<-release
+		w.Write([]byte("Hello\n"))
+	}))
+	defer server.Close()
+
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	group := new(InflightGroup)
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	get := func(i int) {
+		defer wg.Done()
+		client := &Client{
+			Src:      server.URL + "/file",
+			Dst:      filepath.Join(td, string(rune('a'+i))),
+			Mode:     ClientModeFile,
+			Inflight: group,
+		}
+		errs[i] = client.Get()
+	}
+
+	// Start one download, then the others once it is in progress
+	wg.Add(3)
+	go get(0)
+	<-started
+	go get(1)
+	go get(2)
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("%d: err: %s", i, err)
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(td, string(rune('a'+i))))
+		if err != nil {
+			t.Fatalf("%d: err: %s", i, err)
+		}
+		if string(data) != "Hello\n" {
+			t.Fatalf("%d: bad: %q", i, data)
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("bad requests: %d", n)
+	}
+
+	// Once it has finished the source is downloaded again
+	client := &Client{
+		Src:      server.URL + "/file",
+		Dst:      filepath.Join(td, "d"),
+		Mode:     ClientModeFile,
+		Inflight: group,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Fatalf("bad requests: %d", n)
+	}
+}
+
+func TestInflightGroup_dir(t *testing.T) {
+	src := testModule("basic")
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	// Local files aren't coalesced
+	client := &Client{Src: src, Dst: td, Dir: true, Inflight: new(InflightGroup)}
+	if _, ok := client.inflightKey(); ok {
+		t.Fatal("local files shouldn't be coalesced")
+	}
+
+	// But copying a directory download copies its contents
+	dst := filepath.Join(td, "copy")
+	if err := copyDownload(dst, filepath.Join(fixtureDir, "basic"), SpecialFilesSkip, SymlinksPreserve); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "subdir", "sub.tf")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
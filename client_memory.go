@@ -0,0 +1,499 @@
+package getter
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	urlhelper "github.com/hashicorp/go-getter/helper/url"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// DefaultMemoryLimit is the most bytes that Client.GetMemory holds in
+// memory if the client's MemoryLimit isn't set.
+const DefaultMemoryLimit = 64 * 1024 * 1024
+
+// GetMemory downloads src into memory rather than onto disk, for services
+// with read-only file systems that fetch small modules. src is in the same
+// format as the client's Src, which is ignored, and is downloaded as it is
+// in ClientModeAny: a file is put in the file system with the base name of
+// its URL, and archives and directories are unpacked into it.
+//
+// Files are read with getters that implement StreamGetter, and directories
+// with those that implement FSGetter, such as those for S3 and Git (which
+// still clones into a temporary directory). Archives are unpacked in
+// memory, with the links in tar archives replaced by copies of the files
+// they point to. The checksum parameter is verified, but the checksums and
+// gpgsig parameters aren't supported. The download fails if the source,
+// together with anything unpacked from it, is larger than the client's
+// MemoryLimit.
+func (c *Client) GetMemory(src string) (_ MemFS, err error) {
+	if c.Timeout > 0 {
+		c, cancel := c.withTimeout()
+		defer cancel()
+		return c.GetMemory(src)
+	}
+	c = c.withRetryBudget()
+
+	if c.Ctx != nil {
+		if err := c.Ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.staggerStart(); err != nil {
+		return nil, err
+	}
+
+	decompressors := c.Decompressors
+	if decompressors == nil {
+		decompressors = Decompressors
+	}
+
+	detectors := c.Detectors
+	if detectors == nil {
+		detectors = Detectors
+	}
+	detected, err := Detect(src, c.Pwd, detectors)
+	if err != nil {
+		return nil, err
+	}
+
+	force, detected := getForcedGetter(detected)
+	detected, subDir := SourceDirSubdir(detected)
+
+	u, err := urlhelper.Parse(detected)
+	if err != nil {
+		return nil, err
+	}
+	if force == "" {
+		force = u.Scheme
+	}
+
+	getters := c.Getters
+	if getters == nil {
+		getters = defaultGetters()
+	}
+
+	g, ok := getters[force]
+	if !ok {
+		return nil, fmt.Errorf(
+			"download not supported for scheme '%s'", force)
+	}
+	if c.Metrics != nil {
+		client := *c
+		client.metricsGetter = force
+		c = &client
+
+		end := c.Metrics.start(force)
+		defer func() { end(err) }()
+	}
+	g = getterForClient(g, c)
+	if err := c.checkAddresses(g, u); err != nil {
+		return nil, err
+	}
+
+	if c.deadlineExceeded() {
+		return nil, &AbortError{
+			Reason: AbortDeadline,
+			Err:    fmt.Errorf("deadline exceeded before downloading '%s'", redactURLCredentials(detected)),
+		}
+	}
+
+	archiveV, err := getArchiveType(u, decompressors)
+	if err != nil {
+		return nil, err
+	}
+	decompressor := withLimits(decompressors[archiveV], c.DecompressLimits)
+
+	q := u.Query()
+	if q.Get("checksums") != "" || q.Get("gpgsig") != "" {
+		return nil, fmt.Errorf("checksums and gpgsig aren't supported for downloads into memory")
+	}
+	var checksumValue string
+	if v := q.Get("checksum"); v != "" {
+		q.Del("checksum")
+		u.RawQuery = q.Encode()
+
+		if strings.HasPrefix(v, "file:") {
+			v, err = c.checksumFromFile(strings.TrimPrefix(v, "file:"), path.Base(u.Path))
+			if err != nil {
+				return nil, err
+			}
+		}
+		checksumValue = v
+	}
+
+	if c.Offline {
+		if lg, ok := g.(LocalGetter); !ok || !lg.Local(u) {
+			return nil, &OfflineError{Sources: []string{redactURLCredentials(detected)}}
+		}
+	}
+
+	mode := ClientModeFile
+	if decompressor == nil {
+		mode, err = g.ClientMode(u)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	limit := c.MemoryLimit
+	if limit == 0 {
+		limit = DefaultMemoryLimit
+	}
+	b := &memoryBudget{limit: limit, left: limit}
+
+	var result MemFS
+	if mode == ClientModeDir {
+		result, err = getMemoryDir(g, u, force, checksumValue, b)
+	} else {
+		result, err = getMemoryFile(g, u, force, checksumValue, decompressor, archiveV, b)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error downloading '%s': %w", redactURLCredentials(detected), err)
+	}
+
+	if subDir != "" {
+		return result.sub(subDir)
+	}
+
+	return result, nil
+}
+
+// getMemoryFile downloads the file at u into memory, unpacking it with d
+// if it isn't nil.
+func getMemoryFile(g Getter, u *url.URL, scheme, checksumValue string, d Decompressor, archiveV string, b *memoryBudget) (MemFS, error) {
+	sg, ok := g.(StreamGetter)
+	if !ok {
+		return nil, fmt.Errorf("files can't be downloaded into memory with scheme '%s'", scheme)
+	}
+
+	name := path.Base(u.Path)
+	q := u.Query()
+	if v := q.Get("filename"); v != "" {
+		q.Del("filename")
+		u.RawQuery = q.Encode()
+
+		name = v
+	}
+
+	r, _, err := sg.GetReader(u)
+	if err != nil {
+		return nil, err
+	}
+	data, err := b.read(r)
+	r.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if checksumValue != "" {
+		h, v, err := parseChecksum(checksumValue)
+		if err != nil {
+			return nil, err
+		}
+		h.Write(data)
+		if actual := h.Sum(nil); !bytes.Equal(actual, v) {
+			return nil, &ChecksumError{Path: name, Expected: v, Actual: actual}
+		}
+	}
+
+	if d == nil {
+		return MemFS{name: data}, nil
+	}
+
+	return decompressMemory(d, data, strings.TrimSuffix(name, "."+archiveV), b)
+}
+
+// getMemoryDir reads the directory at u into memory.
+func getMemoryDir(g Getter, u *url.URL, scheme, checksumValue string, b *memoryBudget) (MemFS, error) {
+	fg, ok := g.(FSGetter)
+	if !ok {
+		return nil, fmt.Errorf("directories can't be downloaded into memory with scheme '%s'", scheme)
+	}
+
+	fsys, err := fg.Open(u)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := fsys.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	result := make(MemFS)
+	err = fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" || d.Name() == ".hg" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		// Symlinks are followed, but only to files
+		info, err := fs.Stat(fsys, p)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := fsys.Open(p)
+		if err != nil {
+			return err
+		}
+		data, err := b.read(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		result[p] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if checksumValue != "" {
+		h, v, err := parseChecksum(checksumValue)
+		if err != nil {
+			return nil, err
+		}
+		if actual := result.dirHash(h); !bytes.Equal(actual, v) {
+			return nil, &ChecksumError{Path: u.Path, Expected: v, Actual: actual}
+		}
+	}
+
+	return result, nil
+}
+
+// dirHash returns the hash of the files as documented by ChecksumDir.
+func (m MemFS) dirHash(h hash.Hash) []byte {
+	paths := make([]string, 0, len(m))
+	for p := range m {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var listing bytes.Buffer
+	for _, p := range paths {
+		h.Reset()
+		h.Write(m[p])
+		fmt.Fprintf(&listing, "%s  %s\n", hex.EncodeToString(h.Sum(nil)), p)
+	}
+
+	h.Reset()
+	h.Write(listing.Bytes())
+	return h.Sum(nil)
+}
+
+// decompressMemory unpacks the archive data with the decompressor d into
+// memory. Archives of a single file are unpacked into a file called name.
+// The limits of d are enforced as they are on disk.
+func decompressMemory(d Decompressor, data []byte, name string, b *memoryBudget) (MemFS, error) {
+	var r io.Reader = bytes.NewReader(data)
+	limiter := &extractLimiter{limits: decompressorLimits(d)}
+	switch d.(type) {
+	case *TarDecompressor:
+	case *TarGzipDecompressor, *GzipDecompressor:
+		gzipR, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gzipR.Close()
+		r = gzipR
+	case *TarBzip2Decompressor, *Bzip2Decompressor:
+		r = bzip2.NewReader(r)
+	case *TarXzDecompressor, *XzDecompressor:
+		xzR, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		r = xzR
+	case *TarZstdDecompressor, *ZstdDecompressor:
+		zstdR, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer zstdR.Close()
+		r = zstdR
+	case *TarBrotliDecompressor, *BrotliDecompressor:
+		r = brotli.NewReader(r)
+	case *ZipDecompressor:
+		return unzipMemory(data, b, limiter)
+	default:
+		return nil, fmt.Errorf("archives of type %T can't be unpacked into memory", d)
+	}
+
+	switch d.(type) {
+	case *GzipDecompressor, *Bzip2Decompressor, *XzDecompressor, *ZstdDecompressor, *BrotliDecompressor:
+		contents, err := b.read(limiter.reader(r, ""))
+		if err != nil {
+			return nil, err
+		}
+		return MemFS{name: contents}, nil
+	}
+
+	return untarMemory(r, b, limiter)
+}
+
+// untarMemory unpacks the tar archive read from r into memory.
+func untarMemory(r io.Reader, b *memoryBudget, limiter *extractLimiter) (MemFS, error) {
+	result := make(MemFS)
+	links := make(map[string]string)
+	tarR := tar.NewReader(r)
+	for {
+		hdr, err := tarR.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if hdr.Typeflag == tar.TypeXGlobalHeader || hdr.Typeflag == tar.TypeXHeader {
+			continue
+		}
+		if err := limiter.entry(hdr.Name); err != nil {
+			return nil, err
+		}
+		if hdr.FileInfo().IsDir() {
+			continue
+		}
+
+		name, err := memEntryName(hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeLink:
+			links[name] = path.Clean(hdr.Linkname)
+		case tar.TypeSymlink:
+			links[name] = path.Join(path.Dir(name), hdr.Linkname)
+		default:
+			data, err := b.read(limiter.reader(tarR, hdr.Name))
+			if err != nil {
+				return nil, err
+			}
+			result[name] = data
+		}
+	}
+
+	// Links are copies of the files they point to, which must be in the
+	// archive.
+	for name, target := range links {
+		data, ok := result[target]
+		if !ok {
+			return nil, fmt.Errorf("entry links to a file that isn't in the archive: %s", name)
+		}
+		result[name] = data
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("empty archive")
+	}
+
+	return result, nil
+}
+
+// unzipMemory unpacks the zip archive data into memory.
+func unzipMemory(data []byte, b *memoryBudget, limiter *extractLimiter) (MemFS, error) {
+	zipR, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(MemFS)
+	for _, f := range zipR.File {
+		if err := limiter.entry(f.Name); err != nil {
+			return nil, err
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if f.Mode()&fs.ModeSymlink != 0 {
+			return nil, fmt.Errorf("symlinks in zip archives can't be unpacked into memory: %s", f.Name)
+		}
+
+		name, err := memEntryName(f.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		contents, err := b.read(limiter.reader(r, f.Name))
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		result[name] = contents
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("empty archive")
+	}
+
+	return result, nil
+}
+
+// memEntryName returns the path in a MemFS of the archive entry name,
+// rejecting entries that would be outside of it.
+func memEntryName(name string) (string, error) {
+	if containsDotDot(name) {
+		return "", fmt.Errorf("entry contains '..': %s", name)
+	}
+	if isAbsEntry(name) {
+		return "", fmt.Errorf("entry has an absolute path: %s", name)
+	}
+
+	p := path.Clean(strings.Replace(name, "\\", "/", -1))
+	if !fs.ValidPath(p) || p == "." {
+		return "", fmt.Errorf("entry has an invalid path: %s", name)
+	}
+
+	return p, nil
+}
+
+// memoryBudget is how much more a download into memory may hold.
+type memoryBudget struct {
+	limit int64
+	left  int64
+}
+
+// read reads all of r, failing if that is more than is left.
+func (b *memoryBudget) read(r io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, b.left+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > b.left {
+		return nil, &AbortError{
+			Reason: AbortSizeLimit,
+			Err:    fmt.Errorf("download is larger than the memory limit of %d bytes", b.limit),
+		}
+	}
+	b.left -= int64(len(data))
+
+	return data, nil
+}
@@ -0,0 +1,113 @@
+package getter
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestClient_GetMemory(t *testing.T) {
+	cases := []struct {
+		Src   string
+		Files []string
+	}{
+		{testModule("basic"), []string{"foo/main.tf", "main.tf", "subdir/sub.tf"}},
+		{testModule("basic") + "//sub*", []string{"sub.tf"}},
+		{testModule("basic-file/foo.txt"), []string{"foo.txt"}},
+		{testModule("decompress-zip/subdir.zip"), []string{"file1", "subdir/child"}},
+		{testModule("archive-rooted/archive.tar.gz"), []string{"root/hello.txt"}},
+		{testModule("archive-rooted/archive.tar.gz") + "//root", []string{"hello.txt"}},
+		{testModule("decompress-tbz2/multiple.tar.bz2"), []string{"file1", "file2"}},
+		{testModule("decompress-gz/single.gz"), []string{"single"}},
+	}
+
+	for _, tc := range cases {
+		client := new(Client)
+		fsys, err := client.GetMemory(tc.Src)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Src, err)
+		}
+		assertFSFiles(t, fsys, tc.Files)
+		if err := fstest.TestFS(fsys, tc.Files...); err != nil {
+			t.Fatalf("%s: %s", tc.Src, err)
+		}
+	}
+}
+
+func TestClient_GetMemory_checksum(t *testing.T) {
+	u := testModule("basic-file-archive/archive.tar.gz")
+
+	client := new(Client)
+	fsys, err := client.GetMemory(u + "?checksum=md5:fbd90037dacc4b1ab40811d610dde2f0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(fsys["file"]) != "Hello\n" {
+		t.Fatalf("bad: %q", fsys["file"])
+	}
+
+	_, err = client.GetMemory(u + "?checksum=md5:fbd90037dacc4b1ab40811d610dde2f1")
+	if err == nil || !strings.Contains(err.Error(), "Checksums did not match") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestClient_GetMemory_checksumDir(t *testing.T) {
+	sum, err := ChecksumDir(filepath.Join(fixtureDir, "basic"), "sha256")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	client := new(Client)
+	if _, err := client.GetMemory(testModule("basic") + "?checksum=" + sum); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	_, err = client.GetMemory(testModule("basic") + "?checksum=" + testChecksum("nope"))
+	if err == nil || !strings.Contains(err.Error(), "Checksums did not match") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestClient_GetMemory_errors(t *testing.T) {
+	cases := []struct {
+		Client *Client
+		Src    string
+		Err    string
+	}{
+		{&Client{MemoryLimit: 10}, testModule("basic"), "memory limit of 10 bytes"},
+		{new(Client), testModule("basic") + "?checksums=file:./SHA256SUMS", "aren't supported"},
+		{new(Client), testModule("basic") + "//nope", "not found"},
+		{new(Client), "hg::http://example.com/foo", "directories can't be downloaded into memory"},
+		{&Client{Offline: true}, "http://example.com/foo", "offline"},
+	}
+
+	for _, tc := range cases {
+		_, err := tc.Client.GetMemory(tc.Src)
+		if err == nil || !strings.Contains(err.Error(), tc.Err) {
+			t.Fatalf("%s: bad: %v", tc.Src, err)
+		}
+	}
+}
+
+func TestMemFS(t *testing.T) {
+	fsys := MemFS{
+		"a":     []byte("a"),
+		"b/c":   []byte("c"),
+		"b/d/e": []byte("e"),
+	}
+	if err := fstest.TestFS(fsys, "a", "b/c", "b/d/e"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reading a file can't change it
+	data, err := fsys.ReadFile("a")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	data[0] = 'x'
+	if string(fsys["a"]) != "a" {
+		t.Fatalf("bad: %q", fsys["a"])
+	}
+}
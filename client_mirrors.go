@@ -0,0 +1,124 @@
+package getter
+
+import (
+	"net/url"
+	"os"
+	"strings"
+)
+
+// GetAny downloads the first of sources that succeeds into dst, trying them
+// in order with the client's settings, for sources that are mirrors of each
+// other. The client's Src and Dst are ignored. If every source fails the
+// error is a *BatchError giving the result of each of them.
+//
+// If dst didn't exist before, whatever a failed source left behind is
+// removed before the next source is tried. The client's StartJitter is
+// waited for once, before the first source.
+func (c *Client) GetAny(dst string, sources []string) error {
+	if c.Timeout > 0 {
+		c, cancel := c.withTimeout()
+		defer cancel()
+		return c.GetAny(dst, sources)
+	}
+	c = c.withRetryBudget()
+
+	if err := c.staggerStart(); err != nil {
+		return err
+	}
+
+	_, err := os.Lstat(dst)
+	created := os.IsNotExist(err)
+
+	var results []SourceResult
+	for i, source := range sources {
+		c.trace("mirror", source, "trying source %d of %d", i+1, len(sources))
+		client := *c
+		client.Src = source
+		client.Dst = dst
+		client.StartJitter = 0
+		r := tryResult(source, client.Get)
+		if r.Err == nil {
+			return nil
+		}
+		if c.Ctx != nil && c.Ctx.Err() != nil {
+			return r.Err
+		}
+		results = append(results, r)
+
+		if created {
+			if err := os.RemoveAll(dst); err != nil {
+				return err
+			}
+		}
+	}
+
+	return &BatchError{Sources: results}
+}
+
+// mirrorParams are the query parameters of a source with mirrors that also
+// apply to the mirrors, unless they have their own.
+var mirrorParams = []string{"archive", "checksum", "checksums", "filename", "gpgsig"}
+
+// splitMirrors returns src without its mirrors query parameter, followed by
+// the mirrors it lists with mirrorParams copied from src. It returns nil if
+// src doesn't have any mirrors.
+func splitMirrors(src string) ([]string, error) {
+	// The source may not parse as a URL until it is detected, so only
+	// the query is parsed.
+	idx := strings.Index(src, "?")
+	if idx < 0 {
+		return nil, nil
+	}
+	base, rawQuery := src[:idx], src[idx+1:]
+
+	q, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, err
+	}
+	mirrors := splitSources(q["mirrors"])
+	if len(mirrors) == 0 {
+		return nil, nil
+	}
+	q.Del("mirrors")
+
+	src = base
+	if len(q) > 0 {
+		src += "?" + q.Encode()
+	}
+	result := []string{src}
+	for _, mirror := range mirrors {
+		mirror, err := withParams(mirror, q)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, mirror)
+	}
+
+	return result, nil
+}
+
+// withParams returns source with the mirrorParams in params that it
+// doesn't have already.
+func withParams(source string, params url.Values) (string, error) {
+	base, rawQuery := source, ""
+	if idx := strings.Index(source, "?"); idx >= 0 {
+		base, rawQuery = source[:idx], source[idx+1:]
+	}
+
+	q, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", err
+	}
+	var changed bool
+	for _, k := range mirrorParams {
+		if _, ok := q[k]; !ok && params.Get(k) != "" {
+			q.Set(k, params.Get(k))
+			changed = true
+		}
+	}
+	if !changed {
+		return source, nil
+	}
+
+	return base + "?" + q.Encode(), nil
+}
@@ -0,0 +1,125 @@
+package getter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestClient_GetAny(t *testing.T) {
+	server := testMirrorServer()
+	defer server.Close()
+
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	client := &Client{Mode: ClientModeFile}
+	err := client.GetAny(dst, []string{server.URL + "/bad", server.URL + "/good"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello\n")
+}
+
+func TestClient_GetAny_fails(t *testing.T) {
+	server := testMirrorServer()
+	defer server.Close()
+
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	client := &Client{Mode: ClientModeFile}
+	err := client.GetAny(dst, []string{server.URL + "/bad", server.URL + "/missing"})
+	batchErr, ok := err.(*BatchError)
+	if !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+	if len(batchErr.Sources) != 2 {
+		t.Fatalf("bad: %#v", batchErr.Sources)
+	}
+	for _, r := range batchErr.Sources {
+		if r.Status != SourceFailed || r.Err == nil {
+			t.Fatalf("bad: %#v", r)
+		}
+	}
+	if _, err := os.Lstat(dst); !os.IsNotExist(err) {
+		t.Fatalf("failed download should be removed: %v", err)
+	}
+}
+
+func TestClient_Get_mirrors(t *testing.T) {
+	server := testMirrorServer()
+	defer server.Close()
+
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	// The checksum applies to the mirror too, which makes the corrupt one
+	// fail.
+	mirrors := server.URL + "/corrupt," + server.URL + "/good"
+	client := &Client{
+		Src:  server.URL + "/bad?checksum=" + testChecksum("Hello\n") + "&mirrors=" + url.QueryEscape(mirrors),
+		Dst:  dst,
+		Mode: ClientModeFile,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello\n")
+}
+
+func TestSplitMirrors(t *testing.T) {
+	cases := []struct {
+		Input  string
+		Output []string
+	}{
+		{"https://example.com/foo", nil},
+		{"https://example.com/foo?archive=zip", nil},
+		{
+			"https://a.com/foo?mirrors=https://b.com/foo,https://c.com/foo",
+			[]string{"https://a.com/foo", "https://b.com/foo", "https://c.com/foo"},
+		},
+		{
+			"./foo?mirrors=s3::https://s3.amazonaws.com/b/foo&mirrors=https://c.com/foo",
+			[]string{"./foo", "s3::https://s3.amazonaws.com/b/foo", "https://c.com/foo"},
+		},
+		{
+			"https://a.com/foo?checksum=md5:00&archive=zip&token=x&mirrors=https://b.com/foo?archive=tgz",
+			[]string{
+				"https://a.com/foo?archive=zip&checksum=md5%3A00&token=x",
+				"https://b.com/foo?archive=tgz&checksum=md5%3A00",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		actual, err := splitMirrors(tc.Input)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Input, err)
+		}
+		if !reflect.DeepEqual(actual, tc.Output) {
+			t.Fatalf("%s: bad: %#v", tc.Input, actual)
+		}
+	}
+}
+
+// testMirrorServer serves "Hello\n" at /good and something else at
+// /corrupt, and fails at /bad.
+func testMirrorServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/good":
+			w.Write([]byte("Hello\n"))
+		case "/corrupt":
+			w.Write([]byte("Goodbye\n"))
+		case "/bad":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
@@ -0,0 +1,208 @@
+package getter
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	urlhelper "github.com/hashicorp/go-getter/helper/url"
+	"github.com/hashicorp/go-safetemp"
+)
+
+// Open returns a read-only file system of the contents of src, for tools
+// that only need to inspect some of the files rather than download all of
+// them. src is in the same format as the client's Src, which is ignored.
+//
+// Where possible the file system is lazy: the entries of zip archives are
+// only decompressed as they are read, and getters that implement FSGetter,
+// such as those for S3 and Git, only download the files that are read.
+// Anything else, including sources with a checksum since they can't be
+// verified without all of their contents, is downloaded into a temporary
+// directory first.
+//
+// The returned file system implements io.Closer, and must be closed to
+// remove any temporary files.
+func (c *Client) Open(src string) (fs.FS, error) {
+	decompressors := c.Decompressors
+	if decompressors == nil {
+		decompressors = Decompressors
+	}
+
+	detectors := c.Detectors
+	if detectors == nil {
+		detectors = Detectors
+	}
+	detected, err := Detect(src, c.Pwd, detectors)
+	if err != nil {
+		return nil, err
+	}
+
+	force, detected := getForcedGetter(detected)
+	detected, subDir := SourceDirSubdir(detected)
+
+	u, err := urlhelper.Parse(detected)
+	if err != nil {
+		return nil, err
+	}
+	if force == "" {
+		force = u.Scheme
+	}
+
+	getters := c.Getters
+	if getters == nil {
+		getters = defaultGetters()
+	}
+
+	g, ok := getters[force]
+	if !ok {
+		return nil, fmt.Errorf(
+			"download not supported for scheme '%s'", force)
+	}
+	g = getterForClient(g, c)
+	if err := c.checkAddresses(g, u); err != nil {
+		return nil, err
+	}
+
+	// Sources that have to be verified are downloaded in full
+	q := u.Query()
+	if q.Get("checksum") != "" || q.Get("checksums") != "" {
+		return c.openDownload(src)
+	}
+
+	archiveV, err := getArchiveType(u, decompressors)
+	if err != nil {
+		return nil, err
+	}
+
+	var fsys fs.FS
+	var closer io.Closer
+	switch d := decompressors[archiveV]; {
+	case d != nil:
+		if _, ok := d.(*ZipDecompressor); !ok {
+			// Other archives have to be read from the start to find
+			// any one file, so we might as well unpack all of it.
+			return c.openDownload(src)
+		}
+
+		fsys, closer, err = openZip(g, u)
+	default:
+		fg, ok := g.(FSGetter)
+		if !ok {
+			return c.openDownload(src)
+		}
+
+		fsys, err = fg.Open(u)
+		closer, _ = fsys.(io.Closer)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &closeFS{FS: fsys, closer: closer}
+	if subDir != "" {
+		result.FS, err = subdirFS(fsys, subDir)
+		if err != nil {
+			result.Close()
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// openDownload downloads src into a temporary directory and returns a
+// file system of it.
+func (c *Client) openDownload(src string) (fs.FS, error) {
+	td, tdcloser, err := safetemp.Dir("", "getter")
+	if err != nil {
+		return nil, err
+	}
+
+	client := *c
+	client.Src = src
+	client.Dst = filepath.Join(td, "src")
+	client.Mode = ClientModeAny
+	if err := client.Get(); err != nil {
+		tdcloser.Close()
+		return nil, err
+	}
+
+	return &closeFS{FS: os.DirFS(client.Dst), closer: tdcloser}, nil
+}
+
+// openZip downloads the zip archive at u and returns a file system of its
+// entries.
+func openZip(g Getter, u *url.URL) (fs.FS, io.Closer, error) {
+	td, tdcloser, err := safetemp.Dir("", "getter")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	archive := filepath.Join(td, "archive.zip")
+	if err := g.GetFile(archive, u); err != nil {
+		tdcloser.Close()
+		return nil, nil, err
+	}
+
+	zipR, err := zip.OpenReader(archive)
+	if err != nil {
+		tdcloser.Close()
+		return nil, nil, err
+	}
+
+	return zipR, multiCloser{zipR, tdcloser}, nil
+}
+
+// subdirFS returns the file system of subDir in fsys, which may be a glob
+// pattern like SubdirGlob takes.
+func subdirFS(fsys fs.FS, subDir string) (fs.FS, error) {
+	matches, err := fs.Glob(fsys, path.Clean(subDir))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("subdir %q not found", subDir)
+	}
+
+	if len(matches) > 1 {
+		return nil, fmt.Errorf("subdir %q matches multiple paths", subDir)
+	}
+
+	return fs.Sub(fsys, matches[0])
+}
+
+// closeFS is the file system returned by Client.Open.
+type closeFS struct {
+	fs.FS
+	closer io.Closer
+}
+
+// Close removes any temporary files used by the file system.
+func (f *closeFS) Close() error {
+	if f.closer == nil {
+		return nil
+	}
+
+	return f.closer.Close()
+}
+
+// multiCloser closes each of its closers in order, returning the first
+// error.
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	var result error
+	for _, c := range m {
+		if err := c.Close(); err != nil && result == nil {
+			result = err
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,101 @@
+package getter
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestFileGetter_fsImpl(t *testing.T) {
+	var _ FSGetter = new(FileGetter)
+	var _ FSGetter = new(GitGetter)
+	var _ FSGetter = new(S3Getter)
+}
+
+func TestClientOpen(t *testing.T) {
+	fsys := testClientOpen(t, testModule("basic"))
+	defer fsys.(io.Closer).Close()
+
+	assertFSFiles(t, fsys, []string{"foo/main.tf", "main.tf", "subdir/sub.tf"})
+}
+
+func TestClientOpen_subdir(t *testing.T) {
+	fsys := testClientOpen(t, testModule("basic")+"//sub*")
+	defer fsys.(io.Closer).Close()
+
+	assertFSFiles(t, fsys, []string{"sub.tf"})
+}
+
+func TestClientOpen_zip(t *testing.T) {
+	fsys := testClientOpen(t, testModule("decompress-zip/subdir.zip"))
+	defer fsys.(io.Closer).Close()
+
+	// Zip archives are read in place
+	if _, ok := fsys.(*closeFS).FS.(*zip.ReadCloser); !ok {
+		t.Fatalf("should be the zip reader: %T", fsys.(*closeFS).FS)
+	}
+	assertFSFiles(t, fsys, []string{"file1", "subdir/child"})
+}
+
+func TestClientOpen_tgz(t *testing.T) {
+	fsys := testClientOpen(t, testModule("archive-rooted/archive.tar.gz"))
+	defer fsys.(io.Closer).Close()
+
+	assertFSFiles(t, fsys, []string{"root/hello.txt"})
+}
+
+func TestClientOpen_checksum(t *testing.T) {
+	u := testModule("basic-file-archive/archive.tar.gz")
+
+	fsys := testClientOpen(t, u+"?checksum=md5:fbd90037dacc4b1ab40811d610dde2f0")
+	defer fsys.(io.Closer).Close()
+	data, err := fs.ReadFile(fsys, "file")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != "Hello\n" {
+		t.Fatalf("bad: %q", data)
+	}
+
+	client := &Client{}
+	if _, err := client.Open(u + "?checksum=md5:fbd90037dacc4b1ab40811d610dde2f1"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func testClientOpen(t *testing.T, src string) fs.FS {
+	client := &Client{}
+	fsys, err := client.Open(src)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return fsys
+}
+
+// assertFSFiles checks that the regular files in fsys are exactly those
+// given.
+func assertFSFiles(t *testing.T, fsys fs.FS, expected []string) {
+	var actual []string
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			actual = append(actual, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	sort.Strings(actual)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad files\n\nexpected: %#v\n\nactual: %#v", expected, actual)
+	}
+}
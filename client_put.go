@@ -0,0 +1,109 @@
+package getter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	urlhelper "github.com/hashicorp/go-getter/helper/url"
+)
+
+// Put uploads the file at src to dst, for publishing artifacts that are
+// later downloaded from the same URL. dst is in the same format as the
+// client's Src, which is ignored, and must be handled by a getter that
+// implements Putter, such as those for HTTP, S3, GCS and SFTP.
+//
+// A relative src is relative to the client's Pwd, if it is set.
+//
+// The archive query parameter is removed from dst, so that a URL that
+// downloads an archive uploads it as is, and the checksum query parameter
+// is checked against src before anything is uploaded.
+func (c *Client) Put(dst, src string) error {
+	if c.Timeout > 0 {
+		c, cancel := c.withTimeout()
+		defer cancel()
+		return c.Put(dst, src)
+	}
+
+	if c.Ctx != nil {
+		if err := c.Ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	if !filepath.IsAbs(src) && c.Pwd != "" {
+		src = filepath.Join(c.Pwd, src)
+	}
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("only files can be uploaded: %s", src)
+	}
+
+	detectors := c.Detectors
+	if detectors == nil {
+		detectors = Detectors
+	}
+	detected, err := Detect(dst, c.Pwd, detectors)
+	if err != nil {
+		return err
+	}
+
+	force, detected := getForcedGetter(detected)
+	detected, subDir := SourceDirSubdir(detected)
+	if subDir != "" {
+		return fmt.Errorf("can't upload to a subdirectory: %s", redactURLCredentials(dst))
+	}
+
+	u, err := urlhelper.Parse(detected)
+	if err != nil {
+		return err
+	}
+	if force == "" {
+		force = u.Scheme
+	}
+
+	getters := c.Getters
+	if getters == nil {
+		getters = defaultGetters()
+	}
+
+	g := getterForClient(getters[force], c)
+	p, ok := g.(Putter)
+	if !ok {
+		return fmt.Errorf(
+			"upload not supported for scheme '%s'", force)
+	}
+	if err := c.checkAddresses(g, u); err != nil {
+		return err
+	}
+
+	if c.Offline {
+		return &OfflineError{Sources: []string{redactURLCredentials(detected)}}
+	}
+
+	q := u.Query()
+	if v := q.Get("checksum"); v != "" {
+		h, value, err := parseChecksum(v)
+		if err != nil {
+			return err
+		}
+		if err := checksum(src, h, value); err != nil {
+			return err
+		}
+	}
+	q.Del("archive")
+	q.Del("checksum")
+	u.RawQuery = q.Encode()
+
+	if c.deadlineExceeded() {
+		return &AbortError{
+			Reason: AbortDeadline,
+			Err:    fmt.Errorf("deadline exceeded before uploading to '%s'", redactURLCredentials(u.String())),
+		}
+	}
+
+	return p.PutFile(u, src)
+}
@@ -0,0 +1,139 @@
+package getter
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClient_Put(t *testing.T) {
+	var mu sync.Mutex
+	var attempts int
+	uploads := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Method != "PUT" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// The first attempt fails, and the upload is retried
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		uploads[r.URL.String()] = string(data)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	src := testPutFile(t, "Hello\n")
+	defer os.RemoveAll(filepath.Dir(src))
+
+	client := &Client{
+		RetryPolicy: &RetryPolicy{Backoff: func(int) time.Duration { return 0 }},
+	}
+	dst := server.URL + "/foo.tar.gz?archive=false&checksum=" + testChecksum("Hello\n")
+	if err := client.Put(dst, src); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 2 {
+		t.Fatalf("bad: %d", attempts)
+	}
+	if actual := uploads["/foo.tar.gz"]; actual != "Hello\n" {
+		t.Fatalf("bad: %v", uploads)
+	}
+}
+
+func TestClient_Put_badChecksum(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("nothing should be uploaded")
+	}))
+	defer server.Close()
+
+	src := testPutFile(t, "Hello\n")
+	defer os.RemoveAll(filepath.Dir(src))
+
+	client := new(Client)
+	err := client.Put(server.URL+"/foo?checksum="+testChecksum("Goodbye\n"), src)
+	if err == nil || !strings.Contains(err.Error(), "Checksums did not match") {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// A relative src is relative to the client's Pwd
+	client = &Client{Pwd: filepath.Dir(src)}
+	err = client.Put(server.URL+"/foo?checksum="+testChecksum("Goodbye\n"), filepath.Base(src))
+	if err == nil || !strings.Contains(err.Error(), "Checksums did not match") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestClient_Put_errors(t *testing.T) {
+	src := testPutFile(t, "Hello\n")
+	defer os.RemoveAll(filepath.Dir(src))
+
+	cases := []struct {
+		Client *Client
+		Dst    string
+		Src    string
+		Err    string
+	}{
+		{new(Client), testModule("basic/foo"), src, "upload not supported"},
+		{new(Client), "http://example.com/dir//foo", src, "subdirectory"},
+		{new(Client), "http://example.com/foo", filepath.Dir(src), "only files"},
+		{&Client{Offline: true}, "http://example.com/foo", src, "offline"},
+	}
+
+	for _, tc := range cases {
+		err := tc.Client.Put(tc.Dst, tc.Src)
+		if err == nil || !strings.Contains(err.Error(), tc.Err) {
+			t.Fatalf("%s: bad: %v", tc.Dst, err)
+		}
+	}
+}
+
+func TestHttpGetter_PutFile_badStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	src := testPutFile(t, "Hello\n")
+	defer os.RemoveAll(filepath.Dir(src))
+
+	g := new(HttpGetter)
+	err := g.PutFile(testURL(server.URL+"/foo"), src)
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+// testPutFile returns the path of a new file with the given contents.
+func testPutFile(t *testing.T, contents string) string {
+	path := tempFile(t)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return path
+}
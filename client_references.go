@@ -0,0 +1,123 @@
+package getter
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// ReferencesFile is the file in the root of a downloaded directory that
+// lists further sources to download into it when the Client's References
+// is set. It has the same format as the document read by ManifestGetter.
+const ReferencesFile = ".getter.json"
+
+// DefaultMaxReferenceDepth is how deeply a Client's References may be
+// nested if its MaxReferenceDepth is zero.
+const DefaultMaxReferenceDepth = 5
+
+// getReferences downloads the sources listed in the ReferencesFile of the
+// directory dst, which was downloaded from u, if there is one.
+func (c *Client) getReferences(dst string, u *url.URL) error {
+	f, err := os.Open(filepath.Join(dst, ReferencesFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	m, err := parseManifest(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("error parsing %s of '%s': %s",
+			ReferencesFile, redactURLCredentials(c.Src), err)
+	}
+	if len(m.Sources) == 0 {
+		return nil
+	}
+
+	detectors := c.Detectors
+	if detectors == nil {
+		detectors = Detectors
+	}
+	key, err := canonicalizeSource(c.Src, c.Pwd, detectors)
+	if err != nil {
+		return err
+	}
+	chain := append(append([]string(nil), c.referenceChain...), key)
+	if err := unlinkDir(dst, c.SpecialFiles, c.Symlinks); err != nil {
+		return err
+	}
+
+	maxDepth := c.MaxReferenceDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxReferenceDepth
+	}
+	if len(chain) > maxDepth {
+		return fmt.Errorf("references of '%s' are nested more than %d deep",
+			redactURLCredentials(c.Src), maxDepth)
+	}
+
+	for _, s := range m.Sources {
+		src, mode, err := s.resolve(u)
+		if err != nil {
+			return err
+		}
+		ref, err := canonicalizeSource(src, c.Pwd, detectors)
+		if err != nil {
+			return err
+		}
+		for _, k := range chain {
+			if k == ref {
+				return fmt.Errorf("reference cycle: '%s' refers back to '%s'",
+					redactURLCredentials(c.Src), redactURLCredentials(src))
+			}
+		}
+
+		client := *c
+		client.Src = src
+		client.Dst = filepath.Join(dst, s.Destination)
+		client.Mode = mode
+		client.StartJitter = 0
+		client.referenceChain = chain
+		r := tryResult(src, client.Get)
+		r.Optional = s.Optional
+		if r.Err == nil {
+			continue
+		}
+		if s.Optional {
+			if c.OnOptionalFailure != nil {
+				c.OnOptionalFailure(r)
+			}
+			continue
+		}
+
+		return fmt.Errorf("error downloading reference '%s' of '%s': %w",
+			redactURLCredentials(src), redactURLCredentials(c.Src), r.Err)
+	}
+
+	return nil
+}
+
+// unlinkDir replaces dst with a copy of the directory it links to if it is
+// a symlink, as the FileGetter leaves local directories, so that nothing is
+// downloaded into the source.
+func unlinkDir(dst string, special SpecialFilePolicy, symlinks SymlinkPolicy) error {
+	fi, err := os.Lstat(dst)
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return err
+	}
+
+	src, err := filepath.EvalSymlinks(dst)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(dst); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	return copyDir(dst, src, false, special, symlinks)
+}
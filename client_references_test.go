@@ -0,0 +1,126 @@
+package getter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClient_References(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	testReferences(t, filepath.Join(td, "a"), `{"sources": [
+		{"source": "./b", "destination": "b"},
+		{"source": "./c.txt", "destination": "c.txt", "mode": "file"},
+		{"source": "./missing", "destination": "missing", "optional": true}
+	]}`)
+	testReferences(t, filepath.Join(td, "b"), `{"sources": [
+		{"source": "./c.txt", "destination": "c.txt", "mode": "file"}
+	]}`)
+	if err := ioutil.WriteFile(filepath.Join(td, "c.txt"), []byte("c\n"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var optional []SourceResult
+	dst := filepath.Join(td, "dst")
+	client := &Client{
+		Src:        filepath.Join(td, "a"),
+		Dst:        dst,
+		Dir:        true,
+		References: true,
+		OnOptionalFailure: func(r SourceResult) {
+			optional = append(optional, r)
+		},
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "c.txt"), "c\n")
+	assertContents(t, filepath.Join(dst, "b", "c.txt"), "c\n")
+	if len(optional) != 1 || !strings.HasSuffix(optional[0].Source, "missing") {
+		t.Fatalf("bad: %#v", optional)
+	}
+
+	// Without References, only the directory is downloaded
+	dst = filepath.Join(td, "plain")
+	client = &Client{Src: filepath.Join(td, "a"), Dst: dst, Dir: true}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "b")); !os.IsNotExist(err) {
+		t.Fatalf("reference shouldn't be downloaded: %v", err)
+	}
+}
+
+func TestClient_References_cycle(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	testReferences(t, filepath.Join(td, "a"), `{"sources": [
+		{"source": "./b", "destination": "b"}
+	]}`)
+	testReferences(t, filepath.Join(td, "b"), `{"sources": [
+		{"source": "./a", "destination": "a"}
+	]}`)
+
+	client := &Client{
+		Src:        filepath.Join(td, "a"),
+		Dst:        filepath.Join(td, "dst"),
+		Dir:        true,
+		References: true,
+	}
+	err := client.Get()
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestClient_References_depth(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	for _, name := range []string{"a", "b", "c"} {
+		next := string(name[0] + 1)
+		testReferences(t, filepath.Join(td, name), `{"sources": [
+			{"source": "./`+next+`", "destination": "`+next+`"}
+		]}`)
+	}
+	if err := os.MkdirAll(filepath.Join(td, "d"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	client := &Client{
+		Src:               filepath.Join(td, "a"),
+		Dst:               filepath.Join(td, "dst"),
+		Dir:               true,
+		References:        true,
+		MaxReferenceDepth: 2,
+	}
+	err := client.Get()
+	if err == nil || !strings.Contains(err.Error(), "more than 2 deep") {
+		t.Fatalf("bad: %v", err)
+	}
+
+	client.Dst = filepath.Join(td, "dst2")
+	client.MaxReferenceDepth = 3
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(client.Dst, "b", "c", "d")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+// testReferences creates the directory dir with a ReferencesFile containing
+// refs.
+func testReferences(t *testing.T, dir, refs string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ReferencesFile), []byte(refs), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -0,0 +1,145 @@
+package getter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	urlhelper "github.com/hashicorp/go-getter/helper/url"
+)
+
+// maxResolveRedirects is how many terraform-get redirects Resolve follows
+// before giving up, in case they go round in a loop.
+const maxResolveRedirects = 10
+
+// Resolve returns what the client would download for src without
+// downloading it, for tools that display or cache the resolved address:
+// the scheme of the getter that is used, the URL that it is given, with
+// any subdirectory, and the mode that it is downloaded in. The client's
+// Src and Dst are ignored.
+//
+// HTTP directory sources that redirect to another source with the
+// terraform-get protocol, such as with an X-Terraform-Get header, are
+// followed to the source they redirect to, and a checksum that they give
+// is added to its URL. This needs a request to each of them, but nothing
+// else is fetched. Only the first of several sources, or of a source's
+// mirrors, is resolved.
+func (c *Client) Resolve(src string) (scheme, realURL string, mode ClientMode, err error) {
+	mirrors, err := splitMirrors(src)
+	if err != nil {
+		return "", "", 0, err
+	}
+	if mirrors != nil {
+		src = mirrors[0]
+	}
+
+	mode = c.Mode
+	if mode == ClientModeInvalid {
+		if c.Dir {
+			mode = ClientModeDir
+		} else {
+			mode = ClientModeFile
+		}
+	}
+
+	decompressors := c.Decompressors
+	if decompressors == nil {
+		decompressors = Decompressors
+	}
+	detectors := c.Detectors
+	if detectors == nil {
+		detectors = Detectors
+	}
+	getters := c.Getters
+	if getters == nil {
+		getters = defaultGetters()
+	}
+
+	for i := 0; ; i++ {
+		if i == maxResolveRedirects {
+			return "", "", 0, fmt.Errorf(
+				"too many redirects resolving '%s'", redactURLCredentials(src))
+		}
+
+		detected, err := Detect(src, c.Pwd, detectors)
+		if err != nil {
+			return "", "", 0, err
+		}
+		force, detected := getForcedGetter(detected)
+		detected, subDir := SourceDirSubdir(detected)
+
+		u, err := urlhelper.Parse(detected)
+		if err != nil {
+			return "", "", 0, err
+		}
+		scheme = force
+		if scheme == "" {
+			scheme = u.Scheme
+		}
+		realURL = withSubdir(detected, subDir)
+
+		g, ok := getters[scheme]
+		if !ok {
+			return "", "", 0, fmt.Errorf(
+				"download not supported for scheme '%s'", scheme)
+		}
+		g = getterForClient(g, c)
+
+		// An archive is downloaded as a file and unarchived, so it is the
+		// mode it is unarchived in that matters.
+		archiveV, err := getArchiveType(u, decompressors)
+		if err != nil {
+			return "", "", 0, err
+		}
+		archived := decompressors[archiveV] != nil
+		if mode == ClientModeAny {
+			if archived {
+				mode = ClientModeDir
+			} else if mode, err = g.ClientMode(u); err != nil {
+				return "", "", 0, err
+			}
+		}
+
+		hg, ok := g.(*HttpGetter)
+		if !ok || mode != ClientModeDir || archived {
+			return scheme, realURL, mode, nil
+		}
+
+		if c.Offline {
+			return "", "", 0, &OfflineError{Sources: []string{redactURLCredentials(detected)}}
+		}
+		sources, checksum, err := hg.sources(u)
+		if err != nil {
+			return "", "", 0, err
+		}
+
+		// The source's own subdirectory is downloaded first, and then the
+		// one that was asked for within it.
+		source, sourceSubDir := SourceDirSubdir(sources[0])
+		if checksum != "" {
+			if source, err = withChecksum(source, checksum); err != nil {
+				return "", "", 0, err
+			}
+		}
+		src = withSubdir(source, path.Join(sourceSubDir, subDir))
+	}
+}
+
+// withSubdir returns src with the subdirectory subDir, which is the
+// reverse of SourceDirSubdir.
+func withSubdir(src, subDir string) string {
+	if subDir == "" || subDir == "." {
+		return src
+	}
+
+	var offset int
+	if idx := strings.Index(src, "://"); idx > -1 {
+		offset = idx + 3
+	}
+	query := ""
+	if idx := strings.Index(src[offset:], "?"); idx > -1 {
+		src, query = src[:offset+idx], src[offset+idx:]
+	}
+
+	return src + "//" + subDir + query
+}
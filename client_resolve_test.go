@@ -0,0 +1,74 @@
+package getter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_Resolve(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/module":
+			w.Header().Add("X-Terraform-Get", testModuleURL("basic").String())
+		case "/checksum":
+			w.Header().Add("X-Terraform-Get", testModuleURL("basic-file-archive/archive.tar.gz").String())
+			w.Header().Add("X-Terraform-Get-Checksum", "sha256:abcd")
+		case "/redirect":
+			w.Header().Add("X-Terraform-Get", server.URL+"/module//foo")
+		case "/loop":
+			w.Header().Add("X-Terraform-Get", server.URL+"/loop")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	basic := testModuleURL("basic").String()
+	cases := []struct {
+		Src    string
+		Mode   ClientMode
+		Scheme string
+		URL    string
+		RMode  ClientMode
+	}{
+		{server.URL + "/module", ClientModeDir, "file", basic, ClientModeDir},
+		{server.URL + "/module//sub", ClientModeDir, "file", basic + "//sub", ClientModeDir},
+		{server.URL + "/redirect//sub", ClientModeDir, "file", basic + "//foo/sub", ClientModeDir},
+		{
+			server.URL + "/checksum", ClientModeDir, "file",
+			testModuleURL("basic-file-archive/archive.tar.gz").String() + "?checksum=sha256%3Aabcd", ClientModeDir,
+		},
+		{server.URL + "/file.txt", ClientModeAny, "http", server.URL + "/file.txt", ClientModeFile},
+		{server.URL + "/module.zip", ClientModeAny, "http", server.URL + "/module.zip", ClientModeDir},
+		{"git::" + server.URL + "/repo.git?ref=v1", ClientModeDir, "git", server.URL + "/repo.git?ref=v1", ClientModeDir},
+		{"github.com/hashicorp/foo//bar", ClientModeDir, "git", "https://github.com/hashicorp/foo.git//bar", ClientModeDir},
+	}
+
+	for _, tc := range cases {
+		client := &Client{Mode: tc.Mode}
+		scheme, u, mode, err := client.Resolve(tc.Src)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Src, err)
+		}
+		if scheme != tc.Scheme || u != tc.URL || mode != tc.RMode {
+			t.Fatalf("%s: bad: %s %s %d", tc.Src, scheme, u, mode)
+		}
+	}
+
+	client := &Client{Mode: ClientModeDir}
+	if _, _, _, err := client.Resolve(server.URL + "/loop"); err == nil || !strings.Contains(err.Error(), "too many") {
+		t.Fatalf("bad: %v", err)
+	}
+	if _, _, _, err := client.Resolve(server.URL + "/missing"); err == nil {
+		t.Fatal("should error")
+	}
+
+	// Resolving a redirect needs network access
+	client.Offline = true
+	if _, _, _, err := client.Resolve(server.URL + "/module"); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -0,0 +1,146 @@
+package getter
+
+import (
+	"bytes"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// Strategy is how the client downloads a file that it has to verify
+// against a checksum or decompress.
+type Strategy uint
+
+const (
+	// StrategyTempFile downloads the whole file to disk, into a temporary
+	// file in the case of an archive, before it is verified and then
+	// decompressed. This needs enough disk space for the archive as well
+	// as its contents, but nothing is decompressed until the archive is
+	// known to be good. This is the default.
+	StrategyTempFile Strategy = iota
+
+	// StrategyStream verifies and decompresses the file as it is
+	// downloaded, so the archive is never written to disk or held in
+	// memory. Since the checksum is only known once the whole file has
+	// been read, a file that fails verification has already been written
+	// out and is removed again, and as nothing is kept the download
+	// can't be retried or decompressed again without fetching it again.
+	//
+	// Files are still downloaded to disk first if the getter doesn't
+	// implement StreamGetter, the decompressor doesn't implement
+	// StreamDecompressor, or the client has a PeerCache.
+	StrategyStream
+
+	// StrategyAuto uses StrategyStream for files larger than the client's
+	// StreamThreshold and StrategyTempFile for everything else, including
+	// files whose size the getter can't tell in advance.
+	StrategyAuto
+)
+
+// DefaultStreamThreshold is the size in bytes above which StrategyAuto
+// streams files if the client's StreamThreshold isn't set.
+const DefaultStreamThreshold = 64 * 1024 * 1024
+
+// openStream opens the file at u for reading if the client's strategy
+// allows it and both the getter and the decompressor, if any, support
+// streaming. It returns a nil reader if the getter should be used to
+// download the file as usual. Otherwise stream reports whether the file
+// should be streamed; if it is false the caller should write the reader
+// to disk and carry on as if the getter had downloaded it.
+func (c *Client) openStream(g Getter, u *url.URL, d Decompressor) (io.ReadCloser, bool, error) {
+	if c.Strategy == StrategyTempFile || c.PeerCache != nil {
+		return nil, false, nil
+	}
+
+	sg, ok := g.(StreamGetter)
+	if !ok {
+		return nil, false, nil
+	}
+	if d != nil {
+		if _, ok := d.(StreamDecompressor); !ok {
+			return nil, false, nil
+		}
+	}
+
+	r, size, err := sg.GetReader(u)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if c.Strategy == StrategyAuto {
+		threshold := c.StreamThreshold
+		if threshold == 0 {
+			threshold = DefaultStreamThreshold
+		}
+
+		return r, size > threshold, nil
+	}
+
+	return r, true, nil
+}
+
+// streamFile writes the file read from r to dst, decompressing it with d
+// if it isn't nil and verifying it against the checksum v if h isn't nil.
+// d must implement StreamDecompressor. If the checksum doesn't match then
+// whatever was written to dst is removed.
+func streamFile(dst string, dir bool, r io.Reader, d Decompressor, h hash.Hash, v []byte) error {
+	if h != nil {
+		h.Reset()
+		r = io.TeeReader(r, h)
+	}
+
+	if d != nil {
+		if err := d.(StreamDecompressor).DecompressReader(dst, r, dir); err != nil {
+			return err
+		}
+
+		// Decompressors don't necessarily read up to the end of the
+		// archive, but all of it is part of the checksum.
+		if _, err := io.Copy(ioutil.Discard, r); err != nil {
+			return err
+		}
+	} else {
+		if err := writeFile(dst, r); err != nil {
+			return err
+		}
+	}
+
+	if h != nil {
+		if actual := h.Sum(nil); !bytes.Equal(actual, v) {
+			os.RemoveAll(dst)
+			return &ChecksumError{Path: dst, Expected: v, Actual: actual}
+		}
+	}
+
+	return nil
+}
+
+// writeFile writes the contents of r to the file at dst, creating any
+// parent directories.
+func writeFile(dst string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	// Don't write through a symlink left by an earlier download, such as
+	// one made by the FileGetter.
+	if fi, err := os.Lstat(dst); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(dst); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
@@ -0,0 +1,144 @@
+package getter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileGetter_streamImpl(t *testing.T) {
+	var _ StreamGetter = new(FileGetter)
+	var _ StreamGetter = new(HttpGetter)
+	var _ StreamGetter = new(S3Getter)
+}
+
+func TestGetFile_streamChecksum(t *testing.T) {
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+	client := &Client{
+		Src:      testModule("basic-file/foo.txt") + "?checksum=md5:09f7e02f1290be211da707a266f153b3",
+		Dst:      dst,
+		Getters:  map[string]Getter{"file": new(FileGetter)},
+		Strategy: StrategyStream,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The file getter symlinks files it downloads, so a regular file
+	// means the file was streamed.
+	fi, err := os.Lstat(dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !fi.Mode().IsRegular() {
+		t.Fatalf("should be a regular file: %s", fi.Mode())
+	}
+	assertContents(t, dst, "Hello\n")
+}
+
+func TestGetFile_streamChecksumBad(t *testing.T) {
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+	client := &Client{
+		Src:      testModule("basic-file/foo.txt") + "?checksum=md5:09f7e02f1290be211da707a266f153b4",
+		Dst:      dst,
+		Getters:  map[string]Getter{"file": new(FileGetter)},
+		Strategy: StrategyStream,
+	}
+	if err := client.Get(); err == nil {
+		t.Fatal("should error")
+	}
+
+	if _, err := os.Lstat(dst); !os.IsNotExist(err) {
+		t.Fatalf("should be removed: %v", err)
+	}
+}
+
+func TestGetFile_streamArchive(t *testing.T) {
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+	client := &Client{
+		Src:      testModule("basic-file-archive/archive.tar.gz?checksum=md5:fbd90037dacc4b1ab40811d610dde2f0"),
+		Dst:      dst,
+		Getters:  map[string]Getter{"file": new(FileGetter)},
+		Strategy: StrategyStream,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	assertContents(t, dst, "Hello\n")
+}
+
+func TestGet_streamArchive(t *testing.T) {
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+	client := &Client{
+		Src:      testModule("archive-rooted/archive.tar.gz"),
+		Dst:      dst,
+		Dir:      true,
+		Getters:  map[string]Getter{"file": new(FileGetter)},
+		Strategy: StrategyStream,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dst, "root", "hello.txt")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestGetFile_streamFallback(t *testing.T) {
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	// The mock getter doesn't implement StreamGetter
+	getter := &MockGetter{Proxy: new(FileGetter)}
+	client := &Client{
+		Src:      testModule("basic-file/foo.txt") + "?checksum=md5:09f7e02f1290be211da707a266f153b3",
+		Dst:      dst,
+		Getters:  map[string]Getter{"file": getter},
+		Strategy: StrategyStream,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !getter.GetFileCalled {
+		t.Fatal("should use GetFile")
+	}
+	assertContents(t, dst, "Hello\n")
+}
+
+func TestGetFile_streamAuto(t *testing.T) {
+	cases := []struct {
+		Threshold int64
+		Streamed  bool
+	}{
+		{0, false},
+		{1, true},
+	}
+
+	for _, tc := range cases {
+		dst := tempFile(t)
+		defer os.RemoveAll(filepath.Dir(dst))
+		client := &Client{
+			Src:             testModule("basic-file/foo.txt") + "?checksum=md5:09f7e02f1290be211da707a266f153b4",
+			Dst:             dst,
+			Getters:         map[string]Getter{"file": new(FileGetter)},
+			Strategy:        StrategyAuto,
+			StreamThreshold: tc.Threshold,
+		}
+		if err := client.Get(); err == nil {
+			t.Fatalf("%d: should error", tc.Threshold)
+		}
+
+		// A streamed file that fails verification is removed, a
+		// downloaded one is left in place.
+		_, err := os.Lstat(dst)
+		if streamed := os.IsNotExist(err); streamed != tc.Streamed {
+			t.Fatalf("%d: expected streamed %t, got: %v", tc.Threshold, tc.Streamed, err)
+		}
+	}
+}
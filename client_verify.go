@@ -0,0 +1,162 @@
+package getter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// VerifyMismatch is a file of a directory download that wasn't the same
+// when it was downloaded again to verify it. See Client.VerifySample.
+type VerifyMismatch struct {
+	// Source is the source URL, with any credentials redacted.
+	Source string
+
+	// Path is the path of the file in the download, relative to Dst.
+	Path string
+
+	// Expected is the SHA256 checksum of the file that was downloaded and
+	// Actual that of the file downloaded again, which is nil if it is
+	// missing.
+	Expected []byte
+	Actual   []byte
+}
+
+func (m VerifyMismatch) String() string {
+	if m.Actual == nil {
+		return fmt.Sprintf("%s is missing when downloaded again from %s", m.Path, m.Source)
+	}
+
+	return fmt.Sprintf(
+		"%s differs when downloaded again from %s: expected %x, got %x",
+		m.Path, m.Source, m.Expected, m.Actual)
+}
+
+// verifySample downloads the client's source again and compares a random
+// sample of the files in the directory dst against it.
+func (c *Client) verifySample(dst string) error {
+	paths, err := sampleFiles(dst, c.VerifySample)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	td, err := ioutil.TempDir("", "getter")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(td)
+
+	// Caches on the way to the origin are asked to go back to it, since
+	// they are what is being checked.
+	header := make(http.Header)
+	for k, vs := range c.Header {
+		header[k] = vs
+	}
+	header.Set("Cache-Control", "no-cache")
+
+	client := *c
+	client.Dst = filepath.Join(td, "verify")
+	client.Mode = ClientModeDir
+	client.Header = header
+	client.Lock = false
+	client.Inflight = nil
+	client.PeerCache = nil
+	client.VerifySample = 0
+	client.References = false
+	client.StartJitter = 0
+	if err := client.Get(); err != nil {
+		return fmt.Errorf("error verifying download: %s", err)
+	}
+
+	source := redactURLCredentials(c.Src)
+	for _, path := range paths {
+		expected, err := sha256File(filepath.Join(dst, path))
+		if err != nil {
+			return err
+		}
+		actual, err := sha256File(filepath.Join(client.Dst, path))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if bytes.Equal(expected, actual) {
+			continue
+		}
+
+		m := VerifyMismatch{
+			Source:   source,
+			Path:     filepath.ToSlash(path),
+			Expected: expected,
+			Actual:   actual,
+		}
+		if c.OnVerifyMismatch == nil {
+			return fmt.Errorf("error verifying download: %s", m)
+		}
+		c.OnVerifyMismatch(m)
+	}
+
+	return nil
+}
+
+// sampleFiles returns the paths, relative to dir, of n regular files in it
+// chosen at random, or all of them if there are fewer. Repository metadata
+// is skipped, as it is for ChecksumDir.
+func sampleFiles(dir string, n int) ([]string, error) {
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && (info.Name() == ".git" || info.Name() == ".hg") {
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rand.Shuffle(len(paths), func(i, j int) {
+		paths[i], paths[j] = paths[j], paths[i]
+	})
+	if len(paths) > n {
+		paths = paths[:n]
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// sha256File returns the SHA256 checksum of the file at path.
+func sha256File(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
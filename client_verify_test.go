@@ -0,0 +1,156 @@
+package getter
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestClient_verifySample(t *testing.T) {
+	good := testVerifyArchive(t, map[string]string{"a": "A\n", "b/c": "C\n"})
+	bad := testVerifyArchive(t, map[string]string{"a": "A\n", "b/c": "corrupt\n"})
+	defer os.RemoveAll(filepath.Dir(good))
+	defer os.RemoveAll(filepath.Dir(bad))
+
+	// The first download is served the corrupt archive, as a cache might,
+	// and the origin the right one.
+	var mu sync.Mutex
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+
+		if r.Header.Get("Cache-Control") == "no-cache" {
+			http.ServeFile(w, r, good)
+			return
+		}
+		http.ServeFile(w, r, bad)
+	}))
+	defer server.Close()
+
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	var mismatches []VerifyMismatch
+	client := &Client{
+		Src:          server.URL + "/dir.tar.gz",
+		Dst:          dst,
+		Mode:         ClientModeDir,
+		VerifySample: 10,
+		OnVerifyMismatch: func(m VerifyMismatch) {
+			mismatches = append(mismatches, m)
+		},
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if requests != 2 {
+		t.Fatalf("bad: %d", requests)
+	}
+	if len(mismatches) != 1 || mismatches[0].Path != "b/c" || mismatches[0].Actual == nil {
+		t.Fatalf("bad: %#v", mismatches)
+	}
+	assertContents(t, filepath.Join(dst, "b", "c"), "corrupt\n")
+
+	// Without somewhere to report it, the mismatch fails the download
+	client.OnVerifyMismatch = nil
+	err := client.Get()
+	if err == nil || !strings.Contains(err.Error(), "b/c differs") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestClient_verifySample_matches(t *testing.T) {
+	archive := testVerifyArchive(t, map[string]string{"a": "A\n", "b/c": "C\n"})
+	defer os.RemoveAll(filepath.Dir(archive))
+
+	var mu sync.Mutex
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+
+		http.ServeFile(w, r, archive)
+	}))
+	defer server.Close()
+
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	client := &Client{
+		Src:          server.URL + "/dir.tar.gz",
+		Dst:          dst,
+		Mode:         ClientModeDir,
+		VerifySample: 1,
+		OnVerifyMismatch: func(m VerifyMismatch) {
+			t.Errorf("bad: %s", m)
+		},
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if requests != 2 {
+		t.Fatalf("bad: %d", requests)
+	}
+}
+
+func TestSampleFiles(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a", "b", "c/d", "c/e"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	paths, err := sampleFiles(dir, 2)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(paths) != 2 || paths[0] == paths[1] {
+		t.Fatalf("bad: %v", paths)
+	}
+
+	paths, err = sampleFiles(dir, 10)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(paths) != 4 {
+		t.Fatalf("bad: %v", paths)
+	}
+}
+
+// testVerifyArchive returns the path of a tar.gz archive of the given
+// files, keyed by slash separated path.
+func testVerifyArchive(t *testing.T, files map[string]string) string {
+	src := tempDir(t)
+	defer os.RemoveAll(src)
+	for name, contents := range files {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	dst := filepath.Join(tempDir(t), "dir.tar.gz")
+	if err := Compress(dst, src, "tar.gz"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return dst
+}
@@ -1,20 +1,44 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"os/signal"
+	"strings"
 
 	"github.com/hashicorp/go-getter"
 )
 
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: go-getter [options] URL dst\n"+
+		"       go-getter [options] -resolve URL\n\n"+
+		"Downloads URL to dst in the same way as go-getter's Client, or with\n"+
+		"-resolve prints the getter, URL and mode it would be downloaded with.\n\n"+
+		"Options:\n")
+	flag.PrintDefaults()
+}
+
 func main() {
 	modeRaw := flag.String("mode", "any", "get mode (any, file, dir)")
+	checksum := flag.String("checksum", "", "checksum to verify, as type:value or file:<url of a checksum file>")
+	archive := flag.String("archive", "", "archive format to unpack, or false to not unpack")
+	progress := flag.Bool("progress", false, "show the progress of each file on stderr")
+	resolve := flag.Bool("resolve", false, "print what would be downloaded rather than downloading it")
+	timeout := flag.Duration("timeout", 0, "how long the download may take altogether")
+	offline := flag.Bool("offline", false, "only use sources that can be got without network access")
+	quiet := flag.Bool("q", false, "don't log anything on success")
+	flag.Usage = usage
 	flag.Parse()
 	args := flag.Args()
-	if len(args) < 2 {
+	if *resolve && len(args) < 1 {
+		log.Fatalf("Expected an arg: URL")
+	}
+	if !*resolve && len(args) < 2 {
 		log.Fatalf("Expected two args: URL and dst")
-		os.Exit(1)
 	}
 
 	// Get the mode
@@ -28,28 +52,76 @@ func main() {
 		mode = getter.ClientModeDir
 	default:
 		log.Fatalf("Invalid client mode, must be 'any', 'file', or 'dir': %s", *modeRaw)
-		os.Exit(1)
 	}
 
 	// Get the pwd
 	pwd, err := os.Getwd()
 	if err != nil {
 		log.Fatalf("Error getting wd: %s", err)
-		os.Exit(1)
 	}
 
+	// Stop cleanly on an interrupt, so that a partial download is removed
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
 	// Build the client
+	src := args[0]
+	src = withParam(src, "checksum", *checksum)
+	src = withParam(src, "archive", *archive)
 	client := &getter.Client{
-		Src:  args[0],
-		Dst:  args[1],
-		Pwd:  pwd,
-		Mode: mode,
+		Ctx:     ctx,
+		Src:     src,
+		Pwd:     pwd,
+		Mode:    mode,
+		Timeout: *timeout,
+		Offline: *offline,
+	}
+	if *progress {
+		client.ProgressListener = newProgressBar(os.Stderr)
 	}
 
+	if *resolve {
+		scheme, realURL, mode, err := client.Resolve(src)
+		if err != nil {
+			log.Fatalf("Error resolving: %s", err)
+		}
+		fmt.Printf("%s\t%s\t%s\n", scheme, realURL, modeName(mode))
+		return
+	}
+
+	client.Dst = args[1]
 	if err := client.Get(); err != nil {
 		log.Fatalf("Error downloading: %s", err)
-		os.Exit(1)
 	}
 
-	log.Println("Success!")
+	if !*quiet {
+		log.Println("Success!")
+	}
+}
+
+// withParam returns src with the query parameter name added, unless value
+// is empty. Query parameters go at the very end of a source, after any
+// subdirectory.
+func withParam(src, name, value string) string {
+	if value == "" {
+		return src
+	}
+
+	param := name + "=" + url.QueryEscape(value)
+	if strings.Contains(src, "?") {
+		return src + "&" + param
+	}
+	return src + "?" + param
+}
+
+// modeName returns the name of mode as given to -mode.
+func modeName(mode getter.ClientMode) string {
+	switch mode {
+	case getter.ClientModeFile:
+		return "file"
+	case getter.ClientModeDir:
+		return "dir"
+	default:
+		return "any"
+	}
 }
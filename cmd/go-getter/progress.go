@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sync"
+	"time"
+)
+
+// progressBar is a getter.ProgressTracker that writes the progress of each
+// file as it is downloaded to w, a line at a time, at most every second and
+// once more when the file is done.
+type progressBar struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newProgressBar(w io.Writer) *progressBar {
+	return &progressBar{w: w}
+}
+
+func (p *progressBar) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) io.ReadCloser {
+	return &progressReader{
+		ReadCloser: stream,
+		bar:        p,
+		name:       path.Base(src),
+		read:       currentSize,
+		total:      totalSize,
+	}
+}
+
+// print writes a line with the progress of a file that is read bytes into
+// total, which is -1 if it isn't known.
+func (p *progressBar) print(name string, read, total int64, done bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := ""
+	if done {
+		status = " done"
+	}
+	if total > 0 {
+		fmt.Fprintf(p.w, "%s: %s / %s (%d%%)%s\n",
+			name, formatBytes(read), formatBytes(total), read*100/total, status)
+		return
+	}
+	fmt.Fprintf(p.w, "%s: %s%s\n", name, formatBytes(read), status)
+}
+
+// progressReader reports the progress of a file being read to its bar.
+type progressReader struct {
+	io.ReadCloser
+	bar         *progressBar
+	name        string
+	read, total int64
+	last        time.Time
+	closed      bool
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.read += int64(n)
+	if now := time.Now(); now.Sub(r.last) >= time.Second {
+		r.last = now
+		r.bar.print(r.name, r.read, r.total, false)
+	}
+
+	return n, err
+}
+
+func (r *progressReader) Close() error {
+	if !r.closed {
+		r.closed = true
+		r.bar.print(r.name, r.read, r.total, true)
+	}
+
+	return r.ReadCloser.Close()
+}
+
+// formatBytes returns n in the largest unit that it is at least one of.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
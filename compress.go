@@ -0,0 +1,241 @@
+package getter
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compress writes an archive of the contents of the directory srcDir to the
+// file dst. The format is one of "tar.gz", "tgz", "tar.zst", "tzst" or
+// "zip", which are the same as the archive types that are unarchived.
+//
+// Archives are written so that they can be unarchived by the default
+// decompressors: file modes and modification times are kept, files with
+// several hardlinks are stored as hardlinks in tar archives, and symlinks
+// are stored as symlinks. It is an error for srcDir to contain a symlink
+// that points outside of it or a special file such as a socket. dst is
+// only written once the whole archive has been.
+func Compress(dst, srcDir, format string) error {
+	var write func(io.Writer, string) error
+	switch format {
+	case "tar.gz", "tgz":
+		write = func(w io.Writer, src string) error {
+			gzipW := gzip.NewWriter(w)
+			if err := writeTar(gzipW, src); err != nil {
+				return err
+			}
+			return gzipW.Close()
+		}
+	case "tar.zst", "tzst":
+		write = func(w io.Writer, src string) error {
+			zstdW, err := zstd.NewWriter(w)
+			if err != nil {
+				return err
+			}
+			if err := writeTar(zstdW, src); err != nil {
+				zstdW.Close()
+				return err
+			}
+			return zstdW.Close()
+		}
+	case "zip":
+		write = writeZip
+	default:
+		return fmt.Errorf("unsupported archive format: %s", format)
+	}
+
+	src, err := filepath.EvalSymlinks(srcDir)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("source must be a directory: %s", srcDir)
+	}
+
+	// The archive is written beside dst, which mustn't be archived itself
+	absDst, err := filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(absDst), 0755); err != nil {
+		return err
+	}
+	realDir, err := filepath.EvalSymlinks(filepath.Dir(absDst))
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(src, realDir); err == nil &&
+		rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("archive can't be written inside the directory being archived: %s", dst)
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(absDst), ".getter")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if err := write(f, src); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, absDst)
+}
+
+// archiveEntry is a file found in a directory being archived.
+type archiveEntry struct {
+	// Name is the slash separated path of the file in the archive, which
+	// for directories ends with a slash.
+	Name string
+
+	// Path is where the file is and Info its Lstat.
+	Path string
+	Info os.FileInfo
+
+	// Link is the target of a symlink.
+	Link string
+}
+
+// walkArchive calls fn with each file in the directory src, in lexical
+// order, checking that it can be archived.
+func walkArchive(src string, fn func(archiveEntry) error) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == src {
+			return nil
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		e := archiveEntry{Name: filepath.ToSlash(rel), Path: path, Info: info}
+
+		switch {
+		case info.IsDir():
+			e.Name += "/"
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			e.Link = filepath.ToSlash(link)
+			if !symlinkInside(e.Name, e.Link) {
+				return fmt.Errorf("symlink points outside of the archive: %s -> %s", e.Name, e.Link)
+			}
+		case isSpecialFile(info.Mode()):
+			return fmt.Errorf("special files can't be archived: %s", e.Name)
+		}
+
+		return fn(e)
+	})
+}
+
+// writeTar writes an uncompressed tar archive of the directory src to w.
+func writeTar(w io.Writer, src string) error {
+	tarW := tar.NewWriter(w)
+	links := make(map[fileID]string)
+	err := walkArchive(src, func(e archiveEntry) error {
+		hdr, err := tar.FileInfoHeader(e.Info, e.Link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = e.Name
+
+		if hdr.Typeflag == tar.TypeReg {
+			if id, ok := hardlinkID(e.Info); ok {
+				if target, ok := links[id]; ok {
+					hdr.Typeflag = tar.TypeLink
+					hdr.Linkname = target
+					hdr.Size = 0
+				} else {
+					links[id] = e.Name
+				}
+			}
+		}
+
+		if err := tarW.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil
+		}
+
+		return copyFileTo(tarW, e.Path)
+	})
+	if err != nil {
+		return err
+	}
+
+	return tarW.Close()
+}
+
+// writeZip writes a zip archive of the directory src to w.
+func writeZip(w io.Writer, src string) error {
+	zipW := zip.NewWriter(w)
+	err := walkArchive(src, func(e archiveEntry) error {
+		hdr, err := zip.FileInfoHeader(e.Info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = e.Name
+		if !e.Info.IsDir() {
+			hdr.Method = zip.Deflate
+		}
+
+		fw, err := zipW.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		switch {
+		case e.Info.IsDir():
+			return nil
+		case e.Link != "":
+			// The target of a symlink is its contents
+			_, err := io.WriteString(fw, e.Link)
+			return err
+		default:
+			return copyFileTo(fw, e.Path)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	return zipW.Close()
+}
+
+// copyFileTo copies the contents of the file at path to w.
+func copyFileTo(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,99 @@
+package getter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompress(t *testing.T) {
+	src := testCompressDir(t)
+	defer os.RemoveAll(src)
+
+	for _, format := range []string{"tar.gz", "tgz", "tar.zst", "tzst", "zip"} {
+		td := tempDir(t)
+		defer os.RemoveAll(td)
+
+		archive := filepath.Join(td, "archive."+format)
+		if err := Compress(archive, src, format); err != nil {
+			t.Fatalf("%s: err: %s", format, err)
+		}
+
+		// The archive is unarchived by the default decompressors
+		dst := filepath.Join(td, "out")
+		if err := Decompressors[format].Decompress(dst, archive, true); err != nil {
+			t.Fatalf("%s: err: %s", format, err)
+		}
+		assertContents(t, filepath.Join(dst, "a"), "hello")
+		assertContents(t, filepath.Join(dst, "sub", "b"), "world")
+
+		fi, err := os.Stat(filepath.Join(dst, "sub", "b"))
+		if err != nil {
+			t.Fatalf("%s: err: %s", format, err)
+		}
+		if runtime.GOOS != "windows" && fi.Mode().Perm() != 0600 {
+			t.Fatalf("%s: bad mode: %s", format, fi.Mode())
+		}
+		if format != "zip" && !fi.ModTime().Equal(time.Unix(1000000000, 0)) {
+			t.Fatalf("%s: bad mtime: %s", format, fi.ModTime())
+		}
+
+		// Nothing else is left beside the archive
+		entries, err := ioutil.ReadDir(td)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if len(entries) != 2 {
+			t.Fatalf("%s: bad: %v", format, entries)
+		}
+	}
+}
+
+func TestCompress_bad(t *testing.T) {
+	src := testCompressDir(t)
+	defer os.RemoveAll(src)
+
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	err := Compress(filepath.Join(td, "archive.rar"), src, "rar")
+	if err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Fatalf("bad: %v", err)
+	}
+
+	err = Compress(filepath.Join(src, "sub", "archive.zip"), src, "zip")
+	if err == nil || !strings.Contains(err.Error(), "inside the directory") {
+		t.Fatalf("bad: %v", err)
+	}
+
+	err = Compress(filepath.Join(td, "archive.zip"), filepath.Join(src, "a"), "zip")
+	if err == nil || !strings.Contains(err.Error(), "must be a directory") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+// testCompressDir returns a directory containing a file "a" and a file
+// "sub/b", which has the mode 0600, to archive.
+func testCompressDir(t *testing.T) string {
+	src := tempDir(t)
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "a"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	b := filepath.Join(src, "sub", "b")
+	if err := ioutil.WriteFile(b, []byte("world"), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	mtime := time.Unix(1000000000, 0)
+	if err := os.Chtimes(b, mtime, mtime); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return src
+}
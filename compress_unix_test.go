@@ -0,0 +1,61 @@
+// +build !windows
+
+package getter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompress_links(t *testing.T) {
+	src := testCompressDir(t)
+	defer os.RemoveAll(src)
+	if err := os.Symlink("../a", filepath.Join(src, "sub", "link")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.Link(filepath.Join(src, "a"), filepath.Join(src, "c")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, format := range []string{"tar.gz", "zip"} {
+		td := tempDir(t)
+		defer os.RemoveAll(td)
+
+		archive := filepath.Join(td, "archive."+format)
+		if err := Compress(archive, src, format); err != nil {
+			t.Fatalf("%s: err: %s", format, err)
+		}
+
+		dst := filepath.Join(td, "out")
+		if err := Decompressors[format].Decompress(dst, archive, true); err != nil {
+			t.Fatalf("%s: err: %s", format, err)
+		}
+		assertSymlink(t, filepath.Join(dst, "sub", "link"), "../a")
+		assertContents(t, filepath.Join(dst, "sub", "link"), "hello")
+		assertContents(t, filepath.Join(dst, "c"), "hello")
+		if format == "tar.gz" {
+			assertHardlinked(t, filepath.Join(dst, "a"), filepath.Join(dst, "c"))
+		}
+	}
+}
+
+func TestCompress_escapingSymlink(t *testing.T) {
+	src := testCompressDir(t)
+	defer os.RemoveAll(src)
+	if err := os.Symlink("../../secret", filepath.Join(src, "sub", "link")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	archive := filepath.Join(td, "archive.tar.gz")
+	err := Compress(archive, src, "tar.gz")
+	if err == nil || !strings.Contains(err.Error(), "outside of the archive") {
+		t.Fatalf("bad: %v", err)
+	}
+	if _, err := os.Stat(archive); err == nil {
+		t.Fatal("archive shouldn't be written")
+	}
+}
@@ -11,11 +11,64 @@ import (
 // should already exist.
 //
 // If ignoreDot is set to true, then dot-prefixed files/folders are ignored.
-func copyDir(dst string, src string, ignoreDot bool) error {
+// Special files are handled as special says, and symlinks as symlinks
+// says. Files with several hardlinks in src are hardlinked the same way in
+// dst.
+func copyDir(dst string, src string, ignoreDot bool, special SpecialFilePolicy, symlinks SymlinkPolicy) error {
+	return copySubdir(dst, src, src, ignoreDot, special, symlinks)
+}
+
+// copySubdir is copyDir for the subdirectory src of the directory root
+// that was downloaded. Symlinks in it that are dereferenced may point
+// anywhere inside of root, since what they point to is copied, but those
+// that are preserved must stay inside of src.
+func copySubdir(dst, src, root string, ignoreDot bool, special SpecialFilePolicy, symlinks SymlinkPolicy) error {
 	src, err := filepath.EvalSymlinks(src)
 	if err != nil {
 		return err
 	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+
+	c := &dirCopier{
+		root:      src,
+		derefRoot: root,
+		ignoreDot: ignoreDot,
+		special:   special,
+		symlinks:  symlinks,
+		links:     make(hardlinks),
+		copying:   make(map[string]bool),
+	}
+	return c.copy(dst, src)
+}
+
+// dirCopier copies directory trees for copyDir.
+type dirCopier struct {
+	// root is the directory being copied, which symlinks must stay inside
+	// of. If it is empty symlinks may point anywhere.
+	root string
+
+	// derefRoot, if set, is the directory that symlinks that are
+	// dereferenced must stay inside of instead of root.
+	derefRoot string
+
+	ignoreDot bool
+	special   SpecialFilePolicy
+	symlinks  SymlinkPolicy
+	links     hardlinks
+
+	// copying is the directories that are being copied, so that a
+	// dereferenced symlink to one of them isn't copied forever.
+	copying map[string]bool
+}
+
+// copy copies the contents of the directory src, which has no symlinks in
+// its path, into dst.
+func (c *dirCopier) copy(dst, src string) error {
+	c.copying[src] = true
+	defer delete(c.copying, src)
 
 	walkFn := func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -25,7 +78,7 @@ func copyDir(dst string, src string, ignoreDot bool) error {
 			return nil
 		}
 
-		if ignoreDot && strings.HasPrefix(filepath.Base(path), ".") {
+		if c.ignoreDot && strings.HasPrefix(filepath.Base(path), ".") {
 			// Skip any dot files
 			if info.IsDir() {
 				return filepath.SkipDir
@@ -53,26 +106,43 @@ func copyDir(dst string, src string, ignoreDot bool) error {
 			return nil
 		}
 
-		// If we have a file, copy the contents.
-		srcF, err := os.Open(path)
-		if err != nil {
-			return err
-		}
-		defer srcF.Close()
-
-		dstF, err := os.Create(dstPath)
-		if err != nil {
-			return err
+		if info.Mode()&os.ModeSymlink != 0 {
+			return c.copySymlink(dstPath, path)
 		}
-		defer dstF.Close()
 
-		if _, err := io.Copy(dstF, srcF); err != nil {
-			return err
+		if isSpecialFile(info.Mode()) {
+			return copySpecialFile(dstPath, path, info, c.special)
 		}
 
-		// Chmod it
-		return os.Chmod(dstPath, info.Mode())
+		return c.copyFile(dstPath, path, info)
 	}
 
 	return filepath.Walk(src, walkFn)
 }
+
+// copyFile copies the regular file at path, whose info is given, to dst.
+func (c *dirCopier) copyFile(dst, path string, info os.FileInfo) error {
+	if c.links.link(dst, info) {
+		return nil
+	}
+
+	// If we have a file, copy the contents.
+	srcF, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer srcF.Close()
+
+	dstF, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer dstF.Close()
+
+	if _, err := io.Copy(dstF, srcF); err != nil {
+		return err
+	}
+
+	// Chmod it
+	return os.Chmod(dst, info.Mode())
+}
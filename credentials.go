@@ -0,0 +1,34 @@
+package getter
+
+import (
+	"net/http"
+)
+
+// CredentialProvider supplies the credentials that requests to a host are
+// authenticated with, so that they don't have to be kept in URLs, netrc
+// files or configuration in plain text. See Client.Credentials.
+type CredentialProvider interface {
+	// Credential returns the credential for the given host, without a
+	// port, or nil if there isn't one.
+	Credential(host string) (*Credential, error)
+}
+
+// Credential is a secret that authenticates requests to a host.
+type Credential struct {
+	// Token, if set, is sent as a bearer token. Otherwise Username and
+	// Password are sent with basic authentication.
+	Token    string
+	Username string
+	Password string
+}
+
+// setHeader sets the Authorization header for the credential on header.
+func (c *Credential) setHeader(header http.Header) {
+	if c.Token != "" {
+		header.Set("Authorization", "Bearer "+c.Token)
+		return
+	}
+
+	req := http.Request{Header: header}
+	req.SetBasicAuth(c.Username, c.Password)
+}
@@ -0,0 +1,121 @@
+package getter
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// SystemdCredentials is a CredentialProvider that reads credentials given
+// to the service by systemd, or encrypted with systemd-creds, for devices
+// that mustn't keep plain text tokens on disk. The credential for a host is
+// either a token, in a credential named after the host with ".token"
+// appended, or a username and password in credentials ending in
+// ".username" and ".password". For example, "updates.example.com.token".
+//
+// Credentials are kept in memory once they have been read, so a
+// SystemdCredentials must not be copied after it is first used.
+type SystemdCredentials struct {
+	// Directory is where credentials are read from in plain text. This
+	// defaults to $CREDENTIALS_DIRECTORY, which systemd sets for services
+	// given credentials with LoadCredential=, LoadCredentialEncrypted= or
+	// SetCredentialEncrypted=, and which is only kept in memory.
+	Directory string
+
+	// EncryptedDirectory, if set, is a directory of credentials encrypted
+	// with "systemd-creds encrypt", such as those sealed with a TPM2, with
+	// ".cred" appended to their names. They are decrypted with
+	// "systemd-creds decrypt" if they aren't found in Directory, without
+	// being written to disk.
+	EncryptedDirectory string
+
+	lock  sync.Mutex
+	cache map[string]*Credential
+}
+
+func (s *SystemdCredentials) Credential(host string) (*Credential, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if c, ok := s.cache[host]; ok {
+		return c, nil
+	}
+
+	c, err := s.credential(host)
+	if err != nil {
+		return nil, err
+	}
+	if s.cache == nil {
+		s.cache = make(map[string]*Credential)
+	}
+	s.cache[host] = c
+
+	return c, nil
+}
+
+// credential reads the credential for host.
+func (s *SystemdCredentials) credential(host string) (*Credential, error) {
+	token, err := s.read(host + ".token")
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		return &Credential{Token: token}, nil
+	}
+
+	username, err := s.read(host + ".username")
+	if err != nil || username == "" {
+		return nil, err
+	}
+	password, err := s.read(host + ".password")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credential{Username: username, Password: password}, nil
+}
+
+// read returns the credential with the given name, without a trailing
+// newline, or "" if there is no such credential.
+func (s *SystemdCredentials) read(name string) (string, error) {
+	if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid credential name: %q", name)
+	}
+
+	dir := s.Directory
+	if dir == "" {
+		dir = os.Getenv("CREDENTIALS_DIRECTORY")
+	}
+	if dir != "" {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return strings.TrimRight(string(data), "\r\n"), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	if s.EncryptedDirectory == "" {
+		return "", nil
+	}
+	path := filepath.Join(s.EncryptedDirectory, name+".cred")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", nil
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("systemd-creds", "decrypt", "--name="+name, path, "-")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("error decrypting credential %s: %s: %s",
+			name, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
@@ -0,0 +1,109 @@
+package getter
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSystemdCredentials(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	files := map[string]string{
+		"a.example.com.token":    "abc\n",
+		"b.example.com.username": "user",
+		"b.example.com.password": "pass\n",
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0600); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	cases := []struct {
+		Host   string
+		Output *Credential
+	}{
+		{"a.example.com", &Credential{Token: "abc"}},
+		{"b.example.com", &Credential{Username: "user", Password: "pass"}},
+		{"c.example.com", nil},
+	}
+
+	// The directory defaults to the one systemd gives the service
+	defer os.Setenv("CREDENTIALS_DIRECTORY", os.Getenv("CREDENTIALS_DIRECTORY"))
+	os.Setenv("CREDENTIALS_DIRECTORY", dir)
+
+	for _, s := range []*SystemdCredentials{{Directory: dir}, {}} {
+		for _, tc := range cases {
+			actual, err := s.Credential(tc.Host)
+			if err != nil {
+				t.Fatalf("%s: err: %s", tc.Host, err)
+			}
+			if !reflect.DeepEqual(actual, tc.Output) {
+				t.Fatalf("%s: bad: %#v", tc.Host, actual)
+			}
+		}
+	}
+
+	if _, err := new(SystemdCredentials).Credential("../etc"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestHttpGetter_credentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer abc":
+			w.Write([]byte("token\n"))
+		case "Basic dXNlcjpwYXNz":
+			w.Write([]byte("basic\n"))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL + "/file")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	creds := testCredentials{u.Hostname(): {Token: "abc"}}
+	g := new(HttpGetter)
+	g.client = &Client{Credentials: creds}
+	if err := g.GetFile(filepath.Join(dst, "a"), u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "a"), "token\n")
+
+	// Credentials in the URL are used instead
+	withUser := *u
+	withUser.User = url.UserPassword("user", "pass")
+	if err := g.GetFile(filepath.Join(dst, "b"), &withUser); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "b"), "basic\n")
+
+	// So are Authorization headers
+	g.Header = http.Header{"Authorization": []string{"Bearer nope"}}
+	if err := g.GetFile(filepath.Join(dst, "c"), u); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+// testCredentials is a CredentialProvider of fixed credentials by host.
+type testCredentials map[string]*Credential
+
+func (c testCredentials) Credential(host string) (*Credential, error) {
+	return c[host], nil
+}
@@ -1,6 +1,7 @@
 package getter
 
 import (
+	"io"
 	"strings"
 )
 
@@ -8,8 +9,8 @@ import (
 // support for decompressing a type.
 //
 // Important: if you're implementing a decompressor, please use the
-// containsDotDot helper in this file to ensure that files can't be
-// decompressed outside of the specified directory.
+// entryPath helper, or at least containsDotDot in this file, to ensure that
+// files can't be decompressed outside of the specified directory.
 type Decompressor interface {
 	// Decompress should decompress src to dst. dir specifies whether dst
 	// is a directory or single file. src is guaranteed to be a single file
@@ -17,29 +18,60 @@ type Decompressor interface {
 	Decompress(dst, src string, dir bool) error
 }
 
+// StreamDecompressor is an optional interface implemented by decompressors
+// that can decompress an archive as it is read, without it having to be
+// written to disk first. See Strategy for when it is used.
+type StreamDecompressor interface {
+	// DecompressReader is the same as Decompress, except the archive is
+	// read from src rather than from a file.
+	DecompressReader(dst string, src io.Reader, dir bool) error
+}
+
 // Decompressors is the mapping of extension to the Decompressor implementation
 // that will decompress that extension/type.
+//
+// Modifying the map directly affects every Client that doesn't set its own
+// Decompressors. Use DefaultDecompressors to change them for a single
+// client.
 var Decompressors map[string]Decompressor
 
 func init() {
 	tbzDecompressor := new(TarBzip2Decompressor)
 	tgzDecompressor := new(TarGzipDecompressor)
 	txzDecompressor := new(TarXzDecompressor)
+	tzstDecompressor := new(TarZstdDecompressor)
 
 	Decompressors = map[string]Decompressor{
+		"br":      new(BrotliDecompressor),
 		"bz2":     new(Bzip2Decompressor),
 		"gz":      new(GzipDecompressor),
 		"xz":      new(XzDecompressor),
+		"zst":     new(ZstdDecompressor),
+		"tar.br":  new(TarBrotliDecompressor),
 		"tar.bz2": tbzDecompressor,
 		"tar.gz":  tgzDecompressor,
 		"tar.xz":  txzDecompressor,
+		"tar.zst": tzstDecompressor,
 		"tbz2":    tbzDecompressor,
 		"tgz":     tgzDecompressor,
 		"txz":     txzDecompressor,
+		"tzst":    tzstDecompressor,
 		"zip":     new(ZipDecompressor),
 	}
 }
 
+// DefaultDecompressors returns a copy of Decompressors, which can be
+// modified and used as the Decompressors of a Client without affecting any
+// other client.
+func DefaultDecompressors() map[string]Decompressor {
+	result := make(map[string]Decompressor, len(Decompressors))
+	for k, v := range Decompressors {
+		result[k] = v
+	}
+
+	return result
+}
+
 // containsDotDot checks if the filepath value v contains a ".." entry.
 // This will check filepath components by splitting along / or \. This
 // function is copied directly from the Go net/http implementation.
@@ -0,0 +1,71 @@
+package getter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/andybalholm/brotli"
+)
+
+// BrotliDecompressor is an implementation of Decompressor that can
+// decompress Brotli files.
+type BrotliDecompressor struct {
+	// Limits, if set, are limits on what is extracted from the archive. If
+	// it is nil, a Client downloading with the decompressor uses its own
+	// DecompressLimits.
+	Limits *DecompressLimits
+}
+
+func (d *BrotliDecompressor) Describe() Description {
+	return Description{
+		Summary: "A brotli compressed file.",
+	}
+}
+
+func (d *BrotliDecompressor) limits() *DecompressLimits {
+	return d.Limits
+}
+
+func (d *BrotliDecompressor) withLimits(limits *DecompressLimits) Decompressor {
+	c := *d
+	c.Limits = limits
+	return &c
+}
+
+func (d *BrotliDecompressor) Decompress(dst, src string, dir bool) error {
+	// File first
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return d.DecompressReader(dst, f, dir)
+}
+
+func (d *BrotliDecompressor) DecompressReader(dst string, src io.Reader, dir bool) error {
+	// Directory isn't supported at all
+	if dir {
+		return fmt.Errorf("brotli-compressed files can only unarchive to a single file")
+	}
+
+	// If we're going into a directory we should make that first
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	// Brotli compression is second
+	brotliR := brotli.NewReader(src)
+
+	// Copy it out
+	dstF, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer dstF.Close()
+
+	_, err = (&extractLimiter{limits: d.Limits}).copy(dstF, brotliR, "")
+	return err
+}
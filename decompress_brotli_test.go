@@ -0,0 +1,34 @@
+package getter
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBrotliDecompressor(t *testing.T) {
+	cases := []TestDecompressCase{
+		{
+			"single.br",
+			false,
+			false,
+			nil,
+			"d3b07384d113edec49eaa6238ad5ff00",
+			nil,
+		},
+
+		{
+			"single.br",
+			true,
+			true,
+			nil,
+			"",
+			nil,
+		},
+	}
+
+	for i, tc := range cases {
+		cases[i].Input = filepath.Join("./test-fixtures", "decompress-br", tc.Input)
+	}
+
+	TestDecompressor(t, new(BrotliDecompressor), cases)
+}
@@ -10,9 +10,41 @@ import (
 
 // Bzip2Decompressor is an implementation of Decompressor that can
 // decompress bz2 files.
-type Bzip2Decompressor struct{}
+type Bzip2Decompressor struct {
+	// Limits, if set, are limits on what is extracted from the archive. If
+	// it is nil, a Client downloading with the decompressor uses its own
+	// DecompressLimits.
+	Limits *DecompressLimits
+}
+
+func (d *Bzip2Decompressor) Describe() Description {
+	return Description{
+		Summary: "A bzip2 compressed file.",
+	}
+}
+
+func (d *Bzip2Decompressor) limits() *DecompressLimits {
+	return d.Limits
+}
+
+func (d *Bzip2Decompressor) withLimits(limits *DecompressLimits) Decompressor {
+	c := *d
+	c.Limits = limits
+	return &c
+}
 
 func (d *Bzip2Decompressor) Decompress(dst, src string, dir bool) error {
+	// File first
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return d.DecompressReader(dst, f, dir)
+}
+
+func (d *Bzip2Decompressor) DecompressReader(dst string, src io.Reader, dir bool) error {
 	// Directory isn't supported at all
 	if dir {
 		return fmt.Errorf("bzip2-compressed files can only unarchive to a single file")
@@ -23,15 +55,8 @@ func (d *Bzip2Decompressor) Decompress(dst, src string, dir bool) error {
 		return err
 	}
 
-	// File first
-	f, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
 	// Bzip2 compression is second
-	bzipR := bzip2.NewReader(f)
+	bzipR := bzip2.NewReader(src)
 
 	// Copy it out
 	dstF, err := os.Create(dst)
@@ -40,6 +65,6 @@ func (d *Bzip2Decompressor) Decompress(dst, src string, dir bool) error {
 	}
 	defer dstF.Close()
 
-	_, err = io.Copy(dstF, bzipR)
+	_, err = (&extractLimiter{limits: d.Limits}).copy(dstF, bzipR, "")
 	return err
 }
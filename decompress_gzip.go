@@ -10,9 +10,41 @@ import (
 
 // GzipDecompressor is an implementation of Decompressor that can
 // decompress gzip files.
-type GzipDecompressor struct{}
+type GzipDecompressor struct {
+	// Limits, if set, are limits on what is extracted from the archive. If
+	// it is nil, a Client downloading with the decompressor uses its own
+	// DecompressLimits.
+	Limits *DecompressLimits
+}
+
+func (d *GzipDecompressor) Describe() Description {
+	return Description{
+		Summary: "A gzip compressed file.",
+	}
+}
+
+func (d *GzipDecompressor) limits() *DecompressLimits {
+	return d.Limits
+}
+
+func (d *GzipDecompressor) withLimits(limits *DecompressLimits) Decompressor {
+	c := *d
+	c.Limits = limits
+	return &c
+}
 
 func (d *GzipDecompressor) Decompress(dst, src string, dir bool) error {
+	// File first
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return d.DecompressReader(dst, f, dir)
+}
+
+func (d *GzipDecompressor) DecompressReader(dst string, src io.Reader, dir bool) error {
 	// Directory isn't supported at all
 	if dir {
 		return fmt.Errorf("gzip-compressed files can only unarchive to a single file")
@@ -23,15 +55,8 @@ func (d *GzipDecompressor) Decompress(dst, src string, dir bool) error {
 		return err
 	}
 
-	// File first
-	f, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
 	// gzip compression is second
-	gzipR, err := gzip.NewReader(f)
+	gzipR, err := gzip.NewReader(src)
 	if err != nil {
 		return err
 	}
@@ -44,6 +69,6 @@ func (d *GzipDecompressor) Decompress(dst, src string, dir bool) error {
 	}
 	defer dstF.Close()
 
-	_, err = io.Copy(dstF, gzipR)
+	_, err = (&extractLimiter{limits: d.Limits}).copy(dstF, gzipR, "")
 	return err
 }
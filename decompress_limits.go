@@ -0,0 +1,147 @@
+package getter
+
+import (
+	"io"
+	"strings"
+)
+
+// DecompressLimits are limits on what a decompressor extracts from an
+// archive, so that an untrusted archive, such as a zip bomb that expands to
+// far more than its own size, can't fill the disk or take forever to
+// extract. A limit that is zero isn't enforced. An archive that exceeds a
+// limit is an error, a *LimitExceededError.
+type DecompressLimits struct {
+	// MaxBytes is the most bytes that may be extracted altogether.
+	MaxBytes int64
+
+	// MaxFiles is the most entries that may be extracted, counting
+	// directories and links as well as files.
+	MaxFiles int
+
+	// MaxFileSize is the most bytes that may be extracted to any one file.
+	MaxFileSize int64
+
+	// MaxDepth is the most directories that an entry's path may be nested
+	// in, so that an entry at the root of the archive is 0 deep, and
+	// "a/b/c" is 2. It is only the depth of the path: an archive inside
+	// the archive is extracted as a file like any other, not unarchived,
+	// so there is no depth of nested archives to limit.
+	MaxDepth int
+}
+
+// limitsDecompressor is implemented by the decompressors that enforce
+// DecompressLimits, so that the client's can be applied to them.
+type limitsDecompressor interface {
+	limits() *DecompressLimits
+	withLimits(*DecompressLimits) Decompressor
+}
+
+// withLimits returns d enforcing limits, unless it doesn't support limits
+// or already has its own.
+func withLimits(d Decompressor, limits *DecompressLimits) Decompressor {
+	ld, ok := d.(limitsDecompressor)
+	if !ok || limits == nil || ld.limits() != nil {
+		return d
+	}
+
+	return ld.withLimits(limits)
+}
+
+// decompressorLimits returns the limits d enforces, or nil if there are
+// none.
+func decompressorLimits(d Decompressor) *DecompressLimits {
+	if ld, ok := d.(limitsDecompressor); ok {
+		return ld.limits()
+	}
+	return nil
+}
+
+// extractLimiter enforces DecompressLimits on the entries extracted from an
+// archive, keeping count of what has been extracted so far. A nil limits
+// enforces nothing.
+type extractLimiter struct {
+	limits *DecompressLimits
+	bytes  int64
+	files  int
+}
+
+// entry counts the entry called name, checking that there aren't too many
+// of them and that it isn't nested too deeply.
+func (l *extractLimiter) entry(name string) error {
+	if l.limits == nil {
+		return nil
+	}
+
+	l.files++
+	if max := l.limits.MaxFiles; max > 0 && l.files > max {
+		return &LimitExceededError{Limit: "MaxFiles", Max: int64(max), Entry: name}
+	}
+	if max := l.limits.MaxDepth; max > 0 && entryDepth(name) > max {
+		return &LimitExceededError{Limit: "MaxDepth", Max: int64(max), Entry: name}
+	}
+
+	return nil
+}
+
+// copy copies the contents of the entry called name from src to dst. See
+// reader.
+func (l *extractLimiter) copy(dst io.Writer, src io.Reader, name string) (int64, error) {
+	return io.Copy(dst, l.reader(src, name))
+}
+
+// reader returns src as the contents of the entry called name, failing
+// once they exceed MaxFileSize or the rest of MaxBytes.
+func (l *extractLimiter) reader(src io.Reader, name string) io.Reader {
+	if l.limits == nil || (l.limits.MaxBytes <= 0 && l.limits.MaxFileSize <= 0) {
+		return src
+	}
+
+	r := &limitedEntryReader{
+		r:         src,
+		limiter:   l,
+		remaining: l.limits.MaxFileSize,
+		err:       &LimitExceededError{Limit: "MaxFileSize", Max: l.limits.MaxFileSize, Entry: name},
+	}
+	if max := l.limits.MaxBytes; max > 0 && (r.remaining <= 0 || max-l.bytes < r.remaining) {
+		r.remaining = max - l.bytes
+		r.err = &LimitExceededError{Limit: "MaxBytes", Max: max, Entry: name}
+	}
+	return r
+}
+
+// limitedEntryReader reads the contents of an entry until there have been
+// more than remaining bytes, and then fails with err.
+type limitedEntryReader struct {
+	r         io.Reader
+	limiter   *extractLimiter
+	remaining int64
+	err       error
+}
+
+func (r *limitedEntryReader) Read(p []byte) (int, error) {
+	// One byte more than is allowed is read to tell whether there is more
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.r.Read(p)
+	r.remaining -= int64(n)
+	r.limiter.bytes += int64(n)
+	if r.remaining < 0 {
+		return n - 1, r.err
+	}
+	return n, err
+}
+
+// entryDepth returns how many directories the archive entry name is in.
+func entryDepth(name string) int {
+	var depth int
+	for _, ent := range strings.FieldsFunc(name, isSlashRune) {
+		if ent != "." {
+			depth++
+		}
+	}
+	if depth == 0 {
+		return 0
+	}
+	return depth - 1
+}
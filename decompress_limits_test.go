@@ -0,0 +1,224 @@
+package getter
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testLimitsEntries are the entries of the archives that limits are tested
+// against: 4 entries of 15 bytes altogether, nested 2 deep.
+var testLimitsEntries = []struct {
+	Name string
+	Body string
+}{
+	{"dir/", ""},
+	{"dir/a", "hello"},
+	{"dir/sub/b", "world"},
+	{"c", "12345"},
+}
+
+var testLimitsCases = []struct {
+	Name   string
+	Limits DecompressLimits
+	Limit  string
+	Entry  string
+}{
+	{"none", DecompressLimits{}, "", ""},
+	{"fits", DecompressLimits{MaxBytes: 15, MaxFiles: 4, MaxFileSize: 5, MaxDepth: 2}, "", ""},
+	{"bytes", DecompressLimits{MaxBytes: 12}, "MaxBytes", "c"},
+	{"bytes before size", DecompressLimits{MaxBytes: 7, MaxFileSize: 5}, "MaxBytes", "dir/sub/b"},
+	{"files", DecompressLimits{MaxFiles: 3}, "MaxFiles", "c"},
+	{"file size", DecompressLimits{MaxFileSize: 4}, "MaxFileSize", "dir/a"},
+	{"depth", DecompressLimits{MaxDepth: 1}, "MaxDepth", "dir/sub/b"},
+}
+
+func TestDecompressLimits_tar(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range testLimitsEntries {
+		hdr := &tar.Header{Name: e.Name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(e.Body))}
+		if e.Body == "" {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Mode = 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if _, err := tw.Write([]byte(e.Body)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, tc := range testLimitsCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			td := tempDir(t)
+			defer os.RemoveAll(td)
+
+			limits := tc.Limits
+			d := &TarDecompressor{Limits: &limits}
+			testLimitsError(t, d.DecompressReader(td, bytes.NewReader(buf.Bytes()), true), tc.Limit, tc.Entry)
+		})
+	}
+}
+
+func TestDecompressLimits_zip(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	if err := os.MkdirAll(td, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range testLimitsEntries {
+		w, err := zw.Create(e.Name)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if _, err := w.Write([]byte(e.Body)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	archive := filepath.Join(td, "archive.zip")
+	if err := ioutil.WriteFile(archive, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, tc := range testLimitsCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			limits := tc.Limits
+			d := &ZipDecompressor{Limits: &limits}
+			err := d.Decompress(filepath.Join(td, tc.Name), archive, true)
+			testLimitsError(t, err, tc.Limit, tc.Entry)
+		})
+	}
+}
+
+func TestDecompressLimits_gzip(t *testing.T) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write(bytes.Repeat([]byte{0}, 1<<20))
+	gw.Close()
+
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	// A megabyte of zeros compresses to a kilobyte or so
+	d := &GzipDecompressor{Limits: &DecompressLimits{MaxFileSize: 1 << 19}}
+	err := d.DecompressReader(filepath.Join(td, "zeros"), bytes.NewReader(buf.Bytes()), false)
+	testLimitsError(t, err, "MaxFileSize", "")
+
+	d = &GzipDecompressor{Limits: &DecompressLimits{MaxBytes: 1 << 20}}
+	err = d.DecompressReader(filepath.Join(td, "zeros"), bytes.NewReader(buf.Bytes()), false)
+	testLimitsError(t, err, "", "")
+}
+
+func TestClient_DecompressLimits(t *testing.T) {
+	limits := &DecompressLimits{MaxFileSize: 3}
+	src, err := filepath.Abs(filepath.Join(fixtureDir, "archive.tar.gz"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+	client := &Client{
+		Src:              src,
+		Dst:              dst,
+		Mode:             ClientModeDir,
+		DecompressLimits: limits,
+	}
+	testLimitsError(t, client.Get(), "MaxFileSize", "main.tf")
+
+	// Unpacking into memory is limited the same way
+	_, err = (&Client{DecompressLimits: limits}).GetMemory(src)
+	testLimitsError(t, err, "MaxFileSize", "main.tf")
+
+	// A decompressor's own limits take priority
+	decompressors := DefaultDecompressors()
+	decompressors["tar.gz"] = &TarGzipDecompressor{Limits: &DecompressLimits{MaxFiles: 1}}
+	client = &Client{
+		Src:              src,
+		Dst:              tempDir(t),
+		Mode:             ClientModeDir,
+		Decompressors:    decompressors,
+		DecompressLimits: limits,
+	}
+	defer os.RemoveAll(client.Dst)
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(client.Dst, "main.tf"), "foo\n")
+}
+
+func TestClient_DecompressLimitsRedirect(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	// The archive that X-Terraform-Get refers to is limited too, though
+	// the error is of the BatchError of its sources
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+	client := &Client{
+		Src:              "http://" + ln.Addr().String() + "/checksum-header",
+		Dst:              dst,
+		Mode:             ClientModeDir,
+		DecompressLimits: &DecompressLimits{MaxFileSize: 3},
+	}
+	err := client.Get()
+	if err == nil || !strings.Contains(err.Error(), "exceeds the MaxFileSize limit of 3 at entry: file") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+// testLimitsError fails the test unless err is a *LimitExceededError for
+// the given limit and entry, or nil if limit is "".
+func testLimitsError(t *testing.T, err error, limit, entry string) {
+	t.Helper()
+
+	if limit == "" {
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		return
+	}
+
+	var limitErr *LimitExceededError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("should be a LimitExceededError: %v", err)
+	}
+	if limitErr.Limit != limit || limitErr.Entry != entry {
+		t.Fatalf("bad: %#v", limitErr)
+	}
+}
+
+func TestEntryDepth(t *testing.T) {
+	cases := map[string]int{
+		"a":          0,
+		"dir/":       0,
+		"./a":        0,
+		"a/b/c":      2,
+		"a//b/./c/":  2,
+		"a.tar.gz":   0,
+		"d/a.tar.gz": 1,
+	}
+	for name, expected := range cases {
+		if actual := entryDepth(name); actual != expected {
+			t.Errorf("%q: expected %d, got %d", name, expected, actual)
+		}
+	}
+}
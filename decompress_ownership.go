@@ -0,0 +1,112 @@
+package getter
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// OwnershipMode is how the owners of files extracted from tar archives are
+// chosen. See Ownership.
+type OwnershipMode uint
+
+const (
+	// OwnershipNone leaves files owned by the user running the process.
+	// This is the default.
+	OwnershipNone OwnershipMode = iota
+
+	// OwnershipArchive gives files the user and group recorded in the
+	// archive.
+	OwnershipArchive
+
+	// OwnershipMap maps the user and group recorded in the archive through
+	// Ownership's UIDs and GIDs, falling back to its UID and GID for IDs
+	// that aren't in them.
+	OwnershipMap
+
+	// OwnershipUser gives every file Ownership's UID and GID.
+	OwnershipUser
+
+	// OwnershipInvoker gives every file the user and group that invoked
+	// sudo, from the SUDO_UID and SUDO_GID environment variables. Files
+	// are left owned by the user running the process if it wasn't
+	// started with sudo.
+	OwnershipInvoker
+)
+
+// Ownership says who owns the files and directories extracted from tar
+// archives. Changing owners generally requires running as root, such as
+// in a provisioner, and isn't supported on Windows. Directories that
+// aren't in the archive but are created to hold its files are always
+// owned by the user running the process.
+type Ownership struct {
+	// Mode is how owners are chosen.
+	Mode OwnershipMode
+
+	// UID and GID are the owner of every file with OwnershipUser, and of
+	// files whose archive owner isn't mapped with OwnershipMap.
+	UID int
+	GID int
+
+	// UIDs and GIDs map the user and group IDs recorded in the archive to
+	// those of the extracted files for OwnershipMap.
+	UIDs map[int]int
+	GIDs map[int]int
+}
+
+// chown changes the owner of the file at path, extracted from the entry
+// with the given header, as the ownership says. A nil Ownership is the
+// same as OwnershipNone.
+func (o *Ownership) chown(path string, hdr *tar.Header) error {
+	if o == nil {
+		return nil
+	}
+
+	var uid, gid int
+	switch o.Mode {
+	case OwnershipNone:
+		return nil
+	case OwnershipArchive:
+		uid, gid = hdr.Uid, hdr.Gid
+	case OwnershipMap:
+		var ok bool
+		if uid, ok = o.UIDs[hdr.Uid]; !ok {
+			uid = o.UID
+		}
+		if gid, ok = o.GIDs[hdr.Gid]; !ok {
+			gid = o.GID
+		}
+	case OwnershipUser:
+		uid, gid = o.UID, o.GID
+	case OwnershipInvoker:
+		var ok bool
+		uid, gid, ok = sudoInvoker()
+		if !ok {
+			return nil
+		}
+	default:
+		return fmt.Errorf("unknown ownership mode: %d", o.Mode)
+	}
+
+	if err := os.Lchown(path, uid, gid); err != nil {
+		return fmt.Errorf("error changing the owner of %s: %s", hdr.Name, err)
+	}
+
+	return nil
+}
+
+// sudoInvoker returns the user and group that invoked sudo. It returns
+// false if the process wasn't started by sudo.
+func sudoInvoker() (int, int, bool) {
+	uid, err := strconv.Atoi(os.Getenv("SUDO_UID"))
+	if err != nil {
+		return 0, 0, false
+	}
+	gid, err := strconv.Atoi(os.Getenv("SUDO_GID"))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return uid, gid, true
+}
@@ -0,0 +1,90 @@
+// +build !windows
+
+package getter
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestOwnership(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("changing owners requires root")
+	}
+
+	// An archive of a directory and a file owned by 1000:1000 and
+	// 1001:1002 respectively
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range []*tar.Header{
+		{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755, Uid: 1000, Gid: 1000},
+		{Name: "dir/file", Typeflag: tar.TypeReg, Mode: 0644, Uid: 1001, Gid: 1002},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		Name      string
+		Ownership *Ownership
+		SudoUID   string
+		Dir, File [2]int
+	}{
+		{"nil", nil, "", [2]int{0, 0}, [2]int{0, 0}},
+		{"none", &Ownership{Mode: OwnershipNone}, "", [2]int{0, 0}, [2]int{0, 0}},
+		{"archive", &Ownership{Mode: OwnershipArchive}, "", [2]int{1000, 1000}, [2]int{1001, 1002}},
+		{
+			"map",
+			&Ownership{
+				Mode: OwnershipMap,
+				UID:  2000,
+				GID:  2000,
+				UIDs: map[int]int{1001: 3001},
+				GIDs: map[int]int{1000: 3000},
+			},
+			"",
+			[2]int{2000, 3000},
+			[2]int{3001, 2000},
+		},
+		{"user", &Ownership{Mode: OwnershipUser, UID: 4000, GID: 4001}, "", [2]int{4000, 4001}, [2]int{4000, 4001}},
+		{"invoker", &Ownership{Mode: OwnershipInvoker}, "5000", [2]int{5000, 5001}, [2]int{5000, 5001}},
+		{"invoker without sudo", &Ownership{Mode: OwnershipInvoker}, "", [2]int{0, 0}, [2]int{0, 0}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			defer tempEnv(t, "SUDO_UID", tc.SudoUID)()
+			defer tempEnv(t, "SUDO_GID", "5001")()
+
+			td := tempDir(t)
+			defer os.RemoveAll(td)
+
+			d := &TarDecompressor{Ownership: tc.Ownership}
+			if err := d.DecompressReader(td, bytes.NewReader(buf.Bytes()), true); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			assertOwner(t, filepath.Join(td, "dir"), tc.Dir)
+			assertOwner(t, filepath.Join(td, "dir", "file"), tc.File)
+		})
+	}
+}
+
+func assertOwner(t *testing.T, path string, expected [2]int) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	st := fi.Sys().(*syscall.Stat_t)
+	if actual := [2]int{int(st.Uid), int(st.Gid)}; actual != expected {
+		t.Fatalf("bad owner of %s: %v", path, actual)
+	}
+}
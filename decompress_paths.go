@@ -0,0 +1,130 @@
+package getter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// entryPath returns the path in the directory dst that the archive entry
+// called name is extracted to. Unless insecure is true, entries with
+// absolute paths and those inside a symlinked directory are rejected, so
+// that nothing can be written outside of dst.
+func entryPath(dst, name string, insecure bool) (string, error) {
+	// Disallow parent traversal
+	if containsDotDot(name) {
+		return "", fmt.Errorf("entry contains '..': %s", name)
+	}
+
+	path := filepath.Join(dst, name)
+	if insecure {
+		return path, nil
+	}
+
+	if isAbsEntry(name) {
+		return "", fmt.Errorf("entry has an absolute path: %s", name)
+	}
+
+	// Symlinks extracted earlier are checked to stay inside dst, but only
+	// from where they are, so nothing may be extracted through one.
+	rel, err := filepath.Rel(dst, filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+	dir := dst
+	for _, ent := range strings.FieldsFunc(rel, isSlashRune) {
+		if ent == "." {
+			continue
+		}
+		dir = filepath.Join(dir, ent)
+
+		fi, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("entry is inside a symlink: %s", name)
+		}
+	}
+
+	return path, nil
+}
+
+// isAbsEntry returns true if the archive entry name v is an absolute path
+// on any platform, since archives can be made anywhere.
+func isAbsEntry(v string) bool {
+	if filepath.IsAbs(v) || filepath.VolumeName(v) != "" {
+		return true
+	}
+	if len(v) > 1 && v[1] == ':' {
+		// A Windows drive, which filepath only knows about on Windows
+		return true
+	}
+
+	return v != "" && isSlashRune(rune(v[0]))
+}
+
+// symlinkInside returns true if target, the target of a symlink extracted
+// from an archive entry called name, resolves to a path inside the archive.
+// Targets may only climb out of the link's directory with leading ".."
+// components, since a ".." after any other component might be following
+// another symlink.
+func symlinkInside(name, target string) bool {
+	if target == "" || isAbsEntry(target) {
+		return false
+	}
+
+	depth := -1
+	for _, ent := range strings.FieldsFunc(name, isSlashRune) {
+		if ent != "." {
+			depth++
+		}
+	}
+
+	climbing := true
+	for _, ent := range strings.FieldsFunc(target, isSlashRune) {
+		switch ent {
+		case ".":
+		case "..":
+			if !climbing || depth == 0 {
+				return false
+			}
+			depth--
+		default:
+			climbing = false
+		}
+	}
+
+	return true
+}
+
+// extractSymlink creates the symlink to target extracted from the archive
+// entry called name at path, replacing anything already there. Unless
+// insecure is true, it is an error for the target to be outside of the
+// archive.
+func extractSymlink(path, name, target string, insecure bool) error {
+	if !insecure && !symlinkInside(name, target) {
+		return fmt.Errorf("entry is a symlink to outside of the archive: %s -> %s", name, target)
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.Symlink(target, path)
+}
+
+// removeSymlink removes path if it is a symlink, so that a file extracted
+// there replaces the link rather than being written through it.
+func removeSymlink(path string) error {
+	fi, err := os.Lstat(path)
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,217 @@
+// +build !windows
+
+package getter
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSymlinkInside(t *testing.T) {
+	cases := []struct {
+		Name   string
+		Target string
+		Inside bool
+	}{
+		{"a", "b", true},
+		{"a", "./b/c", true},
+		{"dir/a", "../b", true},
+		{"./dir/a", "../b", true},
+		{"dir/sub/a", "../../b", true},
+		{"a", "../b", false},
+		{"dir/a", "../../b", false},
+		{"dir/a", "b/../../c", false},
+		{"dir/a", "b/../c", false},
+		{"a", "/etc/passwd", false},
+		{"a", `\etc\passwd`, false},
+		{"a", `C:\Windows`, false},
+		{"a", "", false},
+	}
+
+	for _, tc := range cases {
+		if actual := symlinkInside(tc.Name, tc.Target); actual != tc.Inside {
+			t.Fatalf("%s -> %s: bad: %v", tc.Name, tc.Target, actual)
+		}
+	}
+}
+
+func TestTar_symlink(t *testing.T) {
+	archive := testTar(t, []*tar.Header{
+		{Name: "dir/a", Typeflag: tar.TypeReg, Mode: 0644, Size: 5},
+		{Name: "b", Typeflag: tar.TypeSymlink, Linkname: "dir/a"},
+		{Name: "dir/c", Typeflag: tar.TypeSymlink, Linkname: "../b"},
+	})
+
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	if err := untar(bytes.NewReader(archive), td, "test", true, nil, nil, false, SymlinksPreserve, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertSymlink(t, filepath.Join(td, "b"), "dir/a")
+	assertSymlink(t, filepath.Join(td, "dir", "c"), "../b")
+	assertContents(t, filepath.Join(td, "dir", "c"), "hello")
+
+	// Extracting again replaces the links rather than failing
+	if err := untar(bytes.NewReader(archive), td, "test", true, nil, nil, false, SymlinksPreserve, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertSymlink(t, filepath.Join(td, "b"), "dir/a")
+}
+
+func TestTar_unsafe(t *testing.T) {
+	cases := []struct {
+		Name    string
+		Headers []*tar.Header
+		Err     string
+	}{
+		{
+			"absolute path",
+			[]*tar.Header{{Name: "/a", Typeflag: tar.TypeReg, Mode: 0644}},
+			"absolute path",
+		},
+		{
+			"absolute symlink",
+			[]*tar.Header{{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}},
+			"outside of the archive",
+		},
+		{
+			"escaping symlink",
+			[]*tar.Header{{Name: "dir/a", Typeflag: tar.TypeSymlink, Linkname: "../../secret"}},
+			"outside of the archive",
+		},
+		{
+			"through a symlink",
+			[]*tar.Header{
+				{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "."},
+				{Name: "a/b", Typeflag: tar.TypeSymlink, Linkname: "../secret"},
+			},
+			"inside a symlink",
+		},
+		{
+			"absolute hardlink",
+			[]*tar.Header{{Name: "a", Typeflag: tar.TypeLink, Linkname: "/etc/passwd"}},
+			"absolute path",
+		},
+	}
+
+	for _, tc := range cases {
+		td := tempDir(t)
+		defer os.RemoveAll(td)
+
+		archive := testTar(t, tc.Headers)
+		err := untar(bytes.NewReader(archive), td, "test", true, nil, nil, false, SymlinksPreserve, nil)
+		if err == nil || !strings.Contains(err.Error(), tc.Err) {
+			t.Fatalf("%s: bad: %v", tc.Name, err)
+		}
+	}
+}
+
+func TestTar_insecure(t *testing.T) {
+	archive := testTar(t, []*tar.Header{
+		{Name: "/a", Typeflag: tar.TypeReg, Mode: 0644, Size: 5},
+		{Name: "b", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+		{Name: "../c", Typeflag: tar.TypeReg, Mode: 0644, Size: 5},
+	})
+
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	err := untar(bytes.NewReader(archive), td, "test", true, nil, nil, true, SymlinksPreserve, nil)
+	if err == nil || !strings.Contains(err.Error(), "'..'") {
+		t.Fatalf("bad: %v", err)
+	}
+	assertContents(t, filepath.Join(td, "a"), "hello")
+	assertSymlink(t, filepath.Join(td, "b"), "/etc/passwd")
+}
+
+func TestZip_symlink(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct {
+		Name, Contents string
+		Mode           os.FileMode
+	}{
+		{"dir/a", "hello", 0644},
+		{"b", "dir/a", os.ModeSymlink | 0777},
+		{"c", "../secret", os.ModeSymlink | 0777},
+	} {
+		hdr := &zip.FileHeader{Name: f.Name}
+		hdr.SetMode(f.Mode)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.Contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	if err := os.MkdirAll(td, 0755); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(td, "archive.zip")
+	if err := ioutil.WriteFile(src, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The escaping symlink is rejected
+	dst := filepath.Join(td, "out")
+	err := new(ZipDecompressor).Decompress(dst, src, true)
+	if err == nil || !strings.Contains(err.Error(), "outside of the archive") {
+		t.Fatalf("bad: %v", err)
+	}
+	assertSymlink(t, filepath.Join(dst, "b"), "dir/a")
+	assertContents(t, filepath.Join(dst, "b"), "hello")
+
+	// Unless the decompressor is insecure
+	dst = filepath.Join(td, "insecure")
+	if err := (&ZipDecompressor{Insecure: true}).Decompress(dst, src, true); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertSymlink(t, filepath.Join(dst, "c"), "../secret")
+}
+
+// testTar returns a tar archive of the given headers, where every regular
+// file contains "hello".
+func testTar(t *testing.T, hdrs []*tar.Header) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range hdrs {
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = 5
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte("hello")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func assertSymlink(t *testing.T, path, target string) {
+	actual, err := os.Readlink(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual != target {
+		t.Fatalf("bad %s: %s", path, actual)
+	}
+}
@@ -0,0 +1,68 @@
+package getter
+
+import (
+	"archive/tar"
+	"os"
+)
+
+// TarPreserve says which of the attributes recorded in a tar archive are
+// given to the files and directories extracted from it, for build tools
+// that depend on executable bits and modification times surviving a
+// download. A nil TarPreserve keeps modes and modification times, as the
+// tar decompressors always have.
+type TarPreserve struct {
+	// Modes, if true, gives extracted files the modes in the archive,
+	// including their executable bits. Otherwise files and directories are
+	// created with the default modes, less the umask.
+	Modes bool
+
+	// Times, if true, gives extracted files the modification and access
+	// times in the archive. Otherwise they keep the time they were
+	// extracted at.
+	Times bool
+
+	// Owners, if true, gives extracted files the user and group in the
+	// archive when running as root, as OwnershipArchive does. It is ignored
+	// when not running as root, so that the same settings can be used
+	// either way, and when the decompressor's Ownership is set.
+	Owners bool
+}
+
+// defaultTarPreserve is what a nil TarPreserve keeps.
+var defaultTarPreserve = &TarPreserve{Modes: true, Times: true}
+
+// apply gives the file at path, extracted from the entry with the given
+// header, the attributes that p keeps and the owners that owner says.
+func (p *TarPreserve) apply(path string, hdr *tar.Header, owner *Ownership) error {
+	if p == nil {
+		p = defaultTarPreserve
+	}
+	if owner == nil && p.Owners && os.Geteuid() == 0 {
+		owner = &Ownership{Mode: OwnershipArchive}
+	}
+
+	// Owners are changed first, since changing them clears the setuid and
+	// setgid bits
+	if err := owner.chown(path, hdr); err != nil {
+		return err
+	}
+
+	if p.Modes {
+		if err := os.Chmod(path, hdr.FileInfo().Mode()); err != nil {
+			return err
+		}
+	}
+
+	if p.Times {
+		// Archives in the ustar format don't record access times
+		atime := hdr.AccessTime
+		if atime.IsZero() {
+			atime = hdr.ModTime
+		}
+		if err := os.Chtimes(path, atime, hdr.ModTime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+// +build !windows
+
+package getter
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTarPreserve(t *testing.T) {
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+
+	// An archive of an executable in a directory, both modified in 2001
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range []*tar.Header{
+		{Name: "bin/", Typeflag: tar.TypeDir, Mode: 0750, ModTime: mtime},
+		{Name: "bin/tool", Typeflag: tar.TypeReg, Mode: 0751, ModTime: mtime},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		Name     string
+		Preserve *TarPreserve
+		Modes    bool
+		Times    bool
+	}{
+		{"nil", nil, true, true},
+		{"none", &TarPreserve{}, false, false},
+		{"modes", &TarPreserve{Modes: true}, true, false},
+		{"times", &TarPreserve{Times: true}, false, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			td := tempDir(t)
+			defer os.RemoveAll(td)
+
+			d := &TarDecompressor{Preserve: tc.Preserve}
+			if err := d.DecompressReader(td, bytes.NewReader(buf.Bytes()), true); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			for _, name := range []string{"bin", "bin/tool"} {
+				fi, err := os.Stat(filepath.Join(td, name))
+				if err != nil {
+					t.Fatalf("err: %s", err)
+				}
+
+				if mode := fi.Mode().Perm(); (mode == 0750 || mode == 0751) != tc.Modes {
+					t.Fatalf("%s: bad mode: %s", name, fi.Mode())
+				}
+				if fi.ModTime().Equal(mtime) != tc.Times {
+					t.Fatalf("%s: bad mtime: %s", name, fi.ModTime())
+				}
+			}
+		})
+	}
+}
@@ -9,9 +9,15 @@ import (
 )
 
 // untar is a shared helper for untarring an archive. The reader should provide
-// an uncompressed view of the tar archive.
-func untar(input io.Reader, dst, src string, dir bool) error {
+// an uncompressed view of the tar archive. Extracted files are given owners
+// as owner says and the attributes that preserve keeps, and symlinks are
+// handled as symlinks says. If insecure is true, entries that could escape
+// dst are extracted anyway; see entryPath. Nothing more is extracted than
+// limits allow.
+func untar(input io.Reader, dst, src string, dir bool, owner *Ownership, preserve *TarPreserve, insecure bool, symlinks SymlinkPolicy, limits *DecompressLimits) error {
 	tarR := tar.NewReader(input)
+	links := &archiveSymlinks{dst: dst, policy: symlinks, insecure: insecure}
+	limiter := &extractLimiter{limits: limits}
 	done := false
 	dirHdrs := []*tar.Header{}
 	for {
@@ -32,15 +38,16 @@ func untar(input io.Reader, dst, src string, dir bool) error {
 			// don't unpack extended headers as files
 			continue
 		}
+		if err := limiter.entry(hdr.Name); err != nil {
+			return err
+		}
 
 		path := dst
 		if dir {
-			// Disallow parent traversal
-			if containsDotDot(hdr.Name) {
-				return fmt.Errorf("entry contains '..': %s", hdr.Name)
+			path, err = entryPath(dst, hdr.Name, insecure)
+			if err != nil {
+				return err
 			}
-
-			path = filepath.Join(path, hdr.Name)
 		}
 
 		if hdr.FileInfo().IsDir() {
@@ -79,37 +86,67 @@ func untar(input io.Reader, dst, src string, dir bool) error {
 		// Mark that we're done so future in single file mode errors
 		done = true
 
+		if hdr.Typeflag == tar.TypeLink {
+			// A hardlink to a file extracted earlier
+			if !dir {
+				return fmt.Errorf("expected a single file, got a hardlink: %s", src)
+			}
+			if containsDotDot(hdr.Linkname) {
+				return fmt.Errorf("entry links to a path containing '..': %s", hdr.Name)
+			}
+			if !insecure && isAbsEntry(hdr.Linkname) {
+				return fmt.Errorf("entry links to an absolute path: %s", hdr.Name)
+			}
+
+			if err := linkFile(path, filepath.Join(dst, hdr.Linkname)); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if hdr.Typeflag == tar.TypeSymlink {
+			if !dir {
+				return fmt.Errorf("expected a single file, got a symlink: %s", src)
+			}
+			if err := links.extract(path, hdr.Name, hdr.Linkname); err != nil {
+				return err
+			}
+
+			continue
+		}
+
 		// Open the file for writing
+		if err := removeSymlink(path); err != nil {
+			return err
+		}
 		dstF, err := os.Create(path)
 		if err != nil {
 			return err
 		}
-		_, err = io.Copy(dstF, tarR)
+		_, err = limiter.copy(dstF, tarR, hdr.Name)
 		dstF.Close()
 		if err != nil {
 			return err
 		}
 
-		// Chmod the file
-		if err := os.Chmod(path, hdr.FileInfo().Mode()); err != nil {
+		// Set the owner, mode and access and modification times
+		if err := preserve.apply(path, hdr, owner); err != nil {
 			return err
 		}
+	}
 
-		// Set the access and modification time
-		if err := os.Chtimes(path, hdr.AccessTime, hdr.ModTime); err != nil {
-			return err
-		}
+	if err := links.finish(); err != nil {
+		return err
 	}
 
 	// Perform a final pass over extracted directories to update metadata
 	for _, dirHdr := range dirHdrs {
 		path := filepath.Join(dst, dirHdr.Name)
-		// Chmod the directory since they might be created before we know the mode flags
-		if err := os.Chmod(path, dirHdr.FileInfo().Mode()); err != nil {
-			return err
-		}
-		// Set the mtime/atime attributes since they would have been changed during extraction
-		if err := os.Chtimes(path, dirHdr.AccessTime, dirHdr.ModTime); err != nil {
+		// Set the mode since they might be created before we know the mode
+		// flags, and the times since they would have been changed during
+		// extraction
+		if err := preserve.apply(path, dirHdr, owner); err != nil {
 			return err
 		}
 	}
@@ -117,20 +154,59 @@ func untar(input io.Reader, dst, src string, dir bool) error {
 	return nil
 }
 
-// tarDecompressor is an implementation of Decompressor that can
+// TarDecompressor is an implementation of Decompressor that can
 // unpack tar files.
-type tarDecompressor struct{}
+type TarDecompressor struct {
+	// Ownership, if set, says who owns the extracted files.
+	Ownership *Ownership
+
+	// Preserve says which of the modes, times and owners in the archive the
+	// extracted files are given. If nil, modes and times are kept.
+	Preserve *TarPreserve
+
+	// Insecure, if true, extracts entries with absolute paths relative to
+	// the destination and symlinks wherever they point, rather than
+	// rejecting entries that could escape the destination.
+	Insecure bool
+
+	// Symlinks is what is done with symlinks in the archive. If it is left
+	// as SymlinksDefault, a Client downloading with the decompressor uses
+	// its own Symlinks.
+	Symlinks SymlinkPolicy
+
+	// Limits, if set, are limits on what is extracted from the archive. If
+	// it is nil, a Client downloading with the decompressor uses its own
+	// DecompressLimits.
+	Limits *DecompressLimits
+}
 
-func (d *tarDecompressor) Decompress(dst, src string, dir bool) error {
-	// If we're going into a directory we should make that first
-	mkdir := dst
-	if !dir {
-		mkdir = filepath.Dir(dst)
+func (d *TarDecompressor) Describe() Description {
+	return Description{
+		Summary: "A tar archive.",
 	}
-	if err := os.MkdirAll(mkdir, 0755); err != nil {
-		return err
+}
+
+func (d *TarDecompressor) withSymlinks(policy SymlinkPolicy) Decompressor {
+	if d.Symlinks != SymlinksDefault {
+		return d
 	}
 
+	c := *d
+	c.Symlinks = policy
+	return &c
+}
+
+func (d *TarDecompressor) limits() *DecompressLimits {
+	return d.Limits
+}
+
+func (d *TarDecompressor) withLimits(limits *DecompressLimits) Decompressor {
+	c := *d
+	c.Limits = limits
+	return &c
+}
+
+func (d *TarDecompressor) Decompress(dst, src string, dir bool) error {
 	// File first
 	f, err := os.Open(src)
 	if err != nil {
@@ -138,5 +214,24 @@ func (d *tarDecompressor) Decompress(dst, src string, dir bool) error {
 	}
 	defer f.Close()
 
-	return untar(f, dst, src, dir)
+	return d.decompress(dst, f, src, dir)
+}
+
+func (d *TarDecompressor) DecompressReader(dst string, src io.Reader, dir bool) error {
+	return d.decompress(dst, src, "stream", dir)
+}
+
+// decompress unpacks the archive read from input, which is named name in
+// any errors.
+func (d *TarDecompressor) decompress(dst string, input io.Reader, name string, dir bool) error {
+	// If we're going into a directory we should make that first
+	mkdir := dst
+	if !dir {
+		mkdir = filepath.Dir(dst)
+	}
+	if err := os.MkdirAll(mkdir, 0755); err != nil {
+		return err
+	}
+
+	return untar(input, dst, name, dir, d.Ownership, d.Preserve, d.Insecure, d.Symlinks, d.Limits)
 }
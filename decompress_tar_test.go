@@ -39,5 +39,5 @@ func TestTar(t *testing.T) {
 		cases[i].Input = filepath.Join("./test-fixtures", "decompress-tar", tc.Input)
 	}
 
-	TestDecompressor(t, new(tarDecompressor), cases)
+	TestDecompressor(t, new(TarDecompressor), cases)
 }
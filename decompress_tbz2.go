@@ -2,24 +2,64 @@ package getter
 
 import (
 	"compress/bzip2"
+	"io"
 	"os"
 	"path/filepath"
 )
 
 // TarBzip2Decompressor is an implementation of Decompressor that can
 // decompress tar.bz2 files.
-type TarBzip2Decompressor struct{}
+type TarBzip2Decompressor struct {
+	// Ownership, if set, says who owns the extracted files.
+	Ownership *Ownership
 
-func (d *TarBzip2Decompressor) Decompress(dst, src string, dir bool) error {
-	// If we're going into a directory we should make that first
-	mkdir := dst
-	if !dir {
-		mkdir = filepath.Dir(dst)
+	// Preserve says which of the modes, times and owners in the archive the
+	// extracted files are given. If nil, modes and times are kept.
+	Preserve *TarPreserve
+
+	// Insecure, if true, extracts entries with absolute paths relative to
+	// the destination and symlinks wherever they point, rather than
+	// rejecting entries that could escape the destination.
+	Insecure bool
+
+	// Symlinks is what is done with symlinks in the archive. If it is left
+	// as SymlinksDefault, a Client downloading with the decompressor uses
+	// its own Symlinks.
+	Symlinks SymlinkPolicy
+
+	// Limits, if set, are limits on what is extracted from the archive. If
+	// it is nil, a Client downloading with the decompressor uses its own
+	// DecompressLimits.
+	Limits *DecompressLimits
+}
+
+func (d *TarBzip2Decompressor) Describe() Description {
+	return Description{
+		Summary: "A bzip2 compressed tar archive.",
 	}
-	if err := os.MkdirAll(mkdir, 0755); err != nil {
-		return err
+}
+
+func (d *TarBzip2Decompressor) withSymlinks(policy SymlinkPolicy) Decompressor {
+	if d.Symlinks != SymlinksDefault {
+		return d
 	}
 
+	c := *d
+	c.Symlinks = policy
+	return &c
+}
+
+func (d *TarBzip2Decompressor) limits() *DecompressLimits {
+	return d.Limits
+}
+
+func (d *TarBzip2Decompressor) withLimits(limits *DecompressLimits) Decompressor {
+	c := *d
+	c.Limits = limits
+	return &c
+}
+
+func (d *TarBzip2Decompressor) Decompress(dst, src string, dir bool) error {
 	// File first
 	f, err := os.Open(src)
 	if err != nil {
@@ -27,7 +67,26 @@ func (d *TarBzip2Decompressor) Decompress(dst, src string, dir bool) error {
 	}
 	defer f.Close()
 
+	return d.decompress(dst, f, src, dir)
+}
+
+func (d *TarBzip2Decompressor) DecompressReader(dst string, src io.Reader, dir bool) error {
+	return d.decompress(dst, src, "stream", dir)
+}
+
+// decompress unpacks the archive read from input, which is named name in any
+// errors.
+func (d *TarBzip2Decompressor) decompress(dst string, input io.Reader, name string, dir bool) error {
+	// If we're going into a directory we should make that first
+	mkdir := dst
+	if !dir {
+		mkdir = filepath.Dir(dst)
+	}
+	if err := os.MkdirAll(mkdir, 0755); err != nil {
+		return err
+	}
+
 	// Bzip2 compression is second
-	bzipR := bzip2.NewReader(f)
-	return untar(bzipR, dst, src, dir)
+	bzipR := bzip2.NewReader(input)
+	return untar(bzipR, dst, name, dir, d.Ownership, d.Preserve, d.Insecure, d.Symlinks, d.Limits)
 }
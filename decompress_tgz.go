@@ -3,24 +3,64 @@ package getter
 import (
 	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 )
 
 // TarGzipDecompressor is an implementation of Decompressor that can
 // decompress tar.gzip files.
-type TarGzipDecompressor struct{}
+type TarGzipDecompressor struct {
+	// Ownership, if set, says who owns the extracted files.
+	Ownership *Ownership
 
-func (d *TarGzipDecompressor) Decompress(dst, src string, dir bool) error {
-	// If we're going into a directory we should make that first
-	mkdir := dst
-	if !dir {
-		mkdir = filepath.Dir(dst)
+	// Preserve says which of the modes, times and owners in the archive the
+	// extracted files are given. If nil, modes and times are kept.
+	Preserve *TarPreserve
+
+	// Insecure, if true, extracts entries with absolute paths relative to
+	// the destination and symlinks wherever they point, rather than
+	// rejecting entries that could escape the destination.
+	Insecure bool
+
+	// Symlinks is what is done with symlinks in the archive. If it is left
+	// as SymlinksDefault, a Client downloading with the decompressor uses
+	// its own Symlinks.
+	Symlinks SymlinkPolicy
+
+	// Limits, if set, are limits on what is extracted from the archive. If
+	// it is nil, a Client downloading with the decompressor uses its own
+	// DecompressLimits.
+	Limits *DecompressLimits
+}
+
+func (d *TarGzipDecompressor) Describe() Description {
+	return Description{
+		Summary: "A gzip compressed tar archive.",
 	}
-	if err := os.MkdirAll(mkdir, 0755); err != nil {
-		return err
+}
+
+func (d *TarGzipDecompressor) withSymlinks(policy SymlinkPolicy) Decompressor {
+	if d.Symlinks != SymlinksDefault {
+		return d
 	}
 
+	c := *d
+	c.Symlinks = policy
+	return &c
+}
+
+func (d *TarGzipDecompressor) limits() *DecompressLimits {
+	return d.Limits
+}
+
+func (d *TarGzipDecompressor) withLimits(limits *DecompressLimits) Decompressor {
+	c := *d
+	c.Limits = limits
+	return &c
+}
+
+func (d *TarGzipDecompressor) Decompress(dst, src string, dir bool) error {
 	// File first
 	f, err := os.Open(src)
 	if err != nil {
@@ -28,12 +68,31 @@ func (d *TarGzipDecompressor) Decompress(dst, src string, dir bool) error {
 	}
 	defer f.Close()
 
+	return d.decompress(dst, f, src, dir)
+}
+
+func (d *TarGzipDecompressor) DecompressReader(dst string, src io.Reader, dir bool) error {
+	return d.decompress(dst, src, "stream", dir)
+}
+
+// decompress unpacks the archive read from input, which is named name in any
+// errors.
+func (d *TarGzipDecompressor) decompress(dst string, input io.Reader, name string, dir bool) error {
+	// If we're going into a directory we should make that first
+	mkdir := dst
+	if !dir {
+		mkdir = filepath.Dir(dst)
+	}
+	if err := os.MkdirAll(mkdir, 0755); err != nil {
+		return err
+	}
+
 	// Gzip compression is second
-	gzipR, err := gzip.NewReader(f)
+	gzipR, err := gzip.NewReader(input)
 	if err != nil {
-		return fmt.Errorf("Error opening a gzip reader for %s: %s", src, err)
+		return fmt.Errorf("Error opening a gzip reader for %s: %s", name, err)
 	}
 	defer gzipR.Close()
 
-	return untar(gzipR, dst, src, dir)
+	return untar(gzipR, dst, name, dir, d.Ownership, d.Preserve, d.Insecure, d.Symlinks, d.Limits)
 }
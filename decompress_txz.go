@@ -2,6 +2,7 @@ package getter
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -10,18 +11,57 @@ import (
 
 // TarXzDecompressor is an implementation of Decompressor that can
 // decompress tar.xz files.
-type TarXzDecompressor struct{}
+type TarXzDecompressor struct {
+	// Ownership, if set, says who owns the extracted files.
+	Ownership *Ownership
 
-func (d *TarXzDecompressor) Decompress(dst, src string, dir bool) error {
-	// If we're going into a directory we should make that first
-	mkdir := dst
-	if !dir {
-		mkdir = filepath.Dir(dst)
+	// Preserve says which of the modes, times and owners in the archive the
+	// extracted files are given. If nil, modes and times are kept.
+	Preserve *TarPreserve
+
+	// Insecure, if true, extracts entries with absolute paths relative to
+	// the destination and symlinks wherever they point, rather than
+	// rejecting entries that could escape the destination.
+	Insecure bool
+
+	// Symlinks is what is done with symlinks in the archive. If it is left
+	// as SymlinksDefault, a Client downloading with the decompressor uses
+	// its own Symlinks.
+	Symlinks SymlinkPolicy
+
+	// Limits, if set, are limits on what is extracted from the archive. If
+	// it is nil, a Client downloading with the decompressor uses its own
+	// DecompressLimits.
+	Limits *DecompressLimits
+}
+
+func (d *TarXzDecompressor) Describe() Description {
+	return Description{
+		Summary: "An xz compressed tar archive.",
 	}
-	if err := os.MkdirAll(mkdir, 0755); err != nil {
-		return err
+}
+
+func (d *TarXzDecompressor) withSymlinks(policy SymlinkPolicy) Decompressor {
+	if d.Symlinks != SymlinksDefault {
+		return d
 	}
 
+	c := *d
+	c.Symlinks = policy
+	return &c
+}
+
+func (d *TarXzDecompressor) limits() *DecompressLimits {
+	return d.Limits
+}
+
+func (d *TarXzDecompressor) withLimits(limits *DecompressLimits) Decompressor {
+	c := *d
+	c.Limits = limits
+	return &c
+}
+
+func (d *TarXzDecompressor) Decompress(dst, src string, dir bool) error {
 	// File first
 	f, err := os.Open(src)
 	if err != nil {
@@ -29,11 +69,30 @@ func (d *TarXzDecompressor) Decompress(dst, src string, dir bool) error {
 	}
 	defer f.Close()
 
+	return d.decompress(dst, f, src, dir)
+}
+
+func (d *TarXzDecompressor) DecompressReader(dst string, src io.Reader, dir bool) error {
+	return d.decompress(dst, src, "stream", dir)
+}
+
+// decompress unpacks the archive read from input, which is named name in any
+// errors.
+func (d *TarXzDecompressor) decompress(dst string, input io.Reader, name string, dir bool) error {
+	// If we're going into a directory we should make that first
+	mkdir := dst
+	if !dir {
+		mkdir = filepath.Dir(dst)
+	}
+	if err := os.MkdirAll(mkdir, 0755); err != nil {
+		return err
+	}
+
 	// xz compression is second
-	txzR, err := xz.NewReader(f)
+	txzR, err := xz.NewReader(input)
 	if err != nil {
-		return fmt.Errorf("Error opening an xz reader for %s: %s", src, err)
+		return fmt.Errorf("Error opening an xz reader for %s: %s", name, err)
 	}
 
-	return untar(txzR, dst, src, dir)
+	return untar(txzR, dst, name, dir, d.Ownership, d.Preserve, d.Insecure, d.Symlinks, d.Limits)
 }
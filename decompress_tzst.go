@@ -0,0 +1,99 @@
+package getter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// TarZstdDecompressor is an implementation of Decompressor that can
+// decompress tar.zst files.
+type TarZstdDecompressor struct {
+	// Ownership, if set, says who owns the extracted files.
+	Ownership *Ownership
+
+	// Preserve says which of the modes, times and owners in the archive the
+	// extracted files are given. If nil, modes and times are kept.
+	Preserve *TarPreserve
+
+	// Insecure, if true, extracts entries with absolute paths relative to
+	// the destination and symlinks wherever they point, rather than
+	// rejecting entries that could escape the destination.
+	Insecure bool
+
+	// Symlinks is what is done with symlinks in the archive. If it is left
+	// as SymlinksDefault, a Client downloading with the decompressor uses
+	// its own Symlinks.
+	Symlinks SymlinkPolicy
+
+	// Limits, if set, are limits on what is extracted from the archive. If
+	// it is nil, a Client downloading with the decompressor uses its own
+	// DecompressLimits.
+	Limits *DecompressLimits
+}
+
+func (d *TarZstdDecompressor) Describe() Description {
+	return Description{
+		Summary: "A zstd compressed tar archive.",
+	}
+}
+
+func (d *TarZstdDecompressor) withSymlinks(policy SymlinkPolicy) Decompressor {
+	if d.Symlinks != SymlinksDefault {
+		return d
+	}
+
+	c := *d
+	c.Symlinks = policy
+	return &c
+}
+
+func (d *TarZstdDecompressor) limits() *DecompressLimits {
+	return d.Limits
+}
+
+func (d *TarZstdDecompressor) withLimits(limits *DecompressLimits) Decompressor {
+	c := *d
+	c.Limits = limits
+	return &c
+}
+
+func (d *TarZstdDecompressor) Decompress(dst, src string, dir bool) error {
+	// File first
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return d.decompress(dst, f, src, dir)
+}
+
+func (d *TarZstdDecompressor) DecompressReader(dst string, src io.Reader, dir bool) error {
+	return d.decompress(dst, src, "stream", dir)
+}
+
+// decompress unpacks the archive read from input, which is named name in any
+// errors.
+func (d *TarZstdDecompressor) decompress(dst string, input io.Reader, name string, dir bool) error {
+	// If we're going into a directory we should make that first
+	mkdir := dst
+	if !dir {
+		mkdir = filepath.Dir(dst)
+	}
+	if err := os.MkdirAll(mkdir, 0755); err != nil {
+		return err
+	}
+
+	// zstd compression is second
+	zstdR, err := zstd.NewReader(input)
+	if err != nil {
+		return fmt.Errorf("Error opening a zstd reader for %s: %s", name, err)
+	}
+	defer zstdR.Close()
+
+	return untar(zstdR, dst, name, dir, d.Ownership, d.Preserve, d.Insecure, d.Symlinks, d.Limits)
+}
@@ -11,9 +11,41 @@ import (
 
 // XzDecompressor is an implementation of Decompressor that can
 // decompress xz files.
-type XzDecompressor struct{}
+type XzDecompressor struct {
+	// Limits, if set, are limits on what is extracted from the archive. If
+	// it is nil, a Client downloading with the decompressor uses its own
+	// DecompressLimits.
+	Limits *DecompressLimits
+}
+
+func (d *XzDecompressor) Describe() Description {
+	return Description{
+		Summary: "An xz compressed file.",
+	}
+}
+
+func (d *XzDecompressor) limits() *DecompressLimits {
+	return d.Limits
+}
+
+func (d *XzDecompressor) withLimits(limits *DecompressLimits) Decompressor {
+	c := *d
+	c.Limits = limits
+	return &c
+}
 
 func (d *XzDecompressor) Decompress(dst, src string, dir bool) error {
+	// File first
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return d.DecompressReader(dst, f, dir)
+}
+
+func (d *XzDecompressor) DecompressReader(dst string, src io.Reader, dir bool) error {
 	// Directory isn't supported at all
 	if dir {
 		return fmt.Errorf("xz-compressed files can only unarchive to a single file")
@@ -24,15 +56,8 @@ func (d *XzDecompressor) Decompress(dst, src string, dir bool) error {
 		return err
 	}
 
-	// File first
-	f, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
 	// xz compression is second
-	xzR, err := xz.NewReader(f)
+	xzR, err := xz.NewReader(src)
 	if err != nil {
 		return err
 	}
@@ -44,6 +69,6 @@ func (d *XzDecompressor) Decompress(dst, src string, dir bool) error {
 	}
 	defer dstF.Close()
 
-	_, err = io.Copy(dstF, xzR)
+	_, err = (&extractLimiter{limits: d.Limits}).copy(dstF, xzR, "")
 	return err
 }
@@ -4,13 +4,57 @@ import (
 	"archive/zip"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 )
 
 // ZipDecompressor is an implementation of Decompressor that can
-// decompress tar.gzip files.
-type ZipDecompressor struct{}
+// decompress zip files. Entries are copied straight from the archive on
+// disk, so however large they are only the archive's central directory,
+// which lists its entries, is held in memory.
+type ZipDecompressor struct {
+	// Insecure, if true, extracts entries with absolute paths relative to
+	// the destination and symlinks wherever they point, rather than
+	// rejecting entries that could escape the destination.
+	Insecure bool
+
+	// Symlinks is what is done with symlinks in the archive. If it is left
+	// as SymlinksDefault, a Client downloading with the decompressor uses
+	// its own Symlinks.
+	Symlinks SymlinkPolicy
+
+	// Limits, if set, are limits on what is extracted from the archive. If
+	// it is nil, a Client downloading with the decompressor uses its own
+	// DecompressLimits.
+	Limits *DecompressLimits
+}
+
+func (d *ZipDecompressor) Describe() Description {
+	return Description{
+		Summary: "A zip archive.",
+	}
+}
+
+func (d *ZipDecompressor) withSymlinks(policy SymlinkPolicy) Decompressor {
+	if d.Symlinks != SymlinksDefault {
+		return d
+	}
+
+	c := *d
+	c.Symlinks = policy
+	return &c
+}
+
+func (d *ZipDecompressor) limits() *DecompressLimits {
+	return d.Limits
+}
+
+func (d *ZipDecompressor) withLimits(limits *DecompressLimits) Decompressor {
+	c := *d
+	c.Limits = limits
+	return &c
+}
 
 func (d *ZipDecompressor) Decompress(dst, src string, dir bool) error {
 	// If we're going into a directory we should make that first
@@ -39,15 +83,19 @@ func (d *ZipDecompressor) Decompress(dst, src string, dir bool) error {
 	}
 
 	// Go through and unarchive
+	links := &archiveSymlinks{dst: dst, policy: d.Symlinks, insecure: d.Insecure}
+	limiter := &extractLimiter{limits: d.Limits}
 	for _, f := range zipR.File {
+		if err := limiter.entry(f.Name); err != nil {
+			return err
+		}
+
 		path := dst
 		if dir {
-			// Disallow parent traversal
-			if containsDotDot(f.Name) {
-				return fmt.Errorf("entry contains '..': %s", f.Name)
+			path, err = entryPath(dst, f.Name, d.Insecure)
+			if err != nil {
+				return err
 			}
-
-			path = filepath.Join(path, f.Name)
 		}
 
 		if f.FileInfo().IsDir() {
@@ -78,13 +126,34 @@ func (d *ZipDecompressor) Decompress(dst, src string, dir bool) error {
 			return err
 		}
 
+		if f.Mode()&os.ModeSymlink != 0 {
+			// The target of a symlink is its contents
+			target, err := ioutil.ReadAll(io.LimitReader(srcF, 4096))
+			srcF.Close()
+			if err != nil {
+				return err
+			}
+			if !dir {
+				return fmt.Errorf("expected a single file, got a symlink: %s", src)
+			}
+			if err := links.extract(path, f.Name, string(target)); err != nil {
+				return err
+			}
+
+			continue
+		}
+
 		// Open the file for writing
+		if err := removeSymlink(path); err != nil {
+			srcF.Close()
+			return err
+		}
 		dstF, err := os.Create(path)
 		if err != nil {
 			srcF.Close()
 			return err
 		}
-		_, err = io.Copy(dstF, srcF)
+		_, err = limiter.copy(dstF, srcF, f.Name)
 		srcF.Close()
 		dstF.Close()
 		if err != nil {
@@ -97,5 +166,5 @@ func (d *ZipDecompressor) Decompress(dst, src string, dir bool) error {
 		}
 	}
 
-	return nil
+	return links.finish()
 }
@@ -0,0 +1,75 @@
+package getter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ZstdDecompressor is an implementation of Decompressor that can
+// decompress zstd files.
+type ZstdDecompressor struct {
+	// Limits, if set, are limits on what is extracted from the archive. If
+	// it is nil, a Client downloading with the decompressor uses its own
+	// DecompressLimits.
+	Limits *DecompressLimits
+}
+
+func (d *ZstdDecompressor) Describe() Description {
+	return Description{
+		Summary: "A zstd compressed file.",
+	}
+}
+
+func (d *ZstdDecompressor) limits() *DecompressLimits {
+	return d.Limits
+}
+
+func (d *ZstdDecompressor) withLimits(limits *DecompressLimits) Decompressor {
+	c := *d
+	c.Limits = limits
+	return &c
+}
+
+func (d *ZstdDecompressor) Decompress(dst, src string, dir bool) error {
+	// File first
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return d.DecompressReader(dst, f, dir)
+}
+
+func (d *ZstdDecompressor) DecompressReader(dst string, src io.Reader, dir bool) error {
+	// Directory isn't supported at all
+	if dir {
+		return fmt.Errorf("zstd-compressed files can only unarchive to a single file")
+	}
+
+	// If we're going into a directory we should make that first
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	// zstd compression is second
+	zstdR, err := zstd.NewReader(src)
+	if err != nil {
+		return err
+	}
+	defer zstdR.Close()
+
+	// Copy it out
+	dstF, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer dstF.Close()
+
+	_, err = (&extractLimiter{limits: d.Limits}).copy(dstF, zstdR, "")
+	return err
+}
@@ -3,6 +3,7 @@ package getter
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"github.com/hashicorp/go-getter/helper/url"
 )
@@ -18,6 +19,9 @@ type Detector interface {
 
 // Detectors is the list of detectors that are tried on an invalid URL.
 // This is also the order they're tried (index 0 is first).
+//
+// Modifying the list directly affects every Client that doesn't set its
+// own Detectors. Use DefaultDetectors to change them for a single client.
 var Detectors []Detector
 
 func init() {
@@ -29,6 +33,12 @@ func init() {
 	}
 }
 
+// DefaultDetectors returns a copy of Detectors, which can be modified and
+// used as the Detectors of a Client without affecting any other client.
+func DefaultDetectors() []Detector {
+	return append([]Detector(nil), Detectors...)
+}
+
 // Detect turns a source string into another source string if it is
 // detected to be of a known pattern.
 //
@@ -39,6 +49,13 @@ func init() {
 // This is safe to be called with an already valid source string: Detect
 // will just return it.
 func Detect(src string, pwd string, ds []Detector) (string, error) {
+	result, _, err := detect(src, pwd, ds)
+	return result, err
+}
+
+// detect is Detect, also returning the detector that matched src, or nil
+// if it didn't need detecting.
+func detect(src string, pwd string, ds []Detector) (string, Detector, error) {
 	getForce, getSrc := getForcedGetter(src)
 
 	// Separate out the subdir if there is one, we don't pass that to detect
@@ -47,13 +64,32 @@ func Detect(src string, pwd string, ds []Detector) (string, error) {
 	u, err := url.Parse(getSrc)
 	if err == nil && u.Scheme != "" {
 		// Valid URL
-		return src, nil
+		return src, nil, nil
+	}
+
+	// "-" is the client's Stdin, with any query parameters of its own.
+	if getSrc == "-" || strings.HasPrefix(getSrc, "-?") {
+		result := withSubdir("stdin://"+getSrc[1:], subDir)
+		if getForce != "" {
+			result = getForce + "::" + result
+		}
+		return result, nil, nil
+	}
+
+	// Releases of HashiCorp products are named rather than located, so
+	// aren't detected but made into hcrel URLs.
+	if getForce == "hcrel" {
+		result := "hcrel::hcrel://" + getSrc
+		if subDir != "" {
+			result += "//" + subDir
+		}
+		return result, nil, nil
 	}
 
 	for _, d := range ds {
 		result, ok, err := d.Detect(getSrc, pwd)
 		if err != nil {
-			return "", err
+			return "", nil, &DetectError{Source: redactURLCredentials(src), Err: err}
 		}
 		if !ok {
 			continue
@@ -76,7 +112,7 @@ func Detect(src string, pwd string, ds []Detector) (string, error) {
 		if subDir != "" {
 			u, err := url.Parse(result)
 			if err != nil {
-				return "", fmt.Errorf("Error parsing URL: %s", err)
+				return "", nil, fmt.Errorf("Error parsing URL: %s", err)
 			}
 			u.Path += "//" + subDir
 
@@ -96,8 +132,8 @@ func Detect(src string, pwd string, ds []Detector) (string, error) {
 			result = fmt.Sprintf("%s::%s", detectForce, result)
 		}
 
-		return result, nil
+		return result, d, nil
 	}
 
-	return "", fmt.Errorf("invalid source string: %s", src)
+	return "", nil, &DetectError{Source: redactURLCredentials(src)}
 }
@@ -12,6 +12,12 @@ import (
 // them into URLs that the Git or Hg Getter can understand.
 type BitBucketDetector struct{}
 
+func (d *BitBucketDetector) Describe() Description {
+	return Description{
+		Summary: "bitbucket.org/owner/repo, as a Git or Mercurial repository depending on what Bitbucket says it is.",
+	}
+}
+
 func (d *BitBucketDetector) Detect(src, _ string) (string, bool, error) {
 	if len(src) == 0 {
 		return "", false, nil
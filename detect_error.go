@@ -0,0 +1,30 @@
+package getter
+
+import (
+	"fmt"
+)
+
+// DetectError is returned by Detect when a source can't be turned into a
+// URL, either because no detector recognized it or because one of them
+// failed.
+type DetectError struct {
+	// Source is the source that was being detected, with any credentials
+	// in it redacted.
+	Source string
+
+	// Err is the error of the detector that failed, or nil if no detector
+	// recognized the source.
+	Err error
+}
+
+func (e *DetectError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("invalid source string: %s", e.Source)
+	}
+
+	return fmt.Sprintf("error detecting %s: %s", e.Source, e.Err)
+}
+
+func (e *DetectError) Unwrap() error {
+	return e.Err
+}
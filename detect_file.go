@@ -10,6 +10,12 @@ import (
 // FileDetector implements Detector to detect file paths.
 type FileDetector struct{}
 
+func (d *FileDetector) Describe() Description {
+	return Description{
+		Summary: "Local paths, which are relative to the client's Pwd unless they are absolute.",
+	}
+}
+
 func (d *FileDetector) Detect(src, pwd string) (string, bool, error) {
 	if len(src) == 0 {
 		return "", false, nil
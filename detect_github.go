@@ -10,6 +10,12 @@ import (
 // them into URLs that the Git Getter can understand.
 type GitHubDetector struct{}
 
+func (d *GitHubDetector) Describe() Description {
+	return Description{
+		Summary: "github.com/owner/repo and git@github.com:owner/repo, as Git repositories.",
+	}
+}
+
 func (d *GitHubDetector) Detect(src, _ string) (string, bool, error) {
 	if len(src) == 0 {
 		return "", false, nil
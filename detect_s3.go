@@ -10,6 +10,12 @@ import (
 // them into URLs that the S3 getter can understand.
 type S3Detector struct{}
 
+func (d *S3Detector) Describe() Description {
+	return Description{
+		Summary: "bucket.s3.amazonaws.com/key and s3.amazonaws.com/bucket/key, with regions, as S3 objects.",
+	}
+}
+
 func (d *S3Detector) Detect(src, _ string) (string, bool, error) {
 	if len(src) == 0 {
 		return "", false, nil
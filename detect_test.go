@@ -1,6 +1,7 @@
 package getter
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -43,6 +44,12 @@ func TestDetect(t *testing.T) {
 			"file:///bar/foo/archive//*",
 			false,
 		},
+		{
+			"hcrel::terraform/1.5.7/linux/amd64",
+			"/foo",
+			"hcrel::hcrel://terraform/1.5.7/linux/amd64",
+			false,
+		},
 	}
 
 	for i, tc := range cases {
@@ -55,3 +62,35 @@ func TestDetect(t *testing.T) {
 		}
 	}
 }
+
+func TestDetect_error(t *testing.T) {
+	_, err := Detect("nope", "", nil)
+	var detectErr *DetectError
+	if !errors.As(err, &detectErr) || detectErr.Source != "nope" || detectErr.Err != nil {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	// Detectors that fail are wrapped
+	_, err = Detect("./foo", "", Detectors)
+	if !errors.As(err, &detectErr) || detectErr.Err == nil {
+		t.Fatalf("bad: %#v", err)
+	}
+}
+
+func TestDefaultDetectors(t *testing.T) {
+	detectors := DefaultDetectors()
+	detectors[0] = new(FileDetector)
+	if _, ok := Detectors[0].(*GitHubDetector); !ok {
+		t.Fatal("Detectors should not be modified")
+	}
+
+	// A client can use the copy for itself
+	client := &Client{Pwd: "/pwd", Detectors: detectors}
+	scheme, _, _, err := client.Resolve("github.com/hashicorp/foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if scheme != "file" {
+		t.Fatalf("bad: %s", scheme)
+	}
+}
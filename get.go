@@ -13,10 +13,14 @@ package getter
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"io/fs"
 	"net/url"
 	"os/exec"
 	"regexp"
+	"sync"
 	"syscall"
 
 	cleanhttp "github.com/hashicorp/go-cleanhttp"
@@ -43,14 +47,73 @@ type Getter interface {
 	ClientMode(*url.URL) (ClientMode, error)
 }
 
+// StreamGetter is an optional interface implemented by getters that can
+// return the contents of a single file as a stream rather than writing it
+// to a path. It allows the client to verify and decompress a file as it
+// is downloaded. See Strategy for when it is used.
+type StreamGetter interface {
+	// GetReader opens the given URL, which must reference a single file,
+	// and returns its contents along with its size in bytes, or -1 if
+	// the size isn't known. The caller must close the reader.
+	GetReader(*url.URL) (io.ReadCloser, int64, error)
+}
+
+// FSGetter is an optional interface implemented by getters that can
+// expose a directory as a read-only file system without downloading all
+// of it, fetching files only as they are read. See Client.Open.
+type FSGetter interface {
+	// Open returns a file system of the directory at the given URL. If
+	// the file system implements io.Closer, it must be closed once it is
+	// no longer needed.
+	Open(*url.URL) (fs.FS, error)
+}
+
+// LocalGetter is an optional interface implemented by getters that can get
+// some sources without network access, such as from a local cache. An
+// offline Client only uses getters that implement it. See Client.Offline.
+type LocalGetter interface {
+	// Local reports whether the given URL can be got without network
+	// access.
+	Local(*url.URL) bool
+}
+
+// Putter is an optional interface implemented by getters that can also
+// upload a file to a URL they would download it from, so that artifacts can
+// be published with the same URLs and credentials. See Client.Put.
+type Putter interface {
+	// PutFile uploads the file at the given path to the given URL,
+	// replacing anything already there.
+	PutFile(*url.URL, string) error
+}
+
 // Getters is the mapping of scheme to the Getter implementation that will
 // be used to get a dependency.
+//
+// Modifying the map directly affects every Client that doesn't set its own
+// Getters and races with any downloads in progress. Use RegisterGetter to
+// add a getter for every client, or DefaultGetters to add one for a single
+// client.
 var Getters map[string]Getter
 
+// gettersLock guards the Getters variable. RegisterGetter replaces the map
+// rather than modifying it, so a map read under the lock can be used after
+// the lock is released.
+var gettersLock sync.RWMutex
+
 // forcedRegexp is the regular expression that finds forced getters. This
 // syntax is schema::url, example: git::https://foo.com
 var forcedRegexp = regexp.MustCompile(`^([A-Za-z0-9]+)::(.+)$`)
 
+// urlPasswordRegexp finds the password in URLs with userinfo, and
+// urlTokenRegexp finds the username of HTTP URLs without a password since
+// it is commonly an access token.
+var urlPasswordRegexp = regexp.MustCompile(`(://[^/@:\s]*):[^/@\s]*@`)
+var urlTokenRegexp = regexp.MustCompile(`(https?://)[^/@:\s]+@`)
+
+// DefaultUserAgent is the User-Agent header sent with HTTP requests when a
+// Client doesn't set its own.
+const DefaultUserAgent = "go-getter/1 (+https://github.com/hashicorp/go-getter)"
+
 // httpClient is the default client to be used by HttpGetters.
 var httpClient = cleanhttp.DefaultClient()
 
@@ -58,15 +121,59 @@ func init() {
 	httpGetter := &HttpGetter{
 		Netrc: true,
 	}
+	gcsGetter := new(GCSGetter)
 
 	Getters = map[string]Getter{
-		"file":  new(FileGetter),
-		"git":   new(GitGetter),
-		"hg":    new(HgGetter),
-		"s3":    new(S3Getter),
-		"http":  httpGetter,
-		"https": httpGetter,
+		"bundle":   new(BundleGetter),
+		"file":     new(FileGetter),
+		"gcs":      gcsGetter,
+		"git":      new(GitGetter),
+		"gs":       gcsGetter,
+		"hcrel":    new(HCReleasesGetter),
+		"hg":       new(HgGetter),
+		"manifest": new(ManifestGetter),
+		"oci":      new(OCIGetter),
+		"s3":       new(S3Getter),
+		"sftp":     new(SftpGetter),
+		"stdin":    new(StdinGetter),
+		"http":     httpGetter,
+		"https":    httpGetter,
+	}
+}
+
+// RegisterGetter registers g as the getter for the given scheme in
+// Getters, replacing any getter already registered for it. It is safe to
+// call while other clients are downloading.
+func RegisterGetter(scheme string, g Getter) {
+	gettersLock.Lock()
+	defer gettersLock.Unlock()
+
+	getters := make(map[string]Getter, len(Getters)+1)
+	for k, v := range Getters {
+		getters[k] = v
 	}
+	getters[scheme] = g
+	Getters = getters
+}
+
+// DefaultGetters returns a copy of Getters, which can be modified and used
+// as the Getters of a Client without affecting any other client.
+func DefaultGetters() map[string]Getter {
+	getters := defaultGetters()
+	result := make(map[string]Getter, len(getters))
+	for k, v := range getters {
+		result[k] = v
+	}
+
+	return result
+}
+
+// defaultGetters returns Getters, for clients that don't set their own.
+func defaultGetters() map[string]Getter {
+	gettersLock.RLock()
+	defer gettersLock.RUnlock()
+
+	return Getters
 }
 
 // Get downloads the directory specified by src into the folder specified by
@@ -79,7 +186,19 @@ func Get(dst, src string) error {
 		Src:     src,
 		Dst:     dst,
 		Dir:     true,
-		Getters: Getters,
+		Getters: defaultGetters(),
+	}).Get()
+}
+
+// GetWithContext is the same as Get, except that the download is aborted
+// once ctx is done.
+func GetWithContext(ctx context.Context, dst, src string) error {
+	return (&Client{
+		Ctx:     ctx,
+		Src:     src,
+		Dst:     dst,
+		Dir:     true,
+		Getters: defaultGetters(),
 	}).Get()
 }
 
@@ -94,7 +213,7 @@ func GetAny(dst, src string) error {
 		Src:     src,
 		Dst:     dst,
 		Mode:    ClientModeAny,
-		Getters: Getters,
+		Getters: defaultGetters(),
 	}).Get()
 }
 
@@ -105,12 +224,13 @@ func GetFile(dst, src string) error {
 		Src:     src,
 		Dst:     dst,
 		Dir:     false,
-		Getters: Getters,
+		Getters: defaultGetters(),
 	}).Get()
 }
 
 // getRunCommand is a helper that will run a command and capture the output
-// in the case an error happens.
+// in the case an error happens. Any credentials in URLs in the output are
+// redacted.
 func getRunCommand(cmd *exec.Cmd) error {
 	var buf bytes.Buffer
 	cmd.Stdout = &buf
@@ -119,6 +239,7 @@ func getRunCommand(cmd *exec.Cmd) error {
 	if err == nil {
 		return nil
 	}
+	output := redactURLCredentials(buf.String())
 	if exiterr, ok := err.(*exec.ExitError); ok {
 		// The program has exited with an exit code != 0
 		if status, ok := exiterr.Sys().(syscall.WaitStatus); ok {
@@ -126,11 +247,18 @@ func getRunCommand(cmd *exec.Cmd) error {
 				"%s exited with %d: %s",
 				cmd.Path,
 				status.ExitStatus(),
-				buf.String())
+				output)
 		}
 	}
 
-	return fmt.Errorf("error running %s: %s", cmd.Path, buf.String())
+	return fmt.Errorf("error running %s: %s: %s", cmd.Path, err, output)
+}
+
+// redactURLCredentials replaces passwords, and usernames that may be
+// tokens, in any URLs in s.
+func redactURLCredentials(s string) string {
+	s = urlPasswordRegexp.ReplaceAllString(s, "${1}:redacted@")
+	return urlTokenRegexp.ReplaceAllString(s, "${1}redacted@")
 }
 
 // getForcedGetter takes a source and returns the tuple of the forced
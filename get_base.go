@@ -0,0 +1,136 @@
+package getter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// getter is our base getter; it regroups fields all getters have in
+// common.
+type getter struct {
+	client *Client
+}
+
+// clientGetter is implemented by the getters of this package, which honor
+// the configuration of the Client using them. A getter may be shared by
+// many clients and downloads at once, so rather than being changed for
+// each of them, withClient returns a copy of it that uses c.
+type clientGetter interface {
+	withClient(c *Client) Getter
+}
+
+// getterForClient returns the getter that c uses for g: a copy of it using
+// c if it implements clientGetter, or else g itself. Types that embed one
+// of the getters get withClient from it, but the copy would be of the
+// embedded getter alone, so they are used as they are.
+func getterForClient(g Getter, c *Client) Getter {
+	cg, ok := g.(clientGetter)
+	if !ok {
+		return g
+	}
+
+	cp := cg.withClient(c)
+	if reflect.TypeOf(cp) != reflect.TypeOf(g) {
+		return g
+	}
+	return cp
+}
+
+// Context returns the context of the client using the getter, or
+// context.Background if there is no client or it has no context.
+func (g *getter) Context() context.Context {
+	if g.client == nil || g.client.Ctx == nil {
+		return context.Background()
+	}
+
+	return g.client.Ctx
+}
+
+// specialFiles returns the special file policy of the client using the
+// getter.
+func (g *getter) specialFiles() SpecialFilePolicy {
+	if g.client == nil {
+		return SpecialFilesSkip
+	}
+
+	return g.client.SpecialFiles
+}
+
+// symlinks returns the SymlinkPolicy of the client using the getter.
+func (g *getter) symlinks() SymlinkPolicy {
+	if g.client == nil {
+		return SymlinksDefault
+	}
+
+	return g.client.Symlinks
+}
+
+// decompressLimits returns the DecompressLimits of the client using the
+// getter, for getters that unarchive what they download themselves.
+func (g *getter) decompressLimits() *DecompressLimits {
+	if g.client == nil {
+		return nil
+	}
+
+	return g.client.DecompressLimits
+}
+
+// stopped returns why getters that download many files should stop: the
+// client's deadline has passed or its context is done. It returns nil if
+// they should carry on.
+func (g *getter) stopped() error {
+	if g.client != nil && g.client.deadlineExceeded() {
+		return errDeadlineExceeded
+	}
+
+	return g.Context().Err()
+}
+
+// offline returns whether the client using the getter forbids network
+// access.
+func (g *getter) offline() bool {
+	return g.client != nil && g.client.Offline
+}
+
+// header returns the headers to send with requests on behalf of the client
+// using the getter.
+func (g *getter) header() http.Header {
+	h := make(http.Header)
+	userAgent := DefaultUserAgent
+	if g.client != nil {
+		for k, vs := range g.client.Header {
+			for _, v := range vs {
+				h.Add(k, v)
+			}
+		}
+		if g.client.UserAgent != "" {
+			userAgent = g.client.UserAgent
+		}
+	}
+	h.Set("User-Agent", userAgent)
+
+	return h
+}
+
+// subClient returns a client that downloads src into dst on behalf of the
+// client using the getter, as Client.subClient does.
+func (g *getter) subClient(src, dst string, mode ClientMode) *Client {
+	return g.client.subClient(src, dst, mode)
+}
+
+// contextReader is a reader that fails once ctx is done, for copies that
+// wouldn't otherwise notice.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+}
@@ -0,0 +1,262 @@
+package getter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/go-safetemp"
+)
+
+// BundleGetter is a Getter implementation that downloads a SQLite database
+// or asset bundle of named blobs, and extracts each blob into the
+// destination directory as a file of the same name, for tooling that
+// stores assets this way.
+//
+// The bundle itself can be hosted with any supported protocol. It is
+// selected by forcing the getter, for example:
+// "bundle::https://example.com/assets.pak".
+//
+// The format of the bundle is given by the "format" query parameter, or
+// otherwise by the extension of the URL's path:
+//
+//   - "pak" (.pak) is the pak format used by Quake and the engines that
+//     followed it.
+//   - "sqlite" (.sqlite, .sqlite3 or .db) is a SQLite database, which is
+//     read without SQLite itself. Each row of a table is a blob, named by
+//     one column and with the contents of another. The "table",
+//     "name_column" and "data_column" query parameters choose them, and are
+//     "blobs", "name" and "data" by default.
+//
+// Blob names are paths relative to the destination, and may not climb out
+// of it. The client's DecompressLimits apply to blobs as they do to the
+// entries of archives. A single blob is downloaded as a file by naming it with the "blob"
+// query parameter, and then in the "any" mode it is named by the filename
+// parameter, like any other file.
+type BundleGetter struct {
+	getter
+}
+
+func (g *BundleGetter) withClient(c *Client) Getter {
+	cp := *g
+	cp.client = c
+	return &cp
+}
+
+// bundleParams are the query parameters of BundleGetter, which are removed
+// from the URL that the bundle is downloaded from.
+var bundleParams = []string{"format", "table", "name_column", "data_column", "blob"}
+
+func (g *BundleGetter) Describe() Description {
+	return Description{
+		Summary: "A pak file or SQLite database of named blobs, from any source, as a directory with a file for each blob.",
+		Options: []Option{
+			{"format", "pak or sqlite, instead of choosing by the extension of the URL."},
+			{"table", "The SQLite table with a row for each blob, blobs by default."},
+			{"name_column", "The column of the table with the name of each blob, name by default."},
+			{"data_column", "The column of the table with the contents of each blob, data by default."},
+			{"blob", "The name of a single blob to download as a file."},
+		},
+	}
+}
+
+func (g *BundleGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	if u.Query().Get("blob") != "" {
+		return ClientModeFile, nil
+	}
+	return ClientModeDir, nil
+}
+
+// Local implements LocalGetter. The bundle is got with a client that is
+// offline if this one is, so a bundle that can't be got locally is
+// reported then.
+func (g *BundleGetter) Local(*url.URL) bool {
+	return true
+}
+
+func (g *BundleGetter) Get(dst string, u *url.URL) error {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	// Blobs are extracted like the entries of an archive, so the limits
+	// on what is extracted from archives apply to them too
+	limiter := &extractLimiter{limits: g.decompressLimits()}
+	return g.blobs(u, func(name string, r io.Reader) error {
+		if err := g.stopped(); err != nil {
+			return err
+		}
+
+		path, err := entryPath(dst, name, false)
+		if err != nil {
+			return err
+		}
+		if err := limiter.entry(name); err != nil {
+			return err
+		}
+		return writeBlob(path, limiter.reader(r, name))
+	})
+}
+
+func (g *BundleGetter) GetFile(dst string, u *url.URL) error {
+	blob := u.Query().Get("blob")
+	if blob == "" {
+		return fmt.Errorf("bundle sources need a blob parameter to be downloaded as a file")
+	}
+
+	errFound := errors.New("found")
+	err := g.blobs(u, func(name string, r io.Reader) error {
+		if path.Clean(name) != path.Clean(blob) {
+			return nil
+		}
+		limiter := &extractLimiter{limits: g.decompressLimits()}
+		if err := writeBlob(dst, limiter.reader(r, name)); err != nil {
+			return err
+		}
+		return errFound
+	})
+	if err == errFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("bundle has no blob %s", blob)
+}
+
+// blobs downloads the bundle at u and calls fn with the name and contents
+// of each of its blobs, until fn returns an error.
+func (g *BundleGetter) blobs(u *url.URL, fn func(name string, r io.Reader) error) error {
+	q := u.Query()
+	format := q.Get("format")
+	if format == "" {
+		switch strings.ToLower(path.Ext(u.Path)) {
+		case ".pak":
+			format = "pak"
+		case ".sqlite", ".sqlite3", ".db":
+			format = "sqlite"
+		default:
+			return fmt.Errorf("bundle format of %s is unknown, set the format parameter",
+				redactURLCredentials(u.String()))
+		}
+	}
+	if format != "pak" && format != "sqlite" {
+		return fmt.Errorf("unsupported bundle format: %s", format)
+	}
+
+	// Download the bundle itself without our parameters
+	src := *u
+	for _, p := range bundleParams {
+		q.Del(p)
+	}
+	src.RawQuery = q.Encode()
+
+	td, tdcloser, err := safetemp.Dir("", "getter")
+	if err != nil {
+		return err
+	}
+	defer tdcloser.Close()
+
+	bundle := filepath.Join(td, "bundle")
+	if err := g.subClient(src.String(), bundle, ClientModeFile).Get(); err != nil {
+		if _, ok := err.(*OfflineError); ok {
+			return err
+		}
+		return fmt.Errorf("error downloading bundle: %s", err)
+	}
+
+	f, err := os.Open(bundle)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if format == "pak" {
+		return readPak(f, fi.Size(), fn)
+	}
+	return readSQLiteBlobs(f, fi.Size(), u.Query(), fn)
+}
+
+// readSQLiteBlobs calls fn with the name and contents of each row of the
+// table in the SQLite database r that the query parameters q choose.
+func readSQLiteBlobs(r io.ReaderAt, size int64, q url.Values, fn func(name string, r io.Reader) error) error {
+	param := func(name, def string) string {
+		if v := q.Get(name); v != "" {
+			return v
+		}
+		return def
+	}
+
+	db, err := openSQLite(r, size)
+	if err != nil {
+		return err
+	}
+	table, err := db.table(param("table", "blobs"))
+	if err != nil {
+		return err
+	}
+	nameCol, err := table.column(param("name_column", "name"))
+	if err != nil {
+		return err
+	}
+	dataCol, err := table.column(param("data_column", "data"))
+	if err != nil {
+		return err
+	}
+
+	return db.rows(table.root, func(rowid int64, values []interface{}) error {
+		var name string
+		switch v := table.value(rowid, values, nameCol).(type) {
+		case nil:
+			return fmt.Errorf("SQLite row %d has no name", rowid)
+		case []byte:
+			name = string(v)
+		default:
+			name = fmt.Sprint(v)
+		}
+
+		var data []byte
+		switch v := table.value(rowid, values, dataCol).(type) {
+		case nil:
+		case []byte:
+			data = v
+		case string:
+			data = []byte(v)
+		default:
+			data = []byte(fmt.Sprint(v))
+		}
+
+		return fn(name, bytes.NewReader(data))
+	})
+}
+
+// writeBlob writes the contents of r to a new file at path.
+func writeBlob(path string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := removeSymlink(path); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
@@ -0,0 +1,201 @@
+package getter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBundleGetter_impl(t *testing.T) {
+	var _ Getter = new(BundleGetter)
+}
+
+func TestBundleGetter_pak(t *testing.T) {
+	dst := filepath.Join(tempDir(t), "dst")
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	client := &Client{Src: "bundle::" + testModule("bundle/assets.pak"), Dst: dst, Dir: true}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "readme.txt"), "hello\n")
+	assertContents(t, filepath.Join(dst, "maps", "e1m1.bsp"), "map data\n")
+}
+
+func TestBundleGetter_sqlite(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	// The default table and columns
+	dst := filepath.Join(td, "blobs")
+	client := &Client{Src: "bundle::" + testModule("bundle/assets.sqlite"), Dst: dst, Dir: true}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "a.txt"), "a\n")
+	assertContents(t, filepath.Join(dst, "b", "c.txt"), "c\n")
+
+	// A table whose rows span several pages, and a blob that overflows
+	// its page
+	dst = filepath.Join(td, "assets")
+	client = &Client{
+		Src: "bundle::" + testModule("bundle/assets.sqlite") +
+			"?table=assets&name_column=path&data_column=content",
+		Dst: dst,
+		Dir: true,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "readme.txt"), "hello\n")
+	for _, i := range []int{0, 99, 199} {
+		assertContents(t, filepath.Join(dst, "levels", fmt.Sprintf("%03d.txt", i)), fmt.Sprintf("level %d\n", i))
+	}
+	big := make([]byte, 5000)
+	for i := range big {
+		big[i] = byte(i % 251)
+	}
+	assertContents(t, filepath.Join(dst, "textures", "big.bin"), string(big))
+
+	// The rowid is a column too
+	dst = filepath.Join(td, "ids")
+	client.Src = "bundle::" + testModule("bundle/assets.sqlite") +
+		"?table=assets&name_column=id&data_column=path"
+	client.Dst = dst
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "1"), "readme.txt")
+
+	// A missing column
+	client.Src = "bundle::" + testModule("bundle/assets.sqlite") + "?name_column=missing"
+	client.Dst = filepath.Join(td, "missing")
+	if err := client.Get(); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestBundleGetter_blob(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	dst := filepath.Join(td, "e1m1.bsp")
+	client := &Client{
+		Src:  "bundle::" + testModule("bundle/assets.pak") + "?blob=maps/e1m1.bsp",
+		Dst:  dst,
+		Mode: ClientModeFile,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "map data\n")
+
+	client.Src = "bundle::" + testModule("bundle/assets.pak") + "?blob=missing"
+	if err := client.Get(); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestBundleGetter_limits(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	client := &Client{
+		Src:              "bundle::" + testModule("bundle/assets.pak"),
+		Dst:              filepath.Join(td, "files"),
+		Dir:              true,
+		DecompressLimits: &DecompressLimits{MaxFiles: 1},
+	}
+	testLimitsError(t, client.Get(), "MaxFiles", "maps/e1m1.bsp")
+
+	client.Dst = filepath.Join(td, "size")
+	client.DecompressLimits = &DecompressLimits{MaxFileSize: 4}
+	testLimitsError(t, client.Get(), "MaxFileSize", "readme.txt")
+}
+
+func TestSQLite_cycle(t *testing.T) {
+	// A database whose table's root page is an interior page with both of
+	// its children, as well as its right-most child, pointing back at it
+	data := make([]byte, 2*512)
+	copy(data, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(data[16:], 512)
+	binary.BigEndian.PutUint32(data[56:], 1)
+	page := data[512:]
+	page[0] = 0x05
+	binary.BigEndian.PutUint16(page[3:], 2)
+	binary.BigEndian.PutUint32(page[8:], 2)
+	binary.BigEndian.PutUint16(page[12:], 100)
+	binary.BigEndian.PutUint16(page[14:], 100)
+	binary.BigEndian.PutUint32(page[100:], 2)
+	page[104] = 1
+
+	db, err := openSQLite(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err = db.rows(2, func(int64, []interface{}) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "more than once") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestReadPak_escape(t *testing.T) {
+	// A pak file with an entry that climbs out of the destination
+	var buf bytes.Buffer
+	buf.WriteString("PACK\x0d\x00\x00\x00\x40\x00\x00\x00x")
+	name := make([]byte, 56)
+	copy(name, "../x")
+	buf.Write(name)
+	buf.Write([]byte{12, 0, 0, 0, 1, 0, 0, 0})
+
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	if err := os.MkdirAll(td, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	pak := filepath.Join(td, "escape.pak")
+	if err := ioutil.WriteFile(pak, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	client := &Client{Src: "bundle::" + pak, Dst: filepath.Join(td, "dst"), Dir: true}
+	if err := client.Get(); err == nil {
+		t.Fatal("should error")
+	}
+	if _, err := os.Stat(filepath.Join(td, "x")); !os.IsNotExist(err) {
+		t.Fatalf("entry escaped: %v", err)
+	}
+}
+
+func TestSQLiteColumns(t *testing.T) {
+	cases := []struct {
+		SQL     string
+		Columns []string
+		Rowid   int
+	}{
+		{"CREATE TABLE blobs (name TEXT, data BLOB)", []string{"name", "data"}, -1},
+		{
+			`CREATE TABLE "Assets" (id INTEGER PRIMARY KEY, "path" TEXT NOT NULL, content BLOB, UNIQUE(path))`,
+			[]string{"id", "path", "content"},
+			0,
+		},
+		{
+			"CREATE TABLE t ([a b] TEXT DEFAULT 'x,y', `c` NUMERIC(10, 2), CONSTRAINT k PRIMARY KEY (c))",
+			[]string{"a b", "c"},
+			-1,
+		},
+	}
+
+	for _, tc := range cases {
+		columns, rowid := sqliteColumns(tc.SQL)
+		if !reflect.DeepEqual(columns, tc.Columns) || rowid != tc.Rowid {
+			t.Fatalf("%s: bad: %#v %d", tc.SQL, columns, rowid)
+		}
+	}
+}
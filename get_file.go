@@ -1,15 +1,55 @@
 package getter
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"net/url"
 	"os"
+	"path/filepath"
+	"syscall"
 )
 
 // FileGetter is a Getter implementation that will download a module from
 // a file scheme.
 type FileGetter struct {
+	getter
+
 	// Copy, if set to true, will copy data instead of using a symlink
 	Copy bool
+
+	// The following options are for sources on network filesystems such
+	// as NFS, which some of the optimizations used for local files don't
+	// work well with.
+	//
+	// BufferSize, if non-zero, is the size in bytes of the reads that
+	// files are copied with, which can be set to the filesystem's rsize.
+	//
+	// DisableKernelCopy, if true, copies files by reading and writing
+	// them rather than letting the kernel copy them with copy_file_range
+	// or sendfile. Files are never memory mapped either way.
+	//
+	// StaleRetries is how many times to retry looking up, opening and
+	// copying a file that fails with ESTALE, which NFS clients return
+	// when a file is replaced on the server while it is in use. A copy is
+	// started again from the beginning.
+	BufferSize        int
+	DisableKernelCopy bool
+	StaleRetries      int
+}
+
+func (g *FileGetter) withClient(c *Client) Getter {
+	cp := *g
+	cp.client = c
+	return &cp
+}
+
+func (g *FileGetter) Describe() Description {
+	return Description{
+		Summary: "A local file or directory, which is symlinked or copied.",
+		Strict:  true,
+	}
 }
 
 func (g *FileGetter) ClientMode(u *url.URL) (ClientMode, error) {
@@ -18,7 +58,7 @@ func (g *FileGetter) ClientMode(u *url.URL) (ClientMode, error) {
 		path = u.RawPath
 	}
 
-	fi, err := os.Stat(path)
+	fi, err := g.stat(path)
 	if err != nil {
 		return 0, err
 	}
@@ -30,3 +70,176 @@ func (g *FileGetter) ClientMode(u *url.URL) (ClientMode, error) {
 
 	return ClientModeFile, nil
 }
+
+// Local implements LocalGetter, since local files never need network
+// access.
+func (g *FileGetter) Local(*url.URL) bool {
+	return true
+}
+
+func (g *FileGetter) GetReader(u *url.URL) (io.ReadCloser, int64, error) {
+	path := u.Path
+	if u.RawPath != "" {
+		path = u.RawPath
+	}
+
+	// The source path must exist and be a file to be usable.
+	fi, err := g.stat(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("source path error: %s", err)
+	} else if fi.IsDir() {
+		return nil, 0, fmt.Errorf("source path must be a file")
+	} else if isSpecialFile(fi.Mode()) {
+		return nil, 0, fmt.Errorf("source path is a special file (%s)", fi.Mode().Type())
+	}
+
+	f, err := g.open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return g.trackProgress(filepath.Base(path), 0, fi.Size(), g.reader(f)), fi.Size(), nil
+}
+
+// Open implements FSGetter by opening the source directory in place.
+func (g *FileGetter) Open(u *url.URL) (fs.FS, error) {
+	path := u.Path
+	if u.RawPath != "" {
+		path = u.RawPath
+	}
+
+	// The source path must exist and be a directory to be usable.
+	if fi, err := g.stat(path); err != nil {
+		return nil, fmt.Errorf("source path error: %s", err)
+	} else if !fi.IsDir() {
+		return nil, fmt.Errorf("source path must be a directory")
+	}
+
+	return os.DirFS(path), nil
+}
+
+// checkSymlink returns an error if the source path is a symlink that the
+// client's SymlinkPolicy doesn't allow to be copied. Any other symlink is
+// copied from what it points to, since recreating it would be no
+// different from not copying the source at all.
+func (g *FileGetter) checkSymlink(path string) error {
+	fi, err := os.Lstat(path)
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+
+	switch g.symlinks() {
+	case SymlinksSkip, SymlinksError:
+		return fmt.Errorf("source path is a symlink")
+	}
+
+	return nil
+}
+
+// copyFile copies the file at src to dst, starting again if the file goes
+// stale part way through.
+func (g *FileGetter) copyFile(dst, src string) error {
+	return g.retryStale(func() error {
+		srcF, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		fi, err := srcF.Stat()
+		if err != nil {
+			srcF.Close()
+			return err
+		}
+		body := g.trackProgress(filepath.Base(src), 0, fi.Size(), g.reader(srcF))
+		defer body.Close()
+
+		dstF, err := os.Create(dst)
+		if err != nil {
+			return err
+		}
+		defer dstF.Close()
+
+		_, err = io.Copy(dstF, &contextReader{ctx: g.Context(), r: body})
+		return err
+	})
+}
+
+// reader returns f wrapped to be read with the configured BufferSize, and
+// so that it can't be copied by the kernel if that is disabled.
+func (g *FileGetter) reader(f *os.File) io.ReadCloser {
+	switch {
+	case g.BufferSize > 0:
+		return struct {
+			io.Reader
+			io.Closer
+		}{&bufferedReader{r: f, buf: make([]byte, g.BufferSize)}, f}
+	case g.DisableKernelCopy:
+		return struct {
+			io.Reader
+			io.Closer
+		}{f, f}
+	}
+
+	return f
+}
+
+// bufferedReader reads from r in reads the size of buf, whatever the size
+// of the reads from it. Unlike a bufio.Reader it never reads straight into
+// a larger buffer.
+type bufferedReader struct {
+	r          io.Reader
+	buf        []byte
+	start, end int
+}
+
+func (b *bufferedReader) Read(p []byte) (int, error) {
+	if b.start == b.end {
+		n, err := b.r.Read(b.buf)
+		if n == 0 {
+			return 0, err
+		}
+		b.start, b.end = 0, n
+	}
+
+	n := copy(p, b.buf[b.start:b.end])
+	b.start += n
+	return n, nil
+}
+
+// stat is os.Stat, retried if the file is stale.
+func (g *FileGetter) stat(path string) (os.FileInfo, error) {
+	var fi os.FileInfo
+	err := g.retryStale(func() error {
+		var err error
+		fi, err = os.Stat(path)
+		return err
+	})
+
+	return fi, err
+}
+
+// open is os.Open, retried if the file is stale.
+func (g *FileGetter) open(path string) (*os.File, error) {
+	var f *os.File
+	err := g.retryStale(func() error {
+		var err error
+		f, err = os.Open(path)
+		return err
+	})
+
+	return f, err
+}
+
+// retryStale calls f, and again up to StaleRetries times for as long as it
+// fails with ESTALE. Each attempt looks the file up again, which is what
+// an NFS client needs to get a new handle for it.
+func (g *FileGetter) retryStale(f func() error) error {
+	err := f()
+	for i := 0; i < g.StaleRetries && errors.Is(err, syscall.ESTALE); i++ {
+		if err := g.Context().Err(); err != nil {
+			return err
+		}
+		err = f()
+	}
+
+	return err
+}
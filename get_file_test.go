@@ -1,8 +1,12 @@
 package getter
 
 import (
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
 )
 
@@ -149,6 +153,105 @@ func TestFileGetter_GetFile_Copy(t *testing.T) {
 	assertContents(t, dst, "Hello\n")
 }
 
+func TestFileGetter_GetFile_networkFS(t *testing.T) {
+	g := &FileGetter{
+		Copy:              true,
+		BufferSize:        4,
+		DisableKernelCopy: true,
+		StaleRetries:      2,
+	}
+
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+	if err := g.GetFile(dst, testModuleURL("basic-file/foo.txt")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello\n")
+
+	// The reader doesn't expose the file to be copied by the kernel
+	rc, _, err := g.GetReader(testModuleURL("basic-file/foo.txt"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer rc.Close()
+	if _, ok := rc.(*os.File); ok {
+		t.Fatal("reader shouldn't be a file")
+	}
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != "Hello\n" {
+		t.Fatalf("bad: %q", data)
+	}
+}
+
+func TestFileGetter_retryStale(t *testing.T) {
+	for _, tc := range []struct {
+		Retries  int
+		Failures int
+		Err      bool
+	}{
+		{0, 0, false},
+		{0, 1, true},
+		{2, 2, false},
+		{2, 3, true},
+	} {
+		g := &FileGetter{StaleRetries: tc.Retries}
+		var calls int
+		err := g.retryStale(func() error {
+			calls++
+			if calls <= tc.Failures {
+				return &os.PathError{Op: "open", Path: "foo", Err: syscall.ESTALE}
+			}
+			return nil
+		})
+		if (err != nil) != tc.Err {
+			t.Fatalf("%d retries, %d failures: err: %v", tc.Retries, tc.Failures, err)
+		}
+	}
+
+	// Other errors aren't retried
+	g := &FileGetter{StaleRetries: 2}
+	var calls int
+	g.retryStale(func() error {
+		calls++
+		return os.ErrNotExist
+	})
+	if calls != 1 {
+		t.Fatalf("bad calls: %d", calls)
+	}
+}
+
+// readSizes is a reader that records the size of each read from it.
+type readSizes struct {
+	r     io.Reader
+	sizes []int
+}
+
+func (r *readSizes) Read(p []byte) (int, error) {
+	r.sizes = append(r.sizes, len(p))
+	return r.r.Read(p)
+}
+
+func TestBufferedReader(t *testing.T) {
+	src := &readSizes{r: strings.NewReader("Hello, world\n")}
+	r := &bufferedReader{r: src, buf: make([]byte, 5)}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != "Hello, world\n" {
+		t.Fatalf("bad: %q", data)
+	}
+	for _, n := range src.sizes {
+		if n != 5 {
+			t.Fatalf("bad read sizes: %v", src.sizes)
+		}
+	}
+}
+
 // https://github.com/hashicorp/terraform/issues/8418
 func TestFileGetter_percent2F(t *testing.T) {
 	g := new(FileGetter)
@@ -4,20 +4,23 @@ package getter
 
 import (
 	"fmt"
-	"io"
 	"net/url"
 	"os"
 	"path/filepath"
 )
 
 func (g *FileGetter) Get(dst string, u *url.URL) error {
+	if err := g.Context().Err(); err != nil {
+		return err
+	}
+
 	path := u.Path
 	if u.RawPath != "" {
 		path = u.RawPath
 	}
 
 	// The source path must exist and be a directory to be usable.
-	if fi, err := os.Stat(path); err != nil {
+	if fi, err := g.stat(path); err != nil {
 		return fmt.Errorf("source path error: %s", err)
 	} else if !fi.IsDir() {
 		return fmt.Errorf("source path must be a directory")
@@ -50,13 +53,17 @@ func (g *FileGetter) Get(dst string, u *url.URL) error {
 }
 
 func (g *FileGetter) GetFile(dst string, u *url.URL) error {
+	if err := g.Context().Err(); err != nil {
+		return err
+	}
+
 	path := u.Path
 	if u.RawPath != "" {
 		path = u.RawPath
 	}
 
 	// The source path must exist and be a file to be usable.
-	if fi, err := os.Stat(path); err != nil {
+	if fi, err := g.stat(path); err != nil {
 		return fmt.Errorf("source path error: %s", err)
 	} else if fi.IsDir() {
 		return fmt.Errorf("source path must be a file")
@@ -85,19 +92,20 @@ func (g *FileGetter) GetFile(dst string, u *url.URL) error {
 		return os.Symlink(path, dst)
 	}
 
-	// Copy
-	srcF, err := os.Open(path)
-	if err != nil {
+	if err := g.checkSymlink(path); err != nil {
 		return err
 	}
-	defer srcF.Close()
 
-	dstF, err := os.Create(dst)
-	if err != nil {
-		return err
+	// A special file can't be copied by reading it, and leaving it out
+	// would leave nothing at all.
+	if fi, err := g.stat(path); err == nil && isSpecialFile(fi.Mode()) {
+		if g.specialFiles() != SpecialFilesRecreate {
+			return fmt.Errorf("source path is a special file (%s)", fi.Mode().Type())
+		}
+
+		return mknod(dst, fi)
 	}
-	defer dstF.Close()
 
-	_, err = io.Copy(dstF, srcF)
-	return err
+	// Copy
+	return g.copyFile(dst, path)
 }
@@ -0,0 +1,363 @@
+package getter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+)
+
+// GCSGetter is a Getter implementation that will download a module from
+// a Google Cloud Storage bucket, given by a URL such as gs://bucket/path
+// or https://www.googleapis.com/storage/v1/bucket/path.
+//
+// Requests are authenticated with Application Default Credentials unless
+// the getter is given a service account key, or is anonymous.
+type GCSGetter struct {
+	getter
+
+	// CredentialsFile, if set, is the path of a service account key file
+	// in JSON to authenticate with. CredentialsJSON is the same but with
+	// the contents of the file.
+	CredentialsFile string
+	CredentialsJSON []byte
+
+	// Anonymous, if true, sends requests without credentials, for public
+	// buckets, rather than looking for Application Default Credentials,
+	// which is slow and fails confusingly where there are none. The
+	// credentials above aren't used then. The anonymous query parameter
+	// takes priority.
+	Anonymous bool
+}
+
+func (g *GCSGetter) withClient(c *Client) Getter {
+	cp := *g
+	cp.client = c
+	return &cp
+}
+
+func (g *GCSGetter) Describe() Description {
+	return Description{
+		Summary: "An object, or every object under a prefix, in Google Cloud Storage.",
+		Options: []Option{
+			{"anonymous", "If true, requests are sent without credentials, for public buckets."},
+		},
+		Strict: true,
+	}
+}
+
+func (g *GCSGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	ctx := g.Context()
+
+	// Parse URL
+	bucket, object, err := g.parseURL(u)
+	if err != nil {
+		return 0, err
+	}
+
+	client, err := g.newClient(ctx, u)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	// List the object(s) at the given prefix
+	it := client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: object})
+	for {
+		obj, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		// Use file mode on exact match.
+		if obj.Name == object {
+			return ClientModeFile, nil
+		}
+
+		// Use dir mode if child keys are found.
+		if strings.HasPrefix(obj.Name, object+"/") {
+			return ClientModeDir, nil
+		}
+	}
+
+	// There was no match, so just return file mode. The download is going
+	// to fail but we will let GCS return the proper error later.
+	return ClientModeFile, nil
+}
+
+func (g *GCSGetter) Get(dst string, u *url.URL) error {
+	ctx := g.Context()
+
+	// Parse URL
+	bucket, object, err := g.parseURL(u)
+	if err != nil {
+		return err
+	}
+
+	// Remove destination if it already exists
+	_, err = os.Stat(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		// Remove the destination
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	client, err := g.newClient(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Iterate through all matching objects, storing each file relative to
+	// the destination path
+	var completed []string
+	it := client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: object})
+	for {
+		obj, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := g.stopped(); err != nil {
+			return &PartialError{
+				Completed: completed,
+				Remaining: []string{obj.Name},
+				Err:       err,
+			}
+		}
+
+		// If the object name ends with a slash assume it is a directory
+		// and ignore it
+		if strings.HasSuffix(obj.Name, "/") {
+			continue
+		}
+
+		// Get the object destination path
+		objDst, err := filepath.Rel(object, obj.Name)
+		if err != nil {
+			return err
+		}
+		objDst = filepath.Join(dst, objDst)
+
+		if err := g.getObject(ctx, client, objDst, bucket, obj.Name); err != nil {
+			return err
+		}
+		completed = append(completed, obj.Name)
+	}
+
+	return nil
+}
+
+func (g *GCSGetter) GetFile(dst string, u *url.URL) error {
+	ctx := g.Context()
+
+	// Parse URL
+	bucket, object, err := g.parseURL(u)
+	if err != nil {
+		return err
+	}
+
+	client, err := g.newClient(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return g.getObject(ctx, client, dst, bucket, object)
+}
+
+func (g *GCSGetter) GetReader(u *url.URL) (io.ReadCloser, int64, error) {
+	ctx := g.Context()
+
+	bucket, object, err := g.parseURL(u)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	client, err := g.newClient(ctx, u)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	r, err := client.Bucket(bucket).Object(object).NewReader(ctx)
+	if err != nil {
+		client.Close()
+		return nil, 0, err
+	}
+
+	size := r.Attrs.Size
+	body := &gcsReader{Reader: r, client: client}
+	return g.trackProgress(object, 0, size, body), size, nil
+}
+
+// PutFile implements Putter by uploading the file at src to the object in
+// u.
+func (g *GCSGetter) PutFile(u *url.URL, src string) error {
+	ctx := g.Context()
+
+	// Parse URL
+	bucket, object, err := g.parseURL(u)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	body := g.trackProgress(filepath.Base(src), 0, fi.Size(), f)
+	defer body.Close()
+
+	client, err := g.newClient(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// The object is only replaced once the writer is closed, and not at
+	// all if ctx is done first.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w := client.Bucket(bucket).Object(object).NewWriter(ctx)
+	if _, err := io.Copy(w, body); err != nil {
+		cancel()
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+func (g *GCSGetter) getObject(ctx context.Context, client *storage.Client, dst, bucket, object string) error {
+	r, err := client.Bucket(bucket).Object(object).NewReader(ctx)
+	if err != nil {
+		return err
+	}
+	body := g.trackProgress(object, 0, r.Attrs.Size, r)
+	defer body.Close()
+
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, body)
+	return err
+}
+
+// newClient returns a storage client for the object at u, authenticated
+// as the getter and u say, that identifies itself with the Client's
+// User-Agent. If the Client has Addresses, it connects only to the
+// addresses they allow.
+func (g *GCSGetter) newClient(ctx context.Context, u *url.URL) (*storage.Client, error) {
+	anonymous := g.Anonymous
+	if v := u.Query().Get("anonymous"); v != "" {
+		var err error
+		if anonymous, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid anonymous value: %s", v)
+		}
+	}
+
+	opts := []option.ClientOption{
+		option.WithUserAgent(g.header().Get("User-Agent")),
+	}
+	if anonymous {
+		opts = append(opts, option.WithoutAuthentication())
+	} else {
+		if g.CredentialsFile != "" {
+			opts = append(opts, option.WithCredentialsFile(g.CredentialsFile))
+		}
+		if len(g.CredentialsJSON) > 0 {
+			opts = append(opts, option.WithCredentialsJSON(g.CredentialsJSON))
+		}
+	}
+
+	if g.client != nil && g.client.Addresses != nil {
+		// The transport is built here, rather than by the storage client,
+		// so that authentication is added on top of one that checks its
+		// connections
+		opts = append(opts, option.WithScopes(storage.ScopeFullControl))
+		transport, err := htransport.NewTransport(ctx, g.addressClient(httpClient).Transport, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return storage.NewClient(ctx, option.WithHTTPClient(&http.Client{Transport: transport}))
+	}
+
+	return storage.NewClient(ctx, opts...)
+}
+
+// parseURL returns the bucket and object path in u.
+func (g *GCSGetter) parseURL(u *url.URL) (bucket, path string, err error) {
+	if u.Scheme == "gs" {
+		if u.Host == "" {
+			return "", "", fmt.Errorf("URL is not a valid GCS URL")
+		}
+
+		return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+	}
+
+	// https://www.googleapis.com/storage/v1/bucket/path
+	if strings.Contains(u.Host, "googleapis.com") {
+		pathParts := strings.SplitN(strings.TrimPrefix(u.Path, "/storage/v1/"), "/", 2)
+		if len(pathParts) != 2 || pathParts[0] == "" {
+			return "", "", fmt.Errorf("URL is not a valid GCS URL")
+		}
+
+		return pathParts[0], pathParts[1], nil
+	}
+
+	return "", "", fmt.Errorf("URL is not a valid GCS URL")
+}
+
+// gcsReader is an object reader that closes the storage client it was
+// read with once it is closed.
+type gcsReader struct {
+	*storage.Reader
+	client *storage.Client
+}
+
+func (r *gcsReader) Close() error {
+	err := r.Reader.Close()
+	if err1 := r.client.Close(); err == nil {
+		err = err1
+	}
+
+	return err
+}
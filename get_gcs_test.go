@@ -0,0 +1,214 @@
+package getter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGCSGetter_impl(t *testing.T) {
+	var _ Getter = new(GCSGetter)
+}
+
+func TestGCSGetter(t *testing.T) {
+	defer testGCSServer(t, testGCSObjects)()
+
+	g := new(GCSGetter)
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	// With a dir that doesn't exist
+	if err := g.Get(dst, testURL("gs://bucket/prefix")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Verify the main file exists
+	assertContents(t, filepath.Join(dst, "main.tf"), "main")
+	assertContents(t, filepath.Join(dst, "subdir", "sub.tf"), "sub")
+	if _, err := os.Stat(filepath.Join(dst, "other.tf")); err == nil {
+		t.Fatal("objects outside of the prefix shouldn't be downloaded")
+	}
+}
+
+func TestGCSGetter_GetFile(t *testing.T) {
+	defer testGCSServer(t, testGCSObjects)()
+
+	g := new(GCSGetter)
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	// Download
+	if err := g.GetFile(dst, testURL("gs://bucket/prefix/main.tf")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "main")
+}
+
+func TestGCSGetter_GetFile_notfound(t *testing.T) {
+	defer testGCSServer(t, testGCSObjects)()
+
+	g := new(GCSGetter)
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	// Download
+	if err := g.GetFile(dst, testURL("gs://bucket/prefix/missing.tf")); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestGCSGetter_ClientMode(t *testing.T) {
+	defer testGCSServer(t, testGCSObjects)()
+
+	cases := []struct {
+		Input string
+		Mode  ClientMode
+	}{
+		{"gs://bucket/prefix", ClientModeDir},
+		{"gs://bucket/prefix/main.tf", ClientModeFile},
+		{"gs://bucket/missing", ClientModeFile},
+		{"https://www.googleapis.com/storage/v1/bucket/prefix", ClientModeDir},
+	}
+
+	g := new(GCSGetter)
+	for _, tc := range cases {
+		mode, err := g.ClientMode(testURL(tc.Input))
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Input, err)
+		}
+		if mode != tc.Mode {
+			t.Fatalf("%s: bad mode: %d", tc.Input, mode)
+		}
+	}
+}
+
+func TestGCSGetter_Url(t *testing.T) {
+	cases := []struct {
+		Input  string
+		Bucket string
+		Path   string
+		Err    bool
+	}{
+		{"gs://bucket/foo/bar", "bucket", "foo/bar", false},
+		{"gs://bucket", "bucket", "", false},
+		{"https://www.googleapis.com/storage/v1/bucket/foo/bar", "bucket", "foo/bar", false},
+		{"https://www.googleapis.com/storage/v1/bucket", "", "", true},
+		{"https://example.com/bucket/foo", "", "", true},
+	}
+
+	g := new(GCSGetter)
+	for _, tc := range cases {
+		bucket, path, err := g.parseURL(testURL(tc.Input))
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: err: %v", tc.Input, err)
+		}
+		if bucket != tc.Bucket || path != tc.Path {
+			t.Fatalf("%s: bad: %q %q", tc.Input, bucket, path)
+		}
+	}
+}
+
+func TestGCSGetter_anonymous(t *testing.T) {
+	// Application Default Credentials that can't be found
+	defer tempEnv(t, "GOOGLE_APPLICATION_CREDENTIALS", filepath.Join(tempDir(t), "missing.json"))()
+	defer tempEnv(t, "STORAGE_EMULATOR_HOST", "")()
+
+	ctx := context.Background()
+	if _, err := new(GCSGetter).newClient(ctx, testURL("gs://bucket/key")); err == nil {
+		t.Fatal("should error")
+	}
+
+	cases := []struct {
+		Getter *GCSGetter
+		Input  string
+		Err    bool
+	}{
+		{new(GCSGetter), "gs://bucket/key?anonymous=true", false},
+		{&GCSGetter{Anonymous: true}, "gs://bucket/key", false},
+		{&GCSGetter{Anonymous: true}, "gs://bucket/key?anonymous=false", true},
+		{&GCSGetter{Anonymous: true, CredentialsFile: "missing.json"}, "gs://bucket/key", false},
+		{new(GCSGetter), "gs://bucket/key?anonymous=maybe", true},
+	}
+	for _, tc := range cases {
+		client, err := tc.Getter.newClient(ctx, testURL(tc.Input))
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: err: %v", tc.Input, err)
+		}
+		if client != nil {
+			client.Close()
+		}
+	}
+}
+
+var testGCSObjects = map[string]string{
+	"prefix/main.tf":       "main",
+	"prefix/subdir/":       "",
+	"prefix/subdir/sub.tf": "sub",
+	"other.tf":             "other",
+}
+
+// testGCSServer starts a minimal implementation of the GCS API, serving
+// the objects in a single bucket named "bucket", and points the storage
+// client at it as if it were an emulator. The returned func stops it.
+func testGCSServer(t *testing.T, objects map[string]string) func() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		switch {
+		case path == "/storage/v1/b/bucket/o":
+			prefix := r.URL.Query().Get("prefix")
+			var names []string
+			for name := range objects {
+				if strings.HasPrefix(name, prefix) {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+
+			items := make([]map[string]string, 0, len(names))
+			for _, name := range names {
+				items = append(items, map[string]string{
+					"bucket": "bucket",
+					"name":   name,
+					"size":   strconv.Itoa(len(objects[name])),
+				})
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+			return
+		case strings.HasPrefix(path, "/download/storage/v1/b/bucket/o/"):
+			path = strings.TrimPrefix(path, "/download/storage/v1/b/bucket/o/")
+		case strings.HasPrefix(path, "/bucket/"):
+			path = strings.TrimPrefix(path, "/bucket/")
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		body, ok := objects[path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Write([]byte(body))
+	}))
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	closer := tempEnv(t, "STORAGE_EMULATOR_HOST", u.Host)
+
+	return func() {
+		closer()
+		server.Close()
+	}
+}
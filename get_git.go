@@ -1,35 +1,158 @@
 package getter
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	urlhelper "github.com/hashicorp/go-getter/helper/url"
 	"github.com/hashicorp/go-safetemp"
 	"github.com/hashicorp/go-version"
 )
 
+// gitHubArchiveURL is the base URL that GitHub serves repository
+// archives from.
+var gitHubArchiveURL = "https://codeload.github.com"
+
 // GitGetter is a Getter implementation that will download a module from
 // a git repository.
-type GitGetter struct{}
+type GitGetter struct {
+	getter
+
+	// CacheDir, if set, is a directory where a bare mirror of each remote
+	// is kept. Clones are made using the mirror as a reference so that only
+	// objects that are new since the mirror was last updated are downloaded
+	// from the remote. Destinations don't depend on the mirror once cloned.
+	CacheDir string
+
+	// CacheStaleAfter, if non-zero, makes clones of a remote that already
+	// has a mirror in CacheDir come from the mirror alone, without waiting
+	// on the remote. If the mirror was last updated longer ago than
+	// CacheStaleAfter it is then updated in the background, for the clones
+	// that follow. A ref that is newer than the mirror is fetched from the
+	// remote before it is checked out.
+	CacheStaleAfter time.Duration
+
+	// CloneTimeout, FetchTimeout and SubmoduleTimeout, if non-zero, limit
+	// how long cloning, fetching updates and fetching submodules may take
+	// respectively. Commands that run past their timeout are killed.
+	// Timeout, if non-zero, is the limit for whichever of them are zero.
+	CloneTimeout     time.Duration
+	FetchTimeout     time.Duration
+	SubmoduleTimeout time.Duration
+	Timeout          time.Duration
+
+	// AllowedSigners, if set, requires the checked out tag (if ref is a
+	// tag) or commit to be signed by one of these keys for Get to succeed.
+	// Keys are identified by GPG fingerprint or long key ID, or by SSH key
+	// fingerprint ("SHA256:..."). Signatures are checked with
+	// "git verify-tag" and "git verify-commit", so the keys must also be
+	// trusted by the local GPG keyring or gpg.ssh.allowedSignersFile.
+	AllowedSigners []string
+
+	// HostKeys, if set, pins the host keys of remotes that are connected
+	// to over SSH. It is keyed by host as in known_hosts files, such as
+	// "example.com", or "[example.com]:2222" for a port other than 22, and
+	// each key is a public key such as "ssh-ed25519 AAAA...". A command
+	// connecting to a pinned host only accepts the pinned keys for it and
+	// known_hosts isn't used, so submodules on other hosts must be pinned
+	// as well.
+	HostKeys map[string][]string
+
+	// Netrc, if true, looks up credentials for HTTP and HTTPS remotes in
+	// the user's netrc file, as HttpGetter's Netrc does, if the URL
+	// doesn't have any. They are part of the remote's URL, so are kept
+	// in the clone's configuration like credentials given in the URL.
+	Netrc bool
+
+	// Native, if true, clones HTTP and HTTPS remotes with a Go
+	// implementation of Git's smart HTTP protocol instead of the git
+	// command, for environments that don't have git, such as scratch
+	// containers. Only the files of the ref are downloaded, without a .git
+	// directory or any history, so the depth parameter has no effect and
+	// a destination that exists is replaced rather than updated.
+	// Submodules are cloned the same way, so must be on HTTP or HTTPS
+	// remotes too. CacheDir isn't used, and the sshkey and keep_git
+	// parameters and AllowedSigners need git, so aren't supported.
+	Native bool
+}
+
+func (g *GitGetter) withClient(c *Client) Getter {
+	cp := *g
+	cp.client = c
+	return &cp
+}
+
+func (g *GitGetter) Describe() Description {
+	return Description{
+		Summary: "A Git repository, cloned at a ref.",
+		Options: []Option{
+			{"ref", "The branch, tag or commit to check out."},
+			{"sshkey", "A base64 encoded SSH private key to clone with."},
+			{"depth", "The number of commits of history to clone."},
+			{"github_archive", "If true, the tarball of a github.com ref is downloaded instead of cloning."},
+			{"keep_git", "If true, the .git directory is kept. A subdirectory can't be downloaded with it."},
+		},
+	}
+}
 
 func (g *GitGetter) ClientMode(_ *url.URL) (ClientMode, error) {
 	return ClientModeDir, nil
 }
 
-func (g *GitGetter) Get(dst string, u *url.URL) error {
-	if _, err := exec.LookPath("git"); err != nil {
-		return fmt.Errorf("git must be available and on the PATH")
+// Local implements LocalGetter. A repository can be got offline if it has
+// a mirror in CacheDir, unless it is to be downloaded as a GitHub archive
+// or cloned natively.
+func (g *GitGetter) Local(u *url.URL) bool {
+	if g.CacheDir == "" || g.Native {
+		return false
+	}
+
+	q := u.Query()
+	if v, _ := strconv.ParseBool(q.Get("github_archive")); v {
+		if v, _ := strconv.ParseBool(q.Get("keep_git")); !v {
+			return false
+		}
+	}
+	for _, k := range []string{"ref", "sshkey", "depth", "github_archive", "keep_git"} {
+		q.Del(k)
 	}
+	remote := *u
+	remote.RawQuery = q.Encode()
+
+	_, err := os.Stat(g.mirrorPath(&remote))
+	return err == nil
+}
 
+// checkKeepGitSubdir returns an error if g is a GitGetter and u asks for
+// the .git directory to be kept, but only the subdirectory subDir of the
+// repository is copied into the destination, without it.
+func checkKeepGitSubdir(g Getter, u *url.URL, subDir string) error {
+	if _, ok := g.(*GitGetter); !ok || subDir == "" {
+		return nil
+	}
+	if v, _ := strconv.ParseBool(u.Query().Get("keep_git")); v {
+		return fmt.Errorf("keep_git can't be used with a subdirectory, which has no .git directory: %s", subDir)
+	}
+	return nil
+}
+
+func (g *GitGetter) Get(dst string, u *url.URL) error {
 	// Extract some query parameters we use
 	var ref, sshKey string
+	var depth int
+	var githubArchive, keepGit bool
 	q := u.Query()
 	if len(q) > 0 {
 		ref = q.Get("ref")
@@ -38,12 +161,62 @@ func (g *GitGetter) Get(dst string, u *url.URL) error {
 		sshKey = q.Get("sshkey")
 		q.Del("sshkey")
 
+		if v := q.Get("depth"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid depth value, must be a positive integer: %s", v)
+			}
+			depth = n
+		}
+		q.Del("depth")
+
+		if v := q.Get("github_archive"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid github_archive value: %s", v)
+			}
+			githubArchive = b
+		}
+		q.Del("github_archive")
+
+		if v := q.Get("keep_git"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid keep_git value: %s", v)
+			}
+			keepGit = b
+		}
+		q.Del("keep_git")
+
 		// Copy the URL
 		var newU url.URL = *u
 		u = &newU
 		u.RawQuery = q.Encode()
 	}
 
+	// Archives don't contain the repository itself, so if it has been
+	// asked to be kept we have to clone.
+	if githubArchive && !keepGit {
+		return g.getGitHubArchive(dst, u, ref)
+	}
+
+	if g.Native {
+		return g.getNative(dst, u, ref, sshKey, keepGit)
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		return fmt.Errorf("git must be available and on the PATH, or GitGetter.Native set")
+	}
+
+	if g.Netrc && (u.Scheme == "http" || u.Scheme == "https") {
+		// Copy the URL so we can modify it
+		var newU url.URL = *u
+		u = &newU
+		if err := addAuthFromNetrc(u); err != nil {
+			return err
+		}
+	}
+
 	var sshKeyFile string
 	if sshKey != "" {
 		// Check that the git version is sufficiently new.
@@ -51,42 +224,35 @@ func (g *GitGetter) Get(dst string, u *url.URL) error {
 			return fmt.Errorf("Error using ssh key: %v", err)
 		}
 
-		// We have an SSH key - decode it.
-		raw, err := base64.StdEncoding.DecodeString(sshKey)
-		if err != nil {
-			return err
-		}
-
 		// Create a temp file for the key and ensure it is removed.
-		fh, err := ioutil.TempFile("", "go-getter")
+		var err error
+		sshKeyFile, err = writeSSHKey("", sshKey)
 		if err != nil {
 			return err
 		}
-		sshKeyFile = fh.Name()
 		defer os.Remove(sshKeyFile)
+	}
 
-		// Set the permissions prior to writing the key material.
-		if err := os.Chmod(sshKeyFile, 0600); err != nil {
-			return err
-		}
-
-		// Write the raw key into the temp file.
-		_, err = fh.Write(raw)
-		fh.Close()
-		if err != nil {
-			return err
-		}
+	knownHostsFile, err := g.knownHostsFile("", u)
+	if err != nil {
+		return err
+	}
+	if knownHostsFile != "" {
+		defer os.Remove(knownHostsFile)
 	}
 
 	// Clone or update the repository
-	_, err := os.Stat(dst)
+	_, err = os.Stat(dst)
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
 	if err == nil {
-		err = g.update(dst, sshKeyFile, ref)
+		// Offline, an existing clone is checked out without updating it
+		if !g.offline() {
+			err = g.update(dst, sshKeyFile, knownHostsFile, ref, depth)
+		}
 	} else {
-		err = g.clone(dst, sshKeyFile, u)
+		err = g.clone(dst, sshKeyFile, knownHostsFile, u, ref, depth)
 	}
 	if err != nil {
 		return err
@@ -94,13 +260,35 @@ func (g *GitGetter) Get(dst string, u *url.URL) error {
 
 	// Next: check out the proper tag/branch if it is specified, and checkout
 	if ref != "" {
-		if err := g.checkout(dst, ref); err != nil {
+		err := g.checkout(dst, ref)
+		if err != nil && g.CacheStaleAfter > 0 && !g.offline() {
+			// The clone may have come from a mirror that doesn't have
+			// the ref yet.
+			if err := g.runGit(g.FetchTimeout, dst, sshKeyFile, knownHostsFile, "fetch", "--tags", "origin"); err != nil {
+				return err
+			}
+			err = g.checkout(dst, ref)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Make sure what we checked out was signed by someone we trust
+	if len(g.AllowedSigners) > 0 {
+		if err := g.verifySignature(dst, ref); err != nil {
 			return err
 		}
 	}
 
 	// Lastly, download any/all submodules.
-	return g.fetchSubmodules(dst, sshKeyFile)
+	if g.offline() {
+		if _, err := os.Stat(filepath.Join(dst, ".gitmodules")); err == nil {
+			return &OfflineError{Sources: []string{redactURLCredentials(u.String())}}
+		}
+		return nil
+	}
+	return g.fetchSubmodules(dst, sshKeyFile, knownHostsFile)
 }
 
 // GetFile for Git doesn't support updating at this time. It will download
@@ -132,22 +320,312 @@ func (g *GitGetter) GetFile(dst string, u *url.URL) error {
 	return fg.GetFile(dst, u)
 }
 
+// getGitHubArchive downloads the tarball GitHub generates for the given
+// ref instead of cloning the repository. The result is the same as a
+// clone, but without the .git directory.
+func (g *GitGetter) getGitHubArchive(dst string, u *url.URL, ref string) error {
+	if !strings.EqualFold(u.Hostname(), "github.com") {
+		return fmt.Errorf("github_archive can only be used with github.com repositories")
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) != 2 {
+		return fmt.Errorf("GitHub URLs should be github.com/username/repo")
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	archiveU, err := url.Parse(fmt.Sprintf("%s/%s/%s/tar.gz/%s",
+		gitHubArchiveURL, parts[0], strings.TrimSuffix(parts[1], ".git"), ref))
+	if err != nil {
+		return err
+	}
+
+	td, err := ioutil.TempDir("", "getter")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(td)
+
+	archive := filepath.Join(td, "archive.tar.gz")
+	hg := &HttpGetter{Netrc: true}
+	if err := hg.GetFile(archive, archiveU); err != nil {
+		return fmt.Errorf("error downloading GitHub archive: %s", err)
+	}
+
+	extracted := filepath.Join(td, "extracted")
+	d := withLimits(new(TarGzipDecompressor), g.decompressLimits())
+	if err := d.Decompress(extracted, archive, true); err != nil {
+		return err
+	}
+
+	// GitHub archives contain a single top level directory named after the
+	// repository and commit, and it is the contents of that we want.
+	root, err := SubdirGlob(extracted, "*")
+	if err != nil {
+		return fmt.Errorf("unexpected GitHub archive layout: %s", err)
+	}
+
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	return copyDir(dst, root, false, g.specialFiles(), g.symlinks())
+}
+
 func (g *GitGetter) checkout(dst string, ref string) error {
-	cmd := exec.Command("git", "checkout", ref)
+	cmd := exec.CommandContext(g.Context(), "git", "checkout", ref)
 	cmd.Dir = dst
 	return getRunCommand(cmd)
 }
 
-func (g *GitGetter) clone(dst, sshKeyFile string, u *url.URL) error {
-	cmd := exec.Command("git", "clone", u.String(), dst)
-	setupGitEnv(cmd, sshKeyFile)
-	return getRunCommand(cmd)
+func (g *GitGetter) clone(dst, sshKeyFile, knownHostsFile string, u *url.URL, ref string, depth int) error {
+	args := []string{"clone"}
+	if g.CacheDir != "" {
+		if g.CacheStaleAfter > 0 || g.offline() {
+			ok, err := g.cloneMirror(dst, sshKeyFile, knownHostsFile, u)
+			if ok || err != nil {
+				return err
+			}
+		}
+		if g.offline() {
+			return &OfflineError{Sources: []string{redactURLCredentials(u.String())}}
+		}
+
+		mirror, l, err := g.updateMirror(sshKeyFile, knownHostsFile, u)
+		if err != nil {
+			return err
+		}
+		defer l.Unlock()
+
+		args = append(args, "--reference", mirror, "--dissociate")
+	}
+
+	// A shallow clone only has the history of the branch it clones, so
+	// it clones the ref if it is a branch or tag. A commit is fetched
+	// once the default branch has been cloned.
+	var commit bool
+	if depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+		if commit = isCommitID(ref); ref != "" && !commit {
+			args = append(args, "--branch", ref)
+		}
+	}
+	args = append(args, u.String(), dst)
+
+	if err := g.runGit(g.CloneTimeout, "", sshKeyFile, knownHostsFile, args...); err != nil {
+		return err
+	}
+	if commit {
+		return g.fetchRef(dst, sshKeyFile, knownHostsFile, ref, depth)
+	}
+
+	return nil
+}
+
+// fetchRef fetches the tag, branch or commit ref into a shallow clone
+// with the given depth, so that it can be checked out.
+func (g *GitGetter) fetchRef(dst, sshKeyFile, knownHostsFile, ref string, depth int) error {
+	refspecs := []string{ref}
+	if !isCommitID(ref) {
+		// Only the branch that was cloned is tracked, so other branches
+		// are fetched into a local branch to be checked out.
+		refspecs = []string{
+			"+refs/tags/" + ref + ":refs/tags/" + ref,
+			"+refs/heads/" + ref + ":refs/heads/" + ref,
+		}
+	}
+
+	var err error
+	for _, refspec := range refspecs {
+		err = g.runGit(g.FetchTimeout, dst, sshKeyFile, knownHostsFile, "fetch", "--depth", strconv.Itoa(depth), "origin", refspec)
+		if err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// isCommitID returns true if ref looks like a full or abbreviated commit
+// hash rather than the name of a branch or tag.
+func isCommitID(ref string) bool {
+	if len(ref) < 7 || len(ref) > 64 {
+		return false
+	}
+	for _, c := range ref {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+
+	return true
 }
 
-func (g *GitGetter) update(dst, sshKeyFile, ref string) error {
+// updateMirror creates or updates the bare mirror of the given remote in
+// the cache directory and returns its path. The mirror is locked so that
+// other processes don't update it while it is in use, and the lock must
+// be released once the clone that uses it is done.
+func (g *GitGetter) updateMirror(sshKeyFile, knownHostsFile string, u *url.URL) (string, *fileLock, error) {
+	// --dissociate was added in 2.3
+	if err := checkGitVersion("2.3"); err != nil {
+		return "", nil, fmt.Errorf("Error using git cache: %v", err)
+	}
+
+	mirror := g.mirrorPath(u)
+	l, err := lockPath(mirror, g.lockTimeout())
+	if err != nil {
+		return "", nil, err
+	}
+
+	_, err = os.Stat(mirror)
+	if err != nil && !os.IsNotExist(err) {
+		l.Unlock()
+		return "", nil, err
+	}
+
+	if err == nil {
+		g.cacheHit()
+		err = g.runGit(g.FetchTimeout, mirror, sshKeyFile, knownHostsFile, "remote", "update", "--prune")
+	} else {
+		err = g.runGit(g.CloneTimeout, "", sshKeyFile, knownHostsFile, "clone", "--mirror", u.String(), mirror)
+	}
+	if err == nil {
+		err = touchMirror(mirror)
+	}
+	if err != nil {
+		l.Unlock()
+		return "", nil, err
+	}
+
+	return mirror, l, nil
+}
+
+// cloneMirror clones the remote from its existing mirror in the cache
+// directory without contacting the remote, and starts updating the mirror
+// in the background if it is stale and the client isn't offline. It returns false if there is no
+// mirror to clone from yet.
+func (g *GitGetter) cloneMirror(dst, sshKeyFile, knownHostsFile string, u *url.URL) (bool, error) {
+	mirror := g.mirrorPath(u)
+	l, err := lockPath(mirror, g.lockTimeout())
+	if err != nil {
+		return false, err
+	}
+
+	fi, err := os.Stat(mirror)
+	if err != nil {
+		l.Unlock()
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	g.cacheHit()
+	err = g.runGit(g.CloneTimeout, "", "", "", "clone", mirror, dst)
+	if err == nil {
+		err = g.runGit(0, dst, "", "", "remote", "set-url", "origin", u.String())
+	}
+	if err != nil || g.offline() || time.Since(fi.ModTime()) < g.CacheStaleAfter {
+		l.Unlock()
+		return err == nil, err
+	}
+
+	// The key and known hosts files are removed once Get returns, so the
+	// update needs copies of its own.
+	var keyFile, hostsFile string
+	if sshKeyFile != "" {
+		if keyFile, err = copySSHKey(sshKeyFile); err != nil {
+			l.Unlock()
+			return false, err
+		}
+	}
+	if knownHostsFile != "" {
+		if hostsFile, err = g.knownHostsFile("", u); err != nil {
+			os.Remove(keyFile)
+			l.Unlock()
+			return false, err
+		}
+	}
+
+	go func() {
+		defer l.Unlock()
+		for _, f := range []string{keyFile, hostsFile} {
+			if f != "" {
+				defer os.Remove(f)
+			}
+		}
+
+		// The update outlives the client, so it isn't cancelled with it.
+		// If it fails the mirror stays stale and the next clone tries again.
+		err := runGitContext(context.Background(), g.timeout(g.FetchTimeout), mirror, keyFile, hostsFile, "remote", "update", "--prune")
+		if err == nil {
+			touchMirror(mirror)
+		}
+	}()
+
+	return true, nil
+}
+
+// mirrorPath returns the path of the mirror of the given remote in the
+// cache directory.
+func (g *GitGetter) mirrorPath(u *url.URL) string {
+	// Credentials don't change which repository we're talking about.
+	key := *u
+	key.User = nil
+	sum := sha256.Sum256([]byte(key.String()))
+	return filepath.Join(g.CacheDir, hex.EncodeToString(sum[:]))
+}
+
+func (g *GitGetter) lockTimeout() time.Duration {
+	if g.client == nil {
+		return 0
+	}
+
+	return g.client.LockTimeout
+}
+
+// touchMirror records that the mirror was just updated in its
+// modification time, which is how CacheStaleAfter tells it is stale.
+func touchMirror(mirror string) error {
+	now := time.Now()
+	return os.Chtimes(mirror, now, now)
+}
+
+// knownHostsFile writes the host keys pinned for the remote in u to a new
+// temporary known_hosts file in dir, returning its path. It returns "" if
+// the remote isn't connected to over SSH or its host isn't pinned.
+func (g *GitGetter) knownHostsFile(dir string, u *url.URL) (string, error) {
+	if u.Scheme != "ssh" || len(g.HostKeys) == 0 {
+		return "", nil
+	}
+
+	return writeSSHKnownHosts(dir, g.HostKeys, u)
+}
+
+// copySSHKey copies the SSH key file at path into a new temporary file,
+// returning its path.
+func copySSHKey(path string) (string, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return writeSSHKey("", base64.StdEncoding.EncodeToString(raw))
+}
+
+func (g *GitGetter) update(dst, sshKeyFile, knownHostsFile, ref string, depth int) error {
 	// Determine if we're a branch. If we're NOT a branch, then we just
 	// switch to master prior to checking out
-	cmd := exec.Command("git", "show-ref", "-q", "--verify", "refs/heads/"+ref)
+	if depth > 0 {
+		return g.updateShallow(dst, sshKeyFile, knownHostsFile, ref, depth)
+	}
+
+	cmd := exec.CommandContext(g.Context(), "git", "show-ref", "-q", "--verify", "refs/heads/"+ref)
 	cmd.Dir = dst
 
 	if getRunCommand(cmd) != nil {
@@ -162,23 +640,181 @@ func (g *GitGetter) update(dst, sshKeyFile, ref string) error {
 		return err
 	}
 
-	cmd = exec.Command("git", "pull", "--ff-only")
+	return g.runGit(g.FetchTimeout, dst, sshKeyFile, knownHostsFile, "pull", "--ff-only")
+}
+
+// updateShallow updates a shallow clone. Its history is cut off, so the
+// branch can't be fast-forwarded and is reset to what is fetched instead,
+// keeping any local changes. Tags and commits, and branches that weren't
+// cloned, are fetched to be checked out.
+func (g *GitGetter) updateShallow(dst, sshKeyFile, knownHostsFile, ref string, depth int) error {
+	if ref != "" {
+		cmd := exec.CommandContext(g.Context(), "git", "show-ref", "-q", "--verify", "refs/heads/"+ref)
+		cmd.Dir = dst
+		if getRunCommand(cmd) != nil {
+			return g.fetchRef(dst, sshKeyFile, knownHostsFile, ref, depth)
+		}
+		if err := g.checkout(dst, ref); err != nil {
+			return err
+		}
+	}
+
+	// Without a ref the branch that was cloned, and is checked out, is
+	// updated.
+	fetch := ref
+	if fetch == "" {
+		fetch = "HEAD"
+	}
+	if err := g.runGit(g.FetchTimeout, dst, sshKeyFile, knownHostsFile, "fetch", "--depth", strconv.Itoa(depth), "origin", fetch); err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(g.Context(), "git", "reset", "--keep", "FETCH_HEAD")
 	cmd.Dir = dst
-	setupGitEnv(cmd, sshKeyFile)
 	return getRunCommand(cmd)
 }
 
-// fetchSubmodules downloads any configured submodules recursively.
-func (g *GitGetter) fetchSubmodules(dst, sshKeyFile string) error {
-	cmd := exec.Command("git", "submodule", "update", "--init", "--recursive")
+// verifySignature checks that the tag ref, or the commit checked out if
+// ref isn't a tag, has a valid signature from one of the allowed signers.
+func (g *GitGetter) verifySignature(dst, ref string) error {
+	args := []string{"verify-commit", "--raw", "HEAD"}
+	if ref != "" {
+		cmd := exec.CommandContext(g.Context(), "git", "show-ref", "-q", "--verify", "refs/tags/"+ref)
+		cmd.Dir = dst
+		if getRunCommand(cmd) == nil {
+			args = []string{"verify-tag", "--raw", ref}
+		}
+	}
+
+	var buf bytes.Buffer
+	cmd := exec.CommandContext(g.Context(), "git", args...)
 	cmd.Dir = dst
-	setupGitEnv(cmd, sshKeyFile)
-	return getRunCommand(cmd)
+	cmd.Stdout = &buf
+	cmd.Stderr = &buf
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("signature verification failed: %s", strings.TrimSpace(buf.String()))
+	}
+
+	for _, key := range gitSignatureKeys(buf.String()) {
+		for _, allowed := range g.AllowedSigners {
+			if strings.EqualFold(key, strings.Replace(allowed, " ", "", -1)) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("signature is not from an allowed signer: %s", strings.TrimSpace(buf.String()))
+}
+
+// gitSignatureKeys returns the identifiers of the keys that made the good
+// signatures reported in the output of "git verify-commit --raw" or
+// "git verify-tag --raw".
+func gitSignatureKeys(output string) []string {
+	var keys []string
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) >= 3 && fields[0] == "[GNUPG:]" && fields[1] == "GOODSIG":
+			keys = append(keys, fields[2])
+		case len(fields) >= 3 && fields[0] == "[GNUPG:]" && fields[1] == "VALIDSIG":
+			keys = append(keys, fields[2])
+
+			// The fingerprint of the primary key, which may differ if
+			// the signature was made by a subkey.
+			if len(fields) >= 12 {
+				keys = append(keys, fields[11])
+			}
+		case len(fields) > 0 && fields[0] == "Good":
+			// SSH signatures: Good "git" signature for x with ED25519 key SHA256:...
+			for i, f := range fields {
+				if f == "key" && i+1 < len(fields) {
+					keys = append(keys, fields[i+1])
+				}
+			}
+		}
+	}
+
+	return keys
+}
+
+// fetchSubmodules downloads any configured submodules recursively.
+func (g *GitGetter) fetchSubmodules(dst, sshKeyFile, knownHostsFile string) error {
+	return g.runGit(g.SubmoduleTimeout, dst, sshKeyFile, knownHostsFile, "submodule", "update", "--init", "--recursive")
+}
+
+// runGit runs git with the given arguments in dir, killing it if it is
+// still running after timeout or once the client's context is done. A zero
+// timeout means the getter's Timeout, if it has one.
+func (g *GitGetter) runGit(timeout time.Duration, dir, sshKeyFile, knownHostsFile string, args ...string) error {
+	return runGitContext(g.Context(), g.timeout(timeout), dir, sshKeyFile, knownHostsFile, args...)
+}
+
+// timeout returns the timeout of a command, or the getter's Timeout if it
+// is zero.
+func (g *GitGetter) timeout(d time.Duration) time.Duration {
+	if d == 0 {
+		return g.Timeout
+	}
+
+	return d
+}
+
+// runGitContext is runGit with the given context in place of the client's.
+func runGitContext(parent context.Context, timeout time.Duration, dir, sshKeyFile, knownHostsFile string, args ...string) error {
+	ctx := parent
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	setupGitEnv(cmd, sshKeyFile, knownHostsFile)
+	err := getRunCommand(cmd)
+	if err != nil && parent.Err() != nil {
+		return fmt.Errorf("git %s cancelled: %s", args[0], parent.Err())
+	}
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("git %s timed out after %s: %s", args[0], timeout, err)
+	}
+
+	return err
+}
+
+// writeSSHKey decodes the base64 encoded SSH key and writes it to a new
+// temporary file in dir, returning its path.
+func writeSSHKey(dir, sshKey string) (string, error) {
+	// We have an SSH key - decode it.
+	raw, err := base64.StdEncoding.DecodeString(sshKey)
+	if err != nil {
+		return "", err
+	}
+
+	fh, err := ioutil.TempFile(dir, "go-getter")
+	if err != nil {
+		return "", err
+	}
+	defer fh.Close()
+
+	// Set the permissions prior to writing the key material.
+	if err := os.Chmod(fh.Name(), 0600); err != nil {
+		os.Remove(fh.Name())
+		return "", err
+	}
+
+	// Write the raw key into the temp file.
+	if _, err := fh.Write(raw); err != nil {
+		os.Remove(fh.Name())
+		return "", err
+	}
+
+	return fh.Name(), nil
 }
 
 // setupGitEnv sets up the environment for the given command. This is used to
 // pass configuration data to git and ssh and enables advanced cloning methods.
-func setupGitEnv(cmd *exec.Cmd, sshKeyFile string) {
+func setupGitEnv(cmd *exec.Cmd, sshKeyFile, knownHostsFile string) {
 	const gitSSHCommand = "GIT_SSH_COMMAND="
 	var sshCmd []string
 
@@ -204,8 +840,17 @@ func setupGitEnv(cmd *exec.Cmd, sshKeyFile string) {
 		// We have an SSH key temp file configured, tell ssh about this.
 		sshCmd = append(sshCmd, "-i", sshKeyFile)
 	}
+	if knownHostsFile != "" {
+		// Only the pinned host keys are trusted.
+		sshCmd = append(sshCmd, sshKnownHostsArgs(knownHostsFile)...)
+		sshCmd = append(sshCmd, "-o", "StrictHostKeyChecking=yes")
+	}
 
 	env = append(env, strings.Join(sshCmd, " "))
+
+	// Never prompt for credentials, there is nobody there to answer and
+	// the command would hang forever.
+	env = append(env, "GIT_TERMINAL_PROMPT=0")
 	cmd.Env = env
 }
 
@@ -0,0 +1,329 @@
+package getter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-safetemp"
+)
+
+// Open implements FSGetter. The repository is cloned without a working
+// tree and, if the remote supports it, without any file contents, which
+// are then fetched as files are read. Submodules aren't included.
+func (g *GitGetter) Open(u *url.URL) (fs.FS, error) {
+	var ref, sshKey string
+	q := u.Query()
+	if len(q) > 0 {
+		ref = q.Get("ref")
+		q.Del("ref")
+
+		sshKey = q.Get("sshkey")
+		q.Del("sshkey")
+
+		// These only affect how a working tree is downloaded
+		q.Del("github_archive")
+		q.Del("keep_git")
+
+		// Copy the URL
+		var newU url.URL = *u
+		u = &newU
+		u.RawQuery = q.Encode()
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git must be available and on the PATH")
+	}
+
+	td, tdcloser, err := safetemp.Dir("", "getter")
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := g.open(td, u, ref, sshKey)
+	if err != nil {
+		tdcloser.Close()
+		return nil, err
+	}
+	f.closer = tdcloser
+
+	return f, nil
+}
+
+// open clones the repository at u into td and returns its file system.
+func (g *GitGetter) open(td string, u *url.URL, ref, sshKey string) (*gitFS, error) {
+	var sshKeyFile string
+	if sshKey != "" {
+		// Check that the git version is sufficiently new.
+		if err := checkGitVersion("2.3"); err != nil {
+			return nil, fmt.Errorf("Error using ssh key: %v", err)
+		}
+
+		// The key is kept for as long as the file system since reading
+		// files may fetch them from the remote.
+		var err error
+		sshKeyFile, err = writeSSHKey(td, sshKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	knownHostsFile, err := g.knownHostsFile(td, u)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remotes that don't support partial clones ignore the filter and
+	// send everything.
+	repo := filepath.Join(td, "repo")
+	err = g.runGit(g.CloneTimeout, "", sshKeyFile, knownHostsFile,
+		"clone", "--bare", "--filter=blob:none", u.String(), repo)
+	if err != nil {
+		return nil, err
+	}
+
+	// Point HEAD at the ref so that the file system, and the signature
+	// check, are of the ref.
+	if ref != "" {
+		err := g.runGit(0, repo, "", "", "update-ref", "--no-deref", "HEAD", ref+"^{commit}")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(g.AllowedSigners) > 0 {
+		if err := g.verifySignature(repo, ref); err != nil {
+			return nil, err
+		}
+	}
+
+	return &gitFS{
+		ctx:            g.Context(),
+		dir:            repo,
+		sshKeyFile:     sshKeyFile,
+		knownHostsFile: knownHostsFile,
+	}, nil
+}
+
+// gitFS is a read-only file system of the tree at HEAD of the bare
+// repository in dir.
+type gitFS struct {
+	ctx            context.Context
+	dir            string
+	sshKeyFile     string
+	knownHostsFile string
+	closer         io.Closer
+}
+
+// Close removes the repository.
+func (f *gitFS) Close() error {
+	return f.closer.Close()
+}
+
+func (f *gitFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return &gitFile{fs: f, entry: &gitEntry{fs: f, name: ".", typ: "tree"}}, nil
+	}
+
+	entries, err := f.lsTree(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if len(entries) != 1 || entries[0].path != name {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return &gitFile{fs: f, entry: entries[0]}, nil
+}
+
+// lsTree lists the entries at the given paths of the tree at HEAD. A path
+// ending in a slash lists the entries of that directory.
+func (f *gitFS) lsTree(paths ...string) ([]*gitEntry, error) {
+	out, err := f.git(append([]string{"ls-tree", "-z", "HEAD", "--"}, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*gitEntry
+	for _, line := range strings.Split(string(out), "\x00") {
+		if line == "" {
+			continue
+		}
+
+		// Each line is "<mode> <type> <object>\t<path>"
+		tab := strings.Index(line, "\t")
+		if tab < 0 {
+			return nil, fmt.Errorf("unexpected git ls-tree output: %q", line)
+		}
+		fields := strings.Fields(line[:tab])
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected git ls-tree output: %q", line)
+		}
+
+		// Submodules aren't part of the repository
+		if fields[1] == "commit" {
+			continue
+		}
+
+		p := line[tab+1:]
+		result = append(result, &gitEntry{
+			fs:     f,
+			name:   path.Base(p),
+			path:   p,
+			mode:   fields[0],
+			typ:    fields[1],
+			object: fields[2],
+		})
+	}
+
+	return result, nil
+}
+
+// git runs git with the given arguments in the repository, returning its
+// output.
+func (f *gitFS) git(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(f.ctx, "git", append([]string{"--literal-pathspecs"}, args...)...)
+	cmd.Dir = f.dir
+	cmd.Stderr = &stderr
+	setupGitEnv(cmd, f.sshKeyFile, f.knownHostsFile)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %s: %s",
+			args[0], err, redactURLCredentials(strings.TrimSpace(stderr.String())))
+	}
+
+	return out, nil
+}
+
+// gitFile is a file or directory opened from a gitFS. The contents of a
+// file are only read from the repository, and fetched from the remote if
+// need be, when it is first read.
+type gitFile struct {
+	fs      *gitFS
+	entry   *gitEntry
+	content *bytes.Reader
+	entries []fs.DirEntry
+	listed  bool
+}
+
+func (f *gitFile) Stat() (fs.FileInfo, error) { return f.entry, nil }
+
+func (f *gitFile) Close() error { return nil }
+
+func (f *gitFile) Read(p []byte) (int, error) {
+	if f.entry.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: f.entry.path, Err: fs.ErrInvalid}
+	}
+
+	if f.content == nil {
+		out, err := f.fs.git("cat-file", "blob", f.entry.object)
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.entry.path, Err: err}
+		}
+		f.content = bytes.NewReader(out)
+	}
+
+	return f.content.Read(p)
+}
+
+func (f *gitFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.entry.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.entry.path, Err: fs.ErrInvalid}
+	}
+
+	if !f.listed {
+		var paths []string
+		if f.entry.path != "" {
+			paths = append(paths, f.entry.path+"/")
+		}
+
+		entries, err := f.fs.lsTree(paths...)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: f.entry.path, Err: err}
+		}
+		for _, e := range entries {
+			f.entries = append(f.entries, e)
+		}
+		f.listed = true
+	}
+
+	if n <= 0 {
+		entries := f.entries
+		f.entries = nil
+		return entries, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(f.entries) {
+		n = len(f.entries)
+	}
+
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+	return entries, nil
+}
+
+// gitEntry is an entry of a tree in a gitFS.
+type gitEntry struct {
+	fs     *gitFS
+	name   string
+	path   string
+	mode   string
+	typ    string
+	object string
+
+	// size is looked up when it is first needed, since that may mean
+	// fetching the file from the remote.
+	size *int64
+}
+
+func (e *gitEntry) Name() string               { return e.name }
+func (e *gitEntry) ModTime() time.Time         { return time.Time{} }
+func (e *gitEntry) IsDir() bool                { return e.typ == "tree" }
+func (e *gitEntry) Sys() interface{}           { return nil }
+func (e *gitEntry) Type() fs.FileMode          { return e.Mode().Type() }
+func (e *gitEntry) Info() (fs.FileInfo, error) { return e, nil }
+
+func (e *gitEntry) Mode() fs.FileMode {
+	switch {
+	case e.IsDir():
+		return fs.ModeDir | 0555
+	case e.mode == "120000":
+		return fs.ModeSymlink | 0444
+	case e.mode == "100755":
+		return 0555
+	default:
+		return 0444
+	}
+}
+
+func (e *gitEntry) Size() int64 {
+	if e.IsDir() {
+		return 0
+	}
+
+	if e.size == nil {
+		var size int64
+		if out, err := e.fs.git("cat-file", "-s", e.object); err == nil {
+			size, _ = strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+		}
+		e.size = &size
+	}
+
+	return *e.size
+}
@@ -0,0 +1,85 @@
+package getter
+
+import (
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestGitGetter_Open(t *testing.T) {
+	if !testHasGit {
+		t.Log("git not found, skipping")
+		t.Skip()
+	}
+
+	repo := testGitRepo(t, "open")
+	defer os.RemoveAll(filepath.Dir(repo.dir))
+	if err := os.Mkdir(filepath.Join(repo.dir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	repo.commitFile("main.tf", "main")
+	repo.commitFile("subdir/sub.tf", "sub")
+	repo.git("tag", "v1.0")
+	repo.commitFile("main.tf", "changed")
+
+	g := new(GitGetter)
+	fsys, err := g.Open(repo.url)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer fsys.(io.Closer).Close()
+
+	if err := fstest.TestFS(fsys, "main.tf", "subdir/sub.tf"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertFSContents(t, fsys, "main.tf", "changed")
+
+	// Open at a ref
+	u, err := url.Parse(repo.url.String() + "?ref=v1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys, err = g.Open(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer fsys.(io.Closer).Close()
+	assertFSContents(t, fsys, "main.tf", "main")
+
+	if _, err := fsys.Open("missing"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestGitGetter_OpenBadRef(t *testing.T) {
+	if !testHasGit {
+		t.Log("git not found, skipping")
+		t.Skip()
+	}
+
+	repo := testGitRepo(t, "open-bad-ref")
+	defer os.RemoveAll(filepath.Dir(repo.dir))
+	repo.commitFile("main.tf", "main")
+
+	u, err := url.Parse(repo.url.String() + "?ref=missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := new(GitGetter).Open(u); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func assertFSContents(t *testing.T, fsys fs.FS, name, expected string) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != expected {
+		t.Fatalf("bad %s: %q", name, data)
+	}
+}
@@ -0,0 +1,514 @@
+package getter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// gitRefs are the refs that a remote advertises, by name, and the
+// capabilities of its upload-pack service. Annotated tags are also
+// advertised peeled, with "^{}" appended to their names.
+type gitRefs struct {
+	refs map[string]string
+	caps map[string]bool
+}
+
+// getNative downloads the files of ref from the remote at u into dst, using
+// Git's smart HTTP protocol directly rather than the git command.
+func (g *GitGetter) getNative(dst string, u *url.URL, ref, sshKey string, keepGit bool) error {
+	switch {
+	case sshKey != "":
+		return fmt.Errorf("sshkey can't be used with native Git clones")
+	case keepGit:
+		return fmt.Errorf("keep_git can't be used with native Git clones")
+	case len(g.AllowedSigners) > 0:
+		return fmt.Errorf("AllowedSigners can't be used with native Git clones")
+	}
+	if g.offline() {
+		return &OfflineError{Sources: []string{redactURLCredentials(u.String())}}
+	}
+
+	if g.Netrc && (u.Scheme == "http" || u.Scheme == "https") {
+		// Copy the URL so we can modify it
+		var newU url.URL = *u
+		u = &newU
+		if err := addAuthFromNetrc(u); err != nil {
+			return err
+		}
+	}
+
+	td, err := ioutil.TempDir("", "getter")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(td)
+
+	tree := filepath.Join(td, "tree")
+	if err := g.cloneNative(tree, td, u, ref, g.CloneTimeout); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	return copyDir(dst, tree, false, g.specialFiles(), g.symlinks())
+}
+
+// cloneNative checks out the files of ref from the remote at u into dir,
+// along with those of its submodules, keeping the packfiles it downloads
+// in td.
+func (g *GitGetter) cloneNative(dir, td string, u *url.URL, ref string, timeout time.Duration) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("native Git clones only support HTTP and HTTPS remotes: %s",
+			redactURLCredentials(u.String()))
+	}
+
+	ctx := g.Context()
+	if timeout = g.timeout(timeout); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	refs, err := g.nativeRefs(ctx, u)
+	if err != nil {
+		return err
+	}
+	want, err := refs.resolve(ref)
+	if err != nil {
+		return err
+	}
+
+	p, err := g.fetchNative(ctx, td, u, refs, want)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+
+	// Peel tags to the commit they are of
+	id := want
+	for {
+		o, err := p.get(id)
+		if err != nil {
+			return err
+		}
+		if o.typ == gitObjCommit {
+			id = gitHeader(o.data, "tree")
+			break
+		}
+		if o.typ != gitObjTag {
+			return fmt.Errorf("ref %s isn't a commit", ref)
+		}
+		id = gitHeader(o.data, "object")
+	}
+
+	submodules := make(map[string]string)
+	if err := checkoutGitTree(p, id, dir, "", submodules); err != nil {
+		return err
+	}
+	if len(submodules) == 0 {
+		return nil
+	}
+
+	// Submodules are checked out at the commits the tree pins them to
+	urls, err := parseGitModules(filepath.Join(dir, ".gitmodules"))
+	if err != nil {
+		return err
+	}
+	for subPath, commit := range submodules {
+		raw, ok := urls[subPath]
+		if !ok {
+			return fmt.Errorf("submodule %s isn't in .gitmodules", subPath)
+		}
+		subURL, err := resolveGitSubmoduleURL(u, raw)
+		if err != nil {
+			return err
+		}
+		subDir := filepath.Join(dir, filepath.FromSlash(subPath))
+		if err := g.cloneNative(subDir, td, subURL, commit, g.SubmoduleTimeout); err != nil {
+			return fmt.Errorf("error cloning submodule %s: %s", subPath, err)
+		}
+	}
+
+	return nil
+}
+
+// nativeRequest sends a request to the Git service at u, with the
+// credentials in its URL as basic auth.
+func (g *GitGetter) nativeRequest(ctx context.Context, method string, u *url.URL, service string, body io.Reader) (*http.Response, error) {
+	target := *u
+	target.User = nil
+	target.Path = strings.TrimSuffix(target.Path, "/") + service
+	target.RawPath = ""
+	if method == "GET" {
+		target.RawQuery = "service=git-upload-pack"
+	}
+
+	req, err := http.NewRequest(method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header = g.header()
+	if method == "POST" {
+		req.Header.Set("Content-Type", "application/x-git-upload-pack-request")
+		req.Header.Set("Accept", "application/x-git-upload-pack-result")
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		req.SetBasicAuth(u.User.Username(), password)
+	}
+
+	resp, err := g.addressClient(httpClient).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: bad response code: %d",
+			method, redactURLCredentials(target.String()), resp.StatusCode)
+	}
+
+	return resp, nil
+}
+
+// nativeRefs asks the remote at u for its refs.
+func (g *GitGetter) nativeRefs(ctx context.Context, u *url.URL) (*gitRefs, error) {
+	resp, err := g.nativeRequest(ctx, "GET", u, "/info/refs", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "application/x-git-upload-pack-advertisement" {
+		return nil, fmt.Errorf("%s isn't a smart HTTP Git remote, which native clones require",
+			redactURLCredentials(u.String()))
+	}
+
+	refs := &gitRefs{refs: make(map[string]string), caps: make(map[string]bool)}
+	r := bufio.NewReader(resp.Body)
+	first := true
+	for {
+		line, err := readPktLine(r)
+		if err != nil {
+			return nil, fmt.Errorf("error reading refs: %s", err)
+		}
+		if line == nil {
+			if first {
+				return nil, fmt.Errorf("remote sent no refs")
+			}
+			if len(refs.caps) == 0 {
+				// The end of the service announcement
+				continue
+			}
+			return refs, nil
+		}
+		first = false
+
+		s := strings.TrimSuffix(string(line), "\n")
+		if strings.HasPrefix(s, "# service=") {
+			continue
+		}
+		if strings.HasPrefix(s, "ERR ") {
+			return nil, fmt.Errorf("remote error: %s", s[4:])
+		}
+		if i := strings.IndexByte(s, 0); i >= 0 {
+			for _, c := range strings.Fields(s[i+1:]) {
+				refs.caps[c] = true
+			}
+			s = s[:i]
+		}
+		fields := strings.Fields(s)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid ref advertisement: %q", s)
+		}
+		refs.refs[fields[1]] = fields[0]
+	}
+}
+
+// resolve returns the ID of the object that ref names, or that HEAD is if
+// ref is empty.
+func (r *gitRefs) resolve(ref string) (string, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	for _, name := range []string{ref, "refs/heads/" + ref, "refs/tags/" + ref} {
+		if id, ok := r.refs[name]; ok {
+			return id, nil
+		}
+	}
+
+	if isCommitID(ref) {
+		if len(ref) == 40 {
+			return ref, nil
+		}
+
+		// An abbreviated commit ID can only be found among the refs
+		var found string
+		for _, id := range r.refs {
+			if strings.HasPrefix(id, ref) {
+				if found != "" && found != id {
+					return "", fmt.Errorf("commit ID %s is ambiguous", ref)
+				}
+				found = id
+			}
+		}
+		if found != "" {
+			return found, nil
+		}
+		return "", fmt.Errorf("abbreviated commit ID %s isn't a ref of the remote, use all of it", ref)
+	}
+
+	return "", fmt.Errorf("ref %s not found on the remote", ref)
+}
+
+// fetchNative downloads a packfile with the object want from the remote at
+// u into td and reads it. Only the object's own commit is fetched if the
+// remote supports shallow clones, since its history isn't kept.
+func (g *GitGetter) fetchNative(ctx context.Context, td string, u *url.URL, refs *gitRefs, want string) (*gitPack, error) {
+	var caps []string
+	for _, c := range []string{"side-band-64k", "ofs-delta", "shallow", "no-progress"} {
+		if refs.caps[c] {
+			caps = append(caps, c)
+		}
+	}
+	if !refs.caps["side-band-64k"] && refs.caps["side-band"] {
+		caps = append(caps, "side-band")
+	}
+
+	var req bytes.Buffer
+	req.WriteString(pktLine("want " + want + " " + strings.Join(caps, " ") + "\n"))
+	if refs.caps["shallow"] {
+		req.WriteString(pktLine("deepen 1\n"))
+	}
+	req.WriteString("0000")
+	req.WriteString(pktLine("done\n"))
+
+	resp, err := g.nativeRequest(ctx, "POST", u, "/git-upload-pack", &req)
+	if err != nil {
+		return nil, err
+	}
+	body := g.trackProgress(path.Base(u.Path), 0, -1, resp.Body)
+	defer body.Close()
+	r := bufio.NewReader(body)
+
+	// The shallow commits come first, then the acknowledgement
+	if refs.caps["shallow"] {
+		for {
+			line, err := readPktLine(r)
+			if err != nil {
+				return nil, fmt.Errorf("error reading shallow commits: %s", err)
+			}
+			if line == nil {
+				break
+			}
+			if bytes.HasPrefix(line, []byte("ERR ")) {
+				return nil, fmt.Errorf("remote error: %s", bytes.TrimSpace(line[4:]))
+			}
+		}
+	}
+	line, err := readPktLine(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading fetch response: %s", err)
+	}
+	if bytes.HasPrefix(line, []byte("ERR ")) {
+		return nil, fmt.Errorf("remote error: %s", bytes.TrimSpace(line[4:]))
+	}
+	if string(bytes.TrimSpace(line)) != "NAK" {
+		return nil, fmt.Errorf("unexpected fetch response: %q", line)
+	}
+
+	f, err := ioutil.TempFile(td, "pack")
+	if err != nil {
+		return nil, err
+	}
+
+	if refs.caps["side-band-64k"] || refs.caps["side-band"] {
+		err = readGitSideBand(r, f)
+	} else {
+		_, err = io.Copy(f, r)
+	}
+	var p *gitPack
+	if err == nil {
+		var fi os.FileInfo
+		if fi, err = f.Stat(); err == nil {
+			p, err = readGitPack(f, fi.Size())
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// The pack is read from the file as its objects are checked out
+	p.closer = f
+	return p, nil
+}
+
+// readGitSideBand copies the packfile that is multiplexed in r to w.
+func readGitSideBand(r io.Reader, w io.Writer) error {
+	for {
+		line, err := readPktLine(r)
+		if err != nil {
+			return fmt.Errorf("error reading packfile: %s", err)
+		}
+		if line == nil {
+			return nil
+		}
+		if len(line) == 0 {
+			continue
+		}
+
+		switch line[0] {
+		case 1:
+			if _, err := w.Write(line[1:]); err != nil {
+				return err
+			}
+		case 2:
+			// Progress messages
+		case 3:
+			return fmt.Errorf("remote error: %s", bytes.TrimSpace(line[1:]))
+		default:
+			return fmt.Errorf("invalid side-band %d", line[0])
+		}
+	}
+}
+
+// checkoutGitTree writes the files of the tree with the given ID into dir.
+// The commits that submodules are pinned to are added to submodules by
+// their path, which is relative to the top of the checkout and starts
+// with prefix.
+func checkoutGitTree(p *gitPack, id, dir, prefix string, submodules map[string]string) error {
+	o, err := p.get(id)
+	if err != nil {
+		return err
+	}
+	if o.typ != gitObjTree {
+		return fmt.Errorf("object %s isn't a tree", id)
+	}
+	entries, err := parseGitTree(o.data)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		// Names must stay within the tree, as git itself insists
+		if e.name == "" || e.name == "." || e.name == ".." || strings.EqualFold(e.name, ".git") ||
+			strings.ContainsAny(e.name, `/\`) {
+			return fmt.Errorf("tree has an invalid entry name: %q", e.name)
+		}
+		path := filepath.Join(dir, e.name)
+
+		switch e.mode {
+		case "40000":
+			err = checkoutGitTree(p, e.id, path, prefix+e.name+"/", submodules)
+		case "160000":
+			submodules[prefix+e.name] = e.id
+			err = os.MkdirAll(path, 0755)
+		case "100644", "100755", "120000":
+			var blob *gitObject
+			if blob, err = p.get(e.id); err != nil {
+				return err
+			}
+			switch e.mode {
+			case "120000":
+				err = os.Symlink(string(blob.data), path)
+			case "100755":
+				err = ioutil.WriteFile(path, blob.data, 0755)
+			default:
+				err = ioutil.WriteFile(path, blob.data, 0644)
+			}
+		default:
+			err = fmt.Errorf("tree entry %s has an unknown mode %s", e.name, e.mode)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseGitModules returns the URLs of the submodules in the .gitmodules
+// file at path, by their path.
+func parseGitModules(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading .gitmodules: %s", err)
+	}
+
+	paths := make(map[string]string)
+	urls := make(map[string]string)
+	var name string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			name = ""
+			section := strings.Trim(line, "[]")
+			if strings.HasPrefix(section, "submodule ") {
+				name = strings.Trim(strings.TrimSpace(section[len("submodule "):]), `"`)
+			}
+			continue
+		}
+		if name == "" {
+			continue
+		}
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.Trim(strings.TrimSpace(line[i+1:]), `"`)
+		switch key {
+		case "path":
+			paths[name] = value
+		case "url":
+			urls[name] = value
+		}
+	}
+
+	byPath := make(map[string]string)
+	for name, p := range paths {
+		if u, ok := urls[name]; ok {
+			byPath[p] = u
+		}
+	}
+	return byPath, nil
+}
+
+// resolveGitSubmoduleURL returns the URL of a submodule, which may be
+// relative to the URL of its superproject, u.
+func resolveGitSubmoduleURL(u *url.URL, raw string) (*url.URL, error) {
+	if strings.HasPrefix(raw, "./") || strings.HasPrefix(raw, "../") {
+		base := *u
+		base.Path = strings.TrimSuffix(base.Path, "/") + "/"
+		base.RawPath = ""
+		rel, err := url.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		return base.ResolveReference(rel), nil
+	}
+
+	return url.Parse(raw)
+}
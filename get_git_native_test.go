@@ -0,0 +1,255 @@
+package getter
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testGitHTTPServer serves the repositories in the directory root over
+// Git's smart HTTP protocol with git http-backend.
+func testGitHTTPServer(t *testing.T, root string) *httptest.Server {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return httptest.NewServer(&cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Env: []string{
+			"GIT_PROJECT_ROOT=" + root,
+			"GIT_HTTP_EXPORT_ALL=1",
+		},
+	})
+}
+
+// testGitNativeURL returns the URL that the repo is served at by server.
+func testGitNativeURL(t *testing.T, server *httptest.Server, repo *gitRepo, query string) *url.URL {
+	u, err := url.Parse(server.URL + "/" + filepath.Base(repo.dir) + query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestGitGetter_native(t *testing.T) {
+	if !testHasGit {
+		t.Log("git not found, skipping")
+		t.Skip()
+	}
+
+	repo := testGitRepo(t, "native")
+	defer os.RemoveAll(filepath.Dir(repo.dir))
+	server := testGitHTTPServer(t, filepath.Dir(repo.dir))
+	defer server.Close()
+
+	// Two large files that are alike, so that one is sent as a delta of
+	// the other
+	big := strings.Repeat("The quick brown fox jumps over the lazy dog.\n", 400)
+	repo.commitFile("a.txt", big)
+	repo.commitFile("b.txt", big+"One more line.\n")
+	if err := os.MkdirAll(filepath.Join(repo.dir, "sub", "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	repo.commitFile("sub/dir/run.sh", "#!/bin/sh\n")
+	if err := os.Chmod(filepath.Join(repo.dir, "sub", "dir", "run.sh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	repo.git("add", "sub/dir/run.sh")
+	repo.git("commit", "-m", "Make run.sh executable")
+	repo.git("tag", "-a", "v1.0", "-m", "v1.0")
+	repo.commitFile("later.txt", "later")
+
+	g := &GitGetter{Native: true}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+	if err := g.Get(dst, testGitNativeURL(t, server, repo, "")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "a.txt"), big)
+	assertContents(t, filepath.Join(dst, "b.txt"), big+"One more line.\n")
+	assertContents(t, filepath.Join(dst, "later.txt"), "later")
+	if _, err := os.Stat(filepath.Join(dst, ".git")); !os.IsNotExist(err) {
+		t.Fatalf("should have no .git: %v", err)
+	}
+	fi, err := os.Stat(filepath.Join(dst, "sub", "dir", "run.sh"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fi.Mode()&0100 == 0 {
+		t.Fatalf("should be executable: %s", fi.Mode())
+	}
+
+	// An annotated tag replaces what is there
+	if err := g.Get(dst, testGitNativeURL(t, server, repo, "?ref=v1.0")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "a.txt"), big)
+	if _, err := os.Stat(filepath.Join(dst, "later.txt")); !os.IsNotExist(err) {
+		t.Fatalf("should be removed: %v", err)
+	}
+
+	// A ref that doesn't exist
+	if err := g.Get(dst, testGitNativeURL(t, server, repo, "?ref=nope")); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestGitGetter_nativeSubmodule(t *testing.T) {
+	if !testHasGit {
+		t.Log("git not found, skipping")
+		t.Skip()
+	}
+
+	root, err := ioutil.TempDir("", "go-getter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	server := testGitHTTPServer(t, root)
+	defer server.Close()
+
+	child := &gitRepo{t: t, dir: filepath.Join(root, "child")}
+	parent := &gitRepo{t: t, dir: filepath.Join(root, "parent")}
+	for _, r := range []*gitRepo{child, parent} {
+		if err := os.Mkdir(r.dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		r.git("init")
+		r.git("config", "user.name", "go-getter")
+		r.git("config", "user.email", "go-getter@hashicorp.com")
+	}
+	child.commitFile("child.txt", "child")
+
+	parent.commitFile("parent.txt", "parent")
+	parent.git("-c", "protocol.file.allow=always", "submodule", "add", "../child", "modules/child")
+	parent.git("commit", "-m", "Add child")
+
+	g := &GitGetter{Native: true}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+	if err := g.Get(dst, testGitNativeURL(t, server, parent, "")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "parent.txt"), "parent")
+	assertContents(t, filepath.Join(dst, "modules", "child", "child.txt"), "child")
+}
+
+func TestGitGetter_nativeUnsupported(t *testing.T) {
+	g := &GitGetter{Native: true}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	cases := []string{
+		"https://example.com/repo.git?sshkey=abc",
+		"https://example.com/repo.git?keep_git=true",
+		"ssh://git@example.com/repo.git",
+		"file:///tmp/repo",
+	}
+	for _, tc := range cases {
+		u, err := url.Parse(tc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Get(dst, u); err == nil {
+			t.Fatalf("%s: should error", tc)
+		}
+	}
+}
+
+func TestGitGetter_nativeDumbRemote(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789012345678901234567890123456789\trefs/heads/master\n"))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL + "/repo.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+	err = (&GitGetter{Native: true}).Get(dst, u)
+	if err == nil || !strings.Contains(err.Error(), "smart HTTP") {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestGitRefs_resolve(t *testing.T) {
+	refs := &gitRefs{refs: map[string]string{
+		"HEAD":               "1111111111111111111111111111111111111111",
+		"refs/heads/main":    "1111111111111111111111111111111111111111",
+		"refs/tags/v1":       "2222222222222222222222222222222222222222",
+		"refs/heads/feature": "2223333333333333333333333333333333333333",
+	}}
+
+	cases := []struct {
+		ref, want string
+		err       bool
+	}{
+		{"", "1111111111111111111111111111111111111111", false},
+		{"main", "1111111111111111111111111111111111111111", false},
+		{"v1", "2222222222222222222222222222222222222222", false},
+		{"refs/tags/v1", "2222222222222222222222222222222222222222", false},
+		{"1111111", "1111111111111111111111111111111111111111", false},
+		{"2222", "", true},
+		{"2223333", "2223333333333333333333333333333333333333", false},
+		{"222", "", true},
+		{"abcdef0123456789abcdef0123456789abcdef01", "abcdef0123456789abcdef0123456789abcdef01", false},
+		{"nope", "", true},
+	}
+	for _, tc := range cases {
+		got, err := refs.resolve(tc.ref)
+		if (err != nil) != tc.err {
+			t.Fatalf("%q: err: %v", tc.ref, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%q: got %s", tc.ref, got)
+		}
+	}
+}
+
+func TestParseGitModules(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	if err := os.MkdirAll(td, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(td, ".gitmodules")
+	err := ioutil.WriteFile(path, []byte(`[submodule "a"]
+	path = modules/a
+	url = ../a.git
+# A comment
+[submodule "b"]
+	path = b
+	url = "https://example.com/b.git"
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	urls, err := parseGitModules(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if urls["modules/a"] != "../a.git" || urls["b"] != "https://example.com/b.git" || len(urls) != 2 {
+		t.Fatalf("bad: %#v", urls)
+	}
+
+	u, _ := url.Parse("https://example.com/org/repo.git")
+	sub, err := resolveGitSubmoduleURL(u, "../a.git")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if sub.String() != "https://example.com/org/a.git" {
+		t.Fatalf("bad: %s", sub)
+	}
+}
@@ -1,15 +1,20 @@
 package getter
 
 import (
+	"context"
 	"encoding/base64"
 	"io/ioutil"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 var testHasGit bool
@@ -48,6 +53,28 @@ func TestGitGetter(t *testing.T) {
 	}
 }
 
+func TestGitGetter_cancelled(t *testing.T) {
+	if !testHasGit {
+		t.Log("git not found, skipping")
+		t.Skip()
+	}
+
+	repo := testGitRepo(t, "cancelled")
+	defer os.RemoveAll(filepath.Dir(repo.dir))
+	repo.commitFile("foo.txt", "hello")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	g := new(GitGetter)
+	g.client = &Client{Ctx: ctx}
+
+	dst := filepath.Join(tempDir(t), "dst")
+	err := g.Get(dst, repo.url)
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
 func TestGitGetter_branch(t *testing.T) {
 	if !testHasGit {
 		t.Log("git not found, skipping")
@@ -169,6 +196,88 @@ func TestGitGetter_tag(t *testing.T) {
 	}
 }
 
+func TestGitGetter_depth(t *testing.T) {
+	if !testHasGit {
+		t.Log("git not found, skipping")
+		t.Skip()
+	}
+
+	g := new(GitGetter)
+	dst := tempDir(t)
+
+	repo := testGitRepo(t, "depth")
+	repo.commitFile("a.txt", "a")
+	first := testGitOutput(t, repo.dir, "rev-parse", "HEAD")
+	repo.commitFile("b.txt", "b")
+	repo.git("tag", "v1.0")
+	repo.commitFile("c.txt", "c")
+
+	u := *repo.url
+	u.RawQuery = "depth=1"
+	if err := g.Get(dst, &u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if n := testGitOutput(t, dst, "rev-list", "--count", "HEAD"); n != "1" {
+		t.Fatalf("bad commits: %s", n)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "c.txt")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Updates stay shallow
+	repo.commitFile("d.txt", "d")
+	if err := g.Get(dst, &u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "d.txt")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if n := testGitOutput(t, dst, "rev-list", "--count", "HEAD"); n != "1" {
+		t.Fatalf("bad commits: %s", n)
+	}
+
+	// Tags, other branches and commits can be cloned, and fetched into an
+	// existing clone
+	repo.git("branch", "other", first)
+	for _, ref := range []string{"v1.0", "other", first} {
+		u.RawQuery = "depth=1&ref=" + ref
+		for _, d := range []string{tempDir(t), dst} {
+			if err := g.Get(d, &u); err != nil {
+				t.Fatalf("%s: err: %s", ref, err)
+			}
+			if _, err := os.Stat(filepath.Join(d, "a.txt")); err != nil {
+				t.Fatalf("%s: err: %s", ref, err)
+			}
+			if _, err := os.Stat(filepath.Join(d, "c.txt")); err == nil {
+				t.Fatalf("%s: c.txt shouldn't exist", ref)
+			}
+		}
+	}
+
+	u.RawQuery = "depth=-1"
+	if err := g.Get(tempDir(t), &u); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestIsCommitID(t *testing.T) {
+	cases := map[string]bool{
+		"":         false,
+		"abc123":   false,
+		"abc1234":  true,
+		"ABC1234":  false,
+		"v1.0.0":   false,
+		"master":   false,
+		"deadbeef": true,
+		"0123456789abcdef0123456789abcdef01234567": true,
+	}
+	for ref, expected := range cases {
+		if actual := isCommitID(ref); actual != expected {
+			t.Fatalf("%q: expected %t", ref, expected)
+		}
+	}
+}
+
 func TestGitGetter_GetFile(t *testing.T) {
 	if !testHasGit {
 		t.Log("git not found, skipping")
@@ -194,6 +303,390 @@ func TestGitGetter_GetFile(t *testing.T) {
 	assertContents(t, dst, "hello")
 }
 
+func TestGitGetter_githubArchive(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer ln.Close()
+
+	var requested string
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requested = r.URL.Path
+			http.ServeFile(w, r, filepath.Join(fixtureDir, "archive-rooted", "archive.tar.gz"))
+		}),
+	}
+	go server.Serve(ln)
+
+	defer func(old string) { gitHubArchiveURL = old }(gitHubArchiveURL)
+	gitHubArchiveURL = "http://" + ln.Addr().String()
+
+	g := new(GitGetter)
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	u := testURL("https://github.com/hashicorp/foo.git?github_archive=true&ref=v1.0.0")
+	if err := g.Get(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if requested != "/hashicorp/foo/tar.gz/v1.0.0" {
+		t.Fatalf("bad path: %s", requested)
+	}
+
+	// The top level directory of the archive is stripped
+	mainPath := filepath.Join(dst, "hello.txt")
+	if _, err := os.Stat(mainPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestGitGetter_keepGit(t *testing.T) {
+	if !testHasGit {
+		t.Log("git not found, skipping")
+		t.Skip()
+	}
+
+	g := new(GitGetter)
+	dst := tempDir(t)
+
+	repo := testGitRepo(t, "keep-git")
+	repo.commitFile("foo.txt", "hello")
+
+	// keep_git takes precedence over github_archive, which would otherwise
+	// fail since the repository isn't on GitHub.
+	q := repo.url.Query()
+	q.Add("github_archive", "true")
+	q.Add("keep_git", "true")
+	repo.url.RawQuery = q.Encode()
+
+	if err := g.Get(dst, repo.url); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dst, ".git")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestGitGetter_keepGitSubdir(t *testing.T) {
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	// Only the subdirectory would be copied, without the .git directory,
+	// so this fails before anything is cloned
+	client := &Client{
+		Src:  "git::https://example.com/repo.git//modules/foo?ref=v1&keep_git=true",
+		Dst:  dst,
+		Mode: ClientModeDir,
+	}
+	err := client.Get()
+	if err == nil || !strings.Contains(err.Error(), "keep_git can't be used with a subdirectory") {
+		t.Fatalf("bad: %v", err)
+	}
+	if err := client.Validate(client.Src); err == nil {
+		t.Fatal("should not be valid")
+	}
+
+	client.Src = "git::https://example.com/repo.git//modules/foo?ref=v1&keep_git=false"
+	if err := client.Validate(client.Src); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestGitGetter_cacheDir(t *testing.T) {
+	if !testHasGit {
+		t.Log("git not found, skipping")
+		t.Skip()
+	}
+
+	cacheDir := tempDir(t)
+	defer os.RemoveAll(cacheDir)
+	g := &GitGetter{CacheDir: cacheDir}
+
+	repo := testGitRepo(t, "cache")
+	repo.commitFile("foo.txt", "hello")
+
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+	if err := g.Get(dst, repo.url); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A second clone should reuse and update the mirror
+	repo.commitFile("bar.txt", "world")
+	dst2 := tempDir(t)
+	defer os.RemoveAll(dst2)
+	if err := g.Get(dst2, repo.url); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, path := range []string{
+		filepath.Join(dst, "foo.txt"),
+		filepath.Join(dst2, "foo.txt"),
+		filepath.Join(dst2, "bar.txt"),
+	} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// The mirror's lock file sits beside it
+	entries, err := ioutil.ReadDir(cacheDir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var mirrors []string
+	for _, e := range entries {
+		if e.IsDir() {
+			mirrors = append(mirrors, e.Name())
+		}
+	}
+	if len(mirrors) != 1 {
+		t.Fatalf("expected one mirror, got %d", len(mirrors))
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, mirrors[0]+".lock")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Destinations must not depend on the mirror
+	alternates := filepath.Join(dst2, ".git", "objects", "info", "alternates")
+	if _, err := os.Stat(alternates); !os.IsNotExist(err) {
+		t.Fatalf("destination references the mirror: %s", err)
+	}
+}
+
+func TestGitGetter_cacheStaleAfter(t *testing.T) {
+	if !testHasGit {
+		t.Log("git not found, skipping")
+		t.Skip()
+	}
+
+	cacheDir := tempDir(t)
+	defer os.RemoveAll(cacheDir)
+	g := &GitGetter{CacheDir: cacheDir, CacheStaleAfter: time.Hour}
+
+	repo := testGitRepo(t, "cache-stale")
+	repo.commitFile("foo.txt", "hello")
+
+	// The first clone creates the mirror
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	if err := g.Get(filepath.Join(td, "a"), repo.url); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	mirror := g.mirrorPath(repo.url)
+
+	// While the mirror is fresh clones come from it alone
+	repo.commitFile("bar.txt", "world")
+	dst := filepath.Join(td, "b")
+	if err := g.Get(dst, repo.url); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "bar.txt")); !os.IsNotExist(err) {
+		t.Fatalf("clone should come from the mirror: %v", err)
+	}
+
+	// It still points at the remote though
+	cmd := exec.Command("git", "remote", "get-url", "origin")
+	cmd.Dir = dst
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != repo.url.String() {
+		t.Fatalf("bad origin: %s", got)
+	}
+
+	// A ref the mirror doesn't have yet is fetched from the remote
+	repo.git("tag", "v1.0")
+	q := repo.url.Query()
+	q.Set("ref", "v1.0")
+	u := *repo.url
+	u.RawQuery = q.Encode()
+	dst = filepath.Join(td, "c")
+	if err := g.Get(dst, &u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "bar.txt")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Once stale the mirror is served and then updated in the background
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(mirror, old, old); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	dst = filepath.Join(td, "d")
+	if err := g.Get(dst, repo.url); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "bar.txt")); !os.IsNotExist(err) {
+		t.Fatalf("clone should come from the stale mirror: %v", err)
+	}
+
+	// The update holds the mirror's lock until it is done
+	l, err := lockPath(mirror, 10*time.Second)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	l.Unlock()
+	fi, err := os.Stat(mirror)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !fi.ModTime().After(old) {
+		t.Fatal("mirror wasn't updated")
+	}
+
+	dst = filepath.Join(td, "e")
+	if err := g.Get(dst, repo.url); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "bar.txt")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestGitGetter_offline(t *testing.T) {
+	if !testHasGit {
+		t.Log("git not found, skipping")
+		t.Skip()
+	}
+
+	cacheDir := tempDir(t)
+	defer os.RemoveAll(cacheDir)
+	g := &GitGetter{CacheDir: cacheDir}
+
+	repo := testGitRepo(t, "offline")
+	repo.commitFile("foo.txt", "hello")
+
+	// Without a mirror the repository isn't available
+	g.client = &Client{Offline: true}
+	if g.Local(repo.url) {
+		t.Fatal("should not be local")
+	}
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	if _, ok := g.Get(filepath.Join(td, "a"), repo.url).(*OfflineError); !ok {
+		t.Fatal("should be an OfflineError")
+	}
+
+	// Once there is one it is cloned without contacting the remote
+	g.client = nil
+	if err := g.Get(filepath.Join(td, "b"), repo.url); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	repo.commitFile("bar.txt", "world")
+	os.RemoveAll(repo.dir)
+
+	g.client = &Client{Offline: true}
+	if !g.Local(repo.url) {
+		t.Fatal("should be local")
+	}
+	dst := filepath.Join(td, "c")
+	if err := g.Get(dst, repo.url); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "foo.txt"), "hello")
+	if _, err := os.Stat(filepath.Join(dst, "bar.txt")); !os.IsNotExist(err) {
+		t.Fatalf("mirror should not be updated: %v", err)
+	}
+
+	// An existing clone is left as it is
+	if err := g.Get(dst, repo.url); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestGitGetter_allowedSignersUnsigned(t *testing.T) {
+	if !testHasGit {
+		t.Log("git not found, skipping")
+		t.Skip()
+	}
+
+	g := &GitGetter{AllowedSigners: []string{"ABCDEF0123456789"}}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	repo := testGitRepo(t, "unsigned")
+	repo.commitFile("foo.txt", "hello")
+
+	if err := g.Get(dst, repo.url); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestGitSignatureKeys(t *testing.T) {
+	cases := []struct {
+		Output string
+		Keys   []string
+	}{
+		{
+			"",
+			nil,
+		},
+		{
+			`[GNUPG:] NEWSIG
+[GNUPG:] GOODSIG 4AEE18F83AFDEB23 GitHub <noreply@github.com>
+[GNUPG:] VALIDSIG 5DE3E0509C47EA3CF04A42D34AEE18F83AFDEB23 2017-08-16 1502905098 0 4 0 1 8 00 5DE3E0509C47EA3CF04A42D34AEE18F83AFDEB23
+[GNUPG:] TRUST_UNDEFINED 0 pgp`,
+			[]string{
+				"4AEE18F83AFDEB23",
+				"5DE3E0509C47EA3CF04A42D34AEE18F83AFDEB23",
+				"5DE3E0509C47EA3CF04A42D34AEE18F83AFDEB23",
+			},
+		},
+		{
+			`[GNUPG:] BADSIG 4AEE18F83AFDEB23 GitHub <noreply@github.com>`,
+			nil,
+		},
+		{
+			`Good "git" signature for foo@example.com with ED25519 key SHA256:u6DLZPSmJ4Ep0ulSXiUhK4UjO77lskSXBgkyLQEojLk`,
+			[]string{"SHA256:u6DLZPSmJ4Ep0ulSXiUhK4UjO77lskSXBgkyLQEojLk"},
+		},
+	}
+
+	for i, tc := range cases {
+		actual := gitSignatureKeys(tc.Output)
+		if !reflect.DeepEqual(actual, tc.Keys) {
+			t.Fatalf("%d: bad: %#v", i, actual)
+		}
+	}
+}
+
+func TestGitGetter_cloneTimeout(t *testing.T) {
+	if !testHasGit {
+		t.Log("git not found, skipping")
+		t.Skip()
+	}
+
+	g := &GitGetter{CloneTimeout: time.Nanosecond}
+	dst := tempDir(t)
+
+	repo := testGitRepo(t, "timeout")
+	repo.commitFile("foo.txt", "hello")
+
+	err := g.Get(dst, repo.url)
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestGitGetter_githubArchiveNotGitHub(t *testing.T) {
+	g := new(GitGetter)
+	dst := tempDir(t)
+
+	u := testURL("https://example.com/hashicorp/foo.git?github_archive=true")
+	if err := g.Get(dst, u); err == nil {
+		t.Fatal("should error")
+	}
+}
+
 func TestGitGetter_gitVersion(t *testing.T) {
 	dir, err := ioutil.TempDir("", "go-getter")
 	if err != nil {
@@ -303,7 +796,7 @@ func TestGitGetter_setupGitEnv_sshKey(t *testing.T) {
 	}
 
 	cmd := exec.Command("/bin/sh", "-c", "echo $GIT_SSH_COMMAND")
-	setupGitEnv(cmd, "/tmp/foo.pem")
+	setupGitEnv(cmd, "/tmp/foo.pem", "")
 	out, err := cmd.Output()
 	if err != nil {
 		t.Fatal(err)
@@ -326,7 +819,7 @@ func TestGitGetter_setupGitEnvWithExisting_sshKey(t *testing.T) {
 	defer os.Setenv("GIT_SSH_COMMAND", "")
 
 	cmd := exec.Command("/bin/sh", "-c", "echo $GIT_SSH_COMMAND")
-	setupGitEnv(cmd, "/tmp/foo.pem")
+	setupGitEnv(cmd, "/tmp/foo.pem", "")
 	out, err := cmd.Output()
 	if err != nil {
 		t.Fatal(err)
@@ -338,6 +831,55 @@ func TestGitGetter_setupGitEnvWithExisting_sshKey(t *testing.T) {
 	}
 }
 
+func TestGitGetter_setupGitEnv_knownHosts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skipf("skipping on windows since the test requires sh")
+		return
+	}
+
+	defer tempEnv(t, "GIT_SSH_COMMAND", "ssh")()
+
+	cmd := exec.Command("/bin/sh", "-c", "echo $GIT_SSH_COMMAND")
+	setupGitEnv(cmd, "", "/tmp/known_hosts")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := strings.TrimSpace(string(out))
+	expected := "ssh -o UserKnownHostsFile=/tmp/known_hosts -o GlobalKnownHostsFile=/dev/null -o StrictHostKeyChecking=yes"
+	if actual != expected {
+		t.Fatalf("unexpected GIT_SSH_COMMAND: %q", actual)
+	}
+}
+
+func TestGitGetter_knownHostsFile(t *testing.T) {
+	g := &GitGetter{HostKeys: map[string][]string{
+		"example.com": {testSSHHostKey},
+	}}
+
+	// Only SSH remotes use the pinned keys
+	for _, tc := range []struct {
+		URL    string
+		Pinned bool
+	}{
+		{"ssh://git@example.com/foo.git", true},
+		{"https://example.com/foo.git", false},
+		{"ssh://git@example.org/foo.git", false},
+	} {
+		path, err := g.knownHostsFile("", testURL(tc.URL))
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.URL, err)
+		}
+		if path != "" {
+			os.Remove(path)
+		}
+		if (path != "") != tc.Pinned {
+			t.Fatalf("%s: bad: %q", tc.URL, path)
+		}
+	}
+}
+
 // gitRepo is a helper struct which controls a single temp git repo.
 type gitRepo struct {
 	t   *testing.T
@@ -374,6 +916,18 @@ func testGitRepo(t *testing.T, name string) *gitRepo {
 	return r
 }
 
+// testGitOutput runs a git command in dir and returns its trimmed output.
+func testGitOutput(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git %s: %s", strings.Join(args, " "), err)
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
 // git runs a git command against the repo.
 func (r *gitRepo) git(args ...string) {
 	cmd := exec.Command("git", args...)
@@ -0,0 +1,240 @@
+package getter
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/hashicorp/go-safetemp"
+	"golang.org/x/crypto/openpgp"
+)
+
+// DefaultHCReleasesURL is where HCReleasesGetter finds releases if its
+// BaseURL isn't set.
+const DefaultHCReleasesURL = "https://releases.hashicorp.com"
+
+// HCReleasesGetter is a Getter implementation that downloads a release of
+// a HashiCorp product, given by a URL such as
+// hcrel::terraform/1.5.7/linux/amd64, or hcrel://terraform/1.5.7/linux/amd64.
+// The operating system and architecture default to the current platform.
+//
+// The release's metadata is looked up on the releases site to find the zip
+// archive for the platform, and its SHA256SUMS file is verified against its
+// detached signature with the keyring of the HCReleasesGetter, or of the
+// Client using it. The archive is then downloaded and verified against its
+// checksum in SHA256SUMS. Directory downloads unpack the archive, and file
+// downloads keep it as it is.
+type HCReleasesGetter struct {
+	getter
+
+	// BaseURL is the releases site. This defaults to
+	// DefaultHCReleasesURL if left unset.
+	BaseURL string
+
+	// Keyring is the keys that SHA256SUMS files must be signed by, such as
+	// HashiCorp's public key from https://www.hashicorp.com/security. If it
+	// isn't set, the Client's GPGKeyring is used, and releases can't be
+	// downloaded if that isn't set either.
+	Keyring openpgp.KeyRing
+}
+
+func (g *HCReleasesGetter) withClient(c *Client) Getter {
+	cp := *g
+	cp.client = c
+	return &cp
+}
+
+// hcRelease is a release of a product, as described by the index.json in the
+// release's directory of the releases site.
+type hcRelease struct {
+	Name              string       `json:"name"`
+	Version           string       `json:"version"`
+	Shasums           string       `json:"shasums"`
+	ShasumsSignature  string       `json:"shasums_signature"`
+	ShasumsSignatures []string     `json:"shasums_signatures"`
+	Builds            []hcRelBuild `json:"builds"`
+}
+
+// hcRelBuild is the archive of a release for one platform.
+type hcRelBuild struct {
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`
+	Filename string `json:"filename"`
+	URL      string `json:"url"`
+}
+
+func (g *HCReleasesGetter) Describe() Description {
+	return Description{
+		Summary: "A verified release of a HashiCorp product, by name, version, OS and architecture.",
+		Strict:  true,
+	}
+}
+
+func (g *HCReleasesGetter) ClientMode(_ *url.URL) (ClientMode, error) {
+	return ClientModeDir, nil
+}
+
+func (g *HCReleasesGetter) Get(dst string, u *url.URL) error {
+	source, err := g.resolve(u)
+	if err != nil {
+		return err
+	}
+
+	return g.subClient(source+"&archive=zip", dst, ClientModeDir).Get()
+}
+
+func (g *HCReleasesGetter) GetFile(dst string, u *url.URL) error {
+	source, err := g.resolve(u)
+	if err != nil {
+		return err
+	}
+
+	return g.subClient(source+"&archive=false", dst, ClientModeFile).Get()
+}
+
+// resolve returns the source of the archive of the release that u names,
+// with its checksum from the release's verified SHA256SUMS.
+func (g *HCReleasesGetter) resolve(u *url.URL) (string, error) {
+	product, version, goos, arch, err := parseHCRelURL(u)
+	if err != nil {
+		return "", err
+	}
+
+	keyring := g.Keyring
+	if keyring == nil && g.client != nil {
+		keyring = g.client.GPGKeyring
+	}
+	if keyring == nil {
+		return "", fmt.Errorf(
+			"downloading %s %s requires a keyring to verify the release's signature", product, version)
+	}
+
+	base := g.BaseURL
+	if base == "" {
+		base = DefaultHCReleasesURL
+	}
+	dir := fmt.Sprintf("%s/%s/%s/", strings.TrimSuffix(base, "/"), url.PathEscape(product), url.PathEscape(version))
+
+	data, err := g.fetch(dir + "index.json")
+	if err != nil {
+		return "", fmt.Errorf("error looking up %s %s: %s", product, version, err)
+	}
+	var release hcRelease
+	if err := json.Unmarshal(data, &release); err != nil {
+		return "", fmt.Errorf("error parsing the metadata of %s %s: %s", product, version, err)
+	}
+
+	var build *hcRelBuild
+	for i, b := range release.Builds {
+		if b.OS == goos && b.Arch == arch {
+			build = &release.Builds[i]
+			break
+		}
+	}
+	if build == nil {
+		return "", fmt.Errorf("%s %s has no build for %s/%s", product, version, goos, arch)
+	}
+
+	sums, err := g.verifiedSums(dir, &release, keyring)
+	if err != nil {
+		return "", fmt.Errorf("error verifying %s %s: %s", product, version, err)
+	}
+	sum, ok := sums[build.Filename]
+	if !ok || sum.Type != "sha256" {
+		return "", fmt.Errorf("%s %s has no SHA256 checksum for %s", product, version, build.Filename)
+	}
+
+	source := build.URL
+	if source == "" {
+		source = dir + url.PathEscape(build.Filename)
+	}
+	if strings.Contains(source, "?") {
+		return "", fmt.Errorf("%s %s has an unexpected build URL: %s", product, version, source)
+	}
+
+	return source + "?checksum=sha256:" + hex.EncodeToString(sum.Value), nil
+}
+
+// verifiedSums downloads the SHA256SUMS of the release in the directory dir
+// and returns its entries once one of its signatures has been verified.
+func (g *HCReleasesGetter) verifiedSums(dir string, release *hcRelease, keyring openpgp.KeyRing) (map[string]*fileChecksum, error) {
+	if release.Shasums == "" {
+		return nil, fmt.Errorf("the release has no SHA256SUMS")
+	}
+	sums, err := g.fetch(dir + url.PathEscape(release.Shasums))
+	if err != nil {
+		return nil, err
+	}
+
+	// Releases may be signed by several keys, any of which will do
+	names := release.ShasumsSignatures
+	if len(names) == 0 && release.ShasumsSignature != "" {
+		names = []string{release.ShasumsSignature}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("the release's SHA256SUMS isn't signed")
+	}
+
+	err = nil
+	for _, name := range names {
+		var sig []byte
+		sig, err = g.fetch(dir + url.PathEscape(name))
+		if err != nil {
+			continue
+		}
+		if err = checkSignature(bytes.NewReader(sums), sig, keyring); err == nil {
+			return parseChecksumFile(bytes.NewReader(sums))
+		}
+	}
+
+	return nil, err
+}
+
+// fetch downloads the file at src with the settings of the client using the
+// getter, and returns its contents.
+func (g *HCReleasesGetter) fetch(src string) ([]byte, error) {
+	td, tdcloser, err := safetemp.Dir("", "getter")
+	if err != nil {
+		return nil, err
+	}
+	defer tdcloser.Close()
+
+	dst := filepath.Join(td, "file")
+	if err := g.subClient(src+"?archive=false", dst, ClientModeFile).Get(); err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadFile(dst)
+}
+
+// parseHCRelURL returns the release that u names.
+func parseHCRelURL(u *url.URL) (product, version, goos, arch string, err error) {
+	p := strings.Trim(u.Path, "/")
+	if u.Host != "" {
+		p = u.Host + "/" + p
+	}
+	parts := strings.Split(p, "/")
+	if len(parts) < 2 || len(parts) > 4 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", "", fmt.Errorf(
+			"releases must be given as product/version/os/arch, got %q", p)
+	}
+	for len(parts) < 4 {
+		parts = append(parts, "")
+	}
+
+	product, version, goos, arch = parts[0], strings.TrimPrefix(parts[1], "v"), parts[2], parts[3]
+	if goos == "" {
+		goos = runtime.GOOS
+	}
+	if arch == "" {
+		arch = runtime.GOARCH
+	}
+
+	return product, version, goos, arch, nil
+}
@@ -0,0 +1,197 @@
+package getter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestHCReleasesGetter_impl(t *testing.T) {
+	var _ Getter = new(HCReleasesGetter)
+}
+
+func TestHCReleasesGetter(t *testing.T) {
+	signer := testGPGEntity(t)
+	server := testHCRelServer(t, signer, false)
+	defer server.Close()
+
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	client := testHCRelClient(server, "hcrel::terraform/1.5.7/linux/amd64", dst)
+	client.GPGKeyring = openpgp.EntityList{signer}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "terraform"), "binary\n")
+}
+
+func TestHCReleasesGetter_file(t *testing.T) {
+	signer := testGPGEntity(t)
+	server := testHCRelServer(t, signer, false)
+	defer server.Close()
+
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	// The archive is kept as it is, and the keyring can be the getter's
+	client := testHCRelClient(server, "hcrel://terraform/v1.5.7/linux/amd64", dst)
+	client.Mode = ClientModeFile
+	client.Getters["hcrel"].(*HCReleasesGetter).Keyring = openpgp.EntityList{signer}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	data, err := ioutil.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.HasPrefix(data, []byte("PK")) {
+		t.Fatalf("should be a zip archive: %q", data)
+	}
+}
+
+func TestHCReleasesGetter_errors(t *testing.T) {
+	signer := testGPGEntity(t)
+	server := testHCRelServer(t, signer, false)
+	defer server.Close()
+	corrupt := testHCRelServer(t, signer, true)
+	defer corrupt.Close()
+
+	cases := []struct {
+		Server  *httptest.Server
+		Src     string
+		Keyring openpgp.KeyRing
+		Err     string
+	}{
+		{server, "hcrel::terraform/1.5.7/linux/amd64", nil, "requires a keyring"},
+		{server, "hcrel::terraform/1.5.7/linux/amd64", openpgp.EntityList{testGPGEntity(t)}, "signature"},
+		{server, "hcrel::terraform/1.5.7/plan9/386", openpgp.EntityList{signer}, "no build for plan9/386"},
+		{server, "hcrel::terraform/1.0.0/linux/amd64", openpgp.EntityList{signer}, "error looking up terraform 1.0.0"},
+		{corrupt, "hcrel::terraform/1.5.7/linux/amd64", openpgp.EntityList{signer}, "Checksums did not match"},
+	}
+
+	for _, tc := range cases {
+		dst := tempDir(t)
+		defer os.RemoveAll(dst)
+
+		client := testHCRelClient(tc.Server, tc.Src, dst)
+		client.GPGKeyring = tc.Keyring
+		err := client.Get()
+		if err == nil || !strings.Contains(err.Error(), tc.Err) {
+			t.Fatalf("%s: bad: %v", tc.Src, err)
+		}
+	}
+}
+
+func TestParseHCRelURL(t *testing.T) {
+	cases := []struct {
+		Input  string
+		Output string
+	}{
+		{"hcrel://terraform/1.5.7/linux/amd64", "terraform 1.5.7 linux amd64"},
+		{"hcrel://vault/v1.15.0/darwin/arm64", "vault 1.15.0 darwin arm64"},
+		{"hcrel://consul/1.16.0", "consul 1.16.0 " + runtime.GOOS + " " + runtime.GOARCH},
+		{"hcrel://consul", ""},
+		{"hcrel://consul/1.16.0/linux/amd64/extra", ""},
+	}
+
+	for _, tc := range cases {
+		product, version, goos, arch, err := parseHCRelURL(testURL(tc.Input))
+		if tc.Output == "" {
+			if err == nil {
+				t.Fatalf("%s: should error", tc.Input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Input, err)
+		}
+		if actual := strings.Join([]string{product, version, goos, arch}, " "); actual != tc.Output {
+			t.Fatalf("%s: bad: %s", tc.Input, actual)
+		}
+	}
+}
+
+func testHCRelClient(server *httptest.Server, src, dst string) *Client {
+	getters := DefaultGetters()
+	getters["hcrel"] = &HCReleasesGetter{BaseURL: server.URL}
+
+	return &Client{Src: src, Dst: dst, Dir: true, Getters: getters}
+}
+
+// testHCRelServer serves terraform 1.5.7 for linux/amd64 as the releases
+// site does, with its SHA256SUMS signed by signer. If corrupt is true the
+// archive doesn't match its checksum.
+func testHCRelServer(t *testing.T, signer *openpgp.Entity, corrupt bool) *httptest.Server {
+	src := tempDir(t)
+	defer os.RemoveAll(src)
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "terraform"), []byte("binary\n"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	archive := filepath.Join(tempDir(t), "terraform.zip")
+	defer os.RemoveAll(filepath.Dir(archive))
+	if err := Compress(archive, src, "zip"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	zipData, err := ioutil.ReadFile(archive)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	sum := sha256.Sum256(zipData)
+	sums := fmt.Sprintf("%x  terraform_1.5.7_linux_amd64.zip\n", sum)
+	var sig bytes.Buffer
+	if err := openpgp.DetachSign(&sig, signer, strings.NewReader(sums), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if corrupt {
+		zipData = append(zipData, 0)
+	}
+
+	files := map[string][]byte{
+		"/terraform/1.5.7/terraform_1.5.7_SHA256SUMS":      []byte(sums),
+		"/terraform/1.5.7/terraform_1.5.7_SHA256SUMS.sig":  sig.Bytes(),
+		"/terraform/1.5.7/terraform_1.5.7_linux_amd64.zip": zipData,
+	}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/terraform/1.5.7/index.json" {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"name":               "terraform",
+				"version":            "1.5.7",
+				"shasums":            "terraform_1.5.7_SHA256SUMS",
+				"shasums_signatures": []string{"terraform_1.5.7_SHA256SUMS.sig"},
+				"builds": []map[string]string{{
+					"os":       "linux",
+					"arch":     "amd64",
+					"filename": "terraform_1.5.7_linux_amd64.zip",
+					"url":      server.URL + "/terraform/1.5.7/terraform_1.5.7_linux_amd64.zip",
+				}},
+			})
+			return
+		}
+
+		data, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	}))
+
+	return server
+}
@@ -14,7 +14,29 @@ import (
 
 // HgGetter is a Getter implementation that will download a module from
 // a Mercurial repository.
-type HgGetter struct{}
+type HgGetter struct {
+	getter
+
+	// Netrc, if true, looks up credentials for HTTP and HTTPS repositories
+	// in the user's netrc file, as HttpGetter's Netrc does, if the URL
+	// doesn't have any.
+	Netrc bool
+}
+
+func (g *HgGetter) withClient(c *Client) Getter {
+	cp := *g
+	cp.client = c
+	return &cp
+}
+
+func (g *HgGetter) Describe() Description {
+	return Description{
+		Summary: "A Mercurial repository, cloned at a revision.",
+		Options: []Option{
+			{"rev", "The revision to update to."},
+		},
+	}
+}
 
 func (g *HgGetter) ClientMode(_ *url.URL) (ClientMode, error) {
 	return ClientModeDir, nil
@@ -44,6 +66,12 @@ func (g *HgGetter) Get(dst string, u *url.URL) error {
 		newURL.RawQuery = q.Encode()
 	}
 
+	if g.Netrc && (newURL.Scheme == "http" || newURL.Scheme == "https") {
+		if err := addAuthFromNetrc(newURL); err != nil {
+			return err
+		}
+	}
+
 	_, err = os.Stat(dst)
 	if err != nil && !os.IsNotExist(err) {
 		return err
@@ -98,12 +126,12 @@ func (g *HgGetter) GetFile(dst string, u *url.URL) error {
 }
 
 func (g *HgGetter) clone(dst string, u *url.URL) error {
-	cmd := exec.Command("hg", "clone", "-U", u.String(), dst)
+	cmd := exec.CommandContext(g.Context(), "hg", "clone", "-U", u.String(), dst)
 	return getRunCommand(cmd)
 }
 
 func (g *HgGetter) pull(dst string, u *url.URL) error {
-	cmd := exec.Command("hg", "pull")
+	cmd := exec.CommandContext(g.Context(), "hg", "pull")
 	cmd.Dir = dst
 	return getRunCommand(cmd)
 }
@@ -114,7 +142,7 @@ func (g *HgGetter) update(dst string, u *url.URL, rev string) error {
 		args = append(args, rev)
 	}
 
-	cmd := exec.Command("hg", args...)
+	cmd := exec.CommandContext(g.Context(), "hg", args...)
 	cmd.Dir = dst
 	return getRunCommand(cmd)
 }
@@ -1,14 +1,20 @@
 package getter
 
 import (
+	"crypto/tls"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/go-safetemp"
 )
@@ -16,7 +22,11 @@ import (
 // HttpGetter is a Getter implementation that will download from an HTTP
 // endpoint.
 //
-// For file downloads, HTTP is used directly.
+// For file downloads, HTTP is used directly. A file is downloaded next to
+// the destination, with ".part" appended to its name, and moved into place
+// once it is complete. If the download is interrupted, the next one
+// resumes it with a range request, as long as the response had an ETag or
+// Last-Modified header to tell that the source hasn't changed since.
 //
 // The protocol for downloading a directory from an HTTP endpoing is as follows:
 //
@@ -27,13 +37,17 @@ import (
 // First, a header is looked for "X-Terraform-Get" which should contain
 // a source URL to download.
 //
-// If the header is not present, then a meta tag is searched for named
+// If the header is not present and the response is JSON, then it should be
+// an object whose "source" field is a source URL, and whose "checksum"
+// field may give its checksum. Otherwise a meta tag is searched for named
 // "terraform-get" and the content should be a source URL.
 //
 // The source URL, whether from the header or meta tag, must be a fully
 // formed URL. The shorthand syntax of "github.com/foo/bar" or relative
 // paths are not allowed.
 type HttpGetter struct {
+	getter
+
 	// Netrc, if true, will lookup and use auth information found
 	// in the user's netrc file if available.
 	Netrc bool
@@ -41,6 +55,145 @@ type HttpGetter struct {
 	// Client is the http.Client to use for Get requests.
 	// This defaults to a cleanhttp.DefaultClient if left unset.
 	Client *http.Client
+
+	// Header is set on every request the getter sends, including the
+	// requests that probe whether a directory source exists, replacing
+	// any header of the same name set by the Client using the getter. It
+	// isn't sent to the sources that a directory download redirects to,
+	// so it can carry credentials such as an Authorization header.
+	Header http.Header
+
+	// PinnedKeys, if set, pins the public keys of the certificates that
+	// hosts may present, keyed by host name without a port. Each pin is
+	// "sha256/" followed by the base64 encoded SHA-256 hash of a public
+	// key's DER encoded SubjectPublicKeyInfo, as in HPKP, and matches if
+	// the leaf or any certificate in its chain has that key. Requests to
+	// a pinned host fail if none match, or if they aren't sent over HTTPS.
+	PinnedKeys map[string][]string
+
+	// TorProxy, if set, is the address of a Tor SOCKS5 proxy, such as
+	// "127.0.0.1:9050", that requests are sent through. Host names are
+	// resolved by Tor rather than locally. If TorOnionOnly is true, only
+	// requests to .onion hosts are sent through it and others are sent as
+	// usual. Without a TorProxy, requests to .onion hosts always fail
+	// rather than possibly being looked up in DNS.
+	TorProxy     string
+	TorOnionOnly bool
+
+	// TLSSessionCacheSize, if positive, is how many TLS sessions the getter
+	// keeps, one for each host it has connected to most recently, so that
+	// new connections to those hosts resume a session with an abbreviated
+	// handshake. This cuts the cost of fetching many small files from the
+	// same host, particularly as the default client doesn't keep
+	// connections alive. It has no effect if the Client's transport isn't
+	// an *http.Transport or already has a ClientSessionCache. TLS 1.3 early
+	// data (0-RTT) is never sent, since Go's TLS client doesn't support it.
+	TLSSessionCacheSize int
+
+	// CacheDir, if set, is a directory where files that are downloaded are
+	// cached along with their ETag and Last-Modified headers. A file that
+	// is in the cache is then asked for with If-None-Match and
+	// If-Modified-Since, and copied from the cache if the server responds
+	// that it hasn't changed, rather than being downloaded again. Files
+	// served without either header aren't cached.
+	CacheDir string
+
+	// Redirect, if set, is called with the URL of each request that is
+	// redirected to a URL that HTTP can't follow, because its scheme isn't
+	// http or https, such as a server sending downloads on to an
+	// "s3://bucket/key" URL. It returns the source to download in its
+	// place, in any form that a Client accepts such as
+	// "s3::https://s3.amazonaws.com/bucket/key", or an error to refuse the
+	// redirect, so it can both translate the URLs that a server uses and
+	// check that they are ones to trust. Without it, such redirects fail.
+	Redirect func(from, to *url.URL) (string, error)
+
+	// RedirectScope, if set, restricts the hosts that requests may be
+	// redirected to, and that the sources returned by the terraform-get
+	// protocol and by Redirect may be downloaded from, relative to the URL
+	// the getter was given. RedirectHosts are host names that are allowed
+	// whatever the scope, including their subdomains if they start with a
+	// ".", such as ".cdn.example.com". Other redirects and sources fail
+	// with the policy abort reason.
+	RedirectScope RedirectScope
+	RedirectHosts []string
+
+	// Timeout, if positive, fails requests once the server has sent
+	// nothing for this long, whether the request is waiting for a response
+	// or reading its body, so that a server that stops responding can't
+	// hang a download. Downloads that keep making progress aren't limited.
+	Timeout time.Duration
+
+	// TLSConfig, if set, is the TLS configuration of requests in place of
+	// that of the Client's transport. CACerts is PEM encoded certificates
+	// of CAs to trust as well as the RootCAs of that configuration, or
+	// the system's CAs if it has none, for hosts with certificates issued
+	// by a corporate or private CA. InsecureSkipVerify, if true, doesn't
+	// verify the certificates of hosts at all, which should only be used
+	// for testing. Sources can do the same for themselves with the
+	// sslcainfo query parameter, the path of a PEM file of CAs to trust,
+	// and insecure=true. All of these need the Client's transport to be an
+	// *http.Transport.
+	TLSConfig          *tls.Config
+	CACerts            []byte
+	InsecureSkipVerify bool
+
+	// shared is the state that the getter shares with the copies of it
+	// that clients use. It is set, guarded by httpSendLock, the first time
+	// it is needed.
+	shared *httpSend
+}
+
+// httpSendLock guards the shared field of every HttpGetter.
+var httpSendLock sync.Mutex
+
+// httpSend is the state that an HttpGetter shares with its copies.
+type httpSend struct {
+	// clients are the clients that requests are sent with, one for each
+	// set of TLS parameters that sources have given, built from base and
+	// policy, the Client and address policy they were built for.
+	lock    sync.Mutex
+	base    *http.Client
+	policy  *AddressPolicy
+	clients map[tlsParams]*http.Client
+
+	// sessionCache holds the TLS sessions if TLSSessionCacheSize is set.
+	// It outlives clients so that sessions aren't lost when they are
+	// built again.
+	sessionCache tls.ClientSessionCache
+}
+
+func (g *HttpGetter) withClient(c *Client) Getter {
+	httpSendLock.Lock()
+	if g.shared == nil {
+		g.shared = new(httpSend)
+	}
+	cp := *g
+	httpSendLock.Unlock()
+
+	cp.client = c
+	return &cp
+}
+
+// sendState returns the state that the getter shares with its copies.
+func (g *HttpGetter) sendState() *httpSend {
+	httpSendLock.Lock()
+	defer httpSendLock.Unlock()
+
+	if g.shared == nil {
+		g.shared = new(httpSend)
+	}
+	return g.shared
+}
+
+func (g *HttpGetter) Describe() Description {
+	return Description{
+		Summary: "A file over HTTP or HTTPS, or a directory from the source an X-Terraform-Get header or meta tag redirects to.",
+		Options: []Option{
+			{"sslcainfo", "The path of a PEM file of CAs to trust as well."},
+			{"insecure", "If true, certificates aren't verified."},
+		},
+	}
 }
 
 func (g *HttpGetter) ClientMode(u *url.URL) (ClientMode, error) {
@@ -51,6 +204,31 @@ func (g *HttpGetter) ClientMode(u *url.URL) (ClientMode, error) {
 }
 
 func (g *HttpGetter) Get(dst string, u *url.URL) error {
+	sources, checksum, err := g.sources(u)
+	if err != nil {
+		return err
+	}
+
+	var results []SourceResult
+	for _, source := range sources {
+		r := tryResult(source, func() error {
+			return g.getSource(dst, source, checksum)
+		})
+		if r.Err == nil {
+			return nil
+		}
+		results = append(results, r)
+	}
+
+	return &BatchError{Sources: results}
+}
+
+// sources asks the directory endpoint at u for the source URLs that it
+// redirects to with the terraform-get protocol. There may be several,
+// which are mirrors of each other to be tried in order. The response may
+// also give the checksum of the source, which is then verified just as if
+// it were given with the checksum parameter.
+func (g *HttpGetter) sources(u *url.URL) ([]string, string, error) {
 	// Copy the URL so we can modify it
 	var newU url.URL = *u
 	u = &newU
@@ -58,7 +236,7 @@ func (g *HttpGetter) Get(dst string, u *url.URL) error {
 	if g.Netrc {
 		// Add auth from netrc if we can
 		if err := addAuthFromNetrc(u); err != nil {
-			return err
+			return nil, "", err
 		}
 	}
 
@@ -71,41 +249,159 @@ func (g *HttpGetter) Get(dst string, u *url.URL) error {
 	q.Add("terraform-get", "1")
 	u.RawQuery = q.Encode()
 
-	// Get the URL
-	resp, err := g.Client.Get(u.String())
+	// Get the URL. A redirect that Redirect mapped is the source.
+	resp, err := g.do("GET", u, nil)
+	if source, ok := redirectSource(err); ok {
+		g.trace("redirect", "redirected to %s", redactURLCredentials(source))
+		if err := g.checkSourceHost(u, source); err != nil {
+			return nil, "", err
+		}
+		return []string{source}, "", nil
+	}
 	if err != nil {
-		return err
+		return nil, "", err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("bad response code: %d", resp.StatusCode)
+		return nil, "", g.badResponse(u, resp.StatusCode)
 	}
 
-	// Extract the source URL
-	var source string
-	if v := resp.Header.Get("X-Terraform-Get"); v != "" {
-		source = v
+	// Extract the source URLs
+	var sources []string
+	checksum := resp.Header.Get("X-Terraform-Get-Checksum")
+	if vs := resp.Header["X-Terraform-Get"]; len(vs) > 0 {
+		sources = splitSources(vs)
+	} else if isJSON(resp.Header.Get("Content-Type")) {
+		body, err := parseJSONSource(resp.Body)
+		if err != nil {
+			return nil, "", err
+		}
+		sources = splitSources([]string{body.Source})
+		if checksum == "" {
+			checksum = body.Checksum
+		}
 	} else {
-		source, err = g.parseMeta(resp.Body)
+		metas, err := g.parseMeta(resp.Body)
 		if err != nil {
-			return err
+			return nil, "", err
+		}
+		sources = splitSources(metas)
+	}
+	for _, source := range sources {
+		g.trace("redirect", "terraform-get returned %s", redactURLCredentials(source))
+		if err := g.checkSourceHost(u, source); err != nil {
+			return nil, "", err
 		}
 	}
-	if source == "" {
-		return fmt.Errorf("no source URL was returned")
+	if len(sources) == 0 {
+		return nil, "", fmt.Errorf(
+			"no source URL was returned: %s doesn't implement the terraform-get "+
+				"protocol, it should respond with an X-Terraform-Get header, a "+
+				"JSON body with a source, or a terraform-get meta tag",
+			redactURLCredentials(u.String()))
 	}
 
+	return sources, checksum, nil
+}
+
+// getSource downloads a source URL that was returned by the terraform-get
+// protocol into dst, verifying it against checksum unless that is empty.
+func (g *HttpGetter) getSource(dst, source, checksum string) error {
 	// If there is a subdir component, then we download the root separately
 	// into a temporary directory, then copy over the proper subdir.
 	source, subDir := SourceDirSubdir(source)
+	if checksum != "" {
+		var err error
+		source, err = withChecksum(source, checksum)
+		if err != nil {
+			return err
+		}
+	}
 	if subDir == "" {
-		return Get(dst, source)
+		return g.subClient(source, dst, ClientModeDir).Get()
 	}
 
 	// We have a subdir, time to jump some hoops
 	return g.getSubdir(dst, source, subDir)
 }
 
+// maxSourceResponseSize is the most of a terraform-get response body that
+// is read for the source it gives, so that an endpoint that responds with
+// something huge, such as the artifact itself, isn't read to its end.
+const maxSourceResponseSize = 1 << 20
+
+// jsonSource is a JSON response body of the terraform-get protocol.
+type jsonSource struct {
+	Source   string `json:"source"`
+	Checksum string `json:"checksum"`
+}
+
+// isJSON reports whether contentType is the media type of a JSON body.
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+// parseJSONSource parses a JSON response body of the terraform-get
+// protocol.
+func parseJSONSource(r io.Reader) (*jsonSource, error) {
+	var result jsonSource
+	lr := &io.LimitedReader{R: r, N: maxSourceResponseSize}
+	if err := json.NewDecoder(lr).Decode(&result); err != nil {
+		if lr.N == 0 {
+			return nil, fmt.Errorf("JSON response is larger than %d bytes", maxSourceResponseSize)
+		}
+		return nil, fmt.Errorf("error parsing JSON response: %s", err)
+	}
+
+	return &result, nil
+}
+
+// splitSources returns the source URLs in the values of X-Terraform-Get
+// headers or terraform-get meta tags, each of which may be a comma
+// separated list.
+func splitSources(values []string) []string {
+	var result []string
+	for _, v := range values {
+		for _, source := range strings.Split(v, ",") {
+			if source = strings.TrimSpace(source); source != "" {
+				result = append(result, source)
+			}
+		}
+	}
+
+	return result
+}
+
+// withChecksum returns source with the checksum parameter set to checksum.
+// A source that already has a different checksum is an error, since they
+// can't both be right.
+func withChecksum(source, checksum string) (string, error) {
+	// The source may not parse as a URL until it is detected, so only
+	// the query is parsed.
+	base, rawQuery := source, ""
+	if idx := strings.Index(source, "?"); idx >= 0 {
+		base, rawQuery = source[:idx], source[idx+1:]
+	}
+
+	q, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", err
+	}
+	if v := q.Get("checksum"); v != "" {
+		if v != checksum {
+			return "", fmt.Errorf(
+				"X-Terraform-Get-Checksum %q doesn't match the source checksum %q",
+				checksum, v)
+		}
+
+		return source, nil
+	}
+
+	q.Set("checksum", checksum)
+	return base + "?" + q.Encode(), nil
+}
+
 func (g *HttpGetter) GetFile(dst string, u *url.URL) error {
 	if g.Netrc {
 		// Add auth from netrc if we can
@@ -118,13 +414,45 @@ func (g *HttpGetter) GetFile(dst string, u *url.URL) error {
 		g.Client = httpClient
 	}
 
-	resp, err := g.Client.Get(u.String())
+	var entry *httpCacheEntry
+	if g.CacheDir != "" {
+		entry = g.cacheEntry(u)
+
+		// Keep a CacheGC from removing the entry between checking it and
+		// copying it into place.
+		ref, err := refPath(entry.path, entry.timeout)
+		if err != nil {
+			return err
+		}
+		defer ref.Unlock()
+	}
+
+	part := partialDownload(dst)
+	resp, offset, err := g.getFileResponse(part, u, entry)
+	if source, ok := redirectSource(err); ok {
+		g.trace("redirect", "redirected to %s", redactURLCredentials(source))
+		if err := g.checkSourceHost(u, source); err != nil {
+			return err
+		}
+		return g.subClient(source, dst, ClientModeFile).Get()
+	}
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("bad response code: %d", resp.StatusCode)
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		// The cached copy is still current
+		resp.Body.Close()
+		g.cacheHit()
+		return entry.copyTo(dst)
+	}
+	size := resp.ContentLength
+	if size >= 0 {
+		size += offset
+	}
+	body := g.trackProgress(filepath.Base(u.EscapedPath()), offset, size, resp.Body)
+	defer body.Close()
+	if resp.StatusCode != 200 && resp.StatusCode != http.StatusPartialContent {
+		return &BadResponseCodeError{Code: resp.StatusCode}
 	}
 
 	// Create all the parent directories
@@ -132,19 +460,463 @@ func (g *HttpGetter) GetFile(dst string, u *url.URL) error {
 		return err
 	}
 
-	f, err := os.Create(dst)
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if offset > 0 {
+		flag = os.O_WRONLY | os.O_APPEND
+	} else if err := part.start(resp); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(part.path, flag, 0666)
 	if err != nil {
 		return err
 	}
 
-	n, err := io.Copy(f, resp.Body)
+	n, err := io.Copy(f, body)
 	if err == nil && n < resp.ContentLength {
 		err = io.ErrShortWrite
 	}
 	if err1 := f.Close(); err == nil {
 		err = err1
 	}
-	return err
+	if err != nil {
+		// What was downloaded is left to be resumed
+		return err
+	}
+	if err := part.finish(dst); err != nil {
+		return err
+	}
+
+	// Give the file the source's modification time
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		if err := os.Chtimes(dst, time.Now(), t); err != nil {
+			return err
+		}
+	}
+
+	if entry != nil {
+		return entry.store(dst, u, resp.Header)
+	}
+	return nil
+}
+
+// getFileResponse requests u to be downloaded to the partial download
+// part. If part can be resumed, only the rest of the source is requested,
+// if it hasn't changed since, and the offset in part that the response's
+// body starts at is returned. Otherwise all of the source is requested and
+// the offset is 0.
+//
+// If the source is in the cache, it is only requested if it has changed
+// since it was cached, and a response with the status 304 means that the
+// cached copy can be used.
+func (g *HttpGetter) getFileResponse(part *httpPartial, u *url.URL, entry *httpCacheEntry) (*http.Response, int64, error) {
+	if entry != nil {
+		m, err := entry.load()
+		if err != nil {
+			return nil, 0, err
+		}
+		if m != nil {
+			resp, err := g.do("GET", u, m.header())
+			return resp, 0, err
+		}
+	}
+
+	offset, m := part.offset()
+	if m == nil {
+		resp, err := g.do("GET", u, nil)
+		return resp, 0, err
+	}
+
+	resp, err := g.do("GET", u, m.header(offset))
+	if err != nil {
+		return nil, 0, err
+	}
+	if m.resumes(resp, offset) {
+		return resp, offset, nil
+	}
+	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		// The range wasn't used and all of the source was sent
+		return resp, 0, nil
+	}
+
+	// The server rejected the range or sent a different one, so start
+	// again from the beginning
+	resp.Body.Close()
+	resp, err = g.do("GET", u, nil)
+	return resp, 0, err
+}
+
+// PutFile implements Putter by uploading the file at src to u with a PUT
+// request.
+func (g *HttpGetter) PutFile(u *url.URL, src string) error {
+	if g.Netrc {
+		// Add auth from netrc if we can
+		if err := addAuthFromNetrc(u); err != nil {
+			return err
+		}
+	}
+
+	if g.Client == nil {
+		g.Client = httpClient
+	}
+
+	resp, err := g.doFile("PUT", u, nil, src)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &BadResponseCodeError{Code: resp.StatusCode}
+	}
+
+	return nil
+}
+
+// getRange downloads the size bytes of u starting at offset to dst with a
+// range request, which the server must honor.
+func (g *HttpGetter) getRange(dst string, u *url.URL, offset, size int64) error {
+	if g.Netrc {
+		// Add auth from netrc if we can
+		if err := addAuthFromNetrc(u); err != nil {
+			return err
+		}
+	}
+
+	if g.Client == nil {
+		g.Client = httpClient
+	}
+
+	header := make(http.Header)
+	header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+size-1))
+	resp, err := g.do("GET", u, header)
+	if err != nil {
+		return err
+	}
+	body := g.trackProgress(filepath.Base(u.EscapedPath()), 0, size, resp.Body)
+	defer body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("range request failed: %w", &BadResponseCodeError{Code: resp.StatusCode})
+	}
+	if start, _, ok := parseContentRange(resp.Header.Get("Content-Range")); !ok || start != offset {
+		return fmt.Errorf("server sent a different range: %s", resp.Header.Get("Content-Range"))
+	}
+
+	return writeFile(dst, io.LimitReader(body, size))
+}
+
+// parseContentRange parses the first byte position and complete length
+// of a Content-Range header. The position is -1 for an unsatisfied range
+// and the length -1 if it isn't known.
+func parseContentRange(v string) (int64, int64, bool) {
+	v = strings.TrimPrefix(v, "bytes ")
+	idx := strings.Index(v, "/")
+	if idx < 0 {
+		return 0, 0, false
+	}
+
+	total := int64(-1)
+	if v[idx+1:] != "*" {
+		n, err := strconv.ParseInt(v[idx+1:], 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		total = n
+	}
+
+	start := int64(-1)
+	if v[:idx] != "*" {
+		dash := strings.Index(v[:idx], "-")
+		if dash < 0 {
+			return 0, 0, false
+		}
+		n, err := strconv.ParseInt(v[:dash], 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		start = n
+	}
+
+	return start, total, true
+}
+
+func (g *HttpGetter) GetReader(u *url.URL) (io.ReadCloser, int64, error) {
+	if g.Netrc {
+		// Add auth from netrc if we can
+		if err := addAuthFromNetrc(u); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if g.Client == nil {
+		g.Client = httpClient
+	}
+
+	resp, err := g.do("GET", u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return nil, 0, &BadResponseCodeError{Code: resp.StatusCode}
+	}
+
+	body := g.trackProgress(filepath.Base(u.EscapedPath()), 0, resp.ContentLength, resp.Body)
+	return body, resp.ContentLength, nil
+}
+
+// do sends a request to u with the client's headers and any others given,
+// unless the client's CircuitBreaker has stopped requests to its host. It
+// is sent again as the client's RetryPolicy says if it fails.
+func (g *HttpGetter) do(method string, u *url.URL, header http.Header) (*http.Response, error) {
+	return g.doFile(method, u, header, "")
+}
+
+// doFile is do with the contents of the file at path, if it is set, as
+// the body of the request.
+func (g *HttpGetter) doFile(method string, u *url.URL, header http.Header, path string) (*http.Response, error) {
+	var policy *RetryPolicy
+	var budget *retryBudget
+	if g.client != nil {
+		policy = g.client.RetryPolicy
+		budget = g.client.retryBudget
+	}
+
+	refreshed := false
+	for attempt := 1; ; attempt++ {
+		if err := g.refreshExpiredURL(u); err != nil {
+			return nil, err
+		}
+
+		resp, err := g.send(method, u, header, path)
+
+		// A signed URL that is refused may well have expired early or be
+		// checked against a different clock, so is refreshed once and
+		// tried again without it counting as an attempt.
+		if err == nil && resp.StatusCode == http.StatusForbidden && !refreshed && g.canRefreshURL(u) {
+			resp.Body.Close()
+			if err := g.refreshURL(u); err != nil {
+				return nil, err
+			}
+			refreshed = true
+			attempt--
+			continue
+		}
+
+		if !policy.retry(g.Context(), budget, attempt, resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+	}
+}
+
+// send sends a single request for doFile.
+func (g *HttpGetter) send(method string, u *url.URL, header http.Header, path string) (*http.Response, error) {
+	// The source's TLS parameters configure the client rather than being
+	// sent to the server
+	params, u, err := splitTLSParams(u)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.ReadCloser
+	var size int64
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		size = fi.Size()
+		if size > 0 {
+			body = g.trackProgress(filepath.Base(path), 0, size, f)
+		} else {
+			f.Close()
+		}
+	}
+
+	req, err := http.NewRequestWithContext(g.Context(), method, u.String(), body)
+	if err != nil {
+		if body != nil {
+			body.Close()
+		}
+		return nil, err
+	}
+	req.ContentLength = size
+	req.Header = g.header()
+	for _, h := range []http.Header{g.Header, header} {
+		for k, v := range h {
+			req.Header[http.CanonicalHeaderKey(k)] = v
+		}
+	}
+
+	// Requests that aren't authenticated otherwise use the client's
+	// credential for the host, if it has one.
+	if g.client != nil && g.client.Credentials != nil && u.User == nil && req.Header.Get("Authorization") == "" {
+		cred, err := g.client.Credentials.Credential(u.Hostname())
+		if err != nil {
+			if body != nil {
+				body.Close()
+			}
+			return nil, fmt.Errorf("error getting credential for %s: %s", u.Hostname(), err)
+		}
+		if cred != nil {
+			cred.setHeader(req.Header)
+		}
+	}
+
+	client, err := g.transportClient(params)
+	if err != nil {
+		return nil, err
+	}
+
+	var breaker *CircuitBreaker
+	if g.client != nil {
+		breaker = g.client.CircuitBreaker
+	}
+	if breaker == nil {
+		return client.Do(req)
+	}
+
+	if err := breaker.allow(u.Host); err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	breaker.record(u.Host, resp)
+
+	return resp, nil
+}
+
+// transportClient returns the client that requests are sent with, which is
+// Client with Tor, the getter's TLS options and the TLS parameters p of the
+// source, the TLS session cache, PinnedKeys, Redirect, RedirectScope, the
+// client's Addresses and Timeout applied.
+func (g *HttpGetter) transportClient(p tlsParams) (*http.Client, error) {
+	var policy *AddressPolicy
+	if g.client != nil {
+		policy = g.client.Addresses
+	}
+
+	send := g.sendState()
+	send.lock.Lock()
+	defer send.lock.Unlock()
+	if send.base != g.Client || send.policy != policy {
+		send.base, send.policy, send.clients = g.Client, policy, nil
+	}
+	if client, ok := send.clients[p]; ok {
+		return client, nil
+	}
+
+	client, err := torClient(g.Client, g.TorProxy, g.TorOnionOnly)
+	if err != nil {
+		return nil, err
+	}
+	client, err = g.tlsClient(client, p)
+	if err != nil {
+		return nil, err
+	}
+	if cache := g.sessionCacheLocked(send); cache != nil {
+		client = sessionClient(client, cache)
+	}
+	if len(g.PinnedKeys) > 0 {
+		client = pinnedClient(client, g.PinnedKeys)
+	}
+	if g.Redirect != nil {
+		client = redirectClient(client, g.Redirect)
+	}
+	if g.RedirectScope != RedirectAnyHost {
+		client = g.hostsClient(client)
+	}
+	if policy != nil {
+		client = policyClient(client, policy)
+	}
+	if g.Timeout > 0 {
+		client = timeoutClient(client, g.Timeout)
+	}
+
+	if send.clients == nil {
+		send.clients = make(map[tlsParams]*http.Client)
+	}
+	send.clients[p] = client
+	return client, nil
+}
+
+// tlsSessionCache returns the getter's TLS session cache, or nil if it
+// doesn't have one.
+func (g *HttpGetter) tlsSessionCache() tls.ClientSessionCache {
+	send := g.sendState()
+	send.lock.Lock()
+	defer send.lock.Unlock()
+
+	return g.sessionCacheLocked(send)
+}
+
+// sessionCacheLocked is tlsSessionCache for callers holding the lock of
+// send.
+func (g *HttpGetter) sessionCacheLocked(send *httpSend) tls.ClientSessionCache {
+	if send.sessionCache == nil && g.TLSSessionCacheSize > 0 {
+		send.sessionCache = tls.NewLRUClientSessionCache(g.TLSSessionCacheSize)
+	}
+
+	return send.sessionCache
+}
+
+// badResponse returns the error for a directory download that failed with
+// the given response code. If the code means that nothing was found, the
+// endpoint is probed to tell whether the source doesn't exist or the
+// endpoint doesn't implement the protocol at all.
+func (g *HttpGetter) badResponse(u *url.URL, code int) error {
+	if code != http.StatusNotFound && code != http.StatusGone &&
+		code != http.StatusMethodNotAllowed && code != http.StatusNotImplemented {
+		return &BadResponseCodeError{Code: code}
+	}
+
+	supported, err := g.probe(u)
+	switch {
+	case err != nil:
+		return &BadResponseCodeError{Code: code}
+	case supported:
+		return fmt.Errorf("source not found at %s: %w",
+			redactURLCredentials(u.String()), &BadResponseCodeError{Code: code})
+	default:
+		return fmt.Errorf(
+			"%w: %s doesn't appear to implement the "+
+				"terraform-get protocol, check that the URL is correct",
+			&BadResponseCodeError{Code: code}, redactURLCredentials(u.String()))
+	}
+}
+
+// probe asks the endpoint at u, which already has the terraform-get
+// parameter, whether it implements the terraform-get protocol. It sends an
+// OPTIONS request, falling back to HEAD if the endpoint doesn't support
+// that, and endpoints that implement the protocol respond with either an
+// X-Terraform-Get header or an X-Terraform-Get-Protocol header.
+func (g *HttpGetter) probe(u *url.URL) (bool, error) {
+	for _, method := range []string{"OPTIONS", "HEAD"} {
+		resp, err := g.do(method, u, nil)
+		if err != nil {
+			return false, err
+		}
+		resp.Body.Close()
+
+		if resp.Header.Get("X-Terraform-Get") != "" ||
+			resp.Header.Get("X-Terraform-Get-Protocol") != "" {
+			return true, nil
+		}
+		if resp.StatusCode != http.StatusMethodNotAllowed &&
+			resp.StatusCode != http.StatusNotImplemented {
+			break
+		}
+	}
+
+	return false, nil
 }
 
 // getSubdir downloads the source into the destination, but with
@@ -159,7 +931,7 @@ func (g *HttpGetter) getSubdir(dst, source, subDir string) error {
 	defer tdcloser.Close()
 
 	// Download that into the given directory
-	if err := Get(td, source); err != nil {
+	if err := g.subClient(source, td, ClientModeDir).Get(); err != nil {
 		return err
 	}
 
@@ -185,30 +957,32 @@ func (g *HttpGetter) getSubdir(dst, source, subDir string) error {
 		return err
 	}
 
-	return copyDir(dst, sourcePath, false)
+	return copySubdir(dst, sourcePath, td, false, g.specialFiles(), g.symlinks())
 }
 
-// parseMeta looks for the first meta tag in the given reader that
-// will give us the source URL.
-func (g *HttpGetter) parseMeta(r io.Reader) (string, error) {
-	d := xml.NewDecoder(r)
+// parseMeta returns the contents of the terraform-get meta tags in the
+// head of the document in the given reader. No more than the first
+// maxSourceResponseSize bytes are read, which is plenty for the head.
+func (g *HttpGetter) parseMeta(r io.Reader) ([]string, error) {
+	d := xml.NewDecoder(io.LimitReader(r, maxSourceResponseSize))
 	d.CharsetReader = charsetReader
 	d.Strict = false
-	var err error
-	var t xml.Token
+	var result []string
 	for {
-		t, err = d.Token()
+		t, err := d.Token()
 		if err != nil {
-			if err == io.EOF {
+			// Anything that isn't valid after the tags we found is
+			// none of our business.
+			if err == io.EOF || len(result) > 0 {
 				err = nil
 			}
-			return "", err
+			return result, err
 		}
 		if e, ok := t.(xml.StartElement); ok && strings.EqualFold(e.Name.Local, "body") {
-			return "", nil
+			return result, nil
 		}
 		if e, ok := t.(xml.EndElement); ok && strings.EqualFold(e.Name.Local, "head") {
-			return "", nil
+			return result, nil
 		}
 		e, ok := t.(xml.StartElement)
 		if !ok || !strings.EqualFold(e.Name.Local, "meta") {
@@ -218,7 +992,7 @@ func (g *HttpGetter) parseMeta(r io.Reader) (string, error) {
 			continue
 		}
 		if f := attrValue(e.Attr, "content"); f != "" {
-			return f, nil
+			result = append(result, f)
 		}
 	}
 }
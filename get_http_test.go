@@ -1,14 +1,25 @@
 package getter
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestHttpGetter_impl(t *testing.T) {
@@ -40,6 +51,27 @@ func TestHttpGetter_header(t *testing.T) {
 	}
 }
 
+func TestHttpGetter_headerGetters(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	// The source it refers to is got with the client's own getters, which
+	// don't include the file getter
+	client := &Client{
+		Src:     "http://" + ln.Addr().String() + "/header",
+		Dst:     dst,
+		Mode:    ClientModeDir,
+		Getters: map[string]Getter{"http": new(HttpGetter)},
+	}
+	err := client.Get()
+	if err == nil || !strings.Contains(err.Error(), "download not supported for scheme 'file'") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
 func TestHttpGetter_meta(t *testing.T) {
 	ln := testHttpServer(t)
 	defer ln.Close()
@@ -134,6 +166,548 @@ func TestHttpGetter_none(t *testing.T) {
 	}
 }
 
+func TestHttpGetter_mirrors(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	for _, path := range []string{"/mirrors-header", "/mirrors-meta"} {
+		g := new(HttpGetter)
+		dst := tempDir(t)
+		defer os.RemoveAll(dst)
+
+		var u url.URL
+		u.Scheme = "http"
+		u.Host = ln.Addr().String()
+		u.Path = path
+
+		// The first source doesn't exist, so the next one is used
+		if err := g.Get(dst, &u); err != nil {
+			t.Fatalf("%s: err: %s", path, err)
+		}
+
+		mainPath := filepath.Join(dst, "main.tf")
+		if _, err := os.Stat(mainPath); err != nil {
+			t.Fatalf("%s: err: %s", path, err)
+		}
+	}
+}
+
+func TestHttpGetter_mirrorsFail(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	g := new(HttpGetter)
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	var u url.URL
+	u.Scheme = "http"
+	u.Host = ln.Addr().String()
+	u.Path = "/mirrors-bad"
+
+	err := g.Get(dst, &u)
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "all 2 sources") {
+		t.Fatalf("bad error: %s", err)
+	}
+
+	berr, ok := err.(*BatchError)
+	if !ok || len(berr.Sources) != 2 {
+		t.Fatalf("bad: %#v", err)
+	}
+	for _, r := range berr.Sources {
+		if r.Status != SourceFailed || r.Attempts != 1 || r.Err == nil {
+			t.Fatalf("bad: %#v", r)
+		}
+	}
+}
+
+func TestHttpGetter_headerChecksum(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	cases := []struct {
+		Path string
+		Err  bool
+	}{
+		{"/checksum-header", false},
+		{"/checksum-header-bad", true},
+		{"/checksum-header-conflict", true},
+	}
+
+	for _, tc := range cases {
+		g := new(HttpGetter)
+		dst := tempDir(t)
+		defer os.RemoveAll(dst)
+
+		var u url.URL
+		u.Scheme = "http"
+		u.Host = ln.Addr().String()
+		u.Path = tc.Path
+
+		err := g.Get(dst, &u)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: err: %s", tc.Path, err)
+		}
+		if tc.Err {
+			continue
+		}
+
+		if _, err := os.Stat(filepath.Join(dst, "file")); err != nil {
+			t.Fatalf("%s: err: %s", tc.Path, err)
+		}
+	}
+}
+
+func TestHttpGetter_json(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	cases := []struct {
+		Path string
+		Err  bool
+	}{
+		{"/json", false},
+		{"/json-bad-checksum", true},
+		{"/json-invalid", true},
+	}
+
+	for _, tc := range cases {
+		g := new(HttpGetter)
+		dst := tempDir(t)
+		defer os.RemoveAll(dst)
+
+		var u url.URL
+		u.Scheme = "http"
+		u.Host = ln.Addr().String()
+		u.Path = tc.Path
+
+		err := g.Get(dst, &u)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: err: %s", tc.Path, err)
+		}
+		if tc.Err {
+			continue
+		}
+
+		if _, err := os.Stat(filepath.Join(dst, "file")); err != nil {
+			t.Fatalf("%s: err: %s", tc.Path, err)
+		}
+	}
+}
+
+func TestWithChecksum(t *testing.T) {
+	cases := []struct {
+		Input  string
+		Output string
+		Err    bool
+	}{
+		{"github.com/foo/bar", "github.com/foo/bar?checksum=md5%3Aabc", false},
+		{"git::https://example.com/foo?ref=v1", "git::https://example.com/foo?checksum=md5%3Aabc&ref=v1", false},
+		{"git@github.com:foo/bar", "git@github.com:foo/bar?checksum=md5%3Aabc", false},
+		{"https://example.com/foo?checksum=md5:abc", "https://example.com/foo?checksum=md5:abc", false},
+		{"https://example.com/foo?checksum=md5:def", "", true},
+	}
+
+	for _, tc := range cases {
+		actual, err := withChecksum(tc.Input, "md5:abc")
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: err: %s", tc.Input, err)
+		}
+		if actual != tc.Output {
+			t.Fatalf("%s: bad: %s", tc.Input, actual)
+		}
+	}
+}
+
+func TestHttpGetter_pinnedKeys(t *testing.T) {
+	var requests int
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/file":
+			w.Write([]byte("Hello\n"))
+		case "/insecure":
+			http.Redirect(w, r, "http://"+r.Host+"/file", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL + "/file")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	pin := spkiPin(server.Certificate())
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	// A matching pin, among others
+	g := &HttpGetter{
+		Client:     server.Client(),
+		PinnedKeys: map[string][]string{u.Hostname(): {"sha256/AAAA", pin}},
+	}
+	if err := g.GetFile(filepath.Join(dst, "a"), u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "a"), "Hello\n")
+
+	// Redirects to plain HTTP aren't followed
+	insecure := *u
+	insecure.Path = "/insecure"
+	if err := g.GetFile(filepath.Join(dst, "b"), &insecure); err == nil {
+		t.Fatal("should error")
+	}
+
+	// Nor are plain HTTP requests sent
+	insecure.Scheme = "http"
+	insecure.Path = "/file"
+	if err := g.GetFile(filepath.Join(dst, "c"), &insecure); err == nil {
+		t.Fatal("should error")
+	}
+
+	// A mismatch fails closed
+	g = &HttpGetter{
+		Client:     server.Client(),
+		PinnedKeys: map[string][]string{u.Hostname(): {"sha256/AAAA"}},
+	}
+	err = g.GetFile(filepath.Join(dst, "d"), u)
+	if err == nil || !strings.Contains(err.Error(), "pinned keys") {
+		t.Fatalf("bad: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "d")); err == nil {
+		t.Fatal("nothing should be downloaded")
+	}
+
+	// Hosts that aren't pinned aren't checked
+	g = &HttpGetter{
+		Client:     server.Client(),
+		PinnedKeys: map[string][]string{"example.com": {"sha256/AAAA"}},
+	}
+	if err := g.GetFile(filepath.Join(dst, "e"), u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Named hosts are checked during the handshake, before the request is
+	// sent
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return new(net.Dialer).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+	g = &HttpGetter{
+		Client:     &http.Client{Transport: transport},
+		PinnedKeys: map[string][]string{"example.com": {"sha256/AAAA"}},
+	}
+	named, err := url.Parse("https://example.com/file")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	requests = 0
+	if err := g.GetFile(filepath.Join(dst, "f"), named); err == nil {
+		t.Fatal("should error")
+	}
+	if requests != 0 {
+		t.Fatalf("bad requests: %d", requests)
+	}
+	g.PinnedKeys["example.com"] = []string{pin}
+	if err := g.GetFile(filepath.Join(dst, "f"), named); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestHttpGetter_tlsSessionCache(t *testing.T) {
+	var resumed []bool
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resumed = append(resumed, r.TLS.DidResume)
+		w.Write([]byte("Hello\n"))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL + "/file")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	for _, size := range []int{0, 8} {
+		// Each request is on a new connection, as with the default client
+		transport := server.Client().Transport.(*http.Transport).Clone()
+		transport.DisableKeepAlives = true
+		g := &HttpGetter{
+			Client:              &http.Client{Transport: transport},
+			TLSSessionCacheSize: size,
+		}
+
+		resumed = nil
+		for _, name := range []string{"a", "b"} {
+			if err := g.GetFile(filepath.Join(dst, name), u); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		}
+		if expected := []bool{false, size > 0}; !reflect.DeepEqual(resumed, expected) {
+			t.Fatalf("%d: bad: %v", size, resumed)
+		}
+	}
+}
+
+func TestHttpGetter_cacheDir(t *testing.T) {
+	var mu sync.Mutex
+	contents, etag := "Hello\n", `"v1"`
+	var sent, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		sent++
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(contents))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL + "/file")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	g := &HttpGetter{CacheDir: filepath.Join(dst, "cache")}
+	get := func(name, expected string) {
+		t.Helper()
+		if err := g.GetFile(filepath.Join(dst, name), u); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		assertContents(t, filepath.Join(dst, name), expected)
+	}
+
+	// The second download is copied from the cache
+	get("a", "Hello\n")
+	get("b", "Hello\n")
+	if sent != 1 || notModified != 1 {
+		t.Fatalf("bad: %d sent, %d not modified", sent, notModified)
+	}
+
+	// Changes are downloaded, and replace what is cached
+	mu.Lock()
+	contents, etag = "Goodbye\n", `"v2"`
+	mu.Unlock()
+	get("c", "Goodbye\n")
+	get("d", "Goodbye\n")
+	if sent != 2 || notModified != 2 {
+		t.Fatalf("bad: %d sent, %d not modified", sent, notModified)
+	}
+}
+
+func TestHttpGetter_cacheDirLastModified(t *testing.T) {
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.ServeContent(w, r, "file", modTime, strings.NewReader("Hello\n"))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL + "/file")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	g := &HttpGetter{CacheDir: filepath.Join(dst, "cache")}
+	for _, name := range []string{"a", "b"} {
+		if err := g.GetFile(filepath.Join(dst, name), u); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		assertContents(t, filepath.Join(dst, name), "Hello\n")
+
+		// The copy from the cache has the source's modification time too
+		fi, err := os.Stat(filepath.Join(dst, name))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !fi.ModTime().Equal(modTime) {
+			t.Fatalf("%s: bad: %s", name, fi.ModTime())
+		}
+	}
+	if requests != 2 {
+		t.Fatalf("bad: %d", requests)
+	}
+}
+
+func TestHttpGetter_requestHeader(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer abc" || r.Header.Get("X-Request-Id") != "getter" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		methods = append(methods, r.Method)
+
+		switch r.URL.Path {
+		case "/file":
+			w.Write([]byte("Hello\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	g := &HttpGetter{Header: http.Header{
+		"Authorization": []string{"Bearer abc"},
+		"X-Request-Id":  []string{"getter"},
+	}}
+	g.client = &Client{Header: http.Header{"X-Request-Id": []string{"client"}}}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	u, err := url.Parse(server.URL + "/file")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := g.GetFile(filepath.Join(dst, "file"), u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The probes of a missing directory have the headers too
+	u.Path = "/missing"
+	if err := g.Get(filepath.Join(dst, "dir"), u); err == nil {
+		t.Fatal("should error")
+	}
+	if expected := []string{"GET", "GET", "OPTIONS"}; !reflect.DeepEqual(methods, expected) {
+		t.Fatalf("bad: %v", methods)
+	}
+}
+
+func TestHttpGetter_userAgent(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	cases := []struct {
+		Client    *Client
+		UserAgent string
+		RequestID string
+	}{
+		{nil, DefaultUserAgent, ""},
+		{
+			&Client{
+				UserAgent: "test/1.0",
+				Header:    http.Header{"X-Request-Id": []string{"abc"}},
+			},
+			"test/1.0",
+			"abc",
+		},
+	}
+
+	for _, tc := range cases {
+		g := new(HttpGetter)
+		g.client = tc.Client
+		dst := tempDir(t)
+		defer os.RemoveAll(dst)
+
+		var u url.URL
+		u.Scheme = "http"
+		u.Host = ln.Addr().String()
+		u.Path = "/user-agent"
+		u.RawQuery = url.Values{
+			"user_agent": []string{tc.UserAgent},
+			"request_id": []string{tc.RequestID},
+		}.Encode()
+
+		if err := g.GetFile(dst, &u); err != nil {
+			t.Fatalf("%s: err: %s", tc.UserAgent, err)
+		}
+	}
+}
+
+func TestHttpGetter_cancel(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	// The server doesn't respond until the request is cancelled
+	dst := filepath.Join(tempDir(t), "dst")
+	client := &Client{
+		Ctx:  ctx,
+		Src:  "http://" + ln.Addr().String() + "/hang",
+		Dst:  dst,
+		Mode: ClientModeFile,
+	}
+	if err := client.Get(); err == nil {
+		t.Fatal("should error")
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Fatalf("destination should not exist: %v", err)
+	}
+}
+
+func TestHttpGetter_probe(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	cases := []struct {
+		Path     string
+		Expected string
+	}{
+		{"/missing", "doesn't appear to implement the terraform-get protocol"},
+		{"/missing-options", "source not found"},
+		{"/missing-head", "source not found"},
+		{"/file", "doesn't implement the terraform-get protocol"},
+	}
+
+	for _, tc := range cases {
+		g := new(HttpGetter)
+		dst := tempDir(t)
+		defer os.RemoveAll(dst)
+
+		var u url.URL
+		u.Scheme = "http"
+		u.Host = ln.Addr().String()
+		u.Path = tc.Path
+
+		err := g.Get(dst, &u)
+		if err == nil {
+			t.Fatalf("%s: should error", tc.Path)
+		}
+		if !strings.Contains(err.Error(), tc.Expected) {
+			t.Fatalf("%s: bad error: %s", tc.Path, err)
+		}
+	}
+}
+
+func TestHttpGetter_badResponseCodeError(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	for _, path := range []string{"/missing", "/missing-head"} {
+		g := new(HttpGetter)
+		dst := tempDir(t)
+		defer os.RemoveAll(dst)
+
+		var u url.URL
+		u.Scheme = "http"
+		u.Host = ln.Addr().String()
+		u.Path = path
+
+		err := g.Get(dst, &u)
+		var codeErr *BadResponseCodeError
+		if !errors.As(err, &codeErr) || codeErr.Code != http.StatusNotFound {
+			t.Fatalf("%s: bad: %#v", path, err)
+		}
+	}
+}
+
 func TestHttpGetter_file(t *testing.T) {
 	ln := testHttpServer(t)
 	defer ln.Close()
@@ -159,6 +733,249 @@ func TestHttpGetter_file(t *testing.T) {
 	assertContents(t, dst, "Hello\n")
 }
 
+func TestHttpGetter_redirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "test://bucket"+r.URL.Path, http.StatusFound)
+	}))
+	defer server.Close()
+
+	var redirects []string
+	g := &HttpGetter{
+		Redirect: func(from, to *url.URL) (string, error) {
+			redirects = append(redirects, from.Path+" "+to.String())
+			if to.Host != "bucket" {
+				return "", fmt.Errorf("unknown bucket")
+			}
+			return testModule(filepath.Join("basic-file", strings.TrimPrefix(to.Path, "/"))), nil
+		},
+	}
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	// A file
+	u := testURL(server.URL + "/foo.txt")
+	if err := g.GetFile(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello\n")
+	if !reflect.DeepEqual(redirects, []string{"/foo.txt test://bucket/foo.txt"}) {
+		t.Fatalf("bad: %#v", redirects)
+	}
+
+	// A directory, where the redirect is the source
+	dstDir := tempDir(t)
+	defer os.RemoveAll(dstDir)
+	g = &HttpGetter{
+		Redirect: func(from, to *url.URL) (string, error) {
+			return testModule("basic"), nil
+		},
+	}
+	if err := g.Get(dstDir, testURL(server.URL+"/module")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dstDir, "main.tf")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A redirect that is refused
+	g = &HttpGetter{
+		Redirect: func(from, to *url.URL) (string, error) {
+			return "", fmt.Errorf("untrusted")
+		},
+	}
+	err := g.GetFile(dst, u)
+	if err == nil || !strings.Contains(err.Error(), "untrusted") {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// Without Redirect, it isn't followed
+	err = new(HttpGetter).GetFile(dst, u)
+	if err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestHttpGetter_resume(t *testing.T) {
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	var ranges []string
+	var interrupt, ignoreIfRange bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("ETag", `"v1"`)
+		if interrupt {
+			w.Header().Set("Content-Length", "13")
+			w.Write([]byte("Hello"))
+			return
+		}
+		if ignoreIfRange {
+			r.Header.Del("If-Range")
+		}
+		http.ServeContent(w, r, "file", modTime, strings.NewReader("Hello, world\n"))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL + "/file")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	g := new(HttpGetter)
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	// An interrupted download is left next to the destination
+	interrupt = true
+	if err := g.GetFile(dst, u); err == nil {
+		t.Fatal("should error")
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Fatalf("bad: %v", err)
+	}
+	assertContents(t, dst+".part", "Hello")
+
+	// and resumed, and the file is given the source's modification time
+	interrupt = false
+	ranges = nil
+	if err := g.GetFile(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello, world\n")
+	if fi, err := os.Stat(dst); err != nil || !fi.ModTime().Equal(modTime) {
+		t.Fatalf("bad mod time: %v %v", fi, err)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=5-" {
+		t.Fatalf("bad ranges: %q", ranges)
+	}
+	for _, path := range []string{dst + ".part", dst + ".part.json"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("%s: bad: %v", path, err)
+		}
+	}
+
+	cases := []struct {
+		Name          string
+		Partial       string
+		Meta          string
+		IgnoreIfRange bool
+		Ranges        []string
+	}{
+		{
+			"etag",
+			"Hello",
+			`{"etag": "\"v1\"", "size": 13}`,
+			false,
+			[]string{"bytes=5-"},
+		},
+		{
+			"last modified",
+			"Hello",
+			`{"last_modified": "` + modTime.Format(http.TimeFormat) + `", "size": 13}`,
+			false,
+			[]string{"bytes=5-"},
+		},
+		// Downloads without a validator aren't resumed
+		{
+			"no validator",
+			"Hello",
+			"",
+			false,
+			[]string{""},
+		},
+		// Nor are those of a source that has changed since
+		{
+			"changed",
+			"Goodbye",
+			`{"etag": "\"v0\"", "size": 13}`,
+			false,
+			[]string{"bytes=7-"},
+		},
+		// even if the server ignores If-Range
+		{
+			"changed ignoring If-Range",
+			"Goodbye",
+			`{"etag": "\"v0\"", "size": 13}`,
+			true,
+			[]string{"bytes=7-", ""},
+		},
+		{
+			"changed length",
+			"Goodbye",
+			`{"etag": "\"v1\"", "size": 20}`,
+			true,
+			[]string{"bytes=7-", ""},
+		},
+		// Nor are complete ones
+		{
+			"complete",
+			"Hello, world\n",
+			`{"etag": "\"v1\"", "size": 13}`,
+			false,
+			[]string{""},
+		},
+	}
+	for _, tc := range cases {
+		if err := ioutil.WriteFile(dst+".part", []byte(tc.Partial), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if tc.Meta != "" {
+			if err := ioutil.WriteFile(dst+".part.json", []byte(tc.Meta), 0644); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		}
+
+		ignoreIfRange = tc.IgnoreIfRange
+		ranges = nil
+		if err := g.GetFile(dst, u); err != nil {
+			t.Fatalf("%s: err: %s", tc.Name, err)
+		}
+		assertContents(t, dst, "Hello, world\n")
+		if !reflect.DeepEqual(ranges, tc.Ranges) {
+			t.Fatalf("%s: bad ranges: %q", tc.Name, ranges)
+		}
+	}
+}
+
+func TestHttpGetter_resumeUnsupported(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	g := new(HttpGetter)
+	dst := tempFile(t)
+	defer os.RemoveAll(dst)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(dst, []byte("Goodbye, world\n"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A server that ignores the range sends all of the file
+	u := &url.URL{Scheme: "http", Host: ln.Addr().String(), Path: "/file"}
+	if err := g.GetFile(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello\n")
+}
+
+func TestParseContentRange(t *testing.T) {
+	cases := []struct {
+		Input        string
+		Start, Total int64
+		OK           bool
+	}{
+		{"bytes 5-12/13", 5, 13, true},
+		{"bytes 5-12/*", 5, -1, true},
+		{"bytes */13", -1, 13, true},
+		{"", 0, 0, false},
+		{"bytes five-12/13", 0, 0, false},
+	}
+	for _, tc := range cases {
+		start, total, ok := parseContentRange(tc.Input)
+		if start != tc.Start || total != tc.Total || ok != tc.OK {
+			t.Fatalf("%q: bad: %d %d %t", tc.Input, start, total, ok)
+		}
+	}
+}
+
 func TestHttpGetter_auth(t *testing.T) {
 	ln := testHttpServer(t)
 	defer ln.Close()
@@ -255,12 +1072,27 @@ func testHttpServer(t *testing.T) net.Listener {
 	}
 
 	mux := http.NewServeMux()
+	mux.HandleFunc("/archive", testHttpHandlerArchive)
 	mux.HandleFunc("/file", testHttpHandlerFile)
 	mux.HandleFunc("/header", testHttpHandlerHeader)
 	mux.HandleFunc("/meta", testHttpHandlerMeta)
 	mux.HandleFunc("/meta-auth", testHttpHandlerMetaAuth)
 	mux.HandleFunc("/meta-subdir", testHttpHandlerMetaSubdir)
 	mux.HandleFunc("/meta-subdir-glob", testHttpHandlerMetaSubdirGlob)
+	mux.HandleFunc("/checksum-header", testHttpHandlerChecksumHeader("md5:fbd90037dacc4b1ab40811d610dde2f0", ""))
+	mux.HandleFunc("/checksum-header-bad", testHttpHandlerChecksumHeader("md5:fbd90037dacc4b1ab40811d610dde2f1", ""))
+	mux.HandleFunc("/checksum-header-conflict", testHttpHandlerChecksumHeader(
+		"md5:fbd90037dacc4b1ab40811d610dde2f0", "?checksum=md5:fbd90037dacc4b1ab40811d610dde2f1"))
+	mux.HandleFunc("/hang", testHttpHandlerHang)
+	mux.HandleFunc("/json", testHttpHandlerJSON("md5:fbd90037dacc4b1ab40811d610dde2f0"))
+	mux.HandleFunc("/json-bad-checksum", testHttpHandlerJSON("md5:fbd90037dacc4b1ab40811d610dde2f1"))
+	mux.HandleFunc("/json-invalid", testHttpHandlerJSONInvalid)
+	mux.HandleFunc("/mirrors-bad", testHttpHandlerMirrorsBad)
+	mux.HandleFunc("/user-agent", testHttpHandlerUserAgent)
+	mux.HandleFunc("/mirrors-header", testHttpHandlerMirrorsHeader)
+	mux.HandleFunc("/mirrors-meta", testHttpHandlerMirrorsMeta)
+	mux.HandleFunc("/missing-head", testHttpHandlerMissingHead)
+	mux.HandleFunc("/missing-options", testHttpHandlerMissingOptions)
 
 	var server http.Server
 	server.Handler = mux
@@ -269,6 +1101,10 @@ func testHttpServer(t *testing.T) net.Listener {
 	return ln
 }
 
+func testHttpHandlerArchive(w http.ResponseWriter, r *http.Request) {
+	http.ServeFile(w, r, filepath.Join(fixtureDir, "basic-file-archive", "archive.tar.gz"))
+}
+
 func testHttpHandlerFile(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Hello\n"))
 }
@@ -305,10 +1141,208 @@ func testHttpHandlerMetaSubdirGlob(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(fmt.Sprintf(testHttpMetaStr, testModuleURL("basic//sub*").String())))
 }
 
+func testHttpHandlerChecksumHeader(checksum, query string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("X-Terraform-Get", testModuleURL("basic-file-archive/archive.tar.gz").String()+query)
+		w.Header().Add("X-Terraform-Get-Checksum", checksum)
+		w.WriteHeader(200)
+	}
+}
+
+func testHttpHandlerJSON(checksum string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprintf(w, `{"source": %q, "checksum": %q}`,
+			testModuleURL("basic-file-archive/archive.tar.gz").String(), checksum)
+	}
+}
+
+func testHttpHandlerJSONInvalid(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"source": `))
+}
+
+func testHttpHandlerUserAgent(w http.ResponseWriter, r *http.Request) {
+	if r.UserAgent() != r.URL.Query().Get("user_agent") ||
+		r.Header.Get("X-Request-Id") != r.URL.Query().Get("request_id") {
+		w.WriteHeader(400)
+		return
+	}
+
+	w.Write([]byte("Hello\n"))
+}
+
+func testHttpHandlerHang(w http.ResponseWriter, r *http.Request) {
+	<-r.Context().Done()
+}
+
+func testHttpHandlerMirrorsBad(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("X-Terraform-Get", testModuleURL("missing-a").String()+", "+testModuleURL("missing-b").String())
+	w.WriteHeader(200)
+}
+
+func testHttpHandlerMirrorsHeader(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("X-Terraform-Get", testModuleURL("missing").String())
+	w.Header().Add("X-Terraform-Get", testModuleURL("basic").String())
+	w.WriteHeader(200)
+}
+
+func testHttpHandlerMirrorsMeta(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(fmt.Sprintf(testHttpMetaMirrorsStr,
+		testModuleURL("missing-a").String(),
+		testModuleURL("missing-b").String(),
+		testModuleURL("basic").String())))
+}
+
+func testHttpHandlerMissingHead(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	case "HEAD":
+		w.Header().Set("X-Terraform-Get-Protocol", "1")
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func testHttpHandlerMissingOptions(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "OPTIONS" {
+		w.Header().Set("X-Terraform-Get-Protocol", "1")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
 func testHttpHandlerNone(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(testHttpNoneStr))
 }
 
+func TestRedirectAllowed(t *testing.T) {
+	cases := []struct {
+		Scope    RedirectScope
+		Hosts    []string
+		From, To string
+		Allowed  bool
+	}{
+		{RedirectAnyHost, nil, "https://a.example.com", "https://example.net", true},
+		{RedirectSameOrigin, nil, "https://example.com/a", "https://example.com:443/b", true},
+		{RedirectSameOrigin, nil, "https://example.com", "http://example.com", false},
+		{RedirectSameOrigin, nil, "https://example.com", "https://cdn.example.com", false},
+		{RedirectSameDomain, nil, "https://registry.example.com", "https://cdn.example.com", true},
+		{RedirectSameDomain, nil, "https://registry.example.com", "https://example.com", true},
+		{RedirectSameDomain, nil, "https://registry.example.com", "https://example.net", false},
+		{RedirectSameDomain, nil, "https://example.co.uk", "https://other.co.uk", false},
+		{RedirectSameDomain, nil, "https://example.com", "https://badexample.com", false},
+		{RedirectListedHosts, nil, "https://example.com", "https://example.com", false},
+		{RedirectListedHosts, []string{"mirror.example.net"}, "https://example.com", "https://mirror.example.net", true},
+		{RedirectListedHosts, []string{".example.net"}, "https://example.com", "https://a.b.example.net", true},
+		{RedirectSameOrigin, []string{"cdn.example.net"}, "https://example.com", "https://CDN.example.net", true},
+	}
+
+	for _, tc := range cases {
+		from, _ := url.Parse(tc.From)
+		to, _ := url.Parse(tc.To)
+		if allowed := redirectAllowed(tc.Scope, tc.Hosts, from, to); allowed != tc.Allowed {
+			t.Fatalf("%d %v %s -> %s: got %t", tc.Scope, tc.Hosts, tc.From, tc.To, allowed)
+		}
+	}
+}
+
+func TestHttpGetter_redirectScope(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello\n"))
+	}))
+	defer target.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("terraform-get") != "" {
+			w.Header().Set("X-Terraform-Get", "git::https://example.com/repo.git")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Redirect(w, r, target.URL+"/file", http.StatusFound)
+	}))
+	defer server.Close()
+
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	// The target is on another port of the same host
+	cases := []struct {
+		Getter  *HttpGetter
+		Allowed bool
+	}{
+		{new(HttpGetter), true},
+		{&HttpGetter{RedirectScope: RedirectSameOrigin}, false},
+		{&HttpGetter{RedirectScope: RedirectSameDomain}, true},
+		{&HttpGetter{RedirectScope: RedirectListedHosts}, false},
+		{&HttpGetter{RedirectScope: RedirectListedHosts, RedirectHosts: []string{"127.0.0.1"}}, true},
+	}
+	for i, tc := range cases {
+		dst := filepath.Join(td, fmt.Sprintf("file%d", i))
+		err := tc.Getter.GetFile(dst, testURL(server.URL+"/file"))
+		if tc.Allowed {
+			if err != nil {
+				t.Fatalf("%d: err: %s", i, err)
+			}
+			assertContents(t, dst, "hello\n")
+			continue
+		}
+		if reason := AbortReasonOf(err); reason != AbortPolicy {
+			t.Fatalf("%d: bad reason %q: %v", i, reason, err)
+		}
+	}
+
+	// Sources returned by the terraform-get protocol are checked too
+	g := &HttpGetter{RedirectScope: RedirectSameDomain}
+	err := g.Get(filepath.Join(td, "dir"), testURL(server.URL+"/dir"))
+	if reason := AbortReasonOf(err); reason != AbortPolicy {
+		t.Fatalf("bad reason %q: %v", reason, err)
+	}
+}
+
+func TestHttpGetter_timeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			// Keeps sending, more slowly than the timeout overall
+			for i := 0; i < 5; i++ {
+				w.Write([]byte("x"))
+				w.(http.Flusher).Flush()
+				time.Sleep(20 * time.Millisecond)
+			}
+		case "/stalled":
+			w.Write([]byte("x"))
+			w.(http.Flusher).Flush()
+			<-done
+		case "/silent":
+			<-done
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	g := &HttpGetter{Timeout: 50 * time.Millisecond}
+	dst := filepath.Join(td, "slow")
+	if err := g.GetFile(dst, testURL(server.URL+"/slow")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "xxxxx")
+
+	for _, path := range []string{"/stalled", "/silent"} {
+		err := g.GetFile(filepath.Join(td, path), testURL(server.URL+path))
+		if err == nil || AbortReasonOf(err) != AbortDeadline {
+			t.Fatalf("%s: bad: %v", path, err)
+		}
+	}
+}
+
 const testHttpMetaStr = `
 <html>
 <head>
@@ -317,6 +1351,15 @@ const testHttpMetaStr = `
 </html>
 `
 
+const testHttpMetaMirrorsStr = `
+<html>
+<head>
+<meta name="terraform-get" content="%s, %s">
+<meta name="terraform-get" content="%s">
+</head>
+</html>
+`
+
 const testHttpNoneStr = `
 <html>
 <head>
@@ -329,3 +1372,91 @@ machine %s
 login foo
 password bar
 `
+
+func TestHttpGetter_tls(t *testing.T) {
+	var query string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write([]byte("Hello\n"))
+	}))
+	defer server.Close()
+
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	caCerts := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	caInfo := filepath.Join(dst, "ca.pem")
+	if err := ioutil.WriteFile(caInfo, caCerts, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	cases := []struct {
+		Name   string
+		Getter *HttpGetter
+		Query  string
+		Err    bool
+	}{
+		{"untrusted", new(HttpGetter), "", true},
+		{"insecure", &HttpGetter{InsecureSkipVerify: true}, "", false},
+		{"ca certs", &HttpGetter{CACerts: caCerts}, "", false},
+		{"tls config", &HttpGetter{TLSConfig: &tls.Config{RootCAs: roots}}, "", false},
+		{"insecure param", new(HttpGetter), "insecure=true&foo=bar", false},
+		{"sslcainfo param", new(HttpGetter), "sslcainfo=" + url.QueryEscape(caInfo), false},
+		{"bad insecure param", new(HttpGetter), "insecure=maybe", true},
+	}
+	for _, tc := range cases {
+		query = ""
+		u, err := url.Parse(server.URL + "/file?" + tc.Query)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		path := filepath.Join(dst, "file")
+		err = tc.Getter.GetFile(path, u)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: err: %v", tc.Name, err)
+		}
+		if err != nil {
+			continue
+		}
+		assertContents(t, path, "Hello\n")
+		if strings.Contains(query, "insecure") || strings.Contains(query, "sslcainfo") {
+			t.Fatalf("%s: TLS parameters were sent: %s", tc.Name, query)
+		}
+	}
+}
+
+func TestHttpGetter_sourceResponseLimit(t *testing.T) {
+	// Endpoints that respond with something endless
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/json/" {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"source": "`)
+		} else {
+			io.WriteString(w, "<html><head>")
+		}
+		chunk := strings.Repeat("a", 32*1024)
+		for i := 0; i < 1024; i++ {
+			if _, err := io.WriteString(w, chunk); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	// Are read no further than needed for a source
+	for _, path := range []string{"/html/", "/json/"} {
+		u, err := url.Parse(server.URL + path)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		g := new(HttpGetter)
+		if _, _, err := g.sources(u); err == nil {
+			t.Fatalf("%s: should error", path)
+		}
+	}
+}
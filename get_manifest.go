@@ -0,0 +1,264 @@
+package getter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/go-safetemp"
+)
+
+// ManifestGetter is a Getter implementation that downloads a manifest
+// file listing many sources and then downloads each of those sources
+// into the destination directory.
+//
+// The manifest itself can be hosted with any supported protocol. It is
+// selected by forcing the getter, for example:
+// "manifest::https://example.com/bundle.json".
+//
+// The manifest must be a JSON document of the following form, or the same
+// in YAML, whose block style is understood. A manifest that starts with
+// "{" is JSON, and any other is YAML.
+//
+//	{
+//	  "sources": [
+//	    {"source": "github.com/foo/bar", "destination": "bar"},
+//	    {"source": "./files/foo.txt", "destination": "files", "mode": "file"}
+//	  ]
+//	}
+//
+//	sources:
+//	  - source: github.com/foo/bar
+//	    destination: bar
+//	  - source: ./files/foo.txt
+//	    destination: files
+//	    mode: file
+//
+// Each destination is relative to the directory being downloaded into and
+// may not traverse outside of it. Sources beginning with "./" or "../" are
+// resolved relative to the URL of the manifest. The mode of each source may
+// be "any" (the default), "file" or "dir" and has the same meaning as the
+// client modes of the same name.
+//
+// Sources are downloaded in order until one fails, except for sources with
+// "optional" set to true, whose failure is passed to the Client's
+// OnOptionalFailure and doesn't stop the download. An offline Client tries
+// every source, and reports all of those that aren't available offline in
+// a single *OfflineError.
+type ManifestGetter struct {
+	getter
+}
+
+func (g *ManifestGetter) withClient(c *Client) Getter {
+	cp := *g
+	cp.client = c
+	return &cp
+}
+
+// manifest is the decoded form of the document read by ManifestGetter.
+type manifest struct {
+	Sources []manifestSource `json:"sources"`
+}
+
+// manifestSource is a single entry in a manifest.
+type manifestSource struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Mode        string `json:"mode"`
+	Optional    bool   `json:"optional"`
+}
+
+func (g *ManifestGetter) Describe() Description {
+	return Description{
+		Summary: "A JSON or YAML manifest, from any source, of several sources to download into the directory.",
+	}
+}
+
+func (g *ManifestGetter) ClientMode(_ *url.URL) (ClientMode, error) {
+	return ClientModeDir, nil
+}
+
+// Local implements LocalGetter. The manifest and each of its sources are
+// got with clients that are offline if this one is, so those that can't be
+// got locally are reported then.
+func (g *ManifestGetter) Local(*url.URL) bool {
+	return true
+}
+
+func (g *ManifestGetter) Get(dst string, u *url.URL) error {
+	m, err := g.manifest(u)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	var results []SourceResult
+	var missing []string
+	for i, s := range m.Sources {
+		if err := g.stopped(); err != nil {
+			return &PartialError{
+				Completed: manifestSourceNames(m.Sources[:i]),
+				Remaining: manifestSourceNames(m.Sources[i:]),
+				Err:       err,
+			}
+		}
+
+		r := tryResult(s.Source, func() error {
+			return g.getSource(dst, u, s)
+		})
+		r.Optional = s.Optional
+		results = append(results, r)
+		if r.Err != nil && s.Optional {
+			if g.client != nil && g.client.OnOptionalFailure != nil {
+				g.client.OnOptionalFailure(r)
+			}
+			continue
+		}
+		if oe, ok := r.Err.(*OfflineError); ok {
+			// Carry on to find out what else is missing
+			missing = append(missing, oe.Sources...)
+			continue
+		}
+		if r.Err != nil {
+			// The rest of the sources aren't tried
+			for _, s := range m.Sources[i+1:] {
+				results = append(results, SourceResult{
+					Source:   redactURLCredentials(s.Source),
+					Status:   SourceSkipped,
+					Optional: s.Optional,
+				})
+			}
+
+			return &BatchError{Sources: results}
+		}
+	}
+	if len(missing) > 0 {
+		return &OfflineError{Sources: missing}
+	}
+
+	return nil
+}
+
+// GetFile is not supported for manifests since a manifest always
+// describes a set of sources.
+func (g *ManifestGetter) GetFile(string, *url.URL) error {
+	return fmt.Errorf("manifest sources can only be downloaded as a directory")
+}
+
+// manifest downloads and decodes the manifest at the given URL.
+func (g *ManifestGetter) manifest(u *url.URL) (*manifest, error) {
+	td, tdcloser, err := safetemp.Dir("", "getter")
+	if err != nil {
+		return nil, err
+	}
+	defer tdcloser.Close()
+
+	path := filepath.Join(td, "manifest")
+	if err := g.subClient(u.String(), path, ClientModeFile).Get(); err != nil {
+		if _, ok := err.(*OfflineError); ok {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error downloading manifest: %w", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m, err := parseManifest(f)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing manifest: %s", err)
+	}
+
+	return m, nil
+}
+
+// parseManifest decodes and checks a manifest, which is JSON if it starts
+// with "{" and YAML otherwise.
+func parseManifest(r io.Reader) (*manifest, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var m manifest
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		err = json.Unmarshal(data, &m)
+	} else {
+		err = parseManifestYAML(data, &m)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range m.Sources {
+		if s.Source == "" {
+			return nil, fmt.Errorf("manifest entry is missing a source")
+		}
+		if filepath.IsAbs(s.Destination) || containsDotDot(s.Destination) {
+			return nil, fmt.Errorf(
+				"manifest destination must be a relative path inside the destination: %s",
+				s.Destination)
+		}
+	}
+
+	return &m, nil
+}
+
+// getSource downloads a single manifest entry into dst.
+func (g *ManifestGetter) getSource(dst string, u *url.URL, s manifestSource) error {
+	src, mode, err := s.resolve(u)
+	if err != nil {
+		return err
+	}
+
+	return g.subClient(src, filepath.Join(dst, s.Destination), mode).Get()
+}
+
+// resolve returns the source of the entry, resolved relative to u if it
+// begins with "./" or "../", and the mode it is downloaded in.
+func (s manifestSource) resolve(u *url.URL) (string, ClientMode, error) {
+	src := s.Source
+	if strings.HasPrefix(src, "./") || strings.HasPrefix(src, "../") {
+		ref, err := url.Parse(src)
+		if err != nil {
+			return "", 0, err
+		}
+		src = u.ResolveReference(ref).String()
+	}
+
+	var mode ClientMode
+	switch s.Mode {
+	case "", "any":
+		mode = ClientModeAny
+	case "file":
+		mode = ClientModeFile
+	case "dir":
+		mode = ClientModeDir
+	default:
+		return "", 0, fmt.Errorf("invalid mode, must be 'any', 'file', or 'dir': %s", s.Mode)
+	}
+
+	return src, mode, nil
+}
+
+// manifestSourceNames returns the source of each of the given entries.
+func manifestSourceNames(sources []manifestSource) []string {
+	result := make([]string, len(sources))
+	for i, s := range sources {
+		result[i] = s.Source
+	}
+
+	return result
+}
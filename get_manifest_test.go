@@ -0,0 +1,244 @@
+package getter
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManifestGetter_impl(t *testing.T) {
+	var _ Getter = new(ManifestGetter)
+}
+
+func TestManifestGetter(t *testing.T) {
+	g := new(ManifestGetter)
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	if err := g.Get(dst, testModuleURL("manifest/manifest.json")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	check := []string{
+		"module/main.tf",
+		"module/subdir/sub.tf",
+		"files/foo.txt",
+	}
+	for _, name := range check {
+		if _, err := os.Stat(filepath.Join(dst, name)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	assertContents(t, filepath.Join(dst, "renamed.txt"), "Hello\n")
+}
+
+func TestManifestGetter_forced(t *testing.T) {
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	u := "manifest::" + testModule("manifest/manifest.json")
+	if err := Get(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	assertContents(t, filepath.Join(dst, "files", "foo.txt"), "Hello\n")
+}
+
+func TestManifestGetter_yaml(t *testing.T) {
+	g := new(ManifestGetter)
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	if err := g.Get(dst, testModuleURL("manifest/manifest.yaml")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, name := range []string{"module/main.tf", "module/subdir/sub.tf", "files/foo.txt"} {
+		if _, err := os.Stat(filepath.Join(dst, name)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	assertContents(t, filepath.Join(dst, "renamed.txt"), "Hello\n")
+}
+
+func TestParseManifest_yaml(t *testing.T) {
+	cases := []struct {
+		Input  string
+		Output []manifestSource
+		Err    bool
+	}{
+		{
+			"sources:\n- source: a\n  destination: b\n",
+			[]manifestSource{{Source: "a", Destination: "b"}},
+			false,
+		},
+		{
+			"---\nsources:\n  - source: \"a # b\"\n    optional: true # c\n\n  -   source: 'it''s'\n      mode: dir\n",
+			[]manifestSource{{Source: "a # b", Optional: true}, {Source: "it's", Mode: "dir"}},
+			false,
+		},
+		{
+			"version: 1\nsources:\n  - source: https://example.com/x?a=b\n    labels:\n      team: infra\nother:\n  - x\n",
+			[]manifestSource{{Source: "https://example.com/x?a=b"}},
+			false,
+		},
+		{"sources: []\n", []manifestSource{}, false},
+		{"# nothing\n", nil, false},
+
+		{"sources:\n  - source: a\n   destination: b\n", nil, true},
+		{"sources:\n  - source: a\n    optional: maybe\n", nil, true},
+		{"sources:\n  - {source: a}\n", nil, true},
+		{"sources: [{source: a}]\n", nil, true},
+		{"sources:\n  - source: |\n      a\n", nil, true},
+		{"sources:\n  - source: *a\n", nil, true},
+		{"sources:\n  - source: \"a\n", nil, true},
+		{"sources:\n\t- source: a\n", nil, true},
+		{"sources:\n  - source: a\n  - destination: b\n", nil, true},
+	}
+
+	for _, tc := range cases {
+		m, err := parseManifest(strings.NewReader(tc.Input))
+		if (err != nil) != tc.Err {
+			t.Fatalf("%q: err: %v", tc.Input, err)
+		}
+		if err == nil && !reflect.DeepEqual(m.Sources, tc.Output) {
+			t.Fatalf("%q: bad: %#v", tc.Input, m.Sources)
+		}
+	}
+}
+
+func TestManifestGetter_deadline(t *testing.T) {
+	// The manifest itself isn't downloaded once the deadline has passed
+	g := new(ManifestGetter)
+	g.client = &Client{Deadline: time.Now().Add(-time.Second)}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	err := g.Get(dst, testModuleURL("manifest/manifest.json"))
+	if reason := AbortReasonOf(err); reason != AbortDeadline {
+		t.Fatalf("bad reason %q: %v", reason, err)
+	}
+
+	// Nor are its sources once it passes after the manifest is downloaded
+	client := new(Client)
+	client.Getters = map[string]Getter{"file": &deadlineFileGetter{client: client}}
+	g.client = client
+	err = g.Get(dst, testModuleURL("manifest/manifest.json"))
+	perr, ok := err.(*PartialError)
+	if !ok {
+		t.Fatalf("expected a *PartialError, got: %#v", err)
+	}
+	if len(perr.Completed) != 0 || len(perr.Remaining) != 3 {
+		t.Fatalf("bad: %#v", perr)
+	}
+	if perr.Err != errDeadlineExceeded {
+		t.Fatalf("bad: %s", perr.Err)
+	}
+}
+
+// deadlineFileGetter is a FileGetter that passes the deadline of client
+// once it has downloaded a file.
+type deadlineFileGetter struct {
+	FileGetter
+	client *Client
+}
+
+func (g *deadlineFileGetter) GetFile(dst string, u *url.URL) error {
+	err := g.FileGetter.GetFile(dst, u)
+	g.client.Deadline = time.Now().Add(-time.Second)
+	return err
+}
+
+func TestManifestGetter_sourceError(t *testing.T) {
+	g := new(ManifestGetter)
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	err := g.Get(dst, testModuleURL("manifest-missing/manifest.json"))
+	berr, ok := err.(*BatchError)
+	if !ok {
+		t.Fatalf("expected a *BatchError, got: %#v", err)
+	}
+
+	expected := []SourceStatus{SourceSucceeded, SourceFailed, SourceSkipped}
+	if len(berr.Sources) != len(expected) {
+		t.Fatalf("bad: %#v", berr.Sources)
+	}
+	for i, r := range berr.Sources {
+		if r.Status != expected[i] {
+			t.Fatalf("%d: bad status: %#v", i, r)
+		}
+	}
+	if berr.Sources[1].Err == nil || berr.Sources[1].Attempts != 1 || berr.Sources[2].Attempts != 0 {
+		t.Fatalf("bad: %#v", berr.Sources)
+	}
+}
+
+func TestManifestGetter_optional(t *testing.T) {
+	var failures []SourceResult
+	g := new(ManifestGetter)
+	g.client = &Client{OnOptionalFailure: func(r SourceResult) {
+		failures = append(failures, r)
+	}}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	if err := g.Get(dst, testModuleURL("manifest-optional/manifest.json")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The sources after the optional one are still downloaded
+	assertContents(t, filepath.Join(dst, "files", "foo.txt"), "Hello\n")
+	if len(failures) != 1 {
+		t.Fatalf("bad: %#v", failures)
+	}
+	if r := failures[0]; r.Status != SourceFailed || !r.Optional || r.Err == nil {
+		t.Fatalf("bad: %#v", r)
+	}
+}
+
+func TestManifestGetter_offline(t *testing.T) {
+	g := new(ManifestGetter)
+	g.client = &Client{Offline: true}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	err := g.Get(dst, testModuleURL("manifest-offline/manifest.json"))
+	oe, ok := err.(*OfflineError)
+	if !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	// Every missing source is reported, and the local ones downloaded
+	expected := []string{"https://example.com/foo.tar.gz", "https://s3.amazonaws.com/bucket/bar"}
+	if !reflect.DeepEqual(oe.Sources, expected) {
+		t.Fatalf("bad: %#v", oe.Sources)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "module", "main.tf")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestManifestGetter_badDestination(t *testing.T) {
+	g := new(ManifestGetter)
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	if err := g.Get(dst, testModuleURL("manifest-bad/manifest.json")); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestManifestGetter_file(t *testing.T) {
+	g := new(ManifestGetter)
+	dst := tempFile(t)
+
+	if err := g.GetFile(dst, testModuleURL("manifest/manifest.json")); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -0,0 +1,310 @@
+package getter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a line of a YAML manifest with its comment removed.
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// parseManifestYAML decodes a YAML manifest into m. It understands the
+// block style YAML that manifests are written in, a "sources" sequence of
+// mappings of plain, quoted or boolean scalars, and fails on anything else,
+// such as flow collections other than an empty [], anchors and multi-line
+// scalars, rather than guessing at it. Keys that a manifest doesn't have
+// are ignored, as they are in JSON.
+func parseManifestYAML(data []byte, m *manifest) error {
+	lines, err := yamlLines(data)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(lines); {
+		l := lines[i]
+		if l.indent != 0 || strings.HasPrefix(l.text, "-") {
+			return fmt.Errorf("line %d: expected a key at the top level", l.num)
+		}
+		key, value, err := yamlKeyValue(l)
+		if err != nil {
+			return err
+		}
+		i++
+
+		// The block under a key is indented more than it, except that a
+		// sequence may be at the same indentation
+		end := i
+		for end < len(lines) && (lines[end].indent > 0 || yamlItem(lines[end].text)) {
+			end++
+		}
+		block := lines[i:end]
+		i = end
+
+		if key != "sources" {
+			continue
+		}
+		if value == "~" || value == "null" {
+			m.Sources = nil
+			continue
+		}
+		if value != "" {
+			if value != "[]" {
+				return fmt.Errorf("line %d: sources must be a block sequence", l.num)
+			}
+			if len(block) > 0 {
+				return fmt.Errorf("line %d: unexpected indentation", block[0].num)
+			}
+			m.Sources = []manifestSource{}
+			continue
+		}
+
+		m.Sources, err = parseYAMLSources(block)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseYAMLSources decodes the block sequence of sources in lines.
+func parseYAMLSources(lines []yamlLine) ([]manifestSource, error) {
+	sources := []manifestSource{}
+	if len(lines) == 0 {
+		return sources, nil
+	}
+
+	indent := lines[0].indent
+	for i := 0; i < len(lines); {
+		l := lines[i]
+		if l.indent != indent || !yamlItem(l.text) {
+			return nil, fmt.Errorf("line %d: expected a sequence entry", l.num)
+		}
+
+		// The entry's first key follows the "-", and the rest are lined
+		// up with it
+		rest := strings.TrimLeft(l.text[1:], " ")
+		if rest == "" {
+			return nil, fmt.Errorf("line %d: sources must be mappings", l.num)
+		}
+		keyIndent := l.indent + len(l.text) - len(rest)
+		entry := []yamlLine{{num: l.num, indent: keyIndent, text: rest}}
+		for i++; i < len(lines) && lines[i].indent > indent; i++ {
+			entry = append(entry, lines[i])
+		}
+
+		s, err := parseYAMLSource(entry)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, s)
+	}
+
+	return sources, nil
+}
+
+// parseYAMLSource decodes the mapping of a source in lines.
+func parseYAMLSource(lines []yamlLine) (manifestSource, error) {
+	var s manifestSource
+	indent := lines[0].indent
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		if l.indent != indent {
+			return s, fmt.Errorf("line %d: unexpected indentation", l.num)
+		}
+		key, value, err := yamlKeyValue(l)
+		if err != nil {
+			return s, err
+		}
+
+		// Nested blocks only belong to keys that manifests don't have
+		if value == "" {
+			for i+1 < len(lines) && lines[i+1].indent > indent {
+				i++
+			}
+		}
+
+		switch key {
+		case "source", "destination", "mode":
+			v, err := yamlString(value)
+			if err != nil {
+				return s, fmt.Errorf("line %d: %s", l.num, err)
+			}
+			switch key {
+			case "source":
+				s.Source = v
+			case "destination":
+				s.Destination = v
+			case "mode":
+				s.Mode = v
+			}
+		case "optional":
+			switch strings.ToLower(value) {
+			case "true":
+				s.Optional = true
+			case "false", "", "~", "null":
+				s.Optional = false
+			default:
+				return s, fmt.Errorf("line %d: optional must be true or false, got %s", l.num, value)
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// yamlLines splits data into its lines, leaving out blank lines, comments
+// and document markers.
+func yamlLines(data []byte) ([]yamlLine, error) {
+	var lines []yamlLine
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	for num := 1; scanner.Scan(); num++ {
+		raw := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		text := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs can't be used for indentation", num)
+		}
+
+		text = strings.TrimRight(yamlStripComment(text), " \t")
+		if text == "" || text == "---" || text == "..." {
+			continue
+		}
+		if strings.HasPrefix(text, "%") {
+			return nil, fmt.Errorf("line %d: directives aren't supported", num)
+		}
+		lines = append(lines, yamlLine{num: num, indent: len(raw) - len(strings.TrimLeft(raw, " ")), text: text})
+	}
+
+	return lines, scanner.Err()
+}
+
+// yamlStripComment removes the comment, if any, from the end of text.
+func yamlStripComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || text[i-1] == ' ' || text[i-1] == ':' || text[i-1] == '-' {
+				quote = c
+			}
+		case c == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return text[:i]
+		}
+	}
+
+	return text
+}
+
+// yamlItem returns true if text is an entry of a block sequence.
+func yamlItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// yamlKeyValue splits the mapping entry on l into its key and the rest of
+// the line, which is empty if the value is a block on the following lines.
+func yamlKeyValue(l yamlLine) (string, string, error) {
+	text := l.text
+	var key string
+	switch text[0] {
+	case '"', '\'':
+		end := yamlQuoteEnd(text)
+		if end < 0 {
+			return "", "", fmt.Errorf("line %d: unterminated quoted key", l.num)
+		}
+		k, err := yamlString(text[:end+1])
+		if err != nil {
+			return "", "", fmt.Errorf("line %d: %s", l.num, err)
+		}
+		key, text = k, text[end+1:]
+		if !strings.HasPrefix(text, ":") {
+			return "", "", fmt.Errorf("line %d: expected a ':' after the key", l.num)
+		}
+		text = text[1:]
+	case '?', '&', '*', '!', '{', '[':
+		return "", "", fmt.Errorf("line %d: unsupported YAML: %s", l.num, l.text)
+	default:
+		idx := strings.Index(text, ": ")
+		if idx < 0 {
+			if !strings.HasSuffix(text, ":") {
+				return "", "", fmt.Errorf("line %d: expected a key and value: %s", l.num, l.text)
+			}
+			idx = len(text) - 1
+		}
+		key, text = strings.TrimRight(text[:idx], " "), text[idx+1:]
+	}
+	if text != "" && text[0] != ' ' {
+		return "", "", fmt.Errorf("line %d: expected a space after the ':'", l.num)
+	}
+
+	value := strings.TrimSpace(text)
+	if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+		return "", "", fmt.Errorf("line %d: multi-line scalars aren't supported", l.num)
+	}
+	return key, value, nil
+}
+
+// yamlQuoteEnd returns the index in text of the quote that ends the quoted
+// scalar it starts with, or -1 if it isn't ended.
+func yamlQuoteEnd(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote:
+			if quote == '\'' && i+1 < len(text) && text[i+1] == '\'' {
+				// An escaped single quote
+				i++
+				continue
+			}
+			return i
+		}
+	}
+
+	return -1
+}
+
+// yamlString decodes the scalar value as a string.
+func yamlString(value string) (string, error) {
+	if value == "" || value == "~" || value == "null" {
+		return "", nil
+	}
+
+	switch value[0] {
+	case '"':
+		if yamlQuoteEnd(value) != len(value)-1 {
+			return "", fmt.Errorf("bad quoted string: %s", value)
+		}
+		s, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("bad quoted string: %s", value)
+		}
+		return s, nil
+	case '\'':
+		if yamlQuoteEnd(value) != len(value)-1 {
+			return "", fmt.Errorf("bad quoted string: %s", value)
+		}
+		return strings.Replace(value[1:len(value)-1], "''", "'", -1), nil
+	case '&', '*', '!', '{', '[', '|', '>', '@', '`':
+		return "", fmt.Errorf("unsupported YAML: %s", value)
+	}
+
+	return value, nil
+}
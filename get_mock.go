@@ -6,6 +6,8 @@ import (
 
 // MockGetter is an implementation of Getter that can be used for tests.
 type MockGetter struct {
+	getter
+
 	// Proxy, if set, will be called after recording the calls below.
 	// If it isn't set, then the *Err values will be returned.
 	Proxy Getter
@@ -19,29 +21,55 @@ type MockGetter struct {
 	GetFileDst    string
 	GetFileURL    *url.URL
 	GetFileErr    error
+
+	// calls, if set, is the mock that records the calls of this copy of
+	// it, made by withClient.
+	calls *MockGetter
+}
+
+// withClient passes the client on to the proxy. The copy records its calls
+// in the mock itself, so that those of every client using it can be
+// checked.
+func (g *MockGetter) withClient(c *Client) Getter {
+	if g.Proxy == nil {
+		return g
+	}
+
+	return &MockGetter{Proxy: getterForClient(g.Proxy, c), calls: g.recorder()}
+}
+
+// recorder returns the mock that records the calls of g.
+func (g *MockGetter) recorder() *MockGetter {
+	if g.calls != nil {
+		return g.calls
+	}
+
+	return g
 }
 
 func (g *MockGetter) Get(dst string, u *url.URL) error {
-	g.GetCalled = true
-	g.GetDst = dst
-	g.GetURL = u
+	r := g.recorder()
+	r.GetCalled = true
+	r.GetDst = dst
+	r.GetURL = u
 
 	if g.Proxy != nil {
 		return g.Proxy.Get(dst, u)
 	}
 
-	return g.GetErr
+	return r.GetErr
 }
 
 func (g *MockGetter) GetFile(dst string, u *url.URL) error {
-	g.GetFileCalled = true
-	g.GetFileDst = dst
-	g.GetFileURL = u
+	r := g.recorder()
+	r.GetFileCalled = true
+	r.GetFileDst = dst
+	r.GetFileURL = u
 
 	if g.Proxy != nil {
 		return g.Proxy.GetFile(dst, u)
 	}
-	return g.GetFileErr
+	return r.GetFileErr
 }
 
 func (g *MockGetter) ClientMode(u *url.URL) (ClientMode, error) {
@@ -0,0 +1,375 @@
+package getter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// OCI media types of manifests and layers that OCIGetter understands.
+const (
+	ociManifestType       = "application/vnd.oci.image.manifest.v1+json"
+	ociIndexType          = "application/vnd.oci.image.index.v1+json"
+	dockerManifestType    = "application/vnd.docker.distribution.manifest.v2+json"
+	dockerManifestList    = "application/vnd.docker.distribution.manifest.list.v2+json"
+	ociTitleAnnotation    = "org.opencontainers.image.title"
+	dockerLayerGzipSuffix = ".tar.gzip"
+)
+
+// OCIGetter is a Getter implementation that will download an artifact or
+// image from an OCI registry, given by a URL such as
+// oci://registry.example.com/repo:tag or oci://registry.example.com/repo@sha256:...
+// The tag defaults to "latest".
+//
+// Each layer of the manifest is downloaded and verified against its digest
+// in turn. Layers that are tar archives, optionally compressed with gzip or
+// zstd, and zip archives are extracted into the destination, and any other
+// layer is written to it as a file named by its
+// "org.opencontainers.image.title" annotation. Image layers are extracted
+// over each other in order, but whiteout files aren't interpreted. If the
+// reference is an index of manifests for several platforms, the one for
+// the current platform is used.
+//
+// Requests are authenticated with the credentials for the registry in the
+// Docker configuration, ~/.docker/config.json or the config.json in
+// $DOCKER_CONFIG, including those from credential helpers.
+type OCIGetter struct {
+	getter
+
+	// Client is the http.Client to use for requests to registries.
+	// This defaults to a cleanhttp.DefaultClient if left unset.
+	Client *http.Client
+
+	// Insecure, if true, talks to registries over plain HTTP.
+	Insecure bool
+}
+
+func (g *OCIGetter) withClient(c *Client) Getter {
+	cp := *g
+	cp.client = c
+	return &cp
+}
+
+// ociReference is a parsed oci:// URL.
+type ociReference struct {
+	// Registry is the host, and port, of the registry API.
+	Registry string
+
+	// Repository is the name of the repository in the registry.
+	Repository string
+
+	// Reference is the tag or digest of the manifest.
+	Reference string
+}
+
+// ociDescriptor describes content in a registry.
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+	Platform    *struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+	} `json:"platform"`
+}
+
+// ociManifest is an image manifest, or an index of them.
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Layers    []ociDescriptor `json:"layers"`
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+func (g *OCIGetter) Describe() Description {
+	return Description{
+		Summary: "An artifact or image in an OCI registry, by tag or digest.",
+		Strict:  true,
+	}
+}
+
+func (g *OCIGetter) ClientMode(_ *url.URL) (ClientMode, error) {
+	return ClientModeDir, nil
+}
+
+func (g *OCIGetter) Get(dst string, u *url.URL) error {
+	ref, err := parseOCIReference(u)
+	if err != nil {
+		return err
+	}
+
+	c := g.registryClient(ref)
+	m, err := c.manifest(ref.Reference)
+	if err != nil {
+		return err
+	}
+
+	// Remove destination if it already exists, since layers are
+	// extracted over what is there
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	for _, layer := range m.Layers {
+		if err := g.stopped(); err != nil {
+			return err
+		}
+		if err := c.extractLayer(dst, layer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetFile downloads the layer of an artifact that has a single layer.
+func (g *OCIGetter) GetFile(dst string, u *url.URL) error {
+	ref, err := parseOCIReference(u)
+	if err != nil {
+		return err
+	}
+
+	c := g.registryClient(ref)
+	m, err := c.manifest(ref.Reference)
+	if err != nil {
+		return err
+	}
+	if len(m.Layers) != 1 {
+		return fmt.Errorf(
+			"%s has %d layers, only artifacts with a single layer can be downloaded as a file",
+			u.String(), len(m.Layers))
+	}
+
+	return c.blob(dst, m.Layers[0])
+}
+
+func (g *OCIGetter) registryClient(ref *ociReference) *ociClient {
+	client := g.Client
+	if client == nil {
+		client = httpClient
+	}
+	client = g.addressClient(client)
+
+	scheme := "https"
+	if g.Insecure {
+		scheme = "http"
+	}
+
+	return &ociClient{
+		getter: g,
+		client: client,
+		ref:    ref,
+		base:   scheme + "://" + ref.Registry + "/v2/" + ref.Repository,
+	}
+}
+
+// parseOCIReference parses an oci:// URL.
+func parseOCIReference(u *url.URL) (*ociReference, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("OCI URL has no registry: %s", u.String())
+	}
+
+	repo := strings.Trim(u.Path, "/")
+	reference := "latest"
+	if idx := strings.Index(repo, "@"); idx >= 0 {
+		repo, reference = repo[:idx], repo[idx+1:]
+	} else if idx := strings.LastIndex(repo, ":"); idx > strings.LastIndex(repo, "/") {
+		repo, reference = repo[:idx], repo[idx+1:]
+	}
+	if repo == "" || reference == "" {
+		return nil, fmt.Errorf("OCI URL must be of the form oci://registry/repository:tag: %s", u.String())
+	}
+
+	// Docker Hub doesn't serve its API from docker.io, and official
+	// images are in the library namespace.
+	registry := u.Host
+	if registry == "docker.io" || registry == "index.docker.io" {
+		registry = "registry-1.docker.io"
+		if !strings.Contains(repo, "/") {
+			repo = "library/" + repo
+		}
+	}
+
+	return &ociReference{Registry: registry, Repository: repo, Reference: reference}, nil
+}
+
+// ociClient makes requests for a single repository in a registry.
+type ociClient struct {
+	getter *OCIGetter
+	client *http.Client
+	ref    *ociReference
+	base   string
+
+	// auth is the Authorization header to send, once the registry has
+	// asked for one.
+	auth string
+}
+
+// manifest returns the image manifest with the given reference, picking
+// the manifest for the current platform from an index.
+func (c *ociClient) manifest(reference string) (*ociManifest, error) {
+	resp, err := c.do(c.base+"/manifests/"+reference, strings.Join([]string{
+		ociManifestType, ociIndexType, dockerManifestType, dockerManifestList,
+	}, ", "))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var m ociManifest
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return nil, fmt.Errorf("error parsing manifest of %s: %s", c.ref.Repository, err)
+	}
+	if m.MediaType == "" {
+		m.MediaType = resp.Header.Get("Content-Type")
+	}
+
+	if m.MediaType != ociIndexType && m.MediaType != dockerManifestList {
+		return &m, nil
+	}
+
+	var platforms []string
+	for _, d := range m.Manifests {
+		if d.Platform == nil || len(m.Manifests) == 1 ||
+			(d.Platform.OS == runtime.GOOS && d.Platform.Architecture == runtime.GOARCH) {
+			return c.manifest(d.Digest)
+		}
+		platforms = append(platforms, d.Platform.OS+"/"+d.Platform.Architecture)
+	}
+
+	return nil, fmt.Errorf(
+		"%s has no manifest for %s/%s, only for: %s",
+		c.ref.Repository, runtime.GOOS, runtime.GOARCH, strings.Join(platforms, ", "))
+}
+
+// extractLayer downloads the layer and extracts it into dst, or writes it
+// there if it isn't an archive.
+func (c *ociClient) extractLayer(dst string, layer ociDescriptor) error {
+	archiveV := ociArchiveType(layer.MediaType)
+	if archiveV == "" {
+		name := layer.Annotations[ociTitleAnnotation]
+		if name == "" {
+			return fmt.Errorf("layer %s isn't an archive and has no title", layer.Digest)
+		}
+		if filepath.IsAbs(name) || containsDotDot(name) {
+			return fmt.Errorf("layer title must be a relative path inside the destination: %s", name)
+		}
+
+		return c.blob(filepath.Join(dst, name), layer)
+	}
+
+	td, err := ioutil.TempDir("", "getter")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(td)
+
+	archive := filepath.Join(td, "layer")
+	if err := c.blob(archive, layer); err != nil {
+		return err
+	}
+
+	var d Decompressor = new(TarDecompressor)
+	if archiveV != "tar" {
+		d = Decompressors[archiveV]
+	}
+	return withLimits(d, c.getter.decompressLimits()).Decompress(dst, archive, true)
+}
+
+// blob downloads the content described by d to dst, verifying its digest.
+func (c *ociClient) blob(dst string, d ociDescriptor) error {
+	idx := strings.Index(d.Digest, ":")
+	if idx < 0 || d.Digest[:idx] != "sha256" {
+		return fmt.Errorf("unsupported digest: %s", d.Digest)
+	}
+	expected, err := hex.DecodeString(d.Digest[idx+1:])
+	if err != nil {
+		return fmt.Errorf("invalid digest: %s", d.Digest)
+	}
+
+	resp, err := c.do(c.base+"/blobs/"+d.Digest, "")
+	if err != nil {
+		return err
+	}
+	body := c.getter.trackProgress(d.Digest, 0, d.Size, resp.Body)
+	defer body.Close()
+
+	h := sha256.New()
+	if err := writeFile(dst, io.TeeReader(body, h)); err != nil {
+		return err
+	}
+	if actual := h.Sum(nil); !bytes.Equal(actual, expected) {
+		os.Remove(dst)
+		return fmt.Errorf(
+			"%s did not match its digest, got sha256:%s",
+			d.Digest, hex.EncodeToString(actual))
+	}
+
+	return nil
+}
+
+// do sends a GET request to u, authenticating with the registry if it asks
+// for that. Any response other than a 200 is an error.
+func (c *ociClient) do(u, accept string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(c.getter.Context(), "GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header = c.getter.header()
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if c.auth != "" {
+			req.Header.Set("Authorization", c.auth)
+		}
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return nil, &BadResponseCodeError{Code: resp.StatusCode, URL: redactURLCredentials(u)}
+		}
+
+		c.auth, err = c.authenticate(resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ociArchiveType returns the type of archive, as a key of Decompressors,
+// of layers of the given media type, or "" if they aren't archives.
+func ociArchiveType(mediaType string) string {
+	switch {
+	case strings.HasSuffix(mediaType, ".tar"):
+		return "tar"
+	case strings.HasSuffix(mediaType, ".tar+gzip"), strings.HasSuffix(mediaType, dockerLayerGzipSuffix):
+		return "tar.gz"
+	case strings.HasSuffix(mediaType, ".tar+zstd"):
+		return "tar.zst"
+	case strings.HasSuffix(mediaType, "+zip"), strings.HasSuffix(mediaType, ".zip"):
+		return "zip"
+	}
+
+	return ""
+}
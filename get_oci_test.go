@@ -0,0 +1,464 @@
+package getter
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestOCIGetter_impl(t *testing.T) {
+	var _ Getter = new(OCIGetter)
+}
+
+func TestOCIGetter(t *testing.T) {
+	r := newTestOCIRegistry()
+	r.image("v1", ociImageLayer,
+		testOCITarGz(t, map[string]string{"main.tf": "old", "subdir/sub.tf": "sub"}),
+		testOCITarGz(t, map[string]string{"main.tf": "main"}))
+	server := httptest.NewTLSServer(r)
+	defer server.Close()
+	defer testOCIConfig(t, server, "user", "pass")()
+
+	g := &OCIGetter{Client: server.Client()}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	if err := g.Get(dst, testOCIURL(server, "repo:v1")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Later layers are extracted over earlier ones
+	assertContents(t, filepath.Join(dst, "main.tf"), "main")
+	assertContents(t, filepath.Join(dst, "subdir", "sub.tf"), "sub")
+}
+
+func TestOCIGetter_GetFile(t *testing.T) {
+	r := newTestOCIRegistry()
+	r.artifact("v1", "hello.txt", []byte("Hello\n"))
+	server := httptest.NewTLSServer(r)
+	defer server.Close()
+	defer testOCIConfig(t, server, "user", "pass")()
+
+	g := &OCIGetter{Client: server.Client()}
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	if err := g.GetFile(dst, testOCIURL(server, "repo:v1")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello\n")
+
+	// As a directory the layer is named by its title
+	dir := filepath.Join(filepath.Dir(dst), "dir")
+	if err := g.Get(dir, testOCIURL(server, "repo:v1")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dir, "hello.txt"), "Hello\n")
+}
+
+func TestOCIGetter_GetFile_layers(t *testing.T) {
+	r := newTestOCIRegistry()
+	r.image("v1", ociImageLayer,
+		testOCITarGz(t, map[string]string{"a": "a"}),
+		testOCITarGz(t, map[string]string{"b": "b"}))
+	server := httptest.NewTLSServer(r)
+	defer server.Close()
+	defer testOCIConfig(t, server, "user", "pass")()
+
+	g := &OCIGetter{Client: server.Client()}
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	if err := g.GetFile(dst, testOCIURL(server, "repo:v1")); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestOCIGetter_index(t *testing.T) {
+	r := newTestOCIRegistry()
+	other := r.image("other", ociImageLayer, testOCITarGz(t, map[string]string{"main.tf": "other"}))
+	current := r.image("current", ociImageLayer, testOCITarGz(t, map[string]string{"main.tf": "current"}))
+	r.index("v1", map[string]string{
+		"plan9/" + runtime.GOARCH:           other,
+		runtime.GOOS + "/" + runtime.GOARCH: current,
+	})
+	server := httptest.NewTLSServer(r)
+	defer server.Close()
+	defer testOCIConfig(t, server, "user", "pass")()
+
+	g := &OCIGetter{Client: server.Client()}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	if err := g.Get(dst, testOCIURL(server, "repo:v1")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "main.tf"), "current")
+
+	// By digest
+	dst = filepath.Join(dst, "digest")
+	if err := g.Get(dst, testOCIURL(server, "repo@"+other)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "main.tf"), "other")
+}
+
+func TestOCIGetter_badDigest(t *testing.T) {
+	r := newTestOCIRegistry()
+	r.artifact("v1", "hello.txt", []byte("Hello\n"))
+	for digest := range r.blobs {
+		r.blobs[digest] = []byte("Goodbye\n")
+	}
+	server := httptest.NewTLSServer(r)
+	defer server.Close()
+	defer testOCIConfig(t, server, "user", "pass")()
+
+	g := &OCIGetter{Client: server.Client()}
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	err := g.GetFile(dst, testOCIURL(server, "repo:v1"))
+	if err == nil || !strings.Contains(err.Error(), "did not match its digest") {
+		t.Fatalf("bad: %v", err)
+	}
+	if _, err := os.Stat(dst); err == nil {
+		t.Fatal("corrupt layer shouldn't be left in place")
+	}
+}
+
+func TestOCIGetter_badCredentials(t *testing.T) {
+	r := newTestOCIRegistry()
+	r.artifact("v1", "hello.txt", []byte("Hello\n"))
+	server := httptest.NewTLSServer(r)
+	defer server.Close()
+
+	g := &OCIGetter{Client: server.Client()}
+
+	for _, password := range []string{"", "wrong"} {
+		defer testOCIConfig(t, server, "user", password)()
+
+		dst := tempFile(t)
+		defer os.RemoveAll(filepath.Dir(dst))
+		if err := g.GetFile(dst, testOCIURL(server, "repo:v1")); err == nil {
+			t.Fatalf("%q: should error", password)
+		}
+	}
+}
+
+func TestOCIGetter_credentialHelper(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("credential helper is a shell script")
+	}
+
+	r := newTestOCIRegistry()
+	r.artifact("v1", "hello.txt", []byte("Hello\n"))
+	server := httptest.NewTLSServer(r)
+	defer server.Close()
+
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	if err := os.MkdirAll(td, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The helper is given the registry on stdin
+	host := testURL(server.URL).Host
+	helper := "#!/bin/sh\n" +
+		"read host\n" +
+		"if [ \"$1\" = get ] && [ \"$host\" = \"" + host + "\" ]; then\n" +
+		"  echo '{\"ServerURL\":\"" + host + "\",\"Username\":\"user\",\"Secret\":\"pass\"}'\n" +
+		"else\n" +
+		"  echo 'credentials not found in native keychain'\n" +
+		"  exit 1\n" +
+		"fi\n"
+	if err := ioutil.WriteFile(filepath.Join(td, "docker-credential-test"), []byte(helper), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer tempEnv(t, "PATH", td+string(os.PathListSeparator)+os.Getenv("PATH"))()
+	defer testOCIConfigJSON(t, `{"credsStore": "test"}`)()
+
+	g := &OCIGetter{Client: server.Client()}
+	dst := filepath.Join(td, "file")
+	if err := g.GetFile(dst, testOCIURL(server, "repo:v1")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello\n")
+}
+
+func TestParseOCIReference(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Expected *ociReference
+		Err      bool
+	}{
+		{
+			"oci://registry.example.com/repo:v1",
+			&ociReference{"registry.example.com", "repo", "v1"},
+			false,
+		},
+		{
+			"oci://registry.example.com:5000/org/repo",
+			&ociReference{"registry.example.com:5000", "org/repo", "latest"},
+			false,
+		},
+		{
+			"oci://registry.example.com/repo@sha256:abcd",
+			&ociReference{"registry.example.com", "repo", "sha256:abcd"},
+			false,
+		},
+		{
+			"oci://docker.io/ubuntu:22.04",
+			&ociReference{"registry-1.docker.io", "library/ubuntu", "22.04"},
+			false,
+		},
+		{
+			"oci://docker.io/org/repo",
+			&ociReference{"registry-1.docker.io", "org/repo", "latest"},
+			false,
+		},
+		{"oci://registry.example.com/", nil, true},
+		{"oci://registry.example.com/repo:", nil, true},
+	}
+
+	for _, tc := range cases {
+		actual, err := parseOCIReference(testURL(tc.Input))
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: err: %v", tc.Input, err)
+		}
+		if !reflect.DeepEqual(actual, tc.Expected) {
+			t.Fatalf("%s: bad: %#v", tc.Input, actual)
+		}
+	}
+}
+
+func TestParseAuthChallenge(t *testing.T) {
+	scheme, params := parseAuthChallenge(
+		`Bearer realm="https://auth.example.com/token",service="registry",scope="repository:a/b:pull,push"`)
+	if scheme != "Bearer" {
+		t.Fatalf("bad scheme: %s", scheme)
+	}
+	expected := map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry",
+		"scope":   "repository:a/b:pull,push",
+	}
+	if !reflect.DeepEqual(params, expected) {
+		t.Fatalf("bad: %#v", params)
+	}
+
+	scheme, params = parseAuthChallenge(`Basic realm=registry`)
+	if scheme != "Basic" || params["realm"] != "registry" {
+		t.Fatalf("bad: %s %#v", scheme, params)
+	}
+}
+
+const ociImageLayer = "application/vnd.oci.image.layer.v1.tar+gzip"
+
+// testOCIRegistry is a registry serving a single repository named "repo"
+// that requires a bearer token, which is given for the basic credentials
+// user:pass.
+type testOCIRegistry struct {
+	manifests map[string][]byte
+	types     map[string]string
+	blobs     map[string][]byte
+}
+
+func newTestOCIRegistry() *testOCIRegistry {
+	return &testOCIRegistry{
+		manifests: make(map[string][]byte),
+		types:     make(map[string]string),
+		blobs:     make(map[string][]byte),
+	}
+}
+
+func (r *testOCIRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		if user, pass, ok := req.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if req.URL.Query().Get("scope") != "repository:repo:pull" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"token": "secret-token"})
+		return
+	}
+
+	if req.Header.Get("Authorization") != "Bearer secret-token" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="https://`+req.Host+
+			`/token",service="test",scope="repository:repo:pull"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(req.URL.Path, "/v2/repo/manifests/"):
+		ref := strings.TrimPrefix(req.URL.Path, "/v2/repo/manifests/")
+		data, ok := r.manifests[ref]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", r.types[ref])
+		w.Write(data)
+	case strings.HasPrefix(req.URL.Path, "/v2/repo/blobs/"):
+		data, ok := r.blobs[strings.TrimPrefix(req.URL.Path, "/v2/repo/blobs/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// blob adds a blob, returning its descriptor.
+func (r *testOCIRegistry) blob(mediaType string, data []byte) ociDescriptor {
+	sum := sha256.Sum256(data)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	r.blobs[digest] = data
+
+	return ociDescriptor{MediaType: mediaType, Digest: digest, Size: int64(len(data))}
+}
+
+// manifest adds a manifest under the tag and its digest, returning the
+// digest.
+func (r *testOCIRegistry) manifest(tag, mediaType string, m interface{}) string {
+	data, err := json.Marshal(m)
+	if err != nil {
+		panic(err)
+	}
+
+	sum := sha256.Sum256(data)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	for _, ref := range []string{tag, digest} {
+		r.manifests[ref] = data
+		r.types[ref] = mediaType
+	}
+
+	return digest
+}
+
+// image adds an image manifest with the given layers.
+func (r *testOCIRegistry) image(tag, mediaType string, layers ...[]byte) string {
+	m := make(map[string]interface{})
+	m["schemaVersion"] = 2
+	m["mediaType"] = ociManifestType
+	var descriptors []ociDescriptor
+	for _, layer := range layers {
+		descriptors = append(descriptors, r.blob(mediaType, layer))
+	}
+	m["layers"] = descriptors
+
+	return r.manifest(tag, ociManifestType, m)
+}
+
+// artifact adds an artifact manifest with a single file layer.
+func (r *testOCIRegistry) artifact(tag, name string, data []byte) string {
+	d := r.blob("text/plain", data)
+	d.Annotations = map[string]string{ociTitleAnnotation: name}
+
+	return r.manifest(tag, ociManifestType, map[string]interface{}{
+		"schemaVersion": 2,
+		"layers":        []ociDescriptor{d},
+	})
+}
+
+// index adds an index of the manifests keyed by their platform.
+func (r *testOCIRegistry) index(tag string, platforms map[string]string) string {
+	var manifests []map[string]interface{}
+	for platform, digest := range platforms {
+		idx := strings.Index(platform, "/")
+		manifests = append(manifests, map[string]interface{}{
+			"mediaType": ociManifestType,
+			"digest":    digest,
+			"platform": map[string]string{
+				"os":           platform[:idx],
+				"architecture": platform[idx+1:],
+			},
+		})
+	}
+
+	return r.manifest(tag, ociIndexType, map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     ociIndexType,
+		"manifests":     manifests,
+	})
+}
+
+func testOCIURL(server *httptest.Server, ref string) *url.URL {
+	return testURL("oci://" + testURL(server.URL).Host + "/" + ref)
+}
+
+// testOCIConfig sets DOCKER_CONFIG to a configuration with the basic
+// credentials for the server, returning a func that restores it.
+func testOCIConfig(t *testing.T, server *httptest.Server, user, pass string) func() {
+	host := testURL(server.URL).Host
+	auth := base64.StdEncoding.EncodeToString([]byte(user + ":" + pass))
+
+	return testOCIConfigJSON(t, `{"auths": {"`+host+`": {"auth": "`+auth+`"}}}`)
+}
+
+// testOCIConfigJSON sets DOCKER_CONFIG to a configuration with the given
+// contents, returning a func that restores it and removes the
+// configuration.
+func testOCIConfigJSON(t *testing.T, config string) func() {
+	td, err := ioutil.TempDir("", "getter")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(td, "config.json"), []byte(config), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	restore := tempEnv(t, "DOCKER_CONFIG", td)
+	return func() {
+		restore()
+		os.RemoveAll(td)
+	}
+}
+
+// testOCITarGz returns a gzipped tar archive of the given files.
+func testOCITarGz(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, contents := range files {
+		hdr := &tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(contents)),
+			Typeflag: tar.TypeReg,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return buf.Bytes()
+}
@@ -6,30 +6,125 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/hashicorp/go-version"
 )
 
 // S3Getter is a Getter implementation that will download a module from
 // a S3 bucket.
-type S3Getter struct{}
+//
+// A file can be picked from the keys matching a pattern with a single "*"
+// in its last path segment, such as "builds/app-*.tar.gz", by setting the
+// "pick" query parameter. "latest-modified" picks the most recently
+// modified key and "latest-version" the one whose part matched by "*" is
+// the greatest version, ignoring keys where it isn't a version.
+type S3Getter struct {
+	getter
+
+	// RoleARN, if set, is an IAM role to assume with the credentials that
+	// would otherwise be used, such as for buckets in other accounts, and
+	// ExternalID the external ID to assume it with. The role_arn and
+	// external_id query parameters take priority.
+	RoleARN    string
+	ExternalID string
+
+	// Endpoint, if set, is where requests are sent instead of the host in
+	// the URL, for S3 compatible services such as MinIO and Ceph RGW. It
+	// may include a scheme. The endpoint query parameter takes priority.
+	Endpoint string
+
+	// DisablePathStyle, if true, addresses buckets as a subdomain of the
+	// endpoint rather than as the first segment of the path. The
+	// path_style query parameter takes priority.
+	DisablePathStyle bool
+
+	// Timeout, if positive, fails requests to S3 once it has sent nothing
+	// for this long, whether they are waiting for a response or reading an
+	// object, so that an endpoint that stops responding can't hang a
+	// download.
+	Timeout time.Duration
+
+	// Anonymous, if true, sends requests unsigned, for public buckets,
+	// rather than looking for credentials in the environment, shared
+	// credentials file and instance metadata, which is slow and fails
+	// confusingly where there are none. RoleARN isn't assumed then. The
+	// anonymous query parameter and credentials in the URL take priority.
+	Anonymous bool
+
+	// SSECustomerKey, if set, is the 256-bit key that objects are
+	// encrypted with on the server (SSE-C). S3 needs it to decrypt the
+	// objects that are downloaded, and files uploaded by PutFile are
+	// encrypted with it. It is only sent over HTTPS. The sse_customer_key
+	// query parameter, with the key base64 encoded, takes priority.
+	SSECustomerKey []byte
+
+	// SSEKMSKeyID, if set, is the ID, ARN or alias of the KMS key that
+	// files uploaded by PutFile are encrypted with (SSE-KMS). Objects
+	// encrypted with KMS keys are decrypted by S3 itself, so downloading
+	// them only needs credentials that are allowed to use the key, such as
+	// those of RoleARN. The sse_kms_key_id query parameter takes priority.
+	SSEKMSKeyID string
+}
+
+func (g *S3Getter) withClient(c *Client) Getter {
+	cp := *g
+	cp.client = c
+	return &cp
+}
+
+func (g *S3Getter) Describe() Description {
+	return Description{
+		Summary: "An object, or every object under a prefix, in S3 or an S3 compatible service.",
+		Options: []Option{
+			{"aws_access_key_id", "The AWS access key."},
+			{"aws_access_key_secret", "The AWS access key secret."},
+			{"aws_access_token", "The AWS session token."},
+			{"role_arn", "An IAM role to assume."},
+			{"external_id", "The external ID to assume role_arn with."},
+			{"endpoint", "Where to send requests instead of the host in the URL."},
+			{"path_style", "If false, buckets are addressed as a subdomain of the endpoint."},
+			{"anonymous", "If true, requests are sent unsigned, for public buckets."},
+			{"pick", "latest-modified or latest-version, to pick one of the keys matching a pattern."},
+			{"region", "The region of the bucket."},
+			{"version_id", "The version of the object to download, rather than the latest."},
+			{"version", "The same as version_id."},
+			{"sse_customer_key", "The base64 encoded 256-bit key that the objects are encrypted with (SSE-C)."},
+			{"sse_kms_key_id", "The KMS key that uploads are encrypted with (SSE-KMS)."},
+		},
+		Strict: true,
+	}
+}
 
 func (g *S3Getter) ClientMode(u *url.URL) (ClientMode, error) {
 	// Parse URL
-	region, bucket, path, _, creds, err := g.parseUrl(u)
+	region, bucket, path, version, creds, err := g.parseUrl(u)
 	if err != nil {
 		return 0, err
 	}
 
+	// A picked key or a version is always a file, and a version of a key
+	// that has since been deleted isn't listed
+	if u.Query().Get("pick") != "" || version != "" {
+		return ClientModeFile, nil
+	}
+
 	// Create client config
-	config := g.getAWSConfig(region, u, creds)
-	sess := session.New(config)
+	config, err := g.getAWSConfig(region, u, creds)
+	if err != nil {
+		return 0, err
+	}
+	sess := g.newSession(config)
 	client := s3.New(sess)
 
 	// List the object(s) at the given prefix
@@ -37,7 +132,7 @@ func (g *S3Getter) ClientMode(u *url.URL) (ClientMode, error) {
 		Bucket: aws.String(bucket),
 		Prefix: aws.String(path),
 	}
-	resp, err := client.ListObjects(req)
+	resp, err := client.ListObjectsWithContext(g.Context(), req)
 	if err != nil {
 		return 0, err
 	}
@@ -61,7 +156,17 @@ func (g *S3Getter) ClientMode(u *url.URL) (ClientMode, error) {
 
 func (g *S3Getter) Get(dst string, u *url.URL) error {
 	// Parse URL
-	region, bucket, path, _, creds, err := g.parseUrl(u)
+	region, bucket, path, version, creds, err := g.parseUrl(u)
+	if err != nil {
+		return err
+	}
+	if u.Query().Get("pick") != "" {
+		return fmt.Errorf("pick can only be used to download a file")
+	}
+	if version != "" {
+		return fmt.Errorf("a version can only be downloaded as a file")
+	}
+	enc, err := g.encryption(u)
 	if err != nil {
 		return err
 	}
@@ -84,11 +189,15 @@ func (g *S3Getter) Get(dst string, u *url.URL) error {
 		return err
 	}
 
-	config := g.getAWSConfig(region, u, creds)
-	sess := session.New(config)
+	config, err := g.getAWSConfig(region, u, creds)
+	if err != nil {
+		return err
+	}
+	sess := g.newSession(config)
 	client := s3.New(sess)
 
 	// List files in path, keep listing until no more objects are found
+	var completed []string
 	lastMarker := ""
 	hasMore := true
 	for hasMore {
@@ -100,7 +209,7 @@ func (g *S3Getter) Get(dst string, u *url.URL) error {
 			req.Marker = aws.String(lastMarker)
 		}
 
-		resp, err := client.ListObjects(req)
+		resp, err := client.ListObjectsWithContext(g.Context(), req)
 		if err != nil {
 			return err
 		}
@@ -108,7 +217,20 @@ func (g *S3Getter) Get(dst string, u *url.URL) error {
 		hasMore = aws.BoolValue(resp.IsTruncated)
 
 		// Get each object storing each file relative to the destination path
-		for _, object := range resp.Contents {
+		for i, object := range resp.Contents {
+			if err := g.stopped(); err != nil {
+				var remaining []string
+				for _, o := range resp.Contents[i:] {
+					remaining = append(remaining, aws.StringValue(o.Key))
+				}
+
+				return &PartialError{
+					Completed: completed,
+					Remaining: remaining,
+					Err:       err,
+				}
+			}
+
 			lastMarker = aws.StringValue(object.Key)
 			objPath := aws.StringValue(object.Key)
 
@@ -124,9 +246,10 @@ func (g *S3Getter) Get(dst string, u *url.URL) error {
 			}
 			objDst = filepath.Join(dst, objDst)
 
-			if err := g.getObject(client, objDst, bucket, objPath, ""); err != nil {
+			if err := g.getObject(client, objDst, bucket, objPath, "", enc); err != nil {
 				return err
 			}
+			completed = append(completed, objPath)
 		}
 	}
 
@@ -138,14 +261,107 @@ func (g *S3Getter) GetFile(dst string, u *url.URL) error {
 	if err != nil {
 		return err
 	}
+	enc, err := g.encryption(u)
+	if err != nil {
+		return err
+	}
 
-	config := g.getAWSConfig(region, u, creds)
-	sess := session.New(config)
+	config, err := g.getAWSConfig(region, u, creds)
+	if err != nil {
+		return err
+	}
+	sess := g.newSession(config)
 	client := s3.New(sess)
-	return g.getObject(client, dst, bucket, path, version)
+	path, err = g.pickKey(client, bucket, path, u.Query().Get("pick"))
+	if err != nil {
+		return err
+	}
+
+	return g.getObject(client, dst, bucket, path, version, enc)
 }
 
-func (g *S3Getter) getObject(client *s3.S3, dst, bucket, key, version string) error {
+func (g *S3Getter) GetReader(u *url.URL) (io.ReadCloser, int64, error) {
+	region, bucket, path, version, creds, err := g.parseUrl(u)
+	if err != nil {
+		return nil, 0, err
+	}
+	enc, err := g.encryption(u)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	config, err := g.getAWSConfig(region, u, creds)
+	if err != nil {
+		return nil, 0, err
+	}
+	sess := g.newSession(config)
+	client := s3.New(sess)
+	path, err = g.pickKey(client, bucket, path, u.Query().Get("pick"))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(path),
+	}
+	if version != "" {
+		req.VersionId = aws.String(version)
+	}
+	enc.getObject(req)
+
+	resp, err := client.GetObjectWithContext(g.Context(), req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	size := int64(-1)
+	if resp.ContentLength != nil {
+		size = *resp.ContentLength
+	}
+
+	return g.trackProgress(path, 0, size, resp.Body), size, nil
+}
+
+// PutFile implements Putter by uploading the file at src to the key in u.
+// Since the SDK may read the file again to retry the upload, its progress
+// isn't tracked.
+func (g *S3Getter) PutFile(u *url.URL, src string) error {
+	region, bucket, path, version, creds, err := g.parseUrl(u)
+	if err != nil {
+		return err
+	}
+	if version != "" {
+		return fmt.Errorf("a version can't be uploaded to: %s", redactURLCredentials(u.String()))
+	}
+	enc, err := g.encryption(u)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	config, err := g.getAWSConfig(region, u, creds)
+	if err != nil {
+		return err
+	}
+	sess := g.newSession(config)
+	client := s3.New(sess)
+	req := &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(path),
+		Body:   f,
+	}
+	enc.putObject(req)
+	_, err = client.PutObjectWithContext(g.Context(), req)
+	return err
+}
+
+func (g *S3Getter) getObject(client *s3.S3, dst, bucket, key, version string, enc *s3Encryption) error {
 	req := &s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
@@ -153,11 +369,18 @@ func (g *S3Getter) getObject(client *s3.S3, dst, bucket, key, version string) er
 	if version != "" {
 		req.VersionId = aws.String(version)
 	}
+	enc.getObject(req)
 
-	resp, err := client.GetObject(req)
+	resp, err := client.GetObjectWithContext(g.Context(), req)
 	if err != nil {
 		return err
 	}
+	size := int64(-1)
+	if resp.ContentLength != nil {
+		size = *resp.ContentLength
+	}
+	body := g.trackProgress(key, 0, size, resp.Body)
+	defer body.Close()
 
 	// Create all the parent directories
 	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
@@ -170,11 +393,97 @@ func (g *S3Getter) getObject(client *s3.S3, dst, bucket, key, version string) er
 	}
 	defer f.Close()
 
-	_, err = io.Copy(f, resp.Body)
+	_, err = io.Copy(f, body)
 	return err
 }
 
-func (g *S3Getter) getAWSConfig(region string, url *url.URL, creds *credentials.Credentials) *aws.Config {
+// pickKey returns the key matching pattern that pick chooses, or pattern
+// itself if pick is empty.
+func (g *S3Getter) pickKey(client *s3.S3, bucket, pattern, pick string) (string, error) {
+	if pick == "" {
+		return pattern, nil
+	}
+	if pick != "latest-modified" && pick != "latest-version" {
+		return "", fmt.Errorf("unknown pick %q, expected latest-modified or latest-version", pick)
+	}
+
+	star := strings.Index(pattern, "*")
+	if star < 0 || strings.Count(pattern, "*") > 1 || strings.Contains(pattern[star:], "/") {
+		return "", fmt.Errorf("pick requires a single '*' in the last path segment of the key: %s", pattern)
+	}
+	prefix, suffix := pattern[:star], pattern[star+1:]
+
+	var key string
+	var modified time.Time
+	var latest *version.Version
+	lastMarker := ""
+	hasMore := true
+	for hasMore {
+		// Keys in "directories" below the prefix can't match, so they
+		// are left out of the listing
+		req := &s3.ListObjectsInput{
+			Bucket:    aws.String(bucket),
+			Prefix:    aws.String(prefix),
+			Delimiter: aws.String("/"),
+		}
+		if lastMarker != "" {
+			req.Marker = aws.String(lastMarker)
+		}
+
+		resp, err := client.ListObjectsWithContext(g.Context(), req)
+		if err != nil {
+			return "", err
+		}
+		hasMore = aws.BoolValue(resp.IsTruncated)
+		if p := aws.StringValue(resp.NextMarker); p > lastMarker {
+			lastMarker = p
+		}
+		for _, p := range resp.CommonPrefixes {
+			if p := aws.StringValue(p.Prefix); p > lastMarker {
+				lastMarker = p
+			}
+		}
+
+		for _, object := range resp.Contents {
+			k := aws.StringValue(object.Key)
+			if k > lastMarker {
+				lastMarker = k
+			}
+
+			match := strings.TrimPrefix(k, prefix)
+			if !strings.HasSuffix(match, suffix) {
+				continue
+			}
+			match = match[:len(match)-len(suffix)]
+			if strings.Contains(match, "/") {
+				continue
+			}
+
+			switch pick {
+			case "latest-modified":
+				if t := aws.TimeValue(object.LastModified); key == "" || t.After(modified) {
+					key, modified = k, t
+				}
+			case "latest-version":
+				v, err := version.NewVersion(match)
+				if err != nil {
+					continue
+				}
+				if latest == nil || v.GreaterThan(latest) {
+					key, latest = k, v
+				}
+			}
+		}
+	}
+
+	if key == "" {
+		return "", fmt.Errorf("no key matches %s", pattern)
+	}
+
+	return key, nil
+}
+
+func (g *S3Getter) getAWSConfig(region string, url *url.URL, creds *credentials.Credentials) (*aws.Config, error) {
 	conf := &aws.Config{}
 	if creds == nil {
 		// Grab the metadata URL
@@ -197,7 +506,6 @@ func (g *S3Getter) getAWSConfig(region string, url *url.URL, creds *credentials.
 
 	if creds != nil {
 		conf.Endpoint = &url.Host
-		conf.S3ForcePathStyle = aws.Bool(true)
 		if url.Scheme == "http" {
 			conf.DisableSSL = aws.Bool(true)
 		}
@@ -208,7 +516,126 @@ func (g *S3Getter) getAWSConfig(region string, url *url.URL, creds *credentials.
 		conf.Region = aws.String(region)
 	}
 
-	return conf
+	q := url.Query()
+	if endpoint := q.Get("endpoint"); endpoint != "" {
+		conf.Endpoint = aws.String(endpoint)
+	} else if g.Endpoint != "" {
+		conf.Endpoint = aws.String(g.Endpoint)
+	}
+
+	pathStyle := !g.DisablePathStyle
+	if v := q.Get("path_style"); v != "" {
+		var err error
+		pathStyle, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path_style: %s", v)
+		}
+	}
+	conf.S3ForcePathStyle = aws.Bool(pathStyle)
+
+	roleARN, externalID := g.RoleARN, g.ExternalID
+	if v := q.Get("role_arn"); v != "" {
+		roleARN, externalID = v, q.Get("external_id")
+	}
+	if creds == credentials.AnonymousCredentials {
+		// There are no credentials to assume a role with
+		if q.Get("role_arn") != "" {
+			return nil, fmt.Errorf("role_arn can't be given with anonymous")
+		}
+		roleARN = ""
+	}
+	if roleARN == "" {
+		if q.Get("external_id") != "" {
+			return nil, fmt.Errorf("external_id can only be given with role_arn")
+		}
+		return conf, nil
+	}
+
+	// The role is assumed with STS, which isn't at the S3 endpoint
+	sts := session.New(&aws.Config{Credentials: creds, Region: conf.Region})
+	conf.Credentials = stscreds.NewCredentials(sts, roleARN, func(p *stscreds.AssumeRoleProvider) {
+		if externalID != "" {
+			p.ExternalID = aws.String(externalID)
+		}
+	})
+
+	return conf, nil
+}
+
+// newSession returns a session with the given config that sends the
+// client's headers with each request. The client's User-Agent is added to
+// the SDK's own rather than replacing it.
+//
+// If the client has a RetryPolicy it replaces the SDK's own retries, and if
+// it has Addresses the session connects only to the addresses they allow.
+// The getter's Timeout applies to each request.
+func (g *S3Getter) newSession(config *aws.Config) *session.Session {
+	if g.client != nil && g.client.RetryPolicy != nil {
+		config = request.WithRetryer(config, &s3Retryer{policy: g.client.RetryPolicy, budget: g.client.retryBudget})
+		config.EnforceShouldRetryCheck = aws.Bool(true)
+	}
+	if (g.client != nil && g.client.Addresses != nil) || g.Timeout > 0 {
+		client := config.HTTPClient
+		if client == nil {
+			client = httpClient
+		}
+		client = g.addressClient(client)
+		if g.Timeout > 0 {
+			client = timeoutClient(client, g.Timeout)
+		}
+		config.HTTPClient = client
+	}
+
+	sess := session.New(config)
+	header := g.header()
+	sess.Handlers.Build.PushBack(func(r *request.Request) {
+		for k, vs := range header {
+			if k == "User-Agent" {
+				request.AddToUserAgent(r, header.Get(k))
+				continue
+			}
+			r.HTTPRequest.Header[k] = vs
+		}
+	})
+
+	return sess
+}
+
+// s3Retryer is a request.Retryer that retries S3 requests as a
+// RetryPolicy says, within the budget of the download.
+type s3Retryer struct {
+	policy *RetryPolicy
+	budget *retryBudget
+}
+
+func (r *s3Retryer) MaxRetries() int {
+	return r.policy.maxAttempts() - 1
+}
+
+// ShouldRetry works out how long to wait before the retry too, so that it
+// can be taken from the budget, and leaves it in the request's RetryDelay
+// for RetryRules. The SDK asks even once the attempts have run out.
+func (r *s3Retryer) ShouldRetry(req *request.Request) bool {
+	if req.Context().Err() != nil || req.RetryCount >= r.MaxRetries() {
+		return false
+	}
+
+	// The SDK makes up a response with no status for requests that
+	// didn't get one
+	resp := req.HTTPResponse
+	if resp != nil && resp.StatusCode == 0 {
+		resp = nil
+	}
+	if !r.policy.retryable(resp, req.Error) {
+		return false
+	}
+
+	req.RetryDelay = r.policy.delay(req.RetryCount+1, resp)
+	return r.budget.take(req.RetryDelay)
+}
+
+func (r *s3Retryer) RetryRules(req *request.Request) time.Duration {
+	return req.RetryDelay
 }
 
 func (g *S3Getter) parseUrl(u *url.URL) (region, bucket, path, version string, creds *credentials.Credentials, err error) {
@@ -238,7 +665,6 @@ func (g *S3Getter) parseUrl(u *url.URL) (region, bucket, path, version string, c
 
 		bucket = pathParts[1]
 		path = pathParts[2]
-		version = u.Query().Get("version")
 
 	} else {
 		pathParts := strings.SplitN(u.Path, "/", 3)
@@ -248,13 +674,18 @@ func (g *S3Getter) parseUrl(u *url.URL) (region, bucket, path, version string, c
 		}
 		bucket = pathParts[1]
 		path = pathParts[2]
-		version = u.Query().Get("version")
 		region = u.Query().Get("region")
 		if region == "" {
 			region = "us-east-1"
 		}
 	}
 
+	// version_id is the name of the parameter in S3's own API
+	version = u.Query().Get("version_id")
+	if version == "" {
+		version = u.Query().Get("version")
+	}
+
 	_, hasAwsId := u.Query()["aws_access_key_id"]
 	_, hasAwsSecret := u.Query()["aws_access_key_secret"]
 	_, hasAwsToken := u.Query()["aws_access_token"]
@@ -266,5 +697,22 @@ func (g *S3Getter) parseUrl(u *url.URL) (region, bucket, path, version string, c
 		)
 	}
 
+	// Credentials in the URL take priority over the getter's Anonymous,
+	// but not over the URL's own anonymous.
+	anonymous := g.Anonymous && creds == nil
+	if v := u.Query().Get("anonymous"); v != "" {
+		if anonymous, err = strconv.ParseBool(v); err != nil {
+			err = fmt.Errorf("invalid anonymous value: %s", v)
+			return
+		}
+		if anonymous && creds != nil {
+			err = fmt.Errorf("anonymous can't be given with AWS credentials")
+			return
+		}
+	}
+	if anonymous {
+		creds = credentials.AnonymousCredentials
+	}
+
 	return
 }
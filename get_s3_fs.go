@@ -0,0 +1,261 @@
+package getter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Open implements FSGetter. Listing a directory lists the objects with
+// its prefix, and objects are only downloaded once they are read.
+func (g *S3Getter) Open(u *url.URL) (fs.FS, error) {
+	region, bucket, prefix, version, creds, err := g.parseUrl(u)
+	if err != nil {
+		return nil, err
+	}
+	if version != "" {
+		return nil, fmt.Errorf("a version can only be downloaded as a file")
+	}
+	enc, err := g.encryption(u)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := g.getAWSConfig(region, u, creds)
+	if err != nil {
+		return nil, err
+	}
+	sess := g.newSession(config)
+
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	return &s3FS{
+		ctx:    g.Context(),
+		client: s3.New(sess),
+		bucket: bucket,
+		prefix: prefix,
+		enc:    enc,
+	}, nil
+}
+
+// s3FS is a read-only file system of the objects in an S3 bucket whose
+// keys start with prefix, which is empty or ends in a slash. Slashes in
+// keys separate directories.
+type s3FS struct {
+	ctx    context.Context
+	client *s3.S3
+	bucket string
+	prefix string
+	enc    *s3Encryption
+}
+
+func (f *s3FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return &s3Dir{fs: f, info: &s3FileInfo{name: ".", dir: true}, key: f.prefix}, nil
+	}
+
+	key := f.prefix + name
+	req := &s3.HeadObjectInput{
+		Bucket: aws.String(f.bucket),
+		Key:    aws.String(key),
+	}
+	f.enc.headObject(req)
+	head, err := f.client.HeadObjectWithContext(f.ctx, req)
+	if err == nil {
+		return &s3File{fs: f, key: key, info: &s3FileInfo{
+			name:    path.Base(name),
+			size:    aws.Int64Value(head.ContentLength),
+			modTime: aws.TimeValue(head.LastModified),
+		}}, nil
+	}
+	if reqErr, ok := err.(awserr.RequestFailure); !ok || reqErr.StatusCode() != 404 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	// There's no such object, but it is a directory if there are
+	// objects under it.
+	resp, err := f.client.ListObjectsWithContext(f.ctx, &s3.ListObjectsInput{
+		Bucket:  aws.String(f.bucket),
+		Prefix:  aws.String(key + "/"),
+		MaxKeys: aws.Int64(1),
+	})
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if len(resp.Contents) == 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return &s3Dir{fs: f, info: &s3FileInfo{name: path.Base(name), dir: true}, key: key + "/"}, nil
+}
+
+// s3File is an object opened from an s3FS. The object is downloaded when
+// it is first read.
+type s3File struct {
+	fs   *s3FS
+	key  string
+	info *s3FileInfo
+	body io.ReadCloser
+}
+
+func (f *s3File) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *s3File) Read(p []byte) (int, error) {
+	if f.body == nil {
+		req := &s3.GetObjectInput{
+			Bucket: aws.String(f.fs.bucket),
+			Key:    aws.String(f.key),
+		}
+		f.fs.enc.getObject(req)
+		resp, err := f.fs.client.GetObjectWithContext(f.fs.ctx, req)
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.key, Err: err}
+		}
+		f.body = resp.Body
+	}
+
+	return f.body.Read(p)
+}
+
+func (f *s3File) Close() error {
+	if f.body == nil {
+		return nil
+	}
+
+	return f.body.Close()
+}
+
+// s3Dir is a directory opened from an s3FS. key is the prefix of the
+// objects in it. Its entries are listed a page at a time, as they are read,
+// starting after marker.
+type s3Dir struct {
+	fs      *s3FS
+	info    *s3FileInfo
+	key     string
+	entries []fs.DirEntry
+	marker  string
+	listed  bool
+}
+
+func (d *s3Dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+func (d *s3Dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.key, Err: fs.ErrInvalid}
+}
+
+func (d *s3Dir) Close() error { return nil }
+
+func (d *s3Dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	for !d.listed && (n <= 0 || len(d.entries) < n) {
+		if err := d.list(); err != nil {
+			return nil, err
+		}
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// list lists the next page of the objects and common prefixes directly
+// under the key, so that a large directory that is read a few entries at
+// a time isn't held in memory all at once.
+func (d *s3Dir) list() error {
+	req := &s3.ListObjectsInput{
+		Bucket:    aws.String(d.fs.bucket),
+		Prefix:    aws.String(d.key),
+		Delimiter: aws.String("/"),
+	}
+	if d.marker != "" {
+		req.Marker = aws.String(d.marker)
+	}
+
+	resp, err := d.fs.client.ListObjectsWithContext(d.fs.ctx, req)
+	if err != nil {
+		return &fs.PathError{Op: "readdir", Path: d.key, Err: err}
+	}
+	d.listed = !aws.BoolValue(resp.IsTruncated)
+	if marker := aws.StringValue(resp.NextMarker); marker > d.marker {
+		d.marker = marker
+	}
+
+	for _, p := range resp.CommonPrefixes {
+		prefix := aws.StringValue(p.Prefix)
+		if prefix > d.marker {
+			d.marker = prefix
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(prefix, d.key), "/")
+		d.entries = append(d.entries, &s3FileInfo{name: name, dir: true})
+	}
+	for _, o := range resp.Contents {
+		key := aws.StringValue(o.Key)
+		if key > d.marker {
+			d.marker = key
+		}
+
+		// Keys ending in a slash are directory markers
+		if strings.HasSuffix(key, "/") {
+			continue
+		}
+
+		d.entries = append(d.entries, &s3FileInfo{
+			name:    strings.TrimPrefix(key, d.key),
+			size:    aws.Int64Value(o.Size),
+			modTime: aws.TimeValue(o.LastModified),
+		})
+	}
+
+	return nil
+}
+
+// s3FileInfo describes the files and directories of an s3FS.
+type s3FileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i *s3FileInfo) Name() string               { return i.name }
+func (i *s3FileInfo) Size() int64                { return i.size }
+func (i *s3FileInfo) ModTime() time.Time         { return i.modTime }
+func (i *s3FileInfo) IsDir() bool                { return i.dir }
+func (i *s3FileInfo) Sys() interface{}           { return nil }
+func (i *s3FileInfo) Type() fs.FileMode          { return i.Mode().Type() }
+func (i *s3FileInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+func (i *s3FileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0555
+	}
+
+	return 0444
+}
@@ -0,0 +1,144 @@
+package getter
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestS3Getter_Open(t *testing.T) {
+	server := httptest.NewServer(&testS3Server{
+		Bucket: "bucket",
+		Objects: map[string]string{
+			"prefix/main.tf":           "main",
+			"prefix/subdir/":           "",
+			"prefix/subdir/sub.tf":     "sub",
+			"prefix/subdir/deep/a.txt": "a",
+			"other/main.tf":            "other",
+		},
+	})
+	defer server.Close()
+
+	g := new(S3Getter)
+	u, err := url.Parse(server.URL + "/bucket/prefix?aws_access_key_id=a&aws_access_key_secret=b")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	fsys, err := g.Open(u)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := fstest.TestFS(fsys, "main.tf", "subdir/sub.tf", "subdir/deep/a.txt"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	data, err := fs.ReadFile(fsys, "subdir/sub.tf")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != "sub" {
+		t.Fatalf("bad: %q", data)
+	}
+
+	if _, err := fsys.Open("missing"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+// testS3Server is a minimal implementation of the S3 API for path style
+// requests to a single bucket.
+type testS3Server struct {
+	Bucket  string
+	Objects map[string]string
+
+	// ModTimes are the modification times of objects, which otherwise
+	// are all the same.
+	ModTimes map[string]time.Time
+}
+
+func (s *testS3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if path == s.Bucket || path == s.Bucket+"/" {
+		s.list(w, r)
+		return
+	}
+
+	key := strings.TrimPrefix(path, s.Bucket+"/")
+	body, ok := s.Objects[key]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		if r.Method != "HEAD" {
+			fmt.Fprint(w, "<Error><Code>NoSuchKey</Code></Error>")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+	w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+	if r.Method != "HEAD" {
+		fmt.Fprint(w, body)
+	}
+}
+
+func (s *testS3Server) list(w http.ResponseWriter, r *http.Request) {
+	type content struct {
+		Key          string
+		Size         int
+		LastModified string
+	}
+	type commonPrefix struct {
+		Prefix string
+	}
+	var result struct {
+		XMLName        xml.Name `xml:"ListBucketResult"`
+		Name           string
+		IsTruncated    bool
+		Contents       []content
+		CommonPrefixes []commonPrefix
+	}
+	result.Name = s.Bucket
+
+	prefix := r.URL.Query().Get("prefix")
+	delimiter := r.URL.Query().Get("delimiter")
+	var keys []string
+	for k := range s.Objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	seen := make(map[string]bool)
+	for _, k := range keys {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+
+		rest := k[len(prefix):]
+		if idx := strings.Index(rest, delimiter); delimiter != "" && idx >= 0 {
+			p := prefix + rest[:idx+1]
+			if !seen[p] {
+				seen[p] = true
+				result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{p})
+			}
+			continue
+		}
+
+		modTime := "2006-01-02T15:04:05.000Z"
+		if t, ok := s.ModTimes[k]; ok {
+			modTime = t.UTC().Format("2006-01-02T15:04:05.000Z")
+		}
+		result.Contents = append(result.Contents, content{k, len(s.Objects[k]), modTime})
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(&result)
+}
@@ -0,0 +1,78 @@
+package getter
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// s3Encryption is how the objects of an S3 URL are encrypted on the
+// server: with the customer's own key, which is needed to read them too,
+// or with a KMS key, which S3 uses itself when they are read.
+type s3Encryption struct {
+	customerKey string
+	kmsKeyID    string
+}
+
+// encryption returns the encryption of the objects of u, from its
+// sse_customer_key and sse_kms_key_id query parameters or the getter's
+// SSECustomerKey and SSEKMSKeyID, or nil if they aren't encrypted with
+// keys of the customer's.
+func (g *S3Getter) encryption(u *url.URL) (*s3Encryption, error) {
+	q := u.Query()
+	key := g.SSECustomerKey
+	if v := q.Get("sse_customer_key"); v != "" {
+		var err error
+		if key, err = base64.StdEncoding.DecodeString(v); err != nil {
+			return nil, fmt.Errorf("invalid sse_customer_key: %s", err)
+		}
+	}
+	if len(key) > 0 && len(key) != 32 {
+		return nil, fmt.Errorf("the SSE-C key must be 256 bits, got %d", len(key)*8)
+	}
+	kmsKeyID := g.SSEKMSKeyID
+	if v := q.Get("sse_kms_key_id"); v != "" {
+		kmsKeyID = v
+	}
+
+	if len(key) == 0 && kmsKeyID == "" {
+		return nil, nil
+	}
+	if len(key) > 0 && kmsKeyID != "" {
+		return nil, fmt.Errorf("an object can't be encrypted with both an SSE-C key and a KMS key")
+	}
+	return &s3Encryption{customerKey: string(key), kmsKeyID: kmsKeyID}, nil
+}
+
+// getObject sets the key of an object encrypted with the customer's key
+// on req, which the SDK only sends over HTTPS.
+func (e *s3Encryption) getObject(req *s3.GetObjectInput) {
+	if e != nil && e.customerKey != "" {
+		req.SSECustomerAlgorithm = aws.String(s3.ServerSideEncryptionAes256)
+		req.SSECustomerKey = aws.String(e.customerKey)
+	}
+}
+
+// headObject is getObject for HEAD requests.
+func (e *s3Encryption) headObject(req *s3.HeadObjectInput) {
+	if e != nil && e.customerKey != "" {
+		req.SSECustomerAlgorithm = aws.String(s3.ServerSideEncryptionAes256)
+		req.SSECustomerKey = aws.String(e.customerKey)
+	}
+}
+
+// putObject sets how the object uploaded by req is encrypted.
+func (e *s3Encryption) putObject(req *s3.PutObjectInput) {
+	switch {
+	case e == nil:
+	case e.customerKey != "":
+		req.SSECustomerAlgorithm = aws.String(s3.ServerSideEncryptionAes256)
+		req.SSECustomerKey = aws.String(e.customerKey)
+	case e.kmsKeyID != "":
+		req.ServerSideEncryption = aws.String(s3.ServerSideEncryptionAwsKms)
+		req.SSEKMSKeyId = aws.String(e.kmsKeyID)
+	}
+}
@@ -1,12 +1,21 @@
 package getter
 
 import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 )
 
 func init() {
@@ -202,6 +211,14 @@ func TestS3Getter_Url(t *testing.T) {
 			path:    "hello.txt",
 			version: "1",
 		},
+		{
+			name:    "version_id",
+			url:     "s3::https://s3.amazonaws.com/bucket/foo?version_id=abc&version=1",
+			region:  "us-east-1",
+			bucket:  "bucket",
+			path:    "foo",
+			version: "abc",
+		},
 		{
 			name:    "localhost-3",
 			url:     "s3::http://127.0.0.1:9000/test-bucket/hello.txt?aws_access_key_id=TESTID&aws_access_key_secret=TestSecret",
@@ -248,3 +265,381 @@ func TestS3Getter_Url(t *testing.T) {
 		})
 	}
 }
+
+func TestS3Getter_userAgent(t *testing.T) {
+	var userAgent, requestID string
+	backend := &testS3Server{
+		Bucket:  "bucket",
+		Objects: map[string]string{"main.tf": "main"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.UserAgent()
+		requestID = r.Header.Get("X-Request-Id")
+		backend.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	g := new(S3Getter)
+	g.client = &Client{
+		UserAgent: "test/1.0",
+		Header:    http.Header{"X-Request-Id": []string{"abc"}},
+	}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	u, err := url.Parse(server.URL + "/bucket/main.tf?aws_access_key_id=a&aws_access_key_secret=b")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := g.GetFile(filepath.Join(dst, "main.tf"), u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The SDK's own User-Agent is kept
+	if !strings.HasPrefix(userAgent, "aws-sdk-go/") || !strings.HasSuffix(userAgent, " test/1.0") {
+		t.Fatalf("bad User-Agent: %q", userAgent)
+	}
+	if requestID != "abc" {
+		t.Fatalf("bad X-Request-Id: %q", requestID)
+	}
+}
+
+func TestS3Getter_endpoint(t *testing.T) {
+	server := httptest.NewServer(&testS3Server{
+		Bucket:  "bucket",
+		Objects: map[string]string{"main.tf": "main"},
+	})
+	defer server.Close()
+
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	// The host in the URL isn't used
+	g := new(S3Getter)
+	u, err := url.Parse("https://s3.example.invalid/bucket/main.tf?aws_access_key_id=a&aws_access_key_secret=b&endpoint=" + url.QueryEscape(server.URL))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := g.GetFile(filepath.Join(dst, "a"), u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "a"), "main")
+
+	// And neither is it with the getter's endpoint
+	g = &S3Getter{Endpoint: server.URL}
+	u, err = url.Parse("https://s3.example.invalid/bucket/main.tf?aws_access_key_id=a&aws_access_key_secret=b")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := g.GetFile(filepath.Join(dst, "b"), u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "b"), "main")
+}
+
+func TestS3Getter_getAWSConfig(t *testing.T) {
+	cases := []struct {
+		Name      string
+		Getter    *S3Getter
+		Query     string
+		Endpoint  string
+		PathStyle bool
+		Role      bool
+		Err       string
+	}{
+		{"default", new(S3Getter), "", "minio:9000", true, false, ""},
+		{"endpoint", new(S3Getter), "endpoint=http://ceph:7480", "http://ceph:7480", true, false, ""},
+		{"getter endpoint", &S3Getter{Endpoint: "ceph:7480"}, "", "ceph:7480", true, false, ""},
+		{"endpoint priority", &S3Getter{Endpoint: "ceph:7480"}, "endpoint=other:7480", "other:7480", true, false, ""},
+		{"virtual hosted", new(S3Getter), "path_style=false", "minio:9000", false, false, ""},
+		{"getter virtual hosted", &S3Getter{DisablePathStyle: true}, "", "minio:9000", false, false, ""},
+		{"path style priority", &S3Getter{DisablePathStyle: true}, "path_style=true", "minio:9000", true, false, ""},
+		{"bad path style", new(S3Getter), "path_style=maybe", "", false, false, "path_style"},
+		{"role", new(S3Getter), "role_arn=arn:aws:iam::123456789012:role/r&external_id=x", "minio:9000", true, true, ""},
+		{"getter role", &S3Getter{RoleARN: "arn:aws:iam::123456789012:role/r"}, "", "minio:9000", true, true, ""},
+		{"external id without role", new(S3Getter), "external_id=x", "", false, false, "role_arn"},
+	}
+
+	for _, tc := range cases {
+		u, err := url.Parse("http://minio:9000/bucket/key?aws_access_key_id=a&aws_access_key_secret=b&" + tc.Query)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		region, _, _, _, creds, err := tc.Getter.parseUrl(u)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Name, err)
+		}
+
+		conf, err := tc.Getter.getAWSConfig(region, u, creds)
+		if tc.Err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.Err) {
+				t.Fatalf("%s: bad: %v", tc.Name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Name, err)
+		}
+		if *conf.Endpoint != tc.Endpoint {
+			t.Fatalf("%s: bad endpoint: %s", tc.Name, *conf.Endpoint)
+		}
+		if *conf.S3ForcePathStyle != tc.PathStyle {
+			t.Fatalf("%s: bad path style: %v", tc.Name, *conf.S3ForcePathStyle)
+		}
+		if role := conf.Credentials != creds; role != tc.Role {
+			t.Fatalf("%s: bad role: %v", tc.Name, role)
+		}
+	}
+}
+
+func TestS3Getter_anonymous(t *testing.T) {
+	var authorization []string
+	backend := &testS3Server{
+		Bucket:  "bucket",
+		Objects: map[string]string{"main.tf": "main"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = append(authorization, r.Header.Get("Authorization"))
+		backend.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	// Requests aren't signed, and no credentials are looked for
+	defer tempEnv(t, "AWS_METADATA_URL", "http://127.0.0.1:1/latest")()
+	u, err := url.Parse(server.URL + "/bucket/main.tf?anonymous=true")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := new(S3Getter).GetFile(filepath.Join(dst, "main.tf"), u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "main.tf"), "main")
+	for _, v := range authorization {
+		if v != "" {
+			t.Fatalf("should be unsigned: %s", v)
+		}
+	}
+
+	cases := []struct {
+		Name      string
+		Getter    *S3Getter
+		Query     string
+		Anonymous bool
+		Err       string
+	}{
+		{"query", new(S3Getter), "anonymous=true", true, ""},
+		{"getter", &S3Getter{Anonymous: true}, "", true, ""},
+		{"query priority", &S3Getter{Anonymous: true}, "anonymous=false", false, ""},
+		{"credentials priority", &S3Getter{Anonymous: true}, "aws_access_key_id=a&aws_access_key_secret=b", false, ""},
+		{"credentials", new(S3Getter), "anonymous=true&aws_access_key_id=a&aws_access_key_secret=b", false, "credentials"},
+		{"bad", new(S3Getter), "anonymous=maybe", false, "anonymous"},
+		{"getter role", &S3Getter{Anonymous: true, RoleARN: "arn:aws:iam::123456789012:role/r"}, "", true, ""},
+		{"role", new(S3Getter), "anonymous=true&role_arn=arn:aws:iam::123456789012:role/r", false, "role_arn"},
+	}
+	for _, tc := range cases {
+		u, err := url.Parse("http://minio:9000/bucket/key?" + tc.Query)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		region, _, _, _, creds, err := tc.Getter.parseUrl(u)
+		var conf *aws.Config
+		if err == nil {
+			conf, err = tc.Getter.getAWSConfig(region, u, creds)
+		}
+		if tc.Err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.Err) {
+				t.Fatalf("%s: bad: %v", tc.Name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Name, err)
+		}
+		if anonymous := conf.Credentials == credentials.AnonymousCredentials; anonymous != tc.Anonymous {
+			t.Fatalf("%s: bad anonymous: %v", tc.Name, anonymous)
+		}
+	}
+}
+
+func TestS3Getter_pick(t *testing.T) {
+	modTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(&testS3Server{
+		Bucket: "bucket",
+		Objects: map[string]string{
+			"builds/app-1.2.0.tar.gz":   "1.2.0",
+			"builds/app-1.10.0.tar.gz":  "1.10.0",
+			"builds/app-1.9.0.tar.gz":   "1.9.0",
+			"builds/app-latest.tar.gz":  "latest",
+			"builds/app-2.0.0.zip":      "zip",
+			"builds/app-3.0.0/x.tar.gz": "nested",
+		},
+		ModTimes: map[string]time.Time{
+			"builds/app-1.2.0.tar.gz":  modTime.Add(3 * time.Hour),
+			"builds/app-1.10.0.tar.gz": modTime.Add(time.Hour),
+			"builds/app-1.9.0.tar.gz":  modTime.Add(2 * time.Hour),
+			"builds/app-latest.tar.gz": modTime,
+		},
+	})
+	defer server.Close()
+
+	g := new(S3Getter)
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	cases := []struct {
+		Pattern, Pick string
+		Expected      string
+		Err           bool
+	}{
+		{"builds/app-*.tar.gz", "latest-modified", "1.2.0", false},
+		{"builds/app-*.tar.gz", "latest-version", "1.10.0", false},
+		{"builds/app-*.tgz", "latest-modified", "", true},
+		{"builds/*/x.tar.gz", "latest-modified", "", true},
+		{"builds/app-*.tar.gz", "newest", "", true},
+	}
+	for _, tc := range cases {
+		u, err := url.Parse(server.URL + "/bucket/" + tc.Pattern + "?aws_access_key_id=a&aws_access_key_secret=b&pick=" + tc.Pick)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		mode, err := g.ClientMode(u)
+		if err != nil || mode != ClientModeFile {
+			t.Fatalf("%s %s: bad mode: %d %v", tc.Pattern, tc.Pick, mode, err)
+		}
+
+		path := filepath.Join(dst, "file")
+		err = g.GetFile(path, u)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s %s: err: %v", tc.Pattern, tc.Pick, err)
+		}
+		if !tc.Err {
+			assertContents(t, path, tc.Expected)
+		}
+	}
+}
+
+func TestS3Getter_version(t *testing.T) {
+	backend := &testS3Server{
+		Bucket:  "bucket",
+		Objects: map[string]string{"main.tf": "new"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("versionId") == "v1" {
+			w.Write([]byte("old"))
+			return
+		}
+		backend.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	g := new(S3Getter)
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	u, err := url.Parse(server.URL + "/bucket/main.tf?aws_access_key_id=a&aws_access_key_secret=b&version_id=v1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	mode, err := g.ClientMode(u)
+	if err != nil || mode != ClientModeFile {
+		t.Fatalf("bad mode: %d %v", mode, err)
+	}
+	if err := g.GetFile(filepath.Join(dst, "main.tf"), u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "main.tf"), "old")
+
+	// A directory has no version
+	if err := g.Get(filepath.Join(dst, "dir"), u); err == nil || !strings.Contains(err.Error(), "version") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestS3Getter_encryption(t *testing.T) {
+	key := bytes.Repeat([]byte("k"), 32)
+	var putHeader http.Header
+	backend := &testS3Server{
+		Bucket:  "bucket",
+		Objects: map[string]string{"main.tf": "main"},
+	}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			putHeader = r.Header
+			ioutil.ReadAll(r.Body)
+			return
+		}
+		if r.Header.Get("X-Amz-Server-Side-Encryption-Customer-Key") != base64.StdEncoding.EncodeToString(key) ||
+			r.Header.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != "AES256" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	// The SDK uses the default client, which has to trust the server
+	transport := http.DefaultClient.Transport
+	http.DefaultClient.Transport = server.Client().Transport
+	defer func() {
+		http.DefaultClient.Transport = transport
+	}()
+
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	src := server.URL + "/bucket/main.tf?aws_access_key_id=a&aws_access_key_secret=b"
+	u, err := url.Parse(src + "&sse_customer_key=" + url.QueryEscape(base64.StdEncoding.EncodeToString(key)))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := new(S3Getter).GetFile(filepath.Join(dst, "query"), u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "query"), "main")
+
+	u, err = url.Parse(src)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := (&S3Getter{SSECustomerKey: key}).GetFile(filepath.Join(dst, "getter"), u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "getter"), "main")
+	if err := new(S3Getter).GetFile(filepath.Join(dst, "none"), u); err == nil {
+		t.Fatal("should error without the key")
+	}
+
+	// Uploads are encrypted with the KMS key
+	f := filepath.Join(dst, "getter")
+	if err := (&S3Getter{SSEKMSKeyID: "alias/artifacts"}).PutFile(u, f); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if putHeader.Get("X-Amz-Server-Side-Encryption") != "aws:kms" ||
+		putHeader.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id") != "alias/artifacts" {
+		t.Fatalf("bad: %#v", putHeader)
+	}
+
+	cases := []struct {
+		Name   string
+		Getter *S3Getter
+		Query  string
+		Err    string
+	}{
+		{"bad base64", new(S3Getter), "sse_customer_key=%25", "sse_customer_key"},
+		{"short", new(S3Getter), "sse_customer_key=YWJj", "256 bits"},
+		{"both", &S3Getter{SSECustomerKey: key}, "sse_kms_key_id=alias/a", "both"},
+	}
+	for _, tc := range cases {
+		u, err := url.Parse("https://s3.amazonaws.com/bucket/key?" + tc.Query)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if _, err := tc.Getter.encryption(u); err == nil || !strings.Contains(err.Error(), tc.Err) {
+			t.Fatalf("%s: bad: %v", tc.Name, err)
+		}
+	}
+}
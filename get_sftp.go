@@ -0,0 +1,251 @@
+package getter
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// SftpGetter is a Getter implementation that will download a file or a
+// directory over SFTP from a URL such as sftp://user@host/path, using the
+// OpenSSH sftp command. The path is absolute unless it starts with "/~/",
+// in which case it is relative to the user's home directory.
+//
+// sftp authenticates with the SSH agent and the keys in the user's SSH
+// configuration as usual, and with the key given by the "sshkey" query
+// parameter, which is a base64 encoded private key as for the GitGetter.
+// There is nobody to enter a password, so passwords aren't supported.
+// Host keys are always verified, and hosts that aren't known are
+// rejected.
+type SftpGetter struct {
+	getter
+
+	// KeyFile, if set, is the path of a private key to authenticate with,
+	// in addition to the agent's and the user's own.
+	KeyFile string
+
+	// KnownHostsFile, if set, is the known_hosts file that host keys are
+	// verified against instead of the user's.
+	KnownHostsFile string
+
+	// HostKeys, if set, pins the host keys of hosts in the same way as
+	// GitGetter.HostKeys. Pinned hosts are only verified against their
+	// pinned keys, and KnownHostsFile isn't used for them.
+	HostKeys map[string][]string
+
+	// DisableAgent, if true, stops the SSH agent from being used to
+	// authenticate.
+	DisableAgent bool
+
+	// Netrc, if true, looks up the user to log in as in the user's netrc
+	// file if the URL doesn't have one. Passwords aren't supported, so the
+	// netrc file's password for the host is ignored.
+	Netrc bool
+}
+
+func (g *SftpGetter) withClient(c *Client) Getter {
+	cp := *g
+	cp.client = c
+	return &cp
+}
+
+func (g *SftpGetter) Describe() Description {
+	return Description{
+		Summary: "A file or directory over SFTP, with the sftp command.",
+		Options: []Option{
+			{"sshkey", "A base64 encoded SSH private key to authenticate with."},
+		},
+		Strict: true,
+	}
+}
+
+func (g *SftpGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	// Changing into the path only works if it is a directory. If it fails
+	// for any other reason the download will fail with the proper error.
+	if err := g.run(u, fmt.Sprintf("cd %s\n", sftpQuote(sftpPath(u)))); err != nil {
+		return ClientModeFile, nil
+	}
+
+	return ClientModeDir, nil
+}
+
+func (g *SftpGetter) Get(dst string, u *url.URL) error {
+	if err := g.stopped(); err != nil {
+		return err
+	}
+
+	// Remove destination if it already exists, since sftp would copy the
+	// directory into it rather than replace it
+	_, err := os.Stat(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	return g.run(u, fmt.Sprintf("get -pr %s %s\n", sftpQuote(sftpPath(u)), sftpQuote(dst)))
+}
+
+func (g *SftpGetter) GetFile(dst string, u *url.URL) error {
+	if err := g.stopped(); err != nil {
+		return err
+	}
+
+	// Create all the parent directories
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	return g.run(u, fmt.Sprintf("get -p %s %s\n", sftpQuote(sftpPath(u)), sftpQuote(dst)))
+}
+
+// PutFile implements Putter by uploading the file at src to the path in u,
+// whose directory must already exist.
+func (g *SftpGetter) PutFile(u *url.URL, src string) error {
+	if err := g.stopped(); err != nil {
+		return err
+	}
+
+	return g.run(u, fmt.Sprintf("put -p %s %s\n", sftpQuote(src), sftpQuote(sftpPath(u))))
+}
+
+// run runs the sftp batch commands against the host in u.
+func (g *SftpGetter) run(u *url.URL, batch string) error {
+	if _, err := exec.LookPath("sftp"); err != nil {
+		return fmt.Errorf("sftp must be available and on the PATH")
+	}
+
+	var sshKeyFile string
+	if sshKey := u.Query().Get("sshkey"); sshKey != "" {
+		// Create a temp file for the key and ensure it is removed.
+		var err error
+		sshKeyFile, err = writeSSHKey("", sshKey)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(sshKeyFile)
+	}
+
+	knownHostsFile, err := writeSSHKnownHosts("", g.HostKeys, u)
+	if err != nil {
+		return err
+	}
+	if knownHostsFile != "" {
+		defer os.Remove(knownHostsFile)
+	}
+
+	args, err := g.args(u, sshKeyFile, knownHostsFile)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(g.Context(), "sftp", args...)
+	cmd.Stdin = strings.NewReader(batch)
+	err = getRunCommand(cmd)
+	if err != nil && g.Context().Err() != nil {
+		return fmt.Errorf("sftp cancelled: %s", g.Context().Err())
+	}
+
+	return err
+}
+
+// args returns the arguments to run sftp with to read batch commands from
+// stdin for the host in u. knownHostsFile, if set, holds the keys pinned
+// for the host.
+func (g *SftpGetter) args(u *url.URL, sshKeyFile, knownHostsFile string) ([]string, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("sftp URL has no host: %s", redactURLCredentials(u.String()))
+	}
+	if _, ok := u.User.Password(); ok {
+		return nil, fmt.Errorf("sftp passwords aren't supported, use a key instead")
+	}
+
+	// Never prompt, there is nobody there to answer and sftp would hang
+	// forever, and never trust unknown hosts.
+	args := []string{
+		"-b", "-",
+		"-o", "BatchMode=yes",
+		"-o", "StrictHostKeyChecking=yes",
+	}
+	if port := u.Port(); port != "" {
+		args = append(args, "-P", port)
+	}
+	for _, keyFile := range []string{g.KeyFile, sshKeyFile} {
+		if keyFile != "" {
+			args = append(args, "-i", keyFile)
+		}
+	}
+	if knownHostsFile != "" {
+		args = append(args, sshKnownHostsArgs(knownHostsFile)...)
+	} else if g.KnownHostsFile != "" {
+		args = append(args, "-o", "UserKnownHostsFile="+g.KnownHostsFile)
+	}
+	if g.DisableAgent {
+		args = append(args, "-o", "IdentityAgent=none")
+	}
+
+	// With Addresses, sftp connects to the address that was checked, and
+	// looks up the host key of the host it was given
+	addr, err := g.pinnedAddress(u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	if addr != "" {
+		alias := u.Hostname()
+		if port := u.Port(); port != "" && port != "22" {
+			alias = "[" + alias + "]:" + port
+		}
+		args = append(args, "-o", "HostName="+addr, "-o", "HostKeyAlias="+alias)
+	}
+
+	host := u.Hostname()
+	if strings.Contains(host, ":") {
+		// An IPv6 address
+		host = "[" + host + "]"
+	}
+	user := u.User.Username()
+	if user == "" && g.Netrc {
+		machine, err := netrcMachine(u.Host)
+		if err != nil {
+			return nil, err
+		}
+		if machine != nil {
+			user = machine.Login
+		}
+	}
+	if user != "" {
+		host = user + "@" + host
+	}
+
+	return append(args, "--", host), nil
+}
+
+// sftpPath returns the remote path in u.
+func sftpPath(u *url.URL) string {
+	switch {
+	case u.Path == "" || u.Path == "/~" || u.Path == "/~/":
+		return "."
+	case strings.HasPrefix(u.Path, "/~/"):
+		return u.Path[len("/~/"):]
+	}
+
+	return u.Path
+}
+
+// sftpQuote quotes an argument of an sftp batch command.
+func sftpQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
@@ -0,0 +1,84 @@
+package getter
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// StdinGetter is a Getter that reads the source "-", or stdin://, from the
+// Client's Stdin, so that artifacts can be piped through a Client and be
+// verified and decompressed just like any other file, with the checksum
+// and archive query parameters of the source, e.g. "-?archive=tar.gz".
+//
+// Stdin can only be read once, and only as a file, or an archive that is
+// unpacked into a directory. Downloading it in the "any" mode without an
+// archive parameter needs a filename parameter to name the file.
+type StdinGetter struct {
+	getter
+}
+
+func (g *StdinGetter) withClient(c *Client) Getter {
+	cp := *g
+	cp.client = c
+	return &cp
+}
+
+func (g *StdinGetter) Describe() Description {
+	return Description{
+		Summary: "The standard input of the process, or Client.Stdin, read once.",
+		Strict:  true,
+	}
+}
+
+func (g *StdinGetter) ClientMode(u *url.URL) (ClientMode, error) {
+	if u.Query().Get("filename") == "" {
+		return 0, fmt.Errorf("stdin needs a filename parameter to be downloaded in any mode")
+	}
+	return ClientModeFile, nil
+}
+
+func (g *StdinGetter) Get(dst string, u *url.URL) error {
+	return fmt.Errorf("stdin can only be downloaded as a file or an archive")
+}
+
+func (g *StdinGetter) GetFile(dst string, u *url.URL) error {
+	body, _, err := g.GetReader(u)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, body)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+// GetReader implements StreamGetter. The size of stdin is never known.
+func (g *StdinGetter) GetReader(u *url.URL) (io.ReadCloser, int64, error) {
+	var r io.Reader = os.Stdin
+	if g.client != nil && g.client.Stdin != nil {
+		r = g.client.Stdin
+	}
+
+	body := ioutil.NopCloser(&contextReader{ctx: g.Context(), r: r})
+	return g.trackProgress("stdin", 0, -1, body), -1, nil
+}
+
+// Local implements LocalGetter, since stdin is always local.
+func (g *StdinGetter) Local(*url.URL) bool {
+	return true
+}
@@ -0,0 +1,83 @@
+package getter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStdinGetter_impl(t *testing.T) {
+	var _ Getter = new(StdinGetter)
+	var _ StreamGetter = new(StdinGetter)
+}
+
+func TestStdinGetter(t *testing.T) {
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	for _, strategy := range []Strategy{StrategyTempFile, StrategyStream} {
+		client := &Client{
+			Src:      "-?checksum=" + testChecksum("Hello\n"),
+			Dst:      dst,
+			Mode:     ClientModeFile,
+			Stdin:    strings.NewReader("Hello\n"),
+			Strategy: strategy,
+		}
+		if err := client.Get(); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		assertContents(t, dst, "Hello\n")
+
+		client.Stdin = strings.NewReader("Goodbye\n")
+		if err := client.Get(); err == nil {
+			t.Fatal("should error")
+		}
+	}
+}
+
+func TestStdinGetter_archive(t *testing.T) {
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	f, err := os.Open(filepath.Join(fixtureDir, "archive.tar.gz"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	client := &Client{
+		Src:   "-?archive=tar.gz",
+		Dst:   dst,
+		Mode:  ClientModeAny,
+		Stdin: f,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "main.tf")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestStdinGetter_any(t *testing.T) {
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	client := &Client{
+		Src:   "stdin://?filename=foo.txt",
+		Dst:   dst,
+		Mode:  ClientModeAny,
+		Stdin: strings.NewReader("Hello\n"),
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "foo.txt"), "Hello\n")
+
+	// Without a filename, there's nothing to name the file
+	client.Src = "-"
+	if err := client.Get(); err == nil || !strings.Contains(err.Error(), "filename") {
+		t.Fatalf("bad: %v", err)
+	}
+}
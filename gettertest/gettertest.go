@@ -0,0 +1,409 @@
+// Package gettertest is a conformance suite for Getter implementations.
+//
+// It checks the behavior that Client relies on, such as the modes that
+// sources are reported in, what Get and GetFile leave in the destination,
+// subdirectories, checksums, symlinks and cancellation, so that a custom getter behaves
+// like the built-in ones. A getter is tested by describing a few sources
+// it can download:
+//
+//	func TestMyGetter(t *testing.T) {
+//		s := &gettertest.Suite{
+//			Getter:       new(MyGetter),
+//			Dir:          mustParse("my://example/dir"),
+//			DirFiles:     map[string]string{"main.tf": "...", "sub/sub.tf": "..."},
+//			File:         mustParse("my://example/file.txt"),
+//			FileContents: "...",
+//			Missing:      mustParse("my://example/missing"),
+//		}
+//		s.Run(t)
+//	}
+package gettertest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-getter"
+)
+
+// forced is the forced getter that the suite registers the getter under
+// when it is used through a Client.
+const forced = "gettertest"
+
+// Suite describes the sources used to test a getter. Tests of sources
+// that are left unset are skipped.
+type Suite struct {
+	// Getter is the getter being tested.
+	Getter getter.Getter
+
+	// Dir is a directory source, and DirFiles the contents of each of the
+	// files in it keyed by their slash separated path. Files and
+	// directories whose names start with a dot, such as a .git directory,
+	// are ignored when comparing the download with DirFiles.
+	Dir      *url.URL
+	DirFiles map[string]string
+
+	// File is a file source and FileContents its contents.
+	File         *url.URL
+	FileContents string
+
+	// Missing is a source that doesn't exist.
+	Missing *url.URL
+}
+
+// Run runs each test in the suite as a subtest of t.
+func (s *Suite) Run(t *testing.T) {
+	t.Run("ClientModeDir", s.testClientModeDir)
+	t.Run("ClientModeFile", s.testClientModeFile)
+	t.Run("Get", s.testGet)
+	t.Run("GetAgain", s.testGetAgain)
+	t.Run("GetSubdir", s.testGetSubdir)
+	t.Run("GetSymlinks", s.testGetSymlinks)
+	t.Run("GetFile", s.testGetFile)
+	t.Run("GetFileChecksum", s.testGetFileChecksum)
+	t.Run("Missing", s.testMissing)
+	t.Run("Cancelled", s.testCancelled)
+}
+
+func (s *Suite) testClientModeDir(t *testing.T) {
+	s.requireDir(t)
+
+	mode, err := s.Getter.ClientMode(s.Dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != getter.ClientModeDir {
+		t.Fatalf("bad mode: %d", mode)
+	}
+}
+
+func (s *Suite) testClientModeFile(t *testing.T) {
+	s.requireFile(t)
+
+	mode, err := s.Getter.ClientMode(s.File)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if mode != getter.ClientModeFile {
+		t.Fatalf("bad mode: %d", mode)
+	}
+}
+
+// testGet checks that Get creates the destination with the files in it.
+func (s *Suite) testGet(t *testing.T) {
+	s.requireDir(t)
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	dst := filepath.Join(td, "dst")
+	if err := s.Getter.Get(dst, s.Dir); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertFiles(t, dst, s.DirFiles)
+}
+
+// testGetAgain checks that Get downloads into a destination that a
+// previous Get downloaded the same source into, as happens when a source
+// is updated.
+func (s *Suite) testGetAgain(t *testing.T) {
+	s.requireDir(t)
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	dst := filepath.Join(td, "dst")
+	for i := 0; i < 2; i++ {
+		if err := s.Getter.Get(dst, s.Dir); err != nil {
+			t.Fatalf("%d: err: %s", i, err)
+		}
+	}
+	assertFiles(t, dst, s.DirFiles)
+}
+
+// testGetSubdir checks that a Client can download a subdirectory of the
+// source. The first subdirectory in DirFiles is used.
+func (s *Suite) testGetSubdir(t *testing.T) {
+	s.requireDir(t)
+
+	var paths []string
+	for p := range s.DirFiles {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var subDir string
+	for _, p := range paths {
+		if idx := strings.Index(p, "/"); idx >= 0 {
+			subDir = p[:idx]
+			break
+		}
+	}
+	if subDir == "" {
+		t.Skip("DirFiles has no subdirectories")
+	}
+
+	expected := make(map[string]string)
+	for p, contents := range s.DirFiles {
+		if strings.HasPrefix(p, subDir+"/") {
+			expected[strings.TrimPrefix(p, subDir+"/")] = contents
+		}
+	}
+
+	// The subdirectory goes before the query, which SourceDirSubdir
+	// expects after it.
+	u := *s.Dir
+	u.RawQuery = ""
+	src := u.String() + "//" + subDir
+	if s.Dir.RawQuery != "" {
+		src += "?" + s.Dir.RawQuery
+	}
+
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	dst := filepath.Join(td, "dst")
+	client := &getter.Client{
+		Src:     forced + "::" + src,
+		Dst:     dst,
+		Mode:    getter.ClientModeDir,
+		Getters: s.getters(forced),
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertFiles(t, dst, expected)
+}
+
+// testGetSymlinks checks that no symlink in the download points outside
+// of it. The destination itself may be a symlink, as it is for local
+// directories.
+func (s *Suite) testGetSymlinks(t *testing.T) {
+	s.requireDir(t)
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	dst := filepath.Join(td, "dst")
+	if err := s.Getter.Get(dst, s.Dir); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	root, err := filepath.EvalSymlinks(dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			return err
+		}
+
+		target, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return err
+		}
+		if rel, err := filepath.Rel(root, target); err != nil || strings.HasPrefix(rel, "..") {
+			t.Errorf("symlink %s points outside of the download: %s", path, target)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+// testGetFile checks that GetFile creates the file and any parent
+// directories.
+func (s *Suite) testGetFile(t *testing.T) {
+	s.requireFile(t)
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	dst := filepath.Join(td, "a", "b", "file")
+	if err := s.Getter.GetFile(dst, s.File); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, s.FileContents)
+}
+
+// testGetFileChecksum checks that a Client verifies the file against a
+// checksum, which isn't passed on to the getter.
+func (s *Suite) testGetFileChecksum(t *testing.T) {
+	s.requireFile(t)
+
+	sum := sha256.Sum256([]byte(s.FileContents))
+	for _, tc := range []struct {
+		Checksum string
+		Err      bool
+	}{
+		{hex.EncodeToString(sum[:]), false},
+		{strings.Repeat("0", 64), true},
+	} {
+		u := *s.File
+		q := u.Query()
+		q.Set("checksum", "sha256:"+tc.Checksum)
+		u.RawQuery = q.Encode()
+
+		td := tempDir(t)
+		defer os.RemoveAll(td)
+		dst := filepath.Join(td, "file")
+		client := &getter.Client{
+			Src:     forced + "::" + u.String(),
+			Dst:     dst,
+			Mode:    getter.ClientModeFile,
+			Getters: s.getters(forced),
+		}
+
+		err := client.Get()
+		if (err != nil) != tc.Err {
+			t.Fatalf("checksum %s: err: %v", tc.Checksum, err)
+		}
+		if !tc.Err {
+			assertContents(t, dst, s.FileContents)
+		}
+	}
+}
+
+// testMissing checks that getting a source that doesn't exist is an error.
+func (s *Suite) testMissing(t *testing.T) {
+	if s.Missing == nil {
+		t.Skip("no Missing source")
+	}
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+
+	if err := s.Getter.Get(filepath.Join(td, "dir"), s.Missing); err == nil {
+		t.Fatal("Get should error")
+	}
+	if err := s.Getter.GetFile(filepath.Join(td, "file"), s.Missing); err == nil {
+		t.Fatal("GetFile should error")
+	}
+}
+
+// testCancelled checks that Get and GetFile fail once the context of the
+// client using the getter is done.
+func (s *Suite) testCancelled(t *testing.T) {
+	if s.Dir == nil && s.File == nil {
+		t.Skip("no Dir or File source")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	if s.Dir != nil {
+		client := &getter.Client{
+			Ctx:     ctx,
+			Src:     forced + "::" + s.Dir.String(),
+			Dst:     filepath.Join(td, "dir"),
+			Mode:    getter.ClientModeDir,
+			Getters: s.getters(forced),
+		}
+		if err := client.Get(); err == nil {
+			t.Fatal("Get should error")
+		}
+	}
+	if s.File != nil {
+		client := &getter.Client{
+			Ctx:     ctx,
+			Src:     forced + "::" + s.File.String(),
+			Dst:     filepath.Join(td, "file"),
+			Mode:    getter.ClientModeFile,
+			Getters: s.getters(forced),
+		}
+		if err := client.Get(); err == nil {
+			t.Fatal("GetFile should error")
+		}
+	}
+}
+
+// getters returns the default getters with the one being tested registered
+// as forced, so that sources it refers to, such as those of X-Terraform-Get
+// for HTTP, can be got too.
+func (s *Suite) getters(forced string) map[string]getter.Getter {
+	getters := getter.DefaultGetters()
+	getters[forced] = s.Getter
+	return getters
+}
+
+func (s *Suite) requireDir(t *testing.T) {
+	if s.Dir == nil {
+		t.Skip("no Dir source")
+	}
+}
+
+func (s *Suite) requireFile(t *testing.T) {
+	if s.File == nil {
+		t.Skip("no File source")
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "gettertest")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return dir
+}
+
+// assertFiles checks that the files in dir, ignoring dot files, are
+// exactly those given.
+func assertFiles(t *testing.T, dir string, expected map[string]string) {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	actual := make(map[string]string)
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		actual[filepath.ToSlash(rel)] = string(data)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad files\n\nexpected: %#v\n\nactual: %#v", expected, actual)
+	}
+}
+
+func assertContents(t *testing.T, path, expected string) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != expected {
+		t.Fatalf("bad %s: %q", path, data)
+	}
+}
@@ -0,0 +1,87 @@
+package gettertest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-getter"
+	urlhelper "github.com/hashicorp/go-getter/helper/url"
+)
+
+// fixtureDir is the directory of the go-getter test fixtures.
+const fixtureDir = "../test-fixtures"
+
+func TestFileGetter(t *testing.T) {
+	s := fixtureSuite(t)
+	s.Getter = new(getter.FileGetter)
+	s.Dir = fileURL(t, "basic")
+	s.File = fileURL(t, "basic/main.tf")
+	s.Missing = fileURL(t, "missing")
+	s.Run(t)
+}
+
+func TestHttpGetter(t *testing.T) {
+	dir := fileURL(t, "basic")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		// Subdirectories are split off before the trailing slash
+		case "/dir", "/dir/":
+			w.Header().Set("X-Terraform-Get", dir.String())
+		case "/file":
+			http.ServeFile(w, r, filepath.Join(fixtureDir, "basic", "main.tf"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	s := fixtureSuite(t)
+	s.Getter = new(getter.HttpGetter)
+	s.Dir = parseURL(t, server.URL+"/dir/")
+	s.File = parseURL(t, server.URL+"/file")
+	s.Missing = parseURL(t, server.URL+"/missing")
+	s.Run(t)
+}
+
+// fixtureSuite returns a suite with the contents of the basic fixture.
+func fixtureSuite(t *testing.T) *Suite {
+	s := &Suite{DirFiles: make(map[string]string)}
+	for _, p := range []string{"main.tf", "foo/main.tf", "subdir/sub.tf"} {
+		data, err := ioutil.ReadFile(filepath.Join(fixtureDir, "basic", filepath.FromSlash(p)))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		s.DirFiles[p] = string(data)
+	}
+	s.FileContents = s.DirFiles["main.tf"]
+
+	return s
+}
+
+func fileURL(t *testing.T, n string) *url.URL {
+	p, err := filepath.Abs(filepath.Join(fixtureDir, n))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	u, err := urlhelper.Parse(p)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	u.Scheme = "file"
+
+	return u
+}
+
+func parseURL(t *testing.T, s string) *url.URL {
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return u
+}
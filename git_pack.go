@@ -0,0 +1,458 @@
+package getter
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"strconv"
+)
+
+// The types of the objects in a Git packfile.
+const (
+	gitObjCommit   = 1
+	gitObjTree     = 2
+	gitObjBlob     = 3
+	gitObjTag      = 4
+	gitObjOfsDelta = 6
+	gitObjRefDelta = 7
+)
+
+// gitObjectTypes are the names of the object types, which their IDs are
+// hashed with.
+var gitObjectTypes = map[int]string{
+	gitObjCommit: "commit",
+	gitObjTree:   "tree",
+	gitObjBlob:   "blob",
+	gitObjTag:    "tag",
+}
+
+// gitPackCacheSize is about how many bytes of objects a gitPack keeps to
+// resolve the deltas that are based on them.
+const gitPackCacheSize = 32 << 20
+
+// gitPack is a Git packfile that has been read for the objects it has.
+type gitPack struct {
+	r       io.ReaderAt
+	closer  io.Closer
+	offsets map[string]int64
+
+	cache     map[int64]*gitObject
+	cacheSize int
+}
+
+// gitObject is an object of a packfile, with any deltas applied.
+type gitObject struct {
+	typ  int
+	data []byte
+}
+
+// readGitPack reads the packfile r, of the given size, and indexes its
+// objects by ID. The pack's checksum is verified, and it must not be thin:
+// the bases of its deltas must be in it.
+func readGitPack(r io.ReaderAt, size int64) (*gitPack, error) {
+	p := &gitPack{
+		r:       r,
+		offsets: make(map[string]int64),
+		cache:   make(map[int64]*gitObject),
+	}
+
+	h := sha1.New()
+	cr := &countingByteReader{r: bufio.NewReader(io.NewSectionReader(r, 0, size)), h: h}
+	var header [12]byte
+	if _, err := io.ReadFull(cr, header[:]); err != nil {
+		return nil, fmt.Errorf("error reading packfile: %s", err)
+	}
+	if string(header[:4]) != "PACK" {
+		return nil, fmt.Errorf("not a packfile")
+	}
+	if v := binary.BigEndian.Uint32(header[4:8]); v != 2 && v != 3 {
+		return nil, fmt.Errorf("unsupported packfile version %d", v)
+	}
+	count := binary.BigEndian.Uint32(header[8:12])
+
+	// Objects that aren't deltas are hashed as they are read. Deltas are
+	// resolved once all of their bases are known.
+	var deltas []int64
+	for i := uint32(0); i < count; i++ {
+		offset := cr.n
+		typ, size, err := readGitObjectHeader(cr)
+		if err != nil {
+			return nil, err
+		}
+
+		var w io.Writer = ioutil.Discard
+		var oh hash.Hash
+		switch typ {
+		case gitObjOfsDelta:
+			if _, err := readGitOfsDelta(cr); err != nil {
+				return nil, err
+			}
+			deltas = append(deltas, offset)
+		case gitObjRefDelta:
+			if _, err := io.ReadFull(cr, make([]byte, 20)); err != nil {
+				return nil, err
+			}
+			deltas = append(deltas, offset)
+		default:
+			name, ok := gitObjectTypes[typ]
+			if !ok {
+				return nil, fmt.Errorf("packfile has an object of unknown type %d", typ)
+			}
+			oh = sha1.New()
+			fmt.Fprintf(oh, "%s %d\x00", name, size)
+			w = oh
+		}
+
+		zr, err := zlib.NewReader(cr)
+		if err != nil {
+			return nil, fmt.Errorf("error reading packfile object: %s", err)
+		}
+		n, err := io.Copy(w, zr)
+		if err != nil {
+			return nil, fmt.Errorf("error reading packfile object: %s", err)
+		}
+		if n != size {
+			return nil, fmt.Errorf("packfile object is %d bytes rather than %d", n, size)
+		}
+		if oh != nil {
+			p.offsets[hex.EncodeToString(oh.Sum(nil))] = offset
+		}
+	}
+
+	sum := h.Sum(nil)
+	trailer := make([]byte, 20)
+	if _, err := io.ReadFull(cr.r, trailer); err != nil {
+		return nil, fmt.Errorf("error reading packfile checksum: %s", err)
+	}
+	if !bytes.Equal(sum, trailer) {
+		return nil, fmt.Errorf("packfile is corrupt: checksum mismatch")
+	}
+
+	// A delta may be based on one that comes after it, so keep going
+	// while there are deltas whose bases have become known.
+	for len(deltas) > 0 {
+		var pending []int64
+		for _, offset := range deltas {
+			o, err := p.object(offset)
+			if err == errGitMissingBase {
+				pending = append(pending, offset)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			p.offsets[gitObjectID(o)] = offset
+		}
+		if len(pending) == len(deltas) {
+			return nil, fmt.Errorf("packfile has deltas whose bases are missing")
+		}
+		deltas = pending
+	}
+
+	return p, nil
+}
+
+// errGitMissingBase is returned when the base of a ref delta isn't known.
+var errGitMissingBase = fmt.Errorf("delta base is missing")
+
+// Close closes the file that the pack is read from, if it has one.
+func (p *gitPack) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+
+	return p.closer.Close()
+}
+
+// get returns the object with the given ID.
+func (p *gitPack) get(id string) (*gitObject, error) {
+	offset, ok := p.offsets[id]
+	if !ok {
+		return nil, fmt.Errorf("object %s isn't in the packfile", id)
+	}
+
+	return p.object(offset)
+}
+
+// object returns the object at offset in the pack.
+func (p *gitPack) object(offset int64) (*gitObject, error) {
+	if o, ok := p.cache[offset]; ok {
+		return o, nil
+	}
+
+	cr := &countingByteReader{r: bufio.NewReader(io.NewSectionReader(p.r, offset, 1<<62))}
+	typ, size, err := readGitObjectHeader(cr)
+	if err != nil {
+		return nil, err
+	}
+
+	var base *gitObject
+	switch typ {
+	case gitObjOfsDelta:
+		rel, err := readGitOfsDelta(cr)
+		if err != nil {
+			return nil, err
+		}
+		if rel <= 0 || rel > offset {
+			return nil, fmt.Errorf("packfile delta has an invalid base offset")
+		}
+		if base, err = p.object(offset - rel); err != nil {
+			return nil, err
+		}
+	case gitObjRefDelta:
+		id := make([]byte, 20)
+		if _, err := io.ReadFull(cr, id); err != nil {
+			return nil, err
+		}
+		baseOffset, ok := p.offsets[hex.EncodeToString(id)]
+		if !ok {
+			return nil, errGitMissingBase
+		}
+		if base, err = p.object(baseOffset); err != nil {
+			return nil, err
+		}
+	}
+
+	zr, err := zlib.NewReader(cr)
+	if err != nil {
+		return nil, fmt.Errorf("error reading packfile object: %s", err)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return nil, fmt.Errorf("error reading packfile object: %s", err)
+	}
+
+	o := &gitObject{typ: typ, data: data}
+	if base != nil {
+		if o.data, err = applyGitDelta(base.data, data); err != nil {
+			return nil, err
+		}
+		o.typ = base.typ
+	}
+
+	// Forget everything once the cache is full, rather than keeping
+	// track of which objects were used last.
+	if p.cacheSize+len(o.data) > gitPackCacheSize {
+		p.cache = make(map[int64]*gitObject)
+		p.cacheSize = 0
+	}
+	p.cache[offset] = o
+	p.cacheSize += len(o.data)
+	return o, nil
+}
+
+// gitObjectID returns the ID of the object o.
+func gitObjectID(o *gitObject) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s %d\x00", gitObjectTypes[o.typ], len(o.data))
+	h.Write(o.data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// readGitObjectHeader reads the type and inflated size of a packfile
+// object.
+func readGitObjectHeader(r io.ByteReader) (int, int64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, fmt.Errorf("error reading packfile: %s", err)
+	}
+	typ := int(b>>4) & 7
+	size := int64(b & 0x0f)
+	for shift := uint(4); b&0x80 != 0; shift += 7 {
+		if shift > 56 {
+			return 0, 0, fmt.Errorf("packfile object size is too large")
+		}
+		if b, err = r.ReadByte(); err != nil {
+			return 0, 0, fmt.Errorf("error reading packfile: %s", err)
+		}
+		size |= int64(b&0x7f) << shift
+	}
+
+	return typ, size, nil
+}
+
+// readGitOfsDelta reads how far before an offset delta its base is.
+func readGitOfsDelta(r io.ByteReader) (int64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	rel := int64(b & 0x7f)
+	for b&0x80 != 0 {
+		if rel > 1<<55 {
+			return 0, fmt.Errorf("packfile delta base offset is too large")
+		}
+		if b, err = r.ReadByte(); err != nil {
+			return 0, err
+		}
+		rel = (rel+1)<<7 | int64(b&0x7f)
+	}
+
+	return rel, nil
+}
+
+// applyGitDelta returns the object that delta makes of base.
+func applyGitDelta(base, delta []byte) ([]byte, error) {
+	r := bytes.NewReader(delta)
+	baseSize, err := binary.ReadUvarint(r)
+	if err != nil || baseSize != uint64(len(base)) {
+		return nil, fmt.Errorf("packfile delta doesn't match its base")
+	}
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("packfile delta is corrupt")
+	}
+
+	out := make([]byte, 0, size)
+	for r.Len() > 0 {
+		op, _ := r.ReadByte()
+		if op&0x80 == 0 {
+			// Insert the next op bytes of the delta
+			if op == 0 || int(op) > r.Len() {
+				return nil, fmt.Errorf("packfile delta is corrupt")
+			}
+			data := make([]byte, op)
+			r.Read(data)
+			out = append(out, data...)
+			continue
+		}
+
+		// Copy a range of the base, whose offset and size are given by
+		// the bytes that the low bits of op say are present
+		var offset, n uint64
+		for i := uint(0); i < 7; i++ {
+			if op&(1<<i) == 0 {
+				continue
+			}
+			b, err := r.ReadByte()
+			if err != nil {
+				return nil, fmt.Errorf("packfile delta is corrupt")
+			}
+			if i < 4 {
+				offset |= uint64(b) << (8 * i)
+			} else {
+				n |= uint64(b) << (8 * (i - 4))
+			}
+		}
+		if n == 0 {
+			n = 0x10000
+		}
+		if offset+n > uint64(len(base)) {
+			return nil, fmt.Errorf("packfile delta copies past the end of its base")
+		}
+		out = append(out, base[offset:offset+n]...)
+	}
+	if uint64(len(out)) != size {
+		return nil, fmt.Errorf("packfile delta is %d bytes rather than %d", len(out), size)
+	}
+
+	return out, nil
+}
+
+// gitTreeEntry is an entry of a Git tree object.
+type gitTreeEntry struct {
+	mode string
+	name string
+	id   string
+}
+
+// parseGitTree returns the entries of a tree object.
+func parseGitTree(data []byte) ([]gitTreeEntry, error) {
+	var entries []gitTreeEntry
+	for len(data) > 0 {
+		sp := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if sp < 0 || nul < sp || len(data) < nul+21 {
+			return nil, fmt.Errorf("tree object is corrupt")
+		}
+		entries = append(entries, gitTreeEntry{
+			mode: string(data[:sp]),
+			name: string(data[sp+1 : nul]),
+			id:   hex.EncodeToString(data[nul+1 : nul+21]),
+		})
+		data = data[nul+21:]
+	}
+
+	return entries, nil
+}
+
+// gitHeader returns the value of the first header of a commit or tag
+// object with the given name.
+func gitHeader(data []byte, name string) string {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) == 0 {
+			// The headers end at the first blank line
+			break
+		}
+		if bytes.HasPrefix(line, []byte(name+" ")) {
+			return string(line[len(name)+1:])
+		}
+	}
+
+	return ""
+}
+
+// countingByteReader counts, and hashes if h is set, the bytes that are
+// read from r. It is an io.ByteReader so that reading a zlib stream from
+// it doesn't read past the end of the stream.
+type countingByteReader struct {
+	r *bufio.Reader
+	n int64
+	h hash.Hash
+}
+
+func (c *countingByteReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.h != nil {
+		c.h.Write(p[:n])
+	}
+	return n, err
+}
+
+func (c *countingByteReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+		if c.h != nil {
+			c.h.Write([]byte{b})
+		}
+	}
+	return b, err
+}
+
+// pktLine returns s as a pkt-line of Git's protocol.
+func pktLine(s string) string {
+	return strconv.FormatInt(int64(len(s)+4)|0x10000, 16)[1:] + s
+}
+
+// readPktLine reads a pkt-line from r, returning nil for a flush-pkt.
+func readPktLine(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	n, err := strconv.ParseUint(string(header[:]), 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pkt-line length %q", header[:])
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	if n < 4 {
+		return nil, fmt.Errorf("invalid pkt-line length %q", header[:])
+	}
+
+	line := make([]byte, n-4)
+	if _, err := io.ReadFull(r, line); err != nil {
+		return nil, err
+	}
+	return line, nil
+}
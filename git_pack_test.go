@@ -0,0 +1,79 @@
+package getter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestApplyGitDelta(t *testing.T) {
+	base := []byte("Hello, world! This is the base.")
+
+	// Copy "Hello, ", insert "there", copy "! This is the base."
+	delta := []byte{byte(len(base)), 31}
+	delta = append(delta, 0x90, 7)
+	delta = append(delta, 5)
+	delta = append(delta, "there"...)
+	delta = append(delta, 0x91, 12, 19)
+
+	out, err := applyGitDelta(base, delta)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(out) != "Hello, there! This is the base." {
+		t.Fatalf("bad: %q", out)
+	}
+
+	// The base is the wrong size
+	if _, err := applyGitDelta(base[1:], delta); err == nil {
+		t.Fatal("should error")
+	}
+
+	// A copy past the end of the base
+	bad := append([]byte{byte(len(base)), 10}, 0x91, 30, 10)
+	if _, err := applyGitDelta(base, bad); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestPktLine(t *testing.T) {
+	if got := pktLine("done\n"); got != "0009done\n" {
+		t.Fatalf("bad: %q", got)
+	}
+
+	r := strings.NewReader("0009done\n0000000")
+	line, err := readPktLine(r)
+	if err != nil || string(line) != "done\n" {
+		t.Fatalf("bad: %q %v", line, err)
+	}
+	line, err = readPktLine(r)
+	if err != nil || line != nil {
+		t.Fatalf("should be a flush: %q %v", line, err)
+	}
+	if _, err := readPktLine(r); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestParseGitTree(t *testing.T) {
+	id := bytes.Repeat([]byte{0xab}, 20)
+	var data []byte
+	data = append(data, "100644 a.txt\x00"...)
+	data = append(data, id...)
+	data = append(data, "40000 dir\x00"...)
+	data = append(data, id...)
+
+	entries, err := parseGitTree(data)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	want := strings.Repeat("ab", 20)
+	if len(entries) != 2 || entries[0] != (gitTreeEntry{"100644", "a.txt", want}) ||
+		entries[1] != (gitTreeEntry{"40000", "dir", want}) {
+		t.Fatalf("bad: %#v", entries)
+	}
+
+	if _, err := parseGitTree(data[:len(data)-1]); err == nil {
+		t.Fatal("should error")
+	}
+}
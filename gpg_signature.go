@@ -0,0 +1,75 @@
+package getter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/go-safetemp"
+	"golang.org/x/crypto/openpgp"
+)
+
+// maxSignatureSize is the most of a signature that is read, so that a
+// signature URL that refers to something huge isn't read into memory.
+const maxSignatureSize = 1 << 20
+
+// getSignature downloads the detached GPG signature at src, as
+// getChecksumFile does.
+func (c *Client) getSignature(src string) ([]byte, error) {
+	td, tdcloser, err := safetemp.Dir("", "getter")
+	if err != nil {
+		return nil, err
+	}
+	defer tdcloser.Close()
+
+	path := filepath.Join(td, "signature")
+	if err := c.subClient(src, path, ClientModeFile).Get(); err != nil {
+		return nil, fmt.Errorf("error downloading signature: %s", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sig, err := ioutil.ReadAll(io.LimitReader(f, maxSignatureSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(sig) > maxSignatureSize {
+		return nil, fmt.Errorf("signature is larger than %d bytes", maxSignatureSize)
+	}
+	return sig, nil
+}
+
+// verifySignature checks that sig, which is either ASCII armored or
+// binary, is a signature of the file at path by a key in keyring.
+func verifySignature(path string, sig []byte, keyring openpgp.KeyRing) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Failed to open file for signature verification: %s", err)
+	}
+	defer f.Close()
+
+	return checkSignature(f, sig, keyring)
+}
+
+// checkSignature checks that sig, which is either ASCII armored or binary,
+// is a signature of what is read from r by a key in keyring.
+func checkSignature(r io.Reader, sig []byte, keyring openpgp.KeyRing) error {
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(sig), []byte("-----BEGIN")) {
+		_, err = openpgp.CheckArmoredDetachedSignature(keyring, r, bytes.NewReader(sig))
+	} else {
+		_, err = openpgp.CheckDetachedSignature(keyring, r, bytes.NewReader(sig))
+	}
+	if err != nil {
+		return fmt.Errorf("GPG signature verification failed: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,252 @@
+package getter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestGet_gpgsig(t *testing.T) {
+	signer := testGPGEntity(t)
+	server := testGPGServer(t, signer, "Hello\n")
+	defer server.Close()
+
+	for _, sig := range []string{"file.asc", "file.sig"} {
+		dst := tempFile(t)
+		defer os.RemoveAll(filepath.Dir(dst))
+
+		client := &Client{
+			Src:        server.URL + "/file?gpgsig=" + server.URL + "/" + sig,
+			Dst:        dst,
+			Mode:       ClientModeFile,
+			GPGKeyring: openpgp.EntityList{signer},
+		}
+		if err := client.Get(); err != nil {
+			t.Fatalf("%s: err: %s", sig, err)
+		}
+		assertContents(t, dst, "Hello\n")
+
+		// Nothing is left next to the destination
+		entries, err := ioutil.ReadDir(filepath.Dir(dst))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("%s: bad: %v", sig, entries)
+		}
+	}
+}
+
+func TestGet_gpgsigRefreshURL(t *testing.T) {
+	signer := testGPGEntity(t)
+	var sig bytes.Buffer
+	if err := openpgp.DetachSign(&sig, signer, strings.NewReader("Hello\n"), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	server := testSignedFilesServer(map[string][]byte{
+		"/file":     []byte("Hello\n"),
+		"/file.sig": sig.Bytes(),
+	})
+	defer server.Close()
+
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	// The signature is refreshed as well as the file
+	valid := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	gpgsig := server.URL + "/file.sig?se=" + valid + "&sig=stale"
+	client := &Client{
+		Src:        server.URL + "/file?se=" + valid + "&sig=stale&gpgsig=" + url.QueryEscape(gpgsig),
+		Dst:        dst,
+		Mode:       ClientModeFile,
+		GPGKeyring: openpgp.EntityList{signer},
+		RefreshURL: testRefreshURL(valid),
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello\n")
+}
+
+func TestGet_gpgsigBad(t *testing.T) {
+	signer := testGPGEntity(t)
+	server := testGPGServer(t, signer, "Hello\n")
+	defer server.Close()
+
+	// Signed by a key that isn't in the keyring
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+	client := &Client{
+		Src:        server.URL + "/file?gpgsig=" + server.URL + "/file.asc",
+		Dst:        dst,
+		Mode:       ClientModeFile,
+		GPGKeyring: openpgp.EntityList{testGPGEntity(t)},
+	}
+	err := client.Get()
+	if err == nil || !strings.Contains(err.Error(), "signature verification failed") {
+		t.Fatalf("bad: %v", err)
+	}
+	if _, err := os.Stat(dst); err == nil {
+		t.Fatal("unverified file shouldn't be left in place")
+	}
+
+	// Not a signature of the file
+	client.Src = server.URL + "/other?gpgsig=" + server.URL + "/file.asc"
+	client.GPGKeyring = openpgp.EntityList{signer}
+	if err := client.Get(); err == nil {
+		t.Fatal("should error")
+	}
+	if _, err := os.Stat(dst); err == nil {
+		t.Fatal("unverified file shouldn't be left in place")
+	}
+
+	// No keyring
+	client.Src = server.URL + "/file?gpgsig=" + server.URL + "/file.asc"
+	client.GPGKeyring = nil
+	if err := client.Get(); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestGet_gpgsigTooLarge(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	if err := os.MkdirAll(td, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	sigPath := filepath.Join(td, "huge.sig")
+	if err := ioutil.WriteFile(sigPath, make([]byte, maxSignatureSize+1), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	dst := filepath.Join(td, "file")
+	client := &Client{
+		Src:        testModule("basic-file/foo.txt") + "?gpgsig=" + fmtFileURL(sigPath),
+		Dst:        dst,
+		Mode:       ClientModeFile,
+		GPGKeyring: openpgp.EntityList{testGPGEntity(t)},
+	}
+	err := client.Get()
+	if err == nil || !strings.Contains(err.Error(), "signature is larger than") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestGet_gpgsigArchive(t *testing.T) {
+	signer := testGPGEntity(t)
+	archive := filepath.Join(fixtureDir, "archive.tar.gz")
+	sig := testGPGSign(t, signer, archive)
+
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+	sigPath := filepath.Join(dst, "archive.tar.gz.sig")
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(sigPath, sig, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The archive is verified before it is decompressed
+	out := filepath.Join(dst, "out")
+	client := &Client{
+		Src:        testModule("archive.tar.gz") + "?gpgsig=" + fmtFileURL(sigPath),
+		Dst:        out,
+		Dir:        true,
+		GPGKeyring: openpgp.EntityList{signer},
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(out, "main.tf"), "foo\n")
+
+	// And not decompressed if it isn't signed by the keyring
+	out = filepath.Join(dst, "unverified")
+	client.Dst = out
+	client.GPGKeyring = openpgp.EntityList{testGPGEntity(t)}
+	if err := client.Get(); err == nil {
+		t.Fatal("should error")
+	}
+	if _, err := os.Stat(out); err == nil {
+		t.Fatal("unverified archive shouldn't be decompressed")
+	}
+}
+
+func TestGet_gpgsigDir(t *testing.T) {
+	signer := testGPGEntity(t)
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	client := &Client{
+		Src:        testModule("basic") + "?gpgsig=" + testModule("basic/main.tf"),
+		Dst:        dst,
+		Dir:        true,
+		GPGKeyring: openpgp.EntityList{signer},
+	}
+	err := client.Get()
+	if err == nil || !strings.Contains(err.Error(), "files and archives") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func testGPGEntity(t *testing.T) *openpgp.Entity {
+	e, err := openpgp.NewEntity("go-getter", "test", "go-getter@example.com", nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return e
+}
+
+// testGPGSign returns a binary detached signature of the file at path.
+func testGPGSign(t *testing.T, signer *openpgp.Entity, path string) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	if err := openpgp.DetachSign(&buf, signer, f, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return buf.Bytes()
+}
+
+// testGPGServer serves the contents at /file with an armored signature of
+// them at /file.asc and a binary one at /file.sig, and other contents at
+// /other.
+func testGPGServer(t *testing.T, signer *openpgp.Entity, contents string) *httptest.Server {
+	var armored, binary bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&armored, signer, strings.NewReader(contents), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := openpgp.DetachSign(&binary, signer, strings.NewReader(contents), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	files := map[string][]byte{
+		"/file":     []byte(contents),
+		"/file.asc": armored.Bytes(),
+		"/file.sig": binary.Bytes(),
+		"/other":    []byte("Goodbye\n"),
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	}))
+}
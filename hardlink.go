@@ -0,0 +1,44 @@
+package getter
+
+import (
+	"os"
+)
+
+// fileID identifies a file independently of its path, so that the paths
+// of a file with several hardlinks can be recognized as the same file.
+type fileID struct {
+	dev, ino uint64
+}
+
+// hardlinks remembers where files with several hardlinks were copied to so
+// that their other paths can be linked to the copy rather than copied again.
+type hardlinks map[fileID]string
+
+// link links dst to the earlier copy of the file described by info, if
+// there is one and info has other hardlinks. It returns false, having done
+// nothing, if dst should be copied instead, in which case the copy is
+// remembered for the file's other paths.
+func (h hardlinks) link(dst string, info os.FileInfo) bool {
+	id, ok := hardlinkID(info)
+	if !ok {
+		return false
+	}
+
+	target, ok := h[id]
+	if !ok {
+		h[id] = dst
+		return false
+	}
+
+	return linkFile(dst, target) == nil
+}
+
+// linkFile makes dst a hardlink to target, replacing any file already at
+// dst rather than writing through it.
+func linkFile(dst, target string) error {
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.Link(target, dst)
+}
@@ -0,0 +1,124 @@
+// +build !windows
+
+package getter
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTar_hardlink(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "dir/a", Typeflag: tar.TypeReg, Mode: 0644, Size: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "b", Typeflag: tar.TypeLink, Linkname: "dir/a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	if err := untar(bytes.NewReader(buf.Bytes()), td, "test", true, nil, nil, false, SymlinksPreserve, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertHardlinked(t, filepath.Join(td, "dir", "a"), filepath.Join(td, "b"))
+
+	// Extracting again replaces the link rather than writing through it
+	if err := untar(bytes.NewReader(buf.Bytes()), td, "test", true, nil, nil, false, SymlinksPreserve, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertHardlinked(t, filepath.Join(td, "dir", "a"), filepath.Join(td, "b"))
+}
+
+func TestTar_hardlinkDotDot(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "a", Typeflag: tar.TypeLink, Linkname: "../secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	if err := untar(bytes.NewReader(buf.Bytes()), td, "test", true, nil, nil, false, SymlinksPreserve, nil); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestCopyDir_hardlink(t *testing.T) {
+	src := tempDir(t)
+	defer os.RemoveAll(src)
+	if err := os.Mkdir(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "a"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(src, "a"), filepath.Join(src, "sub", "b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "c"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+	if err := os.Mkdir(dst, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := copyDir(dst, src, false, SpecialFilesSkip, SymlinksPreserve); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertHardlinked(t, filepath.Join(dst, "a"), filepath.Join(dst, "sub", "b"))
+
+	// Files that aren't linked in the source are copied separately
+	a, err := os.Stat(filepath.Join(dst, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := os.Stat(filepath.Join(dst, "c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.SameFile(a, c) {
+		t.Fatal("a and c shouldn't be linked")
+	}
+}
+
+func assertHardlinked(t *testing.T, a, b string) {
+	ai, err := os.Stat(a)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !os.SameFile(ai, bi) {
+		t.Fatalf("%s and %s aren't hardlinked", a, b)
+	}
+
+	data, err := ioutil.ReadFile(b)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("bad %s: %q", b, data)
+	}
+}
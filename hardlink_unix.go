@@ -0,0 +1,19 @@
+// +build !windows
+
+package getter
+
+import (
+	"os"
+	"syscall"
+)
+
+// hardlinkID returns the identity of the file described by info if it has
+// more than one hardlink.
+func hardlinkID(info os.FileInfo) (fileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st.Nlink < 2 {
+		return fileID{}, false
+	}
+
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
@@ -0,0 +1,15 @@
+// +build windows
+
+package getter
+
+import (
+	"os"
+)
+
+// hardlinkID returns the identity of the file described by info if it has
+// more than one hardlink. The file information returned by a walk on
+// Windows doesn't include the link count, so hardlinks are copied as
+// separate files.
+func hardlinkID(info os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
@@ -0,0 +1,169 @@
+package getter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// httpCacheEntry is where a file is cached in an HttpGetter's CacheDir:
+// its contents are at path and the validators it was served with at path
+// with ".json" appended.
+type httpCacheEntry struct {
+	path    string
+	timeout time.Duration
+}
+
+// httpCacheMeta is the metadata of a cached file.
+type httpCacheMeta struct {
+	// URL is the URL the file was downloaded from, without credentials.
+	URL string `json:"url"`
+
+	// ETag and LastModified are the headers it was served with.
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// cacheEntry returns the entry in the cache for the file at u.
+func (g *HttpGetter) cacheEntry(u *url.URL) *httpCacheEntry {
+	// Credentials don't change which file we're talking about.
+	key := *u
+	key.User = nil
+	sum := sha256.Sum256([]byte(key.String()))
+
+	var timeout time.Duration
+	if g.client != nil {
+		timeout = g.client.LockTimeout
+	}
+
+	return &httpCacheEntry{
+		path:    filepath.Join(g.CacheDir, hex.EncodeToString(sum[:])),
+		timeout: timeout,
+	}
+}
+
+// load returns the metadata of the cached file, or nil if it isn't cached.
+func (e *httpCacheEntry) load() (*httpCacheMeta, error) {
+	l, err := lockPath(e.path, e.timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Unlock()
+
+	return e.meta()
+}
+
+// meta is load for callers holding the entry's lock.
+func (e *httpCacheEntry) meta() (*httpCacheMeta, error) {
+	data, err := ioutil.ReadFile(e.path + ".json")
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(e.path); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	var m httpCacheMeta
+	if err := json.Unmarshal(data, &m); err != nil {
+		// A corrupt entry is as good as a missing one
+		return nil, nil
+	}
+
+	return &m, nil
+}
+
+// header returns the headers that ask for the file only if it has changed
+// since it was cached.
+func (m *httpCacheMeta) header() http.Header {
+	header := make(http.Header)
+	if m.ETag != "" {
+		header.Set("If-None-Match", m.ETag)
+	}
+	if m.LastModified != "" {
+		header.Set("If-Modified-Since", m.LastModified)
+	}
+
+	return header
+}
+
+// copyTo copies the cached file to dst, with the modification time it was
+// served with.
+func (e *httpCacheEntry) copyTo(dst string) error {
+	l, err := lockPath(e.path, e.timeout)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	m, err := e.meta()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(e.path)
+	if err != nil {
+		return err
+	}
+	err = writeFile(dst, f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	if m != nil {
+		if t, err := http.ParseTime(m.LastModified); err == nil {
+			return os.Chtimes(dst, time.Now(), t)
+		}
+	}
+	return nil
+}
+
+// store caches the file at src, which was downloaded from u with a
+// response with the given header. Files served without an ETag or
+// Last-Modified header can't be asked for conditionally, so aren't cached.
+func (e *httpCacheEntry) store(src string, u *url.URL, header http.Header) error {
+	m := httpCacheMeta{
+		URL:          redactURLCredentials(u.String()),
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+	}
+	if m.ETag == "" && m.LastModified == "" {
+		return nil
+	}
+	data, err := json.Marshal(&m)
+	if err != nil {
+		return err
+	}
+
+	l, err := lockPath(e.path, e.timeout)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	// The metadata is removed while the contents are replaced, so that an
+	// interrupted store leaves an entry that isn't used rather than one
+	// with the wrong validators.
+	if err := os.Remove(e.path + ".json"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	err = writeFile(e.path, f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(e.path+".json", data, 0644)
+}
@@ -0,0 +1,210 @@
+package getter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// RedirectScope is which hosts an HttpGetter follows redirects to, and
+// downloads the sources that the terraform-get protocol returns from,
+// relative to the URL it was given, so that a compromised server can't send
+// downloads anywhere it likes. See HttpGetter.RedirectScope.
+type RedirectScope uint
+
+const (
+	// RedirectAnyHost allows any host.
+	RedirectAnyHost RedirectScope = iota
+
+	// RedirectSameOrigin only allows URLs with the same scheme, host and
+	// port.
+	RedirectSameOrigin
+
+	// RedirectSameDomain only allows hosts in the same registrable domain,
+	// so that "registry.example.com" may send downloads to
+	// "cdn.example.com" but not to "example.net" or, since "co.uk" is a
+	// public suffix, from "example.co.uk" to "other.co.uk".
+	RedirectSameDomain
+
+	// RedirectListedHosts only allows the RedirectHosts.
+	RedirectListedHosts
+)
+
+// redirectError stops a request at a redirect to a URL that HTTP can't
+// follow, which the getter's Redirect has mapped to source.
+type redirectError struct {
+	source string
+}
+
+func (e *redirectError) Error() string {
+	return fmt.Sprintf("redirected to %s", redactURLCredentials(e.source))
+}
+
+// redirectSource returns the source that err says a request was redirected
+// to, if it is a redirect that was mapped by the getter's Redirect.
+func redirectSource(err error) (string, bool) {
+	var re *redirectError
+	if errors.As(err, &re) {
+		return re.source, true
+	}
+
+	return "", false
+}
+
+// redirectClient returns a copy of client that passes redirects to URLs
+// that aren't http or https to redirect, and stops at them with a
+// *redirectError for the source it maps them to. Other redirects are
+// checked by the client's own CheckRedirect.
+func redirectClient(client *http.Client, redirect func(from, to *url.URL) (string, error)) *http.Client {
+	check := client.CheckRedirect
+
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if req.URL.Scheme == "http" || req.URL.Scheme == "https" {
+			if check != nil {
+				return check(req, via)
+			}
+			if len(via) >= 10 {
+				// The same limit as http.Client's default policy
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		}
+
+		source, err := redirect(via[len(via)-1].URL, req.URL)
+		if err != nil {
+			return fmt.Errorf("redirect to %s refused: %s", redactURLCredentials(req.URL.String()), err)
+		}
+		return &redirectError{source: source}
+	}
+	return &c
+}
+
+// redirectHostError is the error that a redirect, or a source returned by
+// the terraform-get protocol, fails with when the getter's RedirectScope
+// and RedirectHosts don't allow its host.
+func redirectHostError(to string) error {
+	return &AbortError{
+		Reason: AbortPolicy,
+		Err:    fmt.Errorf("redirect to %s isn't allowed", redactURLCredentials(to)),
+	}
+}
+
+// redirectAllowed returns whether the URL from may send downloads on to the
+// URL to, as the scope and hosts say. Hosts are host names, which also
+// match their subdomains if they start with a ".".
+func redirectAllowed(scope RedirectScope, hosts []string, from, to *url.URL) bool {
+	host := strings.ToLower(strings.TrimSuffix(to.Hostname(), "."))
+	for _, h := range hosts {
+		h = strings.ToLower(h)
+		if host == h || (strings.HasPrefix(h, ".") && strings.HasSuffix(host, h)) {
+			return true
+		}
+	}
+
+	switch scope {
+	case RedirectAnyHost:
+		return true
+	case RedirectSameOrigin:
+		return from.Scheme == to.Scheme && origin(from) == origin(to)
+	case RedirectSameDomain:
+		domain := registrableDomain(from.Hostname())
+		return host != "" && (host == domain || strings.HasSuffix(host, "."+domain))
+	}
+
+	return false
+}
+
+// origin returns the host and port of u, with the default port of its
+// scheme if it has none.
+func origin(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+
+	return net.JoinHostPort(strings.ToLower(strings.TrimSuffix(u.Hostname(), ".")), port)
+}
+
+// registrableDomain returns the domain of host that can be registered, such
+// as "example.co.uk" for "www.example.co.uk", or host itself if it is an IP
+// address or has no such domain.
+func registrableDomain(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+
+	return domain
+}
+
+// hostsClient returns a copy of client that refuses redirects to http and
+// https URLs that the getter's RedirectScope and RedirectHosts don't allow,
+// relative to the URL that was first requested.
+func (g *HttpGetter) hostsClient(client *http.Client) *http.Client {
+	check := client.CheckRedirect
+
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if req.URL.Scheme == "http" || req.URL.Scheme == "https" {
+			if !redirectAllowed(g.RedirectScope, g.RedirectHosts, via[0].URL, req.URL) {
+				return redirectHostError(req.URL.String())
+			}
+		}
+		if check != nil {
+			return check(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &c
+}
+
+// checkSourceHost returns an error if the getter's RedirectScope and
+// RedirectHosts don't allow the URL u to send downloads on to source, a
+// source returned by the terraform-get protocol or a redirect mapped by
+// Redirect. Sources without a host, such as local files, are only allowed
+// with RedirectAnyHost.
+func (g *HttpGetter) checkSourceHost(u *url.URL, source string) error {
+	if g.RedirectScope == RedirectAnyHost {
+		return nil
+	}
+
+	var pwd string
+	detectors := Detectors
+	if g.client != nil {
+		pwd = g.client.Pwd
+		if g.client.Detectors != nil {
+			detectors = g.client.Detectors
+		}
+	}
+	detected, _, err := detect(source, pwd, detectors)
+	if err != nil {
+		return err
+	}
+	_, detected = getForcedGetter(detected)
+	detected, _ = SourceDirSubdir(detected)
+	to, err := url.Parse(detected)
+	if err != nil || to.Hostname() == "" ||
+		!redirectAllowed(g.RedirectScope, g.RedirectHosts, u, to) {
+		return redirectHostError(source)
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package getter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// httpPartial is where a file download is kept until it is complete, so
+// that it can be resumed if it is interrupted: the bytes downloaded so far
+// are at path, the destination with ".part" appended, and the validators
+// of the response they came from at path with ".json" appended.
+type httpPartial struct {
+	path string
+}
+
+// httpPartialMeta is the metadata of a partial download.
+type httpPartialMeta struct {
+	// ETag and LastModified are the headers the source was served with,
+	// only one of which is set: a strong ETag if there was one, or else
+	// Last-Modified.
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+
+	// Size is the complete length of the source.
+	Size int64 `json:"size"`
+}
+
+// partialDownload returns where the download of a file to dst is kept
+// until it is complete.
+func partialDownload(dst string) *httpPartial {
+	return &httpPartial{path: dst + ".part"}
+}
+
+// offset returns the number of bytes of the partial download that can be
+// resumed, and its metadata, or 0 and nil if there are none. Only the
+// bytes of downloads whose response had a validator to check that the
+// source hasn't changed since, and that aren't already complete, can be.
+func (p *httpPartial) offset() (int64, *httpPartialMeta) {
+	fi, err := os.Lstat(p.path)
+	if err != nil || !fi.Mode().IsRegular() || fi.Size() == 0 {
+		return 0, nil
+	}
+	data, err := ioutil.ReadFile(p.path + ".json")
+	if err != nil {
+		return 0, nil
+	}
+
+	var m httpPartialMeta
+	if err := json.Unmarshal(data, &m); err != nil {
+		// A corrupt download is started again
+		return 0, nil
+	}
+	if m.ETag == "" && m.LastModified == "" || fi.Size() >= m.Size {
+		return 0, nil
+	}
+
+	return fi.Size(), &m
+}
+
+// header returns the headers that ask for the rest of the source, starting
+// at offset, only if it hasn't changed since m.
+func (m *httpPartialMeta) header(offset int64) http.Header {
+	header := make(http.Header)
+	header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	if m.ETag != "" {
+		header.Set("If-Range", m.ETag)
+	} else {
+		header.Set("If-Range", m.LastModified)
+	}
+
+	return header
+}
+
+// resumes returns true if resp is the rest of the source, starting at
+// offset, of the partial download m. Not every server honors If-Range, so
+// the response must have the same validator and length as well as the
+// range that was asked for.
+func (m *httpPartialMeta) resumes(resp *http.Response, offset int64) bool {
+	if resp.StatusCode != http.StatusPartialContent {
+		return false
+	}
+	start, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+	if !ok || start != offset || total != m.Size {
+		return false
+	}
+	if m.ETag != "" {
+		return resp.Header.Get("ETag") == m.ETag
+	}
+
+	return resp.Header.Get("Last-Modified") == m.LastModified
+}
+
+// start records the validator of resp, all of the source of a download,
+// so that the download can be resumed if it is interrupted. A response
+// without a strong ETag or Last-Modified header, or without a length,
+// can't be resumed, so nothing is recorded for it.
+func (p *httpPartial) start(resp *http.Response) error {
+	m := httpPartialMeta{Size: resp.ContentLength}
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		m.ETag = etag
+	} else {
+		m.LastModified = resp.Header.Get("Last-Modified")
+	}
+	if m.ETag == "" && m.LastModified == "" || m.Size <= 0 {
+		return p.remove()
+	}
+
+	data, err := json.Marshal(&m)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p.path+".json", data, 0644)
+}
+
+// finish moves the complete download into place at dst.
+func (p *httpPartial) finish(dst string) error {
+	if err := os.Rename(p.path, dst); err != nil {
+		return err
+	}
+
+	return p.remove()
+}
+
+// remove removes the metadata of the partial download.
+func (p *httpPartial) remove() error {
+	if err := os.Remove(p.path + ".json"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
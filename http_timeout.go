@@ -0,0 +1,118 @@
+package getter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// timeoutClient returns a copy of client whose requests fail once the
+// server has sent nothing for timeout, whether they are waiting for a
+// response or reading its body, so that a server that stops responding
+// can't hang a download forever however large it is. Request bodies being
+// sent count as progress too.
+func timeoutClient(client *http.Client, timeout time.Duration) *http.Client {
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	c := *client
+	c.Transport = &timeoutTransport{transport: transport, timeout: timeout}
+	return &c
+}
+
+// timeoutTransport is the transport of a timeoutClient.
+type timeoutTransport struct {
+	transport http.RoundTripper
+	timeout   time.Duration
+}
+
+func (t *timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	s := &stallTimer{host: req.URL.Host, timeout: t.timeout, cancel: cancel}
+	s.timer = time.AfterFunc(t.timeout, s.fire)
+
+	req = req.WithContext(ctx)
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = &stallBody{ReadCloser: req.Body, stall: s, keep: true}
+	}
+
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		s.stop()
+		return nil, s.wrap(err)
+	}
+
+	s.reset()
+	resp.Body = &stallBody{ReadCloser: resp.Body, stall: s}
+	return resp, nil
+}
+
+// stallTimer cancels a request once it has made no progress for timeout.
+type stallTimer struct {
+	host    string
+	timeout time.Duration
+	cancel  context.CancelFunc
+	timer   *time.Timer
+	fired   int32
+}
+
+func (s *stallTimer) fire() {
+	atomic.StoreInt32(&s.fired, 1)
+	s.cancel()
+}
+
+// reset restarts the timeout, after the request made progress.
+func (s *stallTimer) reset() {
+	s.timer.Reset(s.timeout)
+}
+
+// stop stops the timer and releases the request's context.
+func (s *stallTimer) stop() {
+	s.timer.Stop()
+	s.cancel()
+}
+
+// wrap returns the error that the request failed with, saying that it
+// timed out if that is why.
+func (s *stallTimer) wrap(err error) error {
+	if atomic.LoadInt32(&s.fired) == 0 {
+		return err
+	}
+
+	return fmt.Errorf("%s sent nothing for %s: %w", s.host, s.timeout, context.DeadlineExceeded)
+}
+
+// stallBody restarts the timeout of a request as its body is read. Closing
+// the response body releases the request, but closing the request body,
+// which the transport does once it has been sent, doesn't.
+type stallBody struct {
+	io.ReadCloser
+	stall *stallTimer
+	keep  bool
+}
+
+func (b *stallBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.stall.reset()
+	}
+	if err != nil && err != io.EOF {
+		err = b.stall.wrap(err)
+	}
+
+	return n, err
+}
+
+func (b *stallBody) Close() error {
+	err := b.ReadCloser.Close()
+	if !b.keep {
+		b.stall.stop()
+	}
+
+	return err
+}
@@ -0,0 +1,118 @@
+package getter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// tlsParams are the query parameters of an HTTP source that configure
+// TLS for its requests: sslcainfo, the path of a PEM file of CAs to trust,
+// and insecure, to skip verifying certificates. They are removed from the
+// URL that requests are sent to.
+type tlsParams struct {
+	caInfo   string
+	insecure bool
+}
+
+// splitTLSParams returns the TLS parameters of u, and u without them.
+func splitTLSParams(u *url.URL) (tlsParams, *url.URL, error) {
+	var p tlsParams
+	q := u.Query()
+	if _, ok := q["sslcainfo"]; !ok {
+		if _, ok := q["insecure"]; !ok {
+			return p, u, nil
+		}
+	}
+
+	p.caInfo = q.Get("sslcainfo")
+	if v := q.Get("insecure"); v != "" {
+		insecure, err := strconv.ParseBool(v)
+		if err != nil {
+			return p, nil, fmt.Errorf("invalid insecure parameter: %s", v)
+		}
+		p.insecure = insecure
+	}
+	q.Del("sslcainfo")
+	q.Del("insecure")
+
+	stripped := *u
+	stripped.RawQuery = q.Encode()
+	return p, &stripped, nil
+}
+
+// tlsClient returns a copy of client with the getter's TLSConfig, CACerts
+// and InsecureSkipVerify, and the TLS parameters p of a source, applied to
+// its transport. The client is returned as it is if there are none, and it
+// is an error if there are and its transport isn't an *http.Transport.
+func (g *HttpGetter) tlsClient(client *http.Client, p tlsParams) (*http.Client, error) {
+	if g.TLSConfig == nil && len(g.CACerts) == 0 && !g.InsecureSkipVerify && p == (tlsParams{}) {
+		return client, nil
+	}
+
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	t, ok := transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf(
+			"TLS options require the client's transport to be an *http.Transport, got %T", transport)
+	}
+
+	t = t.Clone()
+	config := t.TLSClientConfig
+	if g.TLSConfig != nil {
+		config = g.TLSConfig
+	}
+	if config == nil {
+		config = new(tls.Config)
+	}
+	config = config.Clone()
+
+	if len(g.CACerts) > 0 || p.caInfo != "" {
+		pool, err := rootPool(config.RootCAs)
+		if err != nil {
+			return nil, err
+		}
+		if len(g.CACerts) > 0 && !pool.AppendCertsFromPEM(g.CACerts) {
+			return nil, fmt.Errorf("CACerts has no PEM encoded certificates")
+		}
+		if p.caInfo != "" {
+			pem, err := ioutil.ReadFile(p.caInfo)
+			if err != nil {
+				return nil, fmt.Errorf("error reading sslcainfo: %s", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("sslcainfo %s has no PEM encoded certificates", p.caInfo)
+			}
+		}
+		config.RootCAs = pool
+	}
+	if g.InsecureSkipVerify || p.insecure {
+		config.InsecureSkipVerify = true
+	}
+	t.TLSClientConfig = config
+
+	c := *client
+	c.Transport = t
+	return &c, nil
+}
+
+// rootPool returns a copy of roots, or of the system's roots if it is nil,
+// that more CAs can be added to.
+func rootPool(roots *x509.CertPool) (*x509.CertPool, error) {
+	if roots != nil {
+		return roots.Clone(), nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		return nil, fmt.Errorf("error loading the system's CAs: %s", err)
+	}
+	return pool, nil
+}
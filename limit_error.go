@@ -0,0 +1,27 @@
+package getter
+
+import "fmt"
+
+// LimitExceededError is returned by a decompressor that would extract more
+// from an archive than its DecompressLimits allow. Whatever was extracted
+// before the limit was reached is left in the destination.
+type LimitExceededError struct {
+	// Limit is the name of the limit that was exceeded: "MaxBytes",
+	// "MaxFiles", "MaxFileSize" or "MaxDepth".
+	Limit string
+
+	// Max is the value of the limit.
+	Max int64
+
+	// Entry is the archive entry that exceeded the limit, or "" for an
+	// archive of a single file.
+	Entry string
+}
+
+func (e *LimitExceededError) Error() string {
+	if e.Entry == "" {
+		return fmt.Sprintf("archive exceeds the %s limit of %d", e.Limit, e.Max)
+	}
+
+	return fmt.Sprintf("archive exceeds the %s limit of %d at entry: %s", e.Limit, e.Max, e.Entry)
+}
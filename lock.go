@@ -0,0 +1,106 @@
+package getter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockPollInterval is how often a lock that is held by another process is
+// tried again.
+const lockPollInterval = 50 * time.Millisecond
+
+// fileLock is an advisory lock held on a lock file.
+type fileLock struct {
+	f *os.File
+}
+
+// lockPath takes an exclusive advisory lock on path, using the file at
+// path with ".lock" appended, which is left in place once unlocked. If
+// another process holds the lock it waits for up to timeout, or forever
+// if timeout is zero, and doesn't wait at all if timeout is negative.
+func lockPath(path string, timeout time.Duration) (*fileLock, error) {
+	return lockFile(path+".lock", true, timeout)
+}
+
+// refPath takes a reference to path, a shared advisory lock on the file at
+// path with ".ref" appended, which a CacheGC doesn't remove path while any
+// process holds. It waits for the lock like lockPath.
+func refPath(path string, timeout time.Duration) (*fileLock, error) {
+	return lockFile(path+".ref", false, timeout)
+}
+
+// lockFile takes a lock on the lock file name, waiting for it as lockPath
+// does. The lock file's modification time is set to when it was locked,
+// which is when a CacheGC takes what it locks to have last been used.
+func lockFile(name string, exclusive bool, timeout time.Duration) (*fileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	for {
+		l, err := tryLock(name, exclusive)
+		if err != nil {
+			return nil, err
+		}
+		if l != nil {
+			now := time.Now()
+			os.Chtimes(name, now, now)
+			return l, nil
+		}
+
+		if timeout < 0 || (timeout > 0 && time.Since(start) >= timeout) {
+			return nil, fmt.Errorf(
+				"timed out waiting for %s, which is locked by another process", name)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// tryLock takes a lock on the lock file name without waiting, returning
+// nil if another process holds it.
+func tryLock(name string, exclusive bool) (*fileLock, error) {
+	for {
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := tryLockFile(f, exclusive)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("error locking %s: %s", name, err)
+		}
+		if !ok {
+			f.Close()
+			return nil, nil
+		}
+
+		// A CacheGC removes lock files while it holds them, so if this one
+		// was removed before we got it, lock the one at its path instead.
+		fi, err := f.Stat()
+		if err == nil {
+			var cur os.FileInfo
+			cur, err = os.Stat(name)
+			if err == nil && os.SameFile(fi, cur) {
+				return &fileLock{f: f}, nil
+			}
+		}
+		unlockFile(f)
+		f.Close()
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+}
+
+// Unlock releases the lock.
+func (l *fileLock) Unlock() error {
+	err := unlockFile(l.f)
+	if err1 := l.f.Close(); err == nil {
+		err = err1
+	}
+
+	return err
+}
@@ -0,0 +1,27 @@
+// +build !windows,!solaris
+
+package getter
+
+import (
+	"os"
+	"syscall"
+)
+
+// tryLockFile takes an exclusive or shared lock on f without waiting,
+// returning false if another process holds a lock that conflicts with it.
+func tryLockFile(f *os.File, exclusive bool) (bool, error) {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,33 @@
+package getter
+
+import (
+	"os"
+	"syscall"
+)
+
+// Solaris and illumos don't have flock, so files are locked with fcntl and
+// fOFDSetLK, F_OFD_SETLK, which the syscall package doesn't define. Unlike
+// those of F_SETLK, its locks belong to the open file rather than to the
+// process, as those of flock do, so that the locks one process takes on
+// the same file conflict and closing one file doesn't release the others.
+const fOFDSetLK = 0x30
+
+// tryLockFile takes an exclusive or shared lock on f without waiting,
+// returning false if another process holds a lock that conflicts with it.
+func tryLockFile(f *os.File, exclusive bool) (bool, error) {
+	lk := syscall.Flock_t{Type: syscall.F_RDLCK}
+	if exclusive {
+		lk.Type = syscall.F_WRLCK
+	}
+	err := syscall.FcntlFlock(f.Fd(), fOFDSetLK, &lk)
+	if err == syscall.EAGAIN || err == syscall.EACCES {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func unlockFile(f *os.File) error {
+	lk := syscall.Flock_t{Type: syscall.F_UNLCK}
+	return syscall.FcntlFlock(f.Fd(), fOFDSetLK, &lk)
+}
@@ -0,0 +1,145 @@
+package getter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockPath(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	dst := filepath.Join(td, "a", "dst")
+
+	l, err := lockPath(dst, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Locks are per open file, so a second one is refused just as it
+	// would be for another process.
+	if _, err := lockPath(dst, -1); err == nil {
+		t.Fatal("should error")
+	}
+	start := time.Now()
+	if _, err := lockPath(dst, 100*time.Millisecond); err == nil {
+		t.Fatal("should error")
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Fatal("should wait for the timeout")
+	}
+
+	// It is released once unlocked
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		l.Unlock()
+	}()
+	l2, err := lockPath(dst, time.Second)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := l2.Unlock(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestClientGet_lock(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	dst := filepath.Join(td, "dst")
+
+	l, err := lockPath(dst, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	client := &Client{
+		Src:         testModule("basic"),
+		Dst:         dst,
+		Dir:         true,
+		Lock:        true,
+		LockTimeout: -1,
+	}
+	if err := client.Get(); err == nil {
+		t.Fatal("should error")
+	}
+
+	l.Unlock()
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "main.tf")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestLockPath_removed(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	dst := filepath.Join(td, "dst")
+
+	l, err := lockPath(dst, 0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	locked := make(chan *fileLock)
+	go func() {
+		l2, err := lockPath(dst, time.Second)
+		if err != nil {
+			t.Errorf("err: %s", err)
+		}
+		locked <- l2
+	}()
+
+	// Whoever is waiting for a lock file that is removed takes a new one
+	time.Sleep(100 * time.Millisecond)
+	if err := l.remove(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	l2 := <-locked
+	if l2 == nil {
+		t.FailNow()
+	}
+	defer l2.Unlock()
+
+	fi, err := os.Stat(dst + ".lock")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	held, err := l2.f.Stat()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !os.SameFile(fi, held) {
+		t.Fatal("should lock the file at the path")
+	}
+}
+
+func TestRefPath(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	dst := filepath.Join(td, "dst")
+
+	// References are shared with each other, but not with a CacheGC
+	r1, err := refPath(dst, -1)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	r2, err := refPath(dst, -1)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if l, err := tryLock(dst+".ref", true); err != nil || l != nil {
+		t.Fatalf("should be in use: %v", err)
+	}
+
+	r1.Unlock()
+	r2.Unlock()
+	l, err := tryLock(dst+".ref", true)
+	if err != nil || l == nil {
+		t.Fatalf("should be free: %v", err)
+	}
+	l.Unlock()
+}
@@ -0,0 +1,19 @@
+// +build !windows
+
+package getter
+
+import (
+	"os"
+)
+
+// remove removes the lock file and releases the lock. The file is removed
+// while it is still locked, so a process waiting for it finds that it is
+// gone and locks a new one.
+func (l *fileLock) remove() error {
+	err := os.Remove(l.f.Name())
+	if err1 := l.Unlock(); err == nil {
+		err = err1
+	}
+
+	return err
+}
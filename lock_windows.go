@@ -0,0 +1,67 @@
+// +build windows
+
+package getter
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	modkernel32      = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = modkernel32.NewProc("LockFileEx")
+	procUnlockFileEx = modkernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+
+	errorLockViolation syscall.Errno = 33
+)
+
+// tryLockFile takes an exclusive or shared lock on f without waiting,
+// returning false if another process holds a lock that conflicts with it.
+func tryLockFile(f *os.File, exclusive bool) (bool, error) {
+	flags := uintptr(lockfileFailImmediately)
+	if exclusive {
+		flags |= lockfileExclusiveLock
+	}
+
+	var ol syscall.Overlapped
+	r, _, err := procLockFileEx.Call(
+		f.Fd(),
+		flags,
+		0, 1, 0,
+		uintptr(unsafe.Pointer(&ol)))
+	if r != 0 {
+		return true, nil
+	}
+	if err == errorLockViolation {
+		return false, nil
+	}
+
+	return false, err
+}
+
+func unlockFile(f *os.File) error {
+	var ol syscall.Overlapped
+	r, _, err := procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&ol)))
+	if r == 0 {
+		return err
+	}
+
+	return nil
+}
+
+// remove releases the lock and removes the lock file. Windows doesn't
+// remove files that are open, so it is left in place if another process
+// has opened it to wait for the lock.
+func (l *fileLock) remove() error {
+	if err := l.Unlock(); err != nil {
+		return err
+	}
+
+	return os.Remove(l.f.Name())
+}
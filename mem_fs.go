@@ -0,0 +1,180 @@
+package getter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MemFS is a read-only file system held in memory, mapping the slash
+// separated path of each file to its contents, as returned by
+// Client.GetMemory. Directories are implied by the files in them, so there
+// are no empty directories.
+type MemFS map[string][]byte
+
+func (m MemFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if data, ok := m[name]; ok {
+		return &memFile{
+			Reader: bytes.NewReader(data),
+			info:   &memFileInfo{name: path.Base(name), size: int64(len(data))},
+		}, nil
+	}
+
+	entries := m.readDir(name)
+	if len(entries) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return &memDir{name: name, info: &memFileInfo{name: path.Base(name), dir: true}, entries: entries}, nil
+}
+
+// ReadFile implements fs.ReadFileFS, returning a copy of the contents of
+// the file so that the file system can't be changed through it.
+func (m MemFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	data, ok := m[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return append([]byte(nil), data...), nil
+}
+
+// readDir returns the entries of the directory name, sorted by name.
+func (m MemFS) readDir(name string) []fs.DirEntry {
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+
+	seen := make(map[string]bool)
+	var entries []fs.DirEntry
+	for p, data := range m {
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+
+		info := &memFileInfo{name: strings.TrimPrefix(p, prefix), size: int64(len(data))}
+		if idx := strings.Index(info.name, "/"); idx >= 0 {
+			info.name, info.size, info.dir = info.name[:idx], 0, true
+		}
+		if seen[info.name] {
+			continue
+		}
+		seen[info.name] = true
+		entries = append(entries, info)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries
+}
+
+// sub returns the files in the directory dir, which may be a glob pattern
+// like SubdirGlob takes, with paths relative to it.
+func (m MemFS) sub(dir string) (MemFS, error) {
+	matches, err := fs.Glob(m, path.Clean(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	// Only directories can be a subdir
+	var dirs []string
+	for _, match := range matches {
+		if _, ok := m[match]; !ok {
+			dirs = append(dirs, match)
+		}
+	}
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("subdir %q not found", dir)
+	}
+	if len(dirs) > 1 {
+		return nil, fmt.Errorf("subdir %q matches multiple paths", dir)
+	}
+
+	result := make(MemFS)
+	prefix := dirs[0] + "/"
+	for p, data := range m {
+		if strings.HasPrefix(p, prefix) {
+			result[strings.TrimPrefix(p, prefix)] = data
+		}
+	}
+
+	return result, nil
+}
+
+// memFile is a file opened from a MemFS.
+type memFile struct {
+	*bytes.Reader
+	info *memFileInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+// memDir is a directory opened from a MemFS.
+type memDir struct {
+	name    string
+	info    *memFileInfo
+	entries []fs.DirEntry
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fs.ErrInvalid}
+}
+
+func (d *memDir) Close() error { return nil }
+
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// memFileInfo describes the files and directories of a MemFS.
+type memFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i *memFileInfo) Name() string               { return i.name }
+func (i *memFileInfo) Size() int64                { return i.size }
+func (i *memFileInfo) ModTime() time.Time         { return time.Time{} }
+func (i *memFileInfo) IsDir() bool                { return i.dir }
+func (i *memFileInfo) Sys() interface{}           { return nil }
+func (i *memFileInfo) Type() fs.FileMode          { return i.Mode().Type() }
+func (i *memFileInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+func (i *memFileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0555
+	}
+
+	return 0444
+}
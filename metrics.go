@@ -0,0 +1,240 @@
+package getter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Metrics counts the downloads that Clients make with each getter, keyed
+// by the name it is registered under such as "http" or "git", so that
+// daemons embedding a Client can export them. It is set with the Client's
+// Metrics, and a single Metrics is meant to be shared by every Client in a
+// program.
+//
+// Metrics is an expvar.Var, so can be published with expvar.Publish, and
+// an http.Handler serving the counters in the Prometheus text format, so
+// can be mounted on a metrics server's /metrics path. Snapshot returns the
+// counters for exporting them any other way.
+type Metrics struct {
+	mu      sync.Mutex
+	getters map[string]*getterCounters
+}
+
+// GetterMetrics are the counters of a Metrics for one getter.
+type GetterMetrics struct {
+	// Active is how many downloads are in progress, and Downloads how
+	// many have been started.
+	Active    int64 `json:"active"`
+	Downloads int64 `json:"downloads"`
+
+	// Bytes is how many bytes have been read from sources. Sources that
+	// are downloaded by running another program, such as Git and
+	// Mercurial repositories, aren't counted.
+	Bytes int64 `json:"bytes"`
+
+	// Errors is how many downloads have failed, by the class of their
+	// error: "canceled", "checksum", "deadline", "network", "offline",
+	// "response", "unhealthy" or "other".
+	Errors map[string]int64 `json:"errors"`
+
+	// CacheHits is how many downloads, or parts of them, were served from
+	// a cache rather than the source: a PeerCache, an HTTP getter's
+	// CacheDir that the source said was current, or a Git getter's
+	// CacheDir mirror.
+	CacheHits int64 `json:"cache_hits"`
+}
+
+// getterCounters are the counters of a Metrics for one getter, which are
+// updated atomically apart from errors.
+type getterCounters struct {
+	active    int64
+	downloads int64
+	bytes     int64
+	cacheHits int64
+
+	// errors is guarded by the Metrics' mu.
+	errors map[string]int64
+}
+
+// Snapshot returns the current counters of each getter that has been used.
+func (m *Metrics) Snapshot() map[string]GetterMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[string]GetterMetrics, len(m.getters))
+	for name, c := range m.getters {
+		errs := make(map[string]int64, len(c.errors))
+		for class, n := range c.errors {
+			errs[class] = n
+		}
+		result[name] = GetterMetrics{
+			Active:    atomic.LoadInt64(&c.active),
+			Downloads: atomic.LoadInt64(&c.downloads),
+			Bytes:     atomic.LoadInt64(&c.bytes),
+			Errors:    errs,
+			CacheHits: atomic.LoadInt64(&c.cacheHits),
+		}
+	}
+
+	return result
+}
+
+// String returns the counters as JSON, for expvar.
+func (m *Metrics) String() string {
+	data, err := json.Marshal(m.Snapshot())
+	if err != nil {
+		return "{}"
+	}
+
+	return string(data)
+}
+
+// ServeHTTP writes the counters in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.writePrometheus(w)
+}
+
+func (m *Metrics) writePrometheus(w io.Writer) {
+	snapshot := m.Snapshot()
+	names := make([]string, 0, len(snapshot))
+	for name := range snapshot {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	metric := func(name, kind, help string, value func(GetterMetrics) int64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, getter := range names {
+			fmt.Fprintf(w, "%s{getter=%s} %d\n", name, strconv.Quote(getter), value(snapshot[getter]))
+		}
+	}
+	metric("getter_active_downloads", "gauge", "Downloads in progress.",
+		func(g GetterMetrics) int64 { return g.Active })
+	metric("getter_downloads_total", "counter", "Downloads started.",
+		func(g GetterMetrics) int64 { return g.Downloads })
+	metric("getter_bytes_total", "counter", "Bytes read from sources.",
+		func(g GetterMetrics) int64 { return g.Bytes })
+	metric("getter_cache_hits_total", "counter", "Downloads served from a cache.",
+		func(g GetterMetrics) int64 { return g.CacheHits })
+
+	fmt.Fprintf(w, "# HELP getter_errors_total Downloads failed, by class of error.\n")
+	fmt.Fprintf(w, "# TYPE getter_errors_total counter\n")
+	for _, getter := range names {
+		errs := snapshot[getter].Errors
+		classes := make([]string, 0, len(errs))
+		for class := range errs {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			fmt.Fprintf(w, "getter_errors_total{getter=%s,class=%s} %d\n",
+				strconv.Quote(getter), strconv.Quote(class), errs[class])
+		}
+	}
+}
+
+// counters returns the counters of the named getter, creating them if
+// this is the first time it is used.
+func (m *Metrics) counters(getter string) *getterCounters {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.getters == nil {
+		m.getters = make(map[string]*getterCounters)
+	}
+	c, ok := m.getters[getter]
+	if !ok {
+		c = &getterCounters{errors: make(map[string]int64)}
+		m.getters[getter] = c
+	}
+
+	return c
+}
+
+// start counts a download with the named getter as started and active,
+// returning a function to call with its result once it is done.
+func (m *Metrics) start(getter string) func(error) {
+	c := m.counters(getter)
+	atomic.AddInt64(&c.downloads, 1)
+	atomic.AddInt64(&c.active, 1)
+
+	return func(err error) {
+		atomic.AddInt64(&c.active, -1)
+		if err == nil {
+			return
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		c.errors[errorClass(err)]++
+	}
+}
+
+// errorClass returns the class that err is counted under by Metrics.
+func errorClass(err error) string {
+	var (
+		checksumErr  *ChecksumError
+		offlineErr   *OfflineError
+		responseErr  *BadResponseCodeError
+		unhealthyErr *HostUnhealthyError
+		netErr       net.Error
+	)
+	switch reason := AbortReasonOf(err); {
+	case reason == AbortCanceled, reason == AbortDeadline:
+		return string(reason)
+	case errors.As(err, &checksumErr):
+		return "checksum"
+	case errors.As(err, &offlineErr):
+		return "offline"
+	case errors.As(err, &unhealthyErr):
+		return "unhealthy"
+	case errors.As(err, &responseErr):
+		return "response"
+	case errors.As(err, &netErr):
+		return "network"
+	}
+
+	return "other"
+}
+
+// metricsReader counts the bytes read from a source.
+type metricsReader struct {
+	io.ReadCloser
+	bytes *int64
+}
+
+func (r *metricsReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	atomic.AddInt64(r.bytes, int64(n))
+	return n, err
+}
+
+// countBytes returns stream wrapped so that what is read from it is
+// counted by the Metrics of the client using the getter, if it has one.
+func (g *getter) countBytes(stream io.ReadCloser) io.ReadCloser {
+	if g.client == nil || g.client.Metrics == nil || g.client.metricsGetter == "" {
+		return stream
+	}
+
+	c := g.client.Metrics.counters(g.client.metricsGetter)
+	return &metricsReader{ReadCloser: stream, bytes: &c.bytes}
+}
+
+// cacheHit counts a cache hit by the Metrics of the client using the
+// getter, if it has one.
+func (g *getter) cacheHit() {
+	if g.client == nil || g.client.Metrics == nil || g.client.metricsGetter == "" {
+		return
+	}
+
+	atomic.AddInt64(&g.client.Metrics.counters(g.client.metricsGetter).cacheHits, 1)
+}
@@ -0,0 +1,103 @@
+package getter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/file" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("Hello\n"))
+	}))
+	defer server.Close()
+
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	metrics := new(Metrics)
+	get := func(src string) error {
+		client := &Client{
+			Src:     src,
+			Dst:     filepath.Join(dst, "file"),
+			Mode:    ClientModeFile,
+			Metrics: metrics,
+		}
+		return client.Get()
+	}
+	if err := get(server.URL + "/file"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := get(server.URL + "/missing"); err == nil {
+		t.Fatal("should error")
+	}
+	if err := get(server.URL + "/file?checksum=" + testChecksum("Goodbye\n")); err == nil {
+		t.Fatal("should error")
+	}
+
+	expected := GetterMetrics{
+		Downloads: 3,
+		Bytes:     12,
+		Errors:    map[string]int64{"checksum": 1, "response": 1},
+	}
+	actual := metrics.Snapshot()["http"]
+	if fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// The counters are JSON for expvar
+	var decoded map[string]GetterMetrics
+	if err := json.Unmarshal([]byte(metrics.String()), &decoded); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fmt.Sprint(decoded["http"]) != fmt.Sprint(expected) {
+		t.Fatalf("bad: %s", metrics.String())
+	}
+
+	// And are served in the Prometheus text format
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range []string{
+		`getter_active_downloads{getter="http"} 0`,
+		`getter_downloads_total{getter="http"} 3`,
+		`getter_bytes_total{getter="http"} 12`,
+		`getter_cache_hits_total{getter="http"} 0`,
+		`getter_errors_total{getter="http",class="checksum"} 1`,
+		`getter_errors_total{getter="http",class="response"} 1`,
+	} {
+		if !strings.Contains(rec.Body.String(), line+"\n") {
+			t.Fatalf("missing %s:\n%s", line, rec.Body.String())
+		}
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	cases := []struct {
+		Err   error
+		Class string
+	}{
+		{context.Canceled, "canceled"},
+		{&PartialError{Err: errDeadlineExceeded}, "deadline"},
+		{&ChecksumError{}, "checksum"},
+		{&OfflineError{}, "offline"},
+		{&HostUnhealthyError{}, "unhealthy"},
+		{fmt.Errorf("error downloading: %w", &BadResponseCodeError{Code: 404}), "response"},
+		{fmt.Errorf("nope"), "other"},
+	}
+
+	for _, tc := range cases {
+		if actual := errorClass(tc.Err); actual != tc.Class {
+			t.Fatalf("%v: bad: %s", tc.Err, actual)
+		}
+	}
+}
@@ -20,6 +20,21 @@ func addAuthFromNetrc(u *url.URL) error {
 		return nil
 	}
 
+	machine, err := netrcMachine(u.Host)
+	if err != nil || machine == nil {
+		return err
+	}
+
+	// Set the user info
+	u.User = url.UserPassword(machine.Login, machine.Password)
+	return nil
+}
+
+// netrcMachine returns the entry for host in the user's netrc file, or nil
+// if there isn't a netrc file or it has no entry for host. It is shared by
+// the getters that support netrc files, which use as much of the entry as
+// they can.
+func netrcMachine(host string) (*netrc.Machine, error) {
 	// Get the netrc file path
 	path := os.Getenv("NETRC")
 	if path == "" {
@@ -31,7 +46,7 @@ func addAuthFromNetrc(u *url.URL) error {
 		var err error
 		path, err = homedir.Expand("~/" + filename)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
@@ -39,29 +54,22 @@ func addAuthFromNetrc(u *url.URL) error {
 	if fi, err := os.Stat(path); err != nil {
 		// File doesn't exist, do nothing
 		if os.IsNotExist(err) {
-			return nil
+			return nil, nil
 		}
 
 		// Some other error!
-		return err
+		return nil, err
 	} else if fi.IsDir() {
 		// File is directory, ignore
-		return nil
+		return nil, nil
 	}
 
 	// Load up the netrc file
 	net, err := netrc.ParseFile(path)
 	if err != nil {
-		return fmt.Errorf("Error parsing netrc file at %q: %s", path, err)
-	}
-
-	machine := net.FindMachine(u.Host)
-	if machine == nil {
-		// Machine not found, no problem
-		return nil
+		return nil, fmt.Errorf("Error parsing netrc file at %q: %s", path, err)
 	}
 
-	// Set the user info
-	u.User = url.UserPassword(machine.Login, machine.Password)
-	return nil
+	// Machine not found is no problem
+	return net.FindMachine(host), nil
 }
@@ -61,3 +61,30 @@ func TestAddAuthFromNetrc_hasUsername(t *testing.T) {
 		t.Fatalf("Mismatch: %q != %q", actual, expected)
 	}
 }
+
+func TestNetrcMachine(t *testing.T) {
+	defer tempEnv(t, "NETRC", "./test-fixtures/netrc/basic")()
+
+	machine, err := netrcMachine("example.com")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if machine == nil || machine.Login != "foo" || machine.Password != "bar" {
+		t.Fatalf("bad: %#v", machine)
+	}
+
+	machine, err = netrcMachine("other.example.com")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if machine != nil {
+		t.Fatalf("bad: %#v", machine)
+	}
+
+	// A missing netrc file is no problem
+	defer tempEnv(t, "NETRC", "./test-fixtures/netrc/missing")()
+	machine, err = netrcMachine("example.com")
+	if err != nil || machine != nil {
+		t.Fatalf("bad: %#v, %v", machine, err)
+	}
+}
@@ -0,0 +1,298 @@
+package getter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// dockerHubConfigKey is the key of Docker Hub in the auths of a Docker
+// configuration.
+const dockerHubConfigKey = "https://index.docker.io/v1/"
+
+// dockerConfig is the part of a Docker config.json with credentials.
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth          string `json:"auth"`
+		Username      string `json:"username"`
+		Password      string `json:"password"`
+		IdentityToken string `json:"identitytoken"`
+	} `json:"auths"`
+	CredHelpers map[string]string `json:"credHelpers"`
+	CredsStore  string            `json:"credsStore"`
+}
+
+// dockerCredentials is a username and secret for a registry. If Username
+// is "<token>" the secret is an identity token to exchange for an access
+// token, rather than a password.
+type dockerCredentials struct {
+	Username string
+	Secret   string
+}
+
+// identityToken is the username of credentials that are identity tokens.
+const identityToken = "<token>"
+
+// registryCredentials returns the credentials for the registry in the
+// Docker configuration, or nil if there are none.
+func (c *ociClient) registryCredentials() (*dockerCredentials, error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "config.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var config dockerConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("error parsing Docker configuration: %s", err)
+	}
+
+	host := c.ref.Registry
+	if host == "registry-1.docker.io" {
+		host = dockerHubConfigKey
+	}
+	if helper, ok := config.CredHelpers[host]; ok {
+		return c.credentialHelper(helper, host)
+	}
+
+	for k, auth := range config.Auths {
+		if k != host && dockerConfigHost(k) != host {
+			continue
+		}
+
+		creds := &dockerCredentials{Username: auth.Username, Secret: auth.Password}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth for %s in Docker configuration", k)
+			}
+			idx := bytes.IndexByte(decoded, ':')
+			if idx < 0 {
+				return nil, fmt.Errorf("invalid auth for %s in Docker configuration", k)
+			}
+			creds.Username, creds.Secret = string(decoded[:idx]), string(decoded[idx+1:])
+		}
+		if auth.IdentityToken != "" {
+			creds.Username, creds.Secret = identityToken, auth.IdentityToken
+		}
+		if creds.Username != "" {
+			return creds, nil
+		}
+	}
+
+	if config.CredsStore != "" {
+		return c.credentialHelper(config.CredsStore, host)
+	}
+
+	return nil, nil
+}
+
+// dockerConfigHost returns the host of a key of the auths in a Docker
+// configuration, which may be a URL.
+func dockerConfigHost(k string) string {
+	if idx := strings.Index(k, "://"); idx >= 0 {
+		k = k[idx+3:]
+	}
+	if idx := strings.Index(k, "/"); idx >= 0 {
+		k = k[:idx]
+	}
+
+	return k
+}
+
+// credentialHelper returns the credentials for host from the Docker
+// credential helper with the given name, or nil if it has none.
+func (c *ociClient) credentialHelper(helper, host string) (*dockerCredentials, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(c.getter.Context(), "docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(host)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		out := strings.TrimSpace(stdout.String() + stderr.String())
+		if strings.Contains(out, "credentials not found") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error running docker-credential-%s: %s: %s", helper, err, out)
+	}
+
+	var creds dockerCredentials
+	if err := json.Unmarshal(stdout.Bytes(), &creds); err != nil {
+		return nil, fmt.Errorf("error parsing output of docker-credential-%s: %s", helper, err)
+	}
+	if creds.Username == "" && creds.Secret == "" {
+		return nil, nil
+	}
+
+	return &creds, nil
+}
+
+// authenticate returns the Authorization header to retry a request with,
+// given the challenge in the WWW-Authenticate header of the response.
+func (c *ociClient) authenticate(challenge string) (string, error) {
+	creds, err := c.registryCredentials()
+	if err != nil {
+		return "", err
+	}
+
+	scheme, params := parseAuthChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if creds == nil || creds.Username == identityToken {
+			return "", fmt.Errorf("%s requires credentials", c.ref.Registry)
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString(
+			[]byte(creds.Username+":"+creds.Secret)), nil
+	case "bearer":
+		token, err := c.token(params, creds)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	}
+
+	return "", fmt.Errorf("unsupported authentication from %s: %q", c.ref.Registry, challenge)
+}
+
+// token requests a bearer token from the token server in the challenge
+// parameters.
+func (c *ociClient) token(params map[string]string, creds *dockerCredentials) (string, error) {
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("%s asked for a token without a realm", c.ref.Registry)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + c.ref.Repository + ":pull"
+	}
+
+	var req *http.Request
+	var err error
+	if creds != nil && creds.Username == identityToken {
+		form := url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {creds.Secret},
+			"service":       {params["service"]},
+			"scope":         {scope},
+			"client_id":     {"go-getter"},
+		}
+		req, err = http.NewRequestWithContext(
+			c.getter.Context(), "POST", realm, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header = c.getter.header()
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		u, perr := url.Parse(realm)
+		if perr != nil {
+			return "", fmt.Errorf("invalid token realm from %s: %s", c.ref.Registry, perr)
+		}
+		q := u.Query()
+		if params["service"] != "" {
+			q.Set("service", params["service"])
+		}
+		q.Set("scope", scope)
+		u.RawQuery = q.Encode()
+
+		req, err = http.NewRequestWithContext(c.getter.Context(), "GET", u.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header = c.getter.header()
+		if creds != nil {
+			req.SetBasicAuth(creds.Username, creds.Secret)
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error requesting a token for %s: %w", c.ref.Registry, &BadResponseCodeError{Code: resp.StatusCode})
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("error parsing token for %s: %s", c.ref.Registry, err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+
+	return "", fmt.Errorf("no token was returned for %s", c.ref.Registry)
+}
+
+// parseAuthChallenge parses a WWW-Authenticate header such as
+// `Bearer realm="https://auth.example.com/token",service="registry"`
+// into its scheme and parameters.
+func parseAuthChallenge(v string) (string, map[string]string) {
+	v = strings.TrimSpace(v)
+	scheme := v
+	rest := ""
+	if idx := strings.IndexByte(v, ' '); idx >= 0 {
+		scheme, rest = v[:idx], v[idx+1:]
+	}
+
+	params := make(map[string]string)
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " ,")
+		idx := strings.IndexByte(rest, '=')
+		if idx < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:idx]))
+		rest = rest[idx+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			rest = rest[1:]
+			var b strings.Builder
+			for len(rest) > 0 && rest[0] != '"' {
+				if rest[0] == '\\' && len(rest) > 1 {
+					rest = rest[1:]
+				}
+				b.WriteByte(rest[0])
+				rest = rest[1:]
+			}
+			value = b.String()
+			if len(rest) > 0 {
+				rest = rest[1:]
+			}
+		} else if idx := strings.IndexByte(rest, ','); idx >= 0 {
+			value, rest = rest[:idx], rest[idx:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[key] = strings.TrimSpace(value)
+	}
+
+	return scheme, params
+}
@@ -0,0 +1,25 @@
+package getter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OfflineError is returned by an offline Client for sources that can't be
+// got without network access. See Client.Offline.
+type OfflineError struct {
+	// Sources are the sources that are missing, with any credentials in
+	// them redacted.
+	Sources []string
+}
+
+func (e *OfflineError) Error() string {
+	if len(e.Sources) == 1 {
+		return fmt.Sprintf("source not available offline: %s", e.Sources[0])
+	}
+
+	return fmt.Sprintf(
+		"%d sources not available offline: %s",
+		len(e.Sources),
+		strings.Join(e.Sources, ", "))
+}
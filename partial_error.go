@@ -0,0 +1,40 @@
+package getter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PartialError is returned by getters that download many files when they
+// stop part way through, for example because the Client's Deadline
+// passed. It describes what was downloaded so that callers can decide how
+// to resume.
+type PartialError struct {
+	// Completed is the list of files or sources that were downloaded.
+	Completed []string
+
+	// Remaining is the list of files or sources that were known about but
+	// not downloaded. There may be more if the getter hadn't finished
+	// listing what to download.
+	Remaining []string
+
+	// Err is the reason the download stopped.
+	Err error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf(
+		"download incomplete (%d completed, %d remaining: %s): %s",
+		len(e.Completed),
+		len(e.Remaining),
+		strings.Join(e.Remaining, ", "),
+		e.Err)
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+// errDeadlineExceeded is the PartialError reason used when a Client's
+// Deadline passes.
+var errDeadlineExceeded = fmt.Errorf("deadline exceeded")
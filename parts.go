@@ -0,0 +1,315 @@
+package getter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	urlhelper "github.com/hashicorp/go-getter/helper/url"
+	"github.com/hashicorp/go-safetemp"
+)
+
+// PartsManifest describes a file that is downloaded in parts, each of which
+// is verified on its own before they are concatenated in order. It is the
+// decoded form of a JSON document of the following form:
+//
+//	{
+//	  "source": "https://example.com/big.iso",
+//	  "checksum": "sha256:...",
+//	  "parts": [
+//	    {"size": 67108864, "checksum": "sha256:..."},
+//	    {"source": "./big.iso.part2", "size": 1024, "checksum": "sha256:..."}
+//	  ]
+//	}
+//
+// A part without a source is that many bytes of the manifest's source,
+// following the parts before it, and is downloaded with an HTTP range
+// request. Other parts are downloaded from their own source, which may be
+// any supported one, with sources beginning with "./" or "../" resolved
+// relative to the manifest's source.
+type PartsManifest struct {
+	// Source is the URL of the whole file. It is only required if a part
+	// doesn't have a source of its own.
+	Source string `json:"source"`
+
+	// Checksum, if set, is the checksum of the whole file, in the same
+	// form as the checksum query parameter.
+	Checksum string `json:"checksum"`
+
+	// Parts are the parts of the file, in order.
+	Parts []Part `json:"parts"`
+}
+
+// Part is a single part of a PartsManifest.
+type Part struct {
+	// Source is where the part is downloaded from. If it is empty, the
+	// part is a range of the manifest's source.
+	Source string `json:"source"`
+
+	// Size is the size of the part in bytes, and must be positive.
+	Size int64 `json:"size"`
+
+	// Checksum is the checksum of the part, in the same form as the
+	// checksum query parameter.
+	Checksum string `json:"checksum"`
+}
+
+// ParsePartsManifest decodes and validates the JSON parts manifest read
+// from r.
+func ParsePartsManifest(r io.Reader) (*PartsManifest, error) {
+	var m PartsManifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, fmt.Errorf("error parsing parts manifest: %s", err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// validate checks that the manifest describes a file that can be got.
+func (m *PartsManifest) validate() error {
+	if len(m.Parts) == 0 {
+		return fmt.Errorf("parts manifest has no parts")
+	}
+	if m.Checksum != "" {
+		if _, _, err := parseChecksum(m.Checksum); err != nil {
+			return fmt.Errorf("parts manifest checksum: %s", err)
+		}
+	}
+
+	for i, p := range m.Parts {
+		if p.Source == "" && m.Source == "" {
+			return fmt.Errorf("part %d has no source, and neither does the manifest", i)
+		}
+		if p.Size <= 0 {
+			return fmt.Errorf("part %d must have a positive size", i)
+		}
+		if p.Checksum == "" {
+			return fmt.Errorf("part %d is missing a checksum", i)
+		}
+		if _, _, err := parseChecksum(p.Checksum); err != nil {
+			return fmt.Errorf("part %d checksum: %s", i, err)
+		}
+	}
+
+	return nil
+}
+
+// GetParts downloads the parts of the file described by m, up to parallel
+// of them at a time, and writes them in order to the Client's Dst once
+// every part, and then the whole file, has been verified. Dst is left
+// untouched if any part fails.
+//
+// The Client's Src, Mode and Dir are ignored. Its Lock, Ctx, Offline and
+// the settings of its HTTP getter apply to the download of every part.
+// Parts with a source of their own are got with the Client's Getters, so
+// custom Getters must be safe for concurrent use if parallel is above 1.
+func (c *Client) GetParts(m *PartsManifest, parallel int) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	if parallel < 1 {
+		parallel = 1
+	}
+	c = c.withRetryBudget()
+
+	if c.Lock {
+		l, err := lockPath(c.Dst, c.LockTimeout)
+		if err != nil {
+			return err
+		}
+		defer l.Unlock()
+	}
+
+	td, tdcloser, err := safetemp.Dir("", "getter")
+	if err != nil {
+		return err
+	}
+	defer tdcloser.Close()
+
+	// The first part to fail stops the rest.
+	ctx := c.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	paths := make([]string, len(m.Parts))
+	errs := make([]error, len(m.Parts))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	var offset int64
+	for i, p := range m.Parts {
+		paths[i] = filepath.Join(td, fmt.Sprintf("part%d", i))
+
+		wg.Add(1)
+		go func(i int, p Part, offset int64) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+
+			if err := c.getPart(ctx, paths[i], m, p, offset); err != nil {
+				errs[i] = fmt.Errorf("error downloading part %d: %s", i, err)
+				cancel()
+			}
+		}(i, p, offset)
+		offset += p.Size
+	}
+	wg.Wait()
+
+	// Report the part that failed rather than those it stopped
+	for _, err := range errs {
+		if err != nil && err != context.Canceled {
+			return err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.joinParts(paths, m.Checksum)
+}
+
+// getPart downloads and verifies the part p, which starts offset bytes
+// into the file, to dst.
+func (c *Client) getPart(ctx context.Context, dst string, m *PartsManifest, p Part, offset int64) error {
+	if p.Source != "" {
+		src := p.Source
+		if m.Source != "" && (strings.HasPrefix(src, "./") || strings.HasPrefix(src, "../")) {
+			base, err := url.Parse(m.Source)
+			if err != nil {
+				return err
+			}
+			ref, err := url.Parse(src)
+			if err != nil {
+				return err
+			}
+			src = base.ResolveReference(ref).String()
+		}
+
+		// Parts are always plain files, whatever they are named.
+		client := *c
+		client.Src = src
+		client.Dst = dst
+		client.Mode = ClientModeFile
+		client.Ctx = ctx
+		client.Lock = false
+		client.Inflight = nil
+		client.PeerCache = nil
+		client.Decompressors = map[string]Decompressor{}
+		if err := client.Get(); err != nil {
+			return err
+		}
+	} else {
+		if c.Offline {
+			return &OfflineError{Sources: []string{redactURLCredentials(m.Source)}}
+		}
+
+		u, err := urlhelper.Parse(m.Source)
+		if err != nil {
+			return err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("parts without a source require an HTTP source, got '%s'", u.Scheme)
+		}
+
+		getters := c.Getters
+		if getters == nil {
+			getters = defaultGetters()
+		}
+		hg, ok := getters[u.Scheme].(*HttpGetter)
+		if !ok {
+			hg = &HttpGetter{Netrc: true}
+		}
+
+		// Use a copy of the getter of its own, as a client's Get would,
+		// so that parts can be got in parallel
+		client := *c
+		client.Ctx = ctx
+		g := getterForClient(hg, &client).(*HttpGetter)
+		if err := g.getRange(dst, u, offset, p.Size); err != nil {
+			return err
+		}
+	}
+
+	fi, err := os.Stat(dst)
+	if err != nil {
+		return err
+	}
+	if fi.Size() != p.Size {
+		return fmt.Errorf("expected %d bytes, got %d", p.Size, fi.Size())
+	}
+
+	h, v, err := parseChecksum(p.Checksum)
+	if err != nil {
+		return err
+	}
+	return checksum(dst, h, v)
+}
+
+// joinParts concatenates the files at paths into the Client's Dst, after
+// checking the result against the checksum v if it is set.
+func (c *Client) joinParts(paths []string, v string) error {
+	if err := os.MkdirAll(filepath.Dir(c.Dst), 0755); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(c.Dst), ".getter")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	for _, path := range paths {
+		if err := appendFile(f, path); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if v != "" {
+		h, value, err := parseChecksum(v)
+		if err != nil {
+			return err
+		}
+		if err := checksum(tmp, h, value); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(tmp, c.Dst)
+}
+
+// appendFile copies the contents of the file at path to the end of f.
+func appendFile(f *os.File, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	_, err = io.Copy(f, src)
+	return err
+}
@@ -0,0 +1,183 @@
+package getter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPartsContents = "Hello, parts of the world\n"
+
+func TestClient_GetParts(t *testing.T) {
+	server := testPartsServer(t, true)
+	defer server.Close()
+
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	m, err := ParsePartsManifest(strings.NewReader(`{
+		"source": "` + server.URL + `/file",
+		"checksum": "` + testChecksum(testPartsContents) + `",
+		"parts": [
+			{"size": 5, "checksum": "` + testChecksum("Hello") + `"},
+			{"size": 7, "checksum": "` + testChecksum(", parts") + `"},
+			{"source": "./second", "size": 14, "checksum": "` + testChecksum(" of the world\n") + `"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	client := &Client{Dst: dst}
+	if err := client.GetParts(m, 2); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, testPartsContents)
+}
+
+func TestClient_GetParts_getter(t *testing.T) {
+	server := testPartsServer(t, true)
+	server.Close()
+	server = httptest.NewTLSServer(server.Config.Handler)
+	defer server.Close()
+
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	// All of the parts are got with the settings of the client's getter
+	m := &PartsManifest{
+		Source:   server.URL + "/file",
+		Checksum: testChecksum(testPartsContents),
+		Parts: []Part{
+			{Size: 5, Checksum: testChecksum("Hello")},
+			{Size: 7, Checksum: testChecksum(", parts")},
+			{Source: "./second", Size: 14, Checksum: testChecksum(" of the world\n")},
+		},
+	}
+	client := &Client{
+		Dst:     dst,
+		Getters: map[string]Getter{"https": &HttpGetter{InsecureSkipVerify: true}},
+	}
+	if err := client.GetParts(m, 3); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, testPartsContents)
+}
+
+func TestClient_GetParts_badChecksum(t *testing.T) {
+	server := testPartsServer(t, true)
+	defer server.Close()
+
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	// A part that doesn't match
+	m := &PartsManifest{
+		Source: server.URL + "/file",
+		Parts: []Part{
+			{Size: 5, Checksum: testChecksum("Hello")},
+			{Size: 21, Checksum: testChecksum("something else")},
+		},
+	}
+	client := &Client{Dst: dst}
+	err := client.GetParts(m, 1)
+	if err == nil || !strings.Contains(err.Error(), "part 1") {
+		t.Fatalf("bad: %v", err)
+	}
+	if _, err := os.Stat(dst); err == nil {
+		t.Fatal("destination shouldn't be written")
+	}
+
+	// Parts that match, but not the whole file
+	m.Checksum = testChecksum("something else")
+	m.Parts[1].Checksum = testChecksum(testPartsContents[5:])
+	if err := client.GetParts(m, 1); err == nil {
+		t.Fatal("should error")
+	}
+	if _, err := os.Stat(dst); err == nil {
+		t.Fatal("destination shouldn't be written")
+	}
+}
+
+func TestClient_GetParts_noRanges(t *testing.T) {
+	server := testPartsServer(t, false)
+	defer server.Close()
+
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	m := &PartsManifest{
+		Source: server.URL + "/file",
+		Parts: []Part{
+			{Size: 5, Checksum: testChecksum("Hello")},
+		},
+	}
+	client := &Client{Dst: dst}
+	err := client.GetParts(m, 1)
+	if err == nil || !strings.Contains(err.Error(), "range") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestParsePartsManifest(t *testing.T) {
+	sum := testChecksum("Hello")
+	cases := []struct {
+		Input string
+		Err   string
+	}{
+		{`{"source": "http://example.com/file", "parts": [{"size": 5, "checksum": "` + sum + `"}]}`, ""},
+		{`{"parts": [{"source": "http://example.com/a", "size": 5, "checksum": "` + sum + `"}]}`, ""},
+		{`{"source": "http://example.com/file", "parts": []}`, "no parts"},
+		{`{"parts": [{"size": 5, "checksum": "` + sum + `"}]}`, "no source"},
+		{`{"source": "http://example.com/file", "parts": [{"checksum": "` + sum + `"}]}`, "positive size"},
+		{`{"source": "http://example.com/file", "parts": [{"size": 5}]}`, "missing a checksum"},
+		{`{"source": "http://example.com/file", "parts": [{"size": 5, "checksum": "nope:00"}]}`, "unsupported checksum type"},
+		{`{"source": "http://example.com/file", "checksum": "sha256:zz", "parts": [{"size": 5, "checksum": "` + sum + `"}]}`, "invalid checksum"},
+		{`{`, "error parsing"},
+	}
+
+	for _, tc := range cases {
+		_, err := ParsePartsManifest(strings.NewReader(tc.Input))
+		if tc.Err == "" {
+			if err != nil {
+				t.Fatalf("%s: err: %s", tc.Input, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.Err) {
+			t.Fatalf("%s: bad: %v", tc.Input, err)
+		}
+	}
+}
+
+// testChecksum returns the SHA256 checksum of s in the form of the checksum
+// query parameter.
+func testChecksum(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// testPartsServer serves testPartsContents at /file, with range requests if
+// ranges is true, and its last 14 bytes at /second.
+func testPartsServer(t *testing.T, ranges bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/file":
+			if !ranges {
+				w.Write([]byte(testPartsContents))
+				return
+			}
+			http.ServeContent(w, r, "file", time.Time{}, strings.NewReader(testPartsContents))
+		case "/second":
+			w.Write([]byte(testPartsContents[12:]))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
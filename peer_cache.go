@@ -0,0 +1,224 @@
+package getter
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// PeerCache is a cache shared between machines that allows a client to
+// fetch an artifact from a peer that has already downloaded it before
+// going to the origin.
+//
+// Artifacts are identified by their digest, in the same "type:value" form
+// as the checksum query parameter. As such a PeerCache is only consulted
+// for file downloads that specify a checksum, and anything fetched from a
+// peer is verified against that checksum before it is used.
+type PeerCache interface {
+	// Fetch downloads the artifact with the given digest into dst. It
+	// returns false if no peer has the artifact.
+	Fetch(dst, digest string) (bool, error)
+
+	// Advertise makes the artifact at path available to peers under the
+	// given digest.
+	Advertise(path, digest string) error
+}
+
+// HTTPPeerCache is an implementation of PeerCache that shares artifacts
+// between machines over HTTP.
+//
+// Advertised artifacts are stored in Dir, and HTTPPeerCache implements
+// http.Handler so that they can be served to other machines, for example
+// with http.ListenAndServe. Fetch copies the artifact from Dir if it is
+// there, and otherwise asks the peers that Discovery finds, if it is set,
+// and then each of the Peers in order for it. Artifacts can also be moved
+// between machines in bundles, see ExportBundle.
+type HTTPPeerCache struct {
+	// Dir is the directory where advertised artifacts are stored.
+	Dir string
+
+	// Peers is the list of base URLs of other machines serving an
+	// HTTPPeerCache, such as "http://10.0.0.2:8080".
+	Peers []string
+
+	// Discovery, if set, finds the other machines that have an artifact
+	// on the local network, see PeerDiscovery.
+	Discovery *PeerDiscovery
+
+	// Client is the http.Client to use for requests to peers.
+	// This defaults to a cleanhttp.DefaultClient if left unset.
+	Client *http.Client
+}
+
+// Fetch implements PeerCache.Fetch. Peers that can't be reached or that
+// don't have the artifact are skipped.
+func (c *HTTPPeerCache) Fetch(dst, digest string) (bool, error) {
+	p, err := peerCachePath(digest)
+	if err != nil {
+		return false, err
+	}
+
+	if c.Dir != "" {
+		f, err := os.Open(filepath.Join(c.Dir, filepath.FromSlash(p)))
+		if err == nil {
+			defer f.Close()
+			return true, writeFile(dst, f)
+		}
+		if !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+
+	client := c.Client
+	if client == nil {
+		client = httpClient
+	}
+
+	if c.Discovery != nil {
+		ok, err := c.Discovery.discover(digest, func(peer string) (bool, error) {
+			return c.fetchPeer(client, dst, strings.TrimSuffix(peer, "/")+"/"+p)
+		})
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+
+	for _, peer := range c.Peers {
+		ok, err := c.fetchPeer(client, dst, strings.TrimSuffix(peer, "/")+"/"+p)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Advertise implements PeerCache.Advertise by copying the artifact into
+// Dir where it will be served to peers.
+func (c *HTTPPeerCache) Advertise(src, digest string) error {
+	p, err := peerCachePath(digest)
+	if err != nil {
+		return err
+	}
+
+	dst := filepath.Join(c.Dir, filepath.FromSlash(p))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	srcF, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcF.Close()
+
+	// Write into a temporary file first so that peers never see a
+	// partially written artifact.
+	tmp := dst + ".tmp"
+	dstF, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dstF, srcF)
+	if err1 := dstF.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, dst)
+}
+
+// ServeHTTP serves the advertised artifacts to peers.
+func (c *HTTPPeerCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(path.Clean(r.URL.Path), "/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	p, err := peerCachePath(parts[0] + ":" + parts[1])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.ServeFile(w, r, filepath.Join(c.Dir, filepath.FromSlash(p)))
+}
+
+// fetchPeer downloads the artifact at the given URL into dst, returning
+// false if the peer doesn't have it or can't be reached.
+func (c *HTTPPeerCache) fetchPeer(client *http.Client, dst, u string) (bool, error) {
+	resp, err := client.Get(u)
+	if err != nil {
+		return false, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return false, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return false, err
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := io.Copy(f, resp.Body)
+	if err == nil && n < resp.ContentLength {
+		err = io.ErrShortWrite
+	}
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		// A peer failing part way through isn't fatal, we'll just
+		// try the next one or the origin.
+		os.Remove(dst)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// peerCachePath returns the slash separated path an artifact with the
+// given digest is stored and served at.
+func peerCachePath(digest string) (string, error) {
+	idx := strings.Index(digest, ":")
+	if idx < 1 || idx == len(digest)-1 {
+		return "", fmt.Errorf("invalid digest: %s", digest)
+	}
+
+	// Types such as "sha3-256" have dashes, but neither can have dots or
+	// slashes that would make the path refer outside of the cache
+	typ, value := digest[:idx], strings.ToLower(digest[idx+1:])
+	for _, r := range typ {
+		if !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') && r != '-' {
+			return "", fmt.Errorf("invalid digest: %s", digest)
+		}
+	}
+	for _, r := range value {
+		if !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') {
+			return "", fmt.Errorf("invalid digest: %s", digest)
+		}
+	}
+
+	return typ + "/" + value, nil
+}
@@ -0,0 +1,209 @@
+package getter
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// bundleIndex is the name of the file at the start of a bundle that lists
+// what is in it.
+const bundleIndex = "index.json"
+
+// BundleEntry is an artifact in a bundle exported from an HTTPPeerCache.
+type BundleEntry struct {
+	// Source is the canonical form of the source the artifact was
+	// downloaded from, as returned by CanonicalizeSource.
+	Source string `json:"source"`
+
+	// Digest identifies the artifact in the same "type:value" form as
+	// the checksum query parameter.
+	Digest string `json:"digest"`
+}
+
+// bundleManifest is the decoded form of a bundle's index.
+type bundleManifest struct {
+	Artifacts []BundleEntry `json:"artifacts"`
+}
+
+// ExportBundle writes the given artifacts, which must have been advertised
+// to the cache, to w as a single gzipped tar file that can be imported into
+// the cache of another machine with ImportBundle, for example to seed an
+// air-gapped machine. Sources are canonicalized before being written.
+func (c *HTTPPeerCache) ExportBundle(w io.Writer, entries []BundleEntry) error {
+	index := bundleManifest{Artifacts: make([]BundleEntry, len(entries))}
+	for i, e := range entries {
+		src, err := CanonicalizeSource(e.Source)
+		if err != nil {
+			return err
+		}
+		if _, err := peerCachePath(e.Digest); err != nil {
+			return err
+		}
+		index.Artifacts[i] = BundleEntry{Source: src, Digest: e.Digest}
+	}
+
+	gzw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gzw)
+
+	data, err := json.Marshal(&index)
+	if err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Name:     bundleIndex,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+
+	// Sources with the same artifact share it
+	written := make(map[string]bool)
+	for _, e := range index.Artifacts {
+		p, _ := peerCachePath(e.Digest)
+		if written[p] {
+			continue
+		}
+		written[p] = true
+
+		if err := c.exportArtifact(tw, p); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gzw.Close()
+}
+
+// ImportBundle adds the artifacts in a bundle written by ExportBundle to
+// the cache, returning what was in it. Each artifact is verified against
+// its digest before it is added, and the import fails if any are missing
+// or don't match.
+func (c *HTTPPeerCache) ImportBundle(r io.Reader) ([]BundleEntry, error) {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading bundle: %s", err)
+	}
+	defer gzr.Close()
+	tr := tar.NewReader(gzr)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("error reading bundle: %s", err)
+	}
+	if hdr.Name != bundleIndex {
+		return nil, fmt.Errorf("bundle doesn't start with %s", bundleIndex)
+	}
+	var index bundleManifest
+	if err := json.NewDecoder(tr).Decode(&index); err != nil {
+		return nil, fmt.Errorf("error parsing bundle index: %s", err)
+	}
+
+	digests := make(map[string]string)
+	for _, e := range index.Artifacts {
+		p, err := peerCachePath(e.Digest)
+		if err != nil {
+			return nil, err
+		}
+		digests[p] = e.Digest
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading bundle: %s", err)
+		}
+
+		digest, ok := digests[hdr.Name]
+		if !ok || hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("unexpected file in bundle: %s", hdr.Name)
+		}
+		if err := c.importArtifact(tr, hdr.Name, digest); err != nil {
+			return nil, err
+		}
+		delete(digests, hdr.Name)
+	}
+
+	for _, e := range index.Artifacts {
+		p, _ := peerCachePath(e.Digest)
+		if _, ok := digests[p]; ok {
+			return nil, fmt.Errorf("bundle is missing artifact %s", e.Digest)
+		}
+	}
+
+	return index.Artifacts, nil
+}
+
+// exportArtifact writes the artifact at the slash separated path p in Dir
+// to tw.
+func (c *HTTPPeerCache) exportArtifact(tw *tar.Writer, p string) error {
+	f, err := os.Open(filepath.Join(c.Dir, filepath.FromSlash(p)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:     p,
+		Mode:     0644,
+		Size:     fi.Size(),
+		ModTime:  fi.ModTime(),
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// importArtifact verifies the artifact read from r against digest and
+// stores it in Dir at the slash separated path p.
+func (c *HTTPPeerCache) importArtifact(r io.Reader, p, digest string) error {
+	idx := strings.Index(digest, ":")
+	h, err := checksumHashForType(digest[:idx])
+	if err != nil {
+		return err
+	}
+	value, err := hex.DecodeString(digest[idx+1:])
+	if err != nil {
+		return fmt.Errorf("invalid digest: %s", digest)
+	}
+
+	dst := filepath.Join(c.Dir, filepath.FromSlash(p))
+	tmp := dst + ".tmp"
+	if err := writeFile(tmp, r); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := checksum(tmp, h, value); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("artifact %s in bundle is corrupt: %s", digest, err)
+	}
+
+	return os.Rename(tmp, dst)
+}
@@ -0,0 +1,143 @@
+package getter
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHTTPPeerCache_bundle(t *testing.T) {
+	src := &HTTPPeerCache{Dir: tempDir(t)}
+	defer os.RemoveAll(src.Dir)
+	if err := src.Advertise(filepath.Join(fixtureDir, "basic-file", "foo.txt"), testPeerCacheDigest); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var buf bytes.Buffer
+	err := src.ExportBundle(&buf, []BundleEntry{
+		{Source: "https://Example.com/foo.txt", Digest: testPeerCacheDigest},
+		{Source: "https://example.com/copy.txt?b=2&a=1", Digest: testPeerCacheDigest},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	dst := &HTTPPeerCache{Dir: tempDir(t)}
+	defer os.RemoveAll(dst.Dir)
+	entries, err := dst.ImportBundle(&buf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []BundleEntry{
+		{Source: "https://example.com/foo.txt", Digest: testPeerCacheDigest},
+		{Source: "https://example.com/copy.txt?a=1&b=2", Digest: testPeerCacheDigest},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("bad: %#v", entries)
+	}
+
+	// Imported artifacts are fetched from the cache's own directory
+	f := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(f))
+	ok, err := dst.Fetch(f, testPeerCacheDigest)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !ok {
+		t.Fatal("should be fetched")
+	}
+	assertContents(t, f, "Hello\n")
+}
+
+func TestHTTPPeerCache_exportMissing(t *testing.T) {
+	c := &HTTPPeerCache{Dir: tempDir(t)}
+	defer os.RemoveAll(c.Dir)
+
+	var buf bytes.Buffer
+	err := c.ExportBundle(&buf, []BundleEntry{
+		{Source: "https://example.com/foo.txt", Digest: testPeerCacheDigest},
+	})
+	if err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestHTTPPeerCache_importBad(t *testing.T) {
+	index := `{"artifacts": [{"source": "https://example.com/foo.txt", "digest": "` + testPeerCacheDigest + `"}]}`
+	cases := map[string]map[string]string{
+		"corrupt": {
+			"index.json":                           index,
+			"md5/09f7e02f1290be211da707a266f153b3": "Goodbye\n",
+		},
+		"missing": {
+			"index.json": index,
+		},
+		"unexpected": {
+			"index.json":                           index,
+			"md5/09f7e02f1290be211da707a266f153b3": "Hello\n",
+			"../escape":                            "Hello\n",
+		},
+		"no index": {
+			"md5/09f7e02f1290be211da707a266f153b3": "Hello\n",
+		},
+	}
+
+	for name, files := range cases {
+		c := &HTTPPeerCache{Dir: tempDir(t)}
+		defer os.RemoveAll(c.Dir)
+
+		if _, err := c.ImportBundle(testBundle(t, files)); err == nil {
+			t.Fatalf("%s: should error", name)
+		}
+
+		// Nothing that fails verification is left in the cache
+		if name == "corrupt" {
+			p := filepath.Join(c.Dir, "md5", "09f7e02f1290be211da707a266f153b3")
+			if _, err := os.Stat(p); !os.IsNotExist(err) {
+				t.Fatalf("%s: artifact should not be imported: %v", name, err)
+			}
+		}
+	}
+}
+
+// testBundle returns a bundle containing the given files, with the index
+// first if there is one.
+func testBundle(t *testing.T, files map[string]string) *bytes.Buffer {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+
+	names := []string{"index.json"}
+	for name := range files {
+		if name != "index.json" {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		data, ok := files[name]
+		if !ok {
+			continue
+		}
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if _, err := tw.Write([]byte(data)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return &buf
+}
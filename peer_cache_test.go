@@ -0,0 +1,183 @@
+package getter
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testPeerCacheDigest = "md5:09f7e02f1290be211da707a266f153b3"
+
+func TestHTTPPeerCache_impl(t *testing.T) {
+	var _ PeerCache = new(HTTPPeerCache)
+}
+
+func TestHTTPPeerCache(t *testing.T) {
+	peer := &HTTPPeerCache{Dir: tempDir(t)}
+	defer os.RemoveAll(peer.Dir)
+	ln := testPeerCacheServer(t, peer)
+	defer ln.Close()
+
+	src := filepath.Join(fixtureDir, "basic-file", "foo.txt")
+	if err := peer.Advertise(src, testPeerCacheDigest); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c := &HTTPPeerCache{Peers: []string{"http://" + ln.Addr().String()}}
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	ok, err := c.Fetch(dst, testPeerCacheDigest)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !ok {
+		t.Fatal("should be fetched from peer")
+	}
+	assertContents(t, dst, "Hello\n")
+
+	// An artifact the peer doesn't have
+	ok, err = c.Fetch(dst, "md5:09f7e02f1290be211da707a266f153b4")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ok {
+		t.Fatal("should not be fetched from peer")
+	}
+}
+
+func TestHTTPPeerCache_unreachable(t *testing.T) {
+	c := &HTTPPeerCache{Peers: []string{"http://127.0.0.1:1"}}
+	dst := tempFile(t)
+
+	ok, err := c.Fetch(dst, testPeerCacheDigest)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ok {
+		t.Fatal("should not be fetched from peer")
+	}
+}
+
+func TestHTTPPeerCache_discovery(t *testing.T) {
+	peer := &HTTPPeerCache{Dir: tempDir(t)}
+	defer os.RemoveAll(peer.Dir)
+	ln := testPeerCacheServer(t, peer)
+	defer ln.Close()
+
+	src := filepath.Join(fixtureDir, "basic-file", "foo.txt")
+	if err := peer.Advertise(src, testPeerCacheDigest); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The peer is only known through its answer to discovery
+	peer.Discovery = &PeerDiscovery{Group: "127.0.0.1:0", URL: "http://" + ln.Addr().String()}
+	conn, err := peer.Discovery.Listen()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer conn.Close()
+	go peer.ServeDiscovery(conn)
+
+	c := &HTTPPeerCache{Discovery: &PeerDiscovery{Group: conn.LocalAddr().String(), Timeout: 5 * time.Second}}
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	ok, err := c.Fetch(dst, testPeerCacheDigest)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !ok {
+		t.Fatal("should be fetched from discovered peer")
+	}
+	assertContents(t, dst, "Hello\n")
+
+	// An artifact the peer doesn't have isn't answered for
+	c.Discovery.Timeout = 100 * time.Millisecond
+	ok, err = c.Fetch(dst, "md5:09f7e02f1290be211da707a266f153b4")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ok {
+		t.Fatal("should not be fetched from peer")
+	}
+}
+
+func TestHTTPPeerCache_badDigest(t *testing.T) {
+	c := &HTTPPeerCache{Dir: tempDir(t)}
+	defer os.RemoveAll(c.Dir)
+
+	for _, digest := range []string{"", "md5", "md5:", "../md5:abc", "md5:../abc", "sha3.256:abc"} {
+		if err := c.Advertise(filepath.Join(fixtureDir, "basic-file", "foo.txt"), digest); err == nil {
+			t.Fatalf("%q: should error", digest)
+		}
+	}
+}
+
+func TestGetFile_peerCache(t *testing.T) {
+	digests := []string{
+		testPeerCacheDigest,
+		"sha3-256:df26b6a0f51e57cf1d2df76970d855b0fe03a43cbe0243651b126783e8f5a07b",
+	}
+	for _, digest := range digests {
+		t.Run(digest, func(t *testing.T) {
+			peer := &HTTPPeerCache{Dir: tempDir(t)}
+			defer os.RemoveAll(peer.Dir)
+			ln := testPeerCacheServer(t, peer)
+			defer ln.Close()
+
+			u := testModule("basic-file/foo.txt") + "?checksum=" + digest
+
+			// The first download comes from the origin and is advertised.
+			dst := tempFile(t)
+			defer os.RemoveAll(filepath.Dir(dst))
+			getter := &MockGetter{Proxy: new(FileGetter)}
+			client := &Client{
+				Src:       u,
+				Dst:       dst,
+				Getters:   map[string]Getter{"file": getter},
+				PeerCache: peer,
+			}
+			if err := client.Get(); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !getter.GetFileCalled {
+				t.Fatal("should download from the origin")
+			}
+
+			// The second download comes from the peer.
+			dst2 := tempFile(t)
+			defer os.RemoveAll(filepath.Dir(dst2))
+			getter = &MockGetter{Proxy: new(FileGetter)}
+			client = &Client{
+				Src:       u,
+				Dst:       dst2,
+				Getters:   map[string]Getter{"file": getter},
+				PeerCache: &HTTPPeerCache{Peers: []string{"http://" + ln.Addr().String()}},
+			}
+			if err := client.Get(); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if getter.GetFileCalled {
+				t.Fatal("should download from the peer")
+			}
+			assertContents(t, dst2, "Hello\n")
+		})
+	}
+}
+
+func testPeerCacheServer(t *testing.T, c *HTTPPeerCache) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var server http.Server
+	server.Handler = c
+	go server.Serve(ln)
+
+	return ln
+}
@@ -0,0 +1,161 @@
+package getter
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultPeerDiscoveryGroup is the UDP multicast group that PeerDiscovery
+// uses if its Group isn't set.
+const DefaultPeerDiscoveryGroup = "239.255.71.71:7171"
+
+// defaultPeerDiscoveryTimeout is how long an HTTPPeerCache waits for peers
+// to answer by default.
+const defaultPeerDiscoveryTimeout = 250 * time.Millisecond
+
+// peerDiscoveryPrefix starts every discovery message, so that other
+// traffic sent to the group is ignored.
+const peerDiscoveryPrefix = "go-getter-peer "
+
+// PeerDiscovery finds the peers of an HTTPPeerCache that have an artifact,
+// so that they don't have to be listed in its Peers.
+//
+// When an artifact is fetched, a query for its digest is sent to Group,
+// a UDP multicast group, and each peer that has the artifact answers with
+// the URL that it serves its HTTPPeerCache at, which the artifact is then
+// fetched from. Peers answer queries with ServeDiscovery. Discovery is
+// meant for machines on the same trusted network: answers aren't
+// authenticated, though what is fetched is still verified against its
+// digest, and the peers they name aren't checked against the Client's
+// Addresses.
+type PeerDiscovery struct {
+	// Group is the UDP address that queries are sent to and answered on,
+	// DefaultPeerDiscoveryGroup if it isn't set. It is normally a
+	// multicast group, but may be the unicast address of a single peer.
+	Group string
+
+	// Interface, if set, is the network interface that the multicast
+	// group is joined on.
+	Interface *net.Interface
+
+	// URL is the base URL that this machine serves its HTTPPeerCache at,
+	// such as "http://10.0.0.2:8080", which ServeDiscovery answers with.
+	URL string
+
+	// Timeout is how long Fetch waits for answers, 250ms if it isn't set.
+	Timeout time.Duration
+}
+
+// Listen opens the connection that ServeDiscovery answers the queries sent
+// to the group on, joining the group if it is a multicast one.
+func (d *PeerDiscovery) Listen() (net.PacketConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", d.group())
+	if err != nil {
+		return nil, err
+	}
+	if addr.IP.IsMulticast() {
+		return net.ListenMulticastUDP("udp", d.Interface, addr)
+	}
+
+	return net.ListenUDP("udp", addr)
+}
+
+func (d *PeerDiscovery) group() string {
+	if d.Group == "" {
+		return DefaultPeerDiscoveryGroup
+	}
+
+	return d.Group
+}
+
+// ServeDiscovery answers the queries of other machines' PeerDiscovery on
+// conn, which is normally opened with the Discovery's Listen, for the
+// artifacts in Dir, until conn is closed.
+func (c *HTTPPeerCache) ServeDiscovery(conn net.PacketConn) error {
+	if c.Discovery == nil || c.Discovery.URL == "" {
+		return fmt.Errorf("peer discovery needs the URL the cache is served at")
+	}
+
+	buf := make([]byte, 1024)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+
+		msg := strings.Fields(string(buf[:n]))
+		if len(msg) != 3 || msg[0]+" " != peerDiscoveryPrefix || msg[1] != "want" {
+			continue
+		}
+		p, err := peerCachePath(msg[2])
+		if err != nil {
+			continue
+		}
+		if fi, err := os.Stat(filepath.Join(c.Dir, filepath.FromSlash(p))); err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+
+		// A machine that doesn't get the answer fetches from the origin,
+		// so failing to send it isn't fatal
+		answer := peerDiscoveryPrefix + "have " + msg[2] + " " + c.Discovery.URL
+		conn.WriteTo([]byte(answer), from)
+	}
+}
+
+// discover asks the group for the peers that have the artifact with the
+// given digest, and calls fetch with the URL of each one that answers
+// until it returns true.
+func (d *PeerDiscovery) discover(digest string, fetch func(peer string) (bool, error)) (bool, error) {
+	group, err := net.ResolveUDPAddr("udp", d.group())
+	if err != nil {
+		return false, err
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.WriteTo([]byte(peerDiscoveryPrefix+"want "+digest), group); err != nil {
+		// Discovery is only a shortcut past the origin
+		return false, nil
+	}
+
+	timeout := d.Timeout
+	if timeout <= 0 {
+		timeout = defaultPeerDiscoveryTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	tried := make(map[string]bool)
+	buf := make([]byte, 1024)
+	for {
+		if err := conn.SetReadDeadline(deadline); err != nil {
+			return false, err
+		}
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			// No more peers answered in time
+			return false, nil
+		}
+
+		msg := strings.Fields(string(buf[:n]))
+		if len(msg) != 4 || msg[0]+" " != peerDiscoveryPrefix || msg[1] != "have" || msg[2] != digest {
+			continue
+		}
+		peer := msg[3]
+		if u, err := url.Parse(peer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || tried[peer] {
+			continue
+		}
+		tried[peer] = true
+
+		ok, err := fetch(peer)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package getter
+
+import (
+	"io"
+)
+
+// ProgressTracker is told about each file as it is downloaded so that
+// callers can show progress bars or record metrics. It is set with the
+// Client's ProgressListener.
+type ProgressTracker interface {
+	// TrackProgress is called when a file starts downloading. src names
+	// the file being downloaded, currentSize is how much of it was
+	// already downloaded, which is zero unless the download is resumed,
+	// and totalSize is its size in bytes, or -1 if that isn't known.
+	//
+	// It returns a ReadCloser wrapping stream, whose every byte read is
+	// downloaded, that the getter reads the file from instead. The getter
+	// closes it once the download is done or has failed.
+	TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) io.ReadCloser
+}
+
+// trackProgress returns stream wrapped by the ProgressListener of the
+// client using the getter, if it has one, and counted by its Metrics.
+func (g *getter) trackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) io.ReadCloser {
+	stream = g.countBytes(stream)
+	if g.client == nil || g.client.ProgressListener == nil {
+		return stream
+	}
+
+	return g.client.ProgressListener.TrackProgress(src, currentSize, totalSize, stream)
+}
@@ -0,0 +1,137 @@
+package getter
+
+import (
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// testProgressTracker records the files it is told about and how much of
+// each was read.
+type testProgressTracker struct {
+	sync.Mutex
+	Files map[string]*testProgressFile
+}
+
+type testProgressFile struct {
+	Total  int64
+	Read   int64
+	Closed bool
+}
+
+func (p *testProgressTracker) TrackProgress(src string, currentSize, totalSize int64, stream io.ReadCloser) io.ReadCloser {
+	p.Lock()
+	defer p.Unlock()
+	if p.Files == nil {
+		p.Files = make(map[string]*testProgressFile)
+	}
+	f := &testProgressFile{Total: totalSize, Read: currentSize}
+	p.Files[src] = f
+
+	return &testProgressReader{tracker: p, file: f, ReadCloser: stream}
+}
+
+// assertFile checks that the file was tracked with the given size and
+// read completely and closed.
+func (p *testProgressTracker) assertFile(t *testing.T, src string, size int64) {
+	p.Lock()
+	defer p.Unlock()
+	f, ok := p.Files[src]
+	if !ok {
+		t.Fatalf("%s wasn't tracked: %#v", src, p.Files)
+	}
+	if f.Total != size || f.Read != size || !f.Closed {
+		t.Fatalf("bad progress for %s: %#v", src, f)
+	}
+}
+
+type testProgressReader struct {
+	io.ReadCloser
+	tracker *testProgressTracker
+	file    *testProgressFile
+}
+
+func (r *testProgressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.tracker.Lock()
+	r.file.Read += int64(n)
+	r.tracker.Unlock()
+	return n, err
+}
+
+func (r *testProgressReader) Close() error {
+	r.tracker.Lock()
+	r.file.Closed = true
+	r.tracker.Unlock()
+	return r.ReadCloser.Close()
+}
+
+func TestProgressTracker_http(t *testing.T) {
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	tracker := new(testProgressTracker)
+	g := new(HttpGetter)
+	g.client = &Client{ProgressListener: tracker}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	u := &url.URL{Scheme: "http", Host: ln.Addr().String(), Path: "/file"}
+	if err := g.GetFile(filepath.Join(dst, "file"), u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tracker.assertFile(t, "file", 6)
+}
+
+func TestProgressTracker_file(t *testing.T) {
+	tracker := new(testProgressTracker)
+	g := &FileGetter{Copy: true}
+	g.client = &Client{ProgressListener: tracker}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	path, err := filepath.Abs(filepath.Join(fixtureDir, "basic", "main.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.GetFile(filepath.Join(dst, "main.tf"), &url.URL{Scheme: "file", Path: path}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tracker.assertFile(t, "main.tf", fi.Size())
+}
+
+func TestProgressTracker_s3(t *testing.T) {
+	server := httptest.NewServer(&testS3Server{
+		Bucket: "bucket",
+		Objects: map[string]string{
+			"prefix/a.txt":     "a",
+			"prefix/sub/b.txt": "bb",
+		},
+	})
+	defer server.Close()
+
+	tracker := new(testProgressTracker)
+	g := new(S3Getter)
+	g.client = &Client{ProgressListener: tracker}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	u, err := url.Parse(server.URL + "/bucket/prefix?aws_access_key_id=a&aws_access_key_secret=b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Get(dst, u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tracker.assertFile(t, "prefix/a.txt", 1)
+	tracker.assertFile(t, "prefix/sub/b.txt", 2)
+}
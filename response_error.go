@@ -0,0 +1,26 @@
+package getter
+
+import (
+	"fmt"
+)
+
+// BadResponseCodeError is returned when an HTTP request, including those
+// made to OCI registries, gets a response with a status code that the
+// getter didn't expect. It is often wrapped in an error saying more about
+// the request, so should be found with errors.As.
+type BadResponseCodeError struct {
+	// Code is the status code of the response.
+	Code int
+
+	// URL, if set, is the URL that was requested, with any credentials
+	// in it redacted.
+	URL string
+}
+
+func (e *BadResponseCodeError) Error() string {
+	if e.URL != "" {
+		return fmt.Sprintf("bad response code from %s: %d", e.URL, e.Code)
+	}
+
+	return fmt.Sprintf("bad response code: %d", e.Code)
+}
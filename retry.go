@@ -0,0 +1,209 @@
+package getter
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RetryPolicy is how HTTP and S3 requests that fail for reasons that may
+// be transient, such as a 503 response or a connection being reset, are
+// retried. Without one a single failed request fails the download.
+type RetryPolicy struct {
+	// MaxAttempts is how many times a request is sent before giving up,
+	// including the first. This defaults to 3 if left unset.
+	MaxAttempts int
+
+	// Backoff returns how long to wait before the given retry, which is 1
+	// for the first. If the response to the failed request has a
+	// Retry-After header that is later, that is waited for instead. This
+	// defaults to DefaultBackoff if left unset.
+	Backoff func(retry int) time.Duration
+
+	// Jitter is the fraction, from 0 to 1, of each Backoff that is taken
+	// off at random, so that many clients whose requests failed at the
+	// same time don't all retry them at the same time too. A Jitter of 1
+	// waits anything up to the Backoff, and the default of 0 waits for
+	// exactly the Backoff. A later Retry-After is still waited for.
+	Jitter float64
+
+	// Retryable reports whether a failed request should be retried. resp
+	// is nil if no response was received, in which case err is why. This
+	// defaults to DefaultRetryable if left unset.
+	Retryable func(resp *http.Response, err error) bool
+
+	// MaxRetries and MaxRetryTime, if set, are the most retries, and the
+	// most time spent waiting before them, of all the requests of a
+	// download with Get, GetAny, GetMemory or GetParts together, however
+	// many sources it has, such as mirrors, the sources of a manifest or
+	// of an X-Terraform-Get header, and parts. Once either runs out,
+	// requests that fail aren't retried for the rest of the download, so
+	// that a pathological endpoint can't have every source retried in
+	// turn. Waits of parts downloaded in parallel each count in full.
+	MaxRetries   int
+	MaxRetryTime time.Duration
+}
+
+// retryBudget is the retries of a download so far, shared by all of its
+// requests, and the MaxRetries and MaxRetryTime they are limited to.
+type retryBudget struct {
+	maxRetries int
+	maxTime    time.Duration
+
+	mu      sync.Mutex
+	retries int
+	waited  time.Duration
+}
+
+// newRetryBudget returns the budget of a download with policy p, or nil if
+// it isn't limited.
+func newRetryBudget(p *RetryPolicy) *retryBudget {
+	if p == nil || (p.MaxRetries <= 0 && p.MaxRetryTime <= 0) {
+		return nil
+	}
+
+	return &retryBudget{maxRetries: p.MaxRetries, maxTime: p.MaxRetryTime}
+}
+
+// take reports whether there is budget left for a retry after waiting d,
+// and spends it if so. A nil budget is never used up.
+func (b *retryBudget) take(d time.Duration) bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.maxRetries > 0 && b.retries >= b.maxRetries {
+		return false
+	}
+	if b.maxTime > 0 && b.waited+d > b.maxTime {
+		return false
+	}
+	b.retries++
+	b.waited += d
+
+	return true
+}
+
+// DefaultBackoff waits one second before the first retry, doubling for
+// each retry after it up to 30 seconds.
+func DefaultBackoff(retry int) time.Duration {
+	d := time.Second
+	for i := 1; i < retry && d < 30*time.Second; i++ {
+		d *= 2
+	}
+	if d > 30*time.Second {
+		d = 30 * time.Second
+	}
+
+	return d
+}
+
+// DefaultRetryable retries requests that got no response, unless they
+// were stopped by a CircuitBreaker, and those whose response was a 429
+// (Too Many Requests) or a 500, 502, 503 or 504 server error.
+func DefaultRetryable(resp *http.Response, err error) bool {
+	if resp == nil {
+		_, unhealthy := err.(*HostUnhealthyError)
+		return err != nil && !unhealthy
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts <= 0 {
+		return 3
+	}
+
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) retryable(resp *http.Response, err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(resp, err)
+	}
+
+	return DefaultRetryable(resp, err)
+}
+
+// delay returns how long to wait before the given retry of a request that
+// got resp, which may be nil.
+func (p *RetryPolicy) delay(retry int, resp *http.Response) time.Duration {
+	backoff := p.Backoff
+	if backoff == nil {
+		backoff = DefaultBackoff
+	}
+
+	d := backoff(retry)
+	if jitter := math.Min(p.Jitter, 1); jitter > 0 && d > 0 {
+		d -= time.Duration(rand.Float64() * jitter * float64(d))
+	}
+	if resp != nil {
+		if until := time.Until(parseRetryAfter(resp.Header.Get("Retry-After"))); until > d {
+			d = until
+		}
+	}
+
+	return d
+}
+
+// retry reports whether the request that was the given attempt, counting
+// from 1, should be sent again after getting resp and err, having waited
+// before returning if so. It returns false if p is nil, budget has run out
+// or ctx is done.
+func (p *RetryPolicy) retry(ctx context.Context, budget *retryBudget, attempt int, resp *http.Response, err error) bool {
+	if p == nil || attempt >= p.maxAttempts() || ctx.Err() != nil {
+		return false
+	}
+	if err == nil && resp.StatusCode < 400 {
+		return false
+	}
+	if !p.retryable(resp, err) {
+		return false
+	}
+
+	d := p.delay(attempt, resp)
+	if !budget.take(d) {
+		return false
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// withRetryBudget returns a copy of the client with a budget for the
+// MaxRetries and MaxRetryTime of its RetryPolicy, unless it has one
+// already, for the download it is about to start.
+func (c *Client) withRetryBudget() *Client {
+	if c.retryBudget != nil {
+		return c
+	}
+	budget := newRetryBudget(c.RetryPolicy)
+	if budget == nil {
+		return c
+	}
+
+	client := *c
+	client.retryBudget = budget
+	return &client
+}
@@ -0,0 +1,200 @@
+package getter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_http(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch n := atomic.AddInt32(&requests, 1); {
+		case r.URL.Path == "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case n <= 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte("Hello\n"))
+		}
+	}))
+	defer server.Close()
+
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+	get := func(policy *RetryPolicy, path string) error {
+		atomic.StoreInt32(&requests, 0)
+		g := new(HttpGetter)
+		g.client = &Client{RetryPolicy: policy}
+		u, err := url.Parse(server.URL + path)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		return g.GetFile(filepath.Join(dst, "file"), u)
+	}
+	backoff := func(int) time.Duration { return time.Millisecond }
+
+	// Without a policy the first failure is returned
+	if err := get(nil, "/file"); err == nil {
+		t.Fatal("should error")
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("bad requests: %d", n)
+	}
+
+	// Failures are retried until the request succeeds
+	if err := get(&RetryPolicy{Backoff: backoff}, "/file"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "file"), "Hello\n")
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Fatalf("bad requests: %d", n)
+	}
+
+	// Or the attempts run out
+	if err := get(&RetryPolicy{MaxAttempts: 2, Backoff: backoff}, "/file"); err == nil {
+		t.Fatal("should error")
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Fatalf("bad requests: %d", n)
+	}
+
+	// Errors that aren't transient aren't retried
+	if err := get(&RetryPolicy{Backoff: backoff}, "/missing"); err == nil {
+		t.Fatal("should error")
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Fatalf("bad requests: %d", n)
+	}
+
+	// Unless the policy says so
+	retryable := func(resp *http.Response, err error) bool { return true }
+	if err := get(&RetryPolicy{Backoff: backoff, Retryable: retryable}, "/missing"); err == nil {
+		t.Fatal("should error")
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Fatalf("bad requests: %d", n)
+	}
+}
+
+func TestRetryPolicy_s3(t *testing.T) {
+	var requests int32
+	backend := &testS3Server{
+		Bucket:  "bucket",
+		Objects: map[string]string{"main.tf": "main"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	g := new(S3Getter)
+	g.client = &Client{RetryPolicy: &RetryPolicy{
+		MaxAttempts: 2,
+		Backoff:     func(int) time.Duration { return time.Millisecond },
+	}}
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	u, err := url.Parse(server.URL + "/bucket/main.tf?aws_access_key_id=a&aws_access_key_secret=b")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := g.GetFile(filepath.Join(dst, "main.tf"), u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "main.tf"), "main")
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Fatalf("bad requests: %d", n)
+	}
+}
+
+func TestRetryPolicy_budget(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+	get := func(policy *RetryPolicy) int32 {
+		atomic.StoreInt32(&requests, 0)
+		c := &Client{
+			Dst:         filepath.Join(dst, "file"),
+			Mode:        ClientModeFile,
+			RetryPolicy: policy,
+		}
+		if err := c.GetAny(c.Dst, []string{server.URL + "/a", server.URL + "/b"}); err == nil {
+			t.Fatal("should error")
+		}
+
+		return atomic.LoadInt32(&requests)
+	}
+	backoff := func(int) time.Duration { return 10 * time.Millisecond }
+
+	// Without a budget each source is retried in full
+	if n := get(&RetryPolicy{MaxAttempts: 4, Backoff: backoff}); n != 8 {
+		t.Fatalf("bad requests: %d", n)
+	}
+
+	// The retries are shared by the sources
+	if n := get(&RetryPolicy{MaxAttempts: 4, Backoff: backoff, MaxRetries: 2}); n != 4 {
+		t.Fatalf("bad requests: %d", n)
+	}
+
+	// As is the time spent waiting for them
+	if n := get(&RetryPolicy{MaxAttempts: 4, Backoff: backoff, MaxRetryTime: 45 * time.Millisecond}); n != 6 {
+		t.Fatalf("bad requests: %d", n)
+	}
+
+	// Each download has a budget of its own
+	policy := &RetryPolicy{MaxAttempts: 4, Backoff: backoff, MaxRetries: 1}
+	for i := 0; i < 2; i++ {
+		if n := get(policy); n != 3 {
+			t.Fatalf("%d: bad requests: %d", i, n)
+		}
+	}
+}
+
+func TestRetryPolicy_delay(t *testing.T) {
+	p := new(RetryPolicy)
+	for retry, expected := range map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		3:  4 * time.Second,
+		10: 30 * time.Second,
+	} {
+		if actual := p.delay(retry, nil); actual != expected {
+			t.Fatalf("%d: bad: %s", retry, actual)
+		}
+	}
+
+	// A later Retry-After is waited for instead
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"60"}}}
+	if actual := p.delay(1, resp); actual < 50*time.Second {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	// Jitter takes off up to that fraction of the backoff
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if actual := p.delay(2, nil); actual < time.Second || actual > 2*time.Second {
+			t.Fatalf("bad: %s", actual)
+		}
+	}
+	if actual := p.delay(1, resp); actual < 50*time.Second {
+		t.Fatalf("bad: %s", actual)
+	}
+}
@@ -0,0 +1,96 @@
+package getter
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// signedURLMargin is how long before a signed URL expires that it is
+// refreshed, so that it doesn't expire while a request is being sent.
+const signedURLMargin = 30 * time.Second
+
+// SignedURLExpiry returns when the signed URL u expires, if it is one. AWS
+// presigned URLs (both Signature Version 4 and 2), CloudFront signed URLs,
+// Google Cloud Storage signed URLs and Azure shared access signatures are
+// recognized from their query parameters.
+func SignedURLExpiry(u *url.URL) (time.Time, bool) {
+	q := u.Query()
+	switch {
+	case q.Get("X-Amz-Expires") != "":
+		return signedURLDuration(q.Get("X-Amz-Date"), q.Get("X-Amz-Expires"))
+	case q.Get("X-Goog-Expires") != "":
+		return signedURLDuration(q.Get("X-Goog-Date"), q.Get("X-Goog-Expires"))
+	case q.Get("Expires") != "" && q.Get("Signature") != "":
+		secs, err := strconv.ParseInt(q.Get("Expires"), 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(secs, 0), true
+	case q.Get("se") != "" && q.Get("sig") != "":
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00", "2006-01-02"} {
+			if t, err := time.Parse(layout, q.Get("se")); err == nil {
+				return t, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// signedURLDuration returns the expiry of a signed URL that was signed at
+// date, in the basic ISO 8601 format, and expires after the given number
+// of seconds.
+func signedURLDuration(date, expires string) (time.Time, bool) {
+	t, err := time.Parse("20060102T150405Z", date)
+	if err != nil {
+		return time.Time{}, false
+	}
+	secs, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t.Add(time.Duration(secs) * time.Second), true
+}
+
+// canRefreshURL returns true if u is a signed URL and the client can
+// refresh it.
+func (g *HttpGetter) canRefreshURL(u *url.URL) bool {
+	if g.client == nil || g.client.RefreshURL == nil {
+		return false
+	}
+
+	_, ok := SignedURLExpiry(u)
+	return ok
+}
+
+// refreshExpiredURL refreshes u if it is a signed URL that has expired, or
+// is about to, and the client can refresh it.
+func (g *HttpGetter) refreshExpiredURL(u *url.URL) error {
+	if !g.canRefreshURL(u) {
+		return nil
+	}
+
+	expiry, _ := SignedURLExpiry(u)
+	if time.Until(expiry) > signedURLMargin {
+		return nil
+	}
+
+	return g.refreshURL(u)
+}
+
+// refreshURL replaces u with the new URL from the client's RefreshURL.
+func (g *HttpGetter) refreshURL(u *url.URL) error {
+	fresh, err := g.client.RefreshURL(u)
+	if err != nil {
+		return fmt.Errorf("error refreshing signed URL: %s", err)
+	}
+	if fresh == nil {
+		return fmt.Errorf("error refreshing signed URL: no URL was returned")
+	}
+
+	*u = *fresh
+	return nil
+}
@@ -0,0 +1,193 @@
+package getter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSignedURLExpiry(t *testing.T) {
+	cases := []struct {
+		Input  string
+		Expiry string
+	}{
+		{"https://b.s3.amazonaws.com/k?X-Amz-Date=20200102T030405Z&X-Amz-Expires=60&X-Amz-Signature=x", "2020-01-02T03:05:05Z"},
+		{"https://b.s3.amazonaws.com/k?AWSAccessKeyId=a&Expires=1577934245&Signature=x", "2020-01-02T03:04:05Z"},
+		{"https://storage.googleapis.com/b/k?X-Goog-Date=20200102T030405Z&X-Goog-Expires=3600&X-Goog-Signature=x", "2020-01-02T04:04:05Z"},
+		{"https://a.blob.core.windows.net/c/k?se=2020-01-02T03:04:05Z&sp=r&sig=x", "2020-01-02T03:04:05Z"},
+		{"https://a.blob.core.windows.net/c/k?se=2020-01-02&sp=r&sig=x", "2020-01-02T00:00:00Z"},
+		{"https://example.com/k?Expires=1577934245", ""},
+		{"https://example.com/k?se=2020-01-02", ""},
+		{"https://example.com/k?X-Amz-Expires=60", ""},
+		{"https://example.com/k", ""},
+	}
+
+	for _, tc := range cases {
+		expiry, ok := SignedURLExpiry(testURL(tc.Input))
+		if tc.Expiry == "" {
+			if ok {
+				t.Fatalf("%s: shouldn't be signed: %s", tc.Input, expiry)
+			}
+			continue
+		}
+		if !ok {
+			t.Fatalf("%s: should be signed", tc.Input)
+		}
+		if actual := expiry.UTC().Format(time.RFC3339); actual != tc.Expiry {
+			t.Fatalf("%s: bad: %s", tc.Input, actual)
+		}
+	}
+}
+
+func TestHttpGetter_refreshURL(t *testing.T) {
+	server := testSignedURLServer()
+	defer server.Close()
+
+	expired := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	valid := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	cases := []struct {
+		Name      string
+		Src       string
+		Refreshes int
+	}{
+		// The URL has expired before it is used
+		{"expired", server.URL + "/file?se=" + expired + "&sig=stale", 1},
+		// The URL hasn't expired but is refused anyway
+		{"refused", server.URL + "/file?se=" + valid + "&sig=stale", 1},
+		// The URL is still good
+		{"valid", server.URL + "/file?se=" + valid + "&sig=fresh", 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			dst := tempFile(t)
+			defer os.RemoveAll(filepath.Dir(dst))
+
+			var mu sync.Mutex
+			var refreshes int
+			client := &Client{
+				Src:  tc.Src,
+				Dst:  dst,
+				Mode: ClientModeFile,
+				RefreshURL: func(u *url.URL) (*url.URL, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					refreshes++
+
+					fresh := *u
+					q := fresh.Query()
+					q.Set("se", valid)
+					q.Set("sig", "fresh")
+					fresh.RawQuery = q.Encode()
+					return &fresh, nil
+				},
+			}
+			if err := client.Get(); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			assertContents(t, dst, "Hello\n")
+
+			mu.Lock()
+			defer mu.Unlock()
+			if refreshes != tc.Refreshes {
+				t.Fatalf("bad: %d", refreshes)
+			}
+		})
+	}
+}
+
+func TestHttpGetter_refreshURL_noCallback(t *testing.T) {
+	server := testSignedURLServer()
+	defer server.Close()
+
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	valid := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	client := &Client{
+		Src:  server.URL + "/file?se=" + valid + "&sig=stale",
+		Dst:  dst,
+		Mode: ClientModeFile,
+	}
+	err := client.Get()
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestHttpGetter_refreshURL_checksumFile(t *testing.T) {
+	sum := sha256.Sum256([]byte("Hello\n"))
+	server := testSignedFilesServer(map[string][]byte{
+		"/file":       []byte("Hello\n"),
+		"/SHA256SUMS": []byte(hex.EncodeToString(sum[:]) + "  file\n"),
+	})
+	defer server.Close()
+
+	dst := tempFile(t)
+	defer os.RemoveAll(filepath.Dir(dst))
+
+	// The checksum file is refreshed as well as the file
+	valid := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	sums := "file:" + server.URL + "/SHA256SUMS?se=" + valid + "&sig=stale"
+	client := &Client{
+		Src:        server.URL + "/file?se=" + valid + "&sig=stale&checksum=" + url.QueryEscape(sums),
+		Dst:        dst,
+		Mode:       ClientModeFile,
+		RefreshURL: testRefreshURL(valid),
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, dst, "Hello\n")
+}
+
+// testRefreshURL returns a RefreshURL that signs URLs with sig=fresh until
+// valid.
+func testRefreshURL(valid string) func(*url.URL) (*url.URL, error) {
+	return func(u *url.URL) (*url.URL, error) {
+		fresh := *u
+		q := fresh.Query()
+		q.Set("se", valid)
+		q.Set("sig", "fresh")
+		fresh.RawQuery = q.Encode()
+		return &fresh, nil
+	}
+}
+
+// testSignedURLServer serves "Hello\n" for requests signed with sig=fresh
+// and refuses any others.
+func testSignedURLServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sig") != "fresh" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("Hello\n"))
+	}))
+}
+
+// testSignedFilesServer serves files for requests signed with sig=fresh
+// and refuses any others.
+func testSignedFilesServer(files map[string][]byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sig") != "fresh" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		data, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	}))
+}
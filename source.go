@@ -2,10 +2,66 @@ package getter
 
 import (
 	"fmt"
+	"path"
 	"path/filepath"
 	"strings"
+
+	urlhelper "github.com/hashicorp/go-getter/helper/url"
 )
 
+// CanonicalizeSource returns the canonical form of a source, so that
+// different ways of writing the same source, such as a shorthand that the
+// Detectors expand or query parameters in another order, can be compared or
+// used as a cache key.
+//
+// The source is detected with the default Detectors, and then the scheme
+// and host are lower cased, default ports are removed, the subdir is
+// cleaned and the query parameters are sorted and consistently encoded.
+// Relative paths can't be canonicalized since they depend on the working
+// directory.
+func CanonicalizeSource(src string) (string, error) {
+	return canonicalizeSource(src, "", Detectors)
+}
+
+// canonicalizeSource is CanonicalizeSource with the given working
+// directory and detectors.
+func canonicalizeSource(src, pwd string, detectors []Detector) (string, error) {
+	src, err := Detect(src, pwd, detectors)
+	if err != nil {
+		return "", err
+	}
+
+	force, src := getForcedGetter(src)
+	src, subDir := SourceDirSubdir(src)
+
+	u, err := urlhelper.Parse(src)
+	if err != nil {
+		return "", fmt.Errorf("invalid source %q: %s", src, err)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if (u.Scheme == "http" && u.Port() == "80") || (u.Scheme == "https" && u.Port() == "443") {
+		u.Host = u.Hostname()
+	}
+	rawQuery := u.Query().Encode()
+	u.RawQuery = ""
+
+	result := u.String()
+	if force != "" && force != u.Scheme {
+		result = force + "::" + result
+	}
+	if subDir != "" {
+		if subDir = path.Clean(subDir); subDir != "." {
+			result += "//" + strings.TrimPrefix(subDir, "/")
+		}
+	}
+	if rawQuery != "" {
+		result += "?" + rawQuery
+	}
+
+	return result, nil
+}
+
 // SourceDirSubdir takes a source and returns a tuple of the URL without
 // the subdir and the URL with the subdir.
 func SourceDirSubdir(src string) (string, string) {
@@ -16,8 +72,15 @@ func SourceDirSubdir(src string) (string, string) {
 		offset = idx + 3
 	}
 
+	// Only look for a subdir before the query string since query
+	// parameters may themselves contain URLs.
+	end := len(src)
+	if idx := strings.Index(src[offset:], "?"); idx > -1 {
+		end = offset + idx
+	}
+
 	// First see if we even have an explicit subdir
-	idx := strings.Index(src[offset:], "//")
+	idx := strings.Index(src[offset:end], "//")
 	if idx == -1 {
 		return src, ""
 	}
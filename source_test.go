@@ -32,6 +32,14 @@ func TestSourceDirSubdir(t *testing.T) {
 			"file://foo//bar",
 			"file://foo", "bar",
 		},
+		{
+			"file://foo?checksums=file:https://hashicorp.com/SHA256SUMS",
+			"file://foo?checksums=file:https://hashicorp.com/SHA256SUMS", "",
+		},
+		{
+			"file://foo//bar?checksums=file:https://hashicorp.com/SHA256SUMS",
+			"file://foo?checksums=file:https://hashicorp.com/SHA256SUMS", "bar",
+		},
 	}
 
 	for i, tc := range cases {
@@ -96,3 +104,58 @@ func TestSourceSubdirGlob(t *testing.T) {
 		t.Fatalf("expected no matches, got %q", res)
 	}
 }
+
+func TestCanonicalizeSource(t *testing.T) {
+	cases := []struct {
+		Input  string
+		Output string
+	}{
+		{
+			"github.com/hashicorp/foo",
+			"git::https://github.com/hashicorp/foo.git",
+		},
+		{
+			"git::https://github.com/hashicorp/foo.git?ref=v1",
+			"git::https://github.com/hashicorp/foo.git?ref=v1",
+		},
+		{
+			"HTTPS://Example.COM:443/foo?b=2&a=1",
+			"https://example.com/foo?a=1&b=2",
+		},
+		{
+			"http://example.com:8080/foo?a=%41",
+			"http://example.com:8080/foo?a=A",
+		},
+		{
+			"https://example.com/foo//bar/../baz/?archive=zip",
+			"https://example.com/foo//baz?archive=zip",
+		},
+		{
+			"https://example.com/foo//.",
+			"https://example.com/foo",
+		},
+		{
+			"http::http://example.com/foo",
+			"http://example.com/foo",
+		},
+		{
+			"s3::https://s3.amazonaws.com/bucket/foo",
+			"s3::https://s3.amazonaws.com/bucket/foo",
+		},
+	}
+
+	for _, tc := range cases {
+		actual, err := CanonicalizeSource(tc.Input)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Input, err)
+		}
+		if actual != tc.Output {
+			t.Fatalf("%s: bad: %s", tc.Input, actual)
+		}
+	}
+
+	// Relative paths depend on the working directory
+	if _, err := CanonicalizeSource("./foo"); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -0,0 +1,47 @@
+package getter
+
+import (
+	"fmt"
+	"os"
+)
+
+// SpecialFilePolicy is what is done with sockets, named pipes and device
+// files when a directory tree, or a local file, is copied into the
+// destination. Reading them as if they were regular files would block or
+// never end.
+type SpecialFilePolicy uint
+
+const (
+	// SpecialFilesSkip leaves special files out of the copy. This is the
+	// default.
+	SpecialFilesSkip SpecialFilePolicy = iota
+
+	// SpecialFilesError fails the copy if there are any special files.
+	SpecialFilesError
+
+	// SpecialFilesRecreate creates a special file of the same type and
+	// permissions in the destination. This isn't supported on Windows,
+	// and device files can generally only be created by root.
+	SpecialFilesRecreate
+)
+
+// isSpecialFile returns true if the file with the given mode is a socket,
+// named pipe or device.
+func isSpecialFile(mode os.FileMode) bool {
+	return mode&(os.ModeSocket|os.ModeNamedPipe|os.ModeDevice|os.ModeCharDevice) != 0
+}
+
+// copySpecialFile applies the policy to the special file at src, which
+// would be copied to dst.
+func copySpecialFile(dst, src string, info os.FileInfo, policy SpecialFilePolicy) error {
+	switch policy {
+	case SpecialFilesSkip:
+		return nil
+	case SpecialFilesError:
+		return fmt.Errorf("%s is a special file (%s) and can't be copied", src, info.Mode().Type())
+	case SpecialFilesRecreate:
+		return mknod(dst, info)
+	default:
+		return fmt.Errorf("unknown special file policy: %d", policy)
+	}
+}
@@ -0,0 +1,10 @@
+// +build !windows,!freebsd
+
+package getter
+
+import "syscall"
+
+// mknodDev returns the device that st describes as syscall.Mknod takes it.
+func mknodDev(st *syscall.Stat_t) int {
+	return int(st.Rdev)
+}
@@ -0,0 +1,9 @@
+package getter
+
+import "syscall"
+
+// mknodDev returns the device that st describes as syscall.Mknod takes it,
+// which on FreeBSD is a uint64.
+func mknodDev(st *syscall.Stat_t) uint64 {
+	return st.Rdev
+}
@@ -0,0 +1,94 @@
+// +build !windows
+
+package getter
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestCopyDir_specialFiles(t *testing.T) {
+	src := tempDir(t)
+	defer os.RemoveAll(src)
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "file"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mkfifo(filepath.Join(src, "fifo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		Policy SpecialFilePolicy
+		Err    bool
+		Fifo   bool
+	}{
+		{SpecialFilesSkip, false, false},
+		{SpecialFilesError, true, false},
+		{SpecialFilesRecreate, false, true},
+	}
+
+	for _, tc := range cases {
+		dst := tempDir(t)
+		defer os.RemoveAll(dst)
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		err := copyDir(dst, src, false, tc.Policy, SymlinksPreserve)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%d: err: %v", tc.Policy, err)
+		}
+		if tc.Err {
+			continue
+		}
+
+		assertContents(t, filepath.Join(dst, "file"), "hello")
+		fi, err := os.Lstat(filepath.Join(dst, "fifo"))
+		if tc.Fifo {
+			if err != nil {
+				t.Fatalf("%d: err: %s", tc.Policy, err)
+			}
+			if fi.Mode()&os.ModeNamedPipe == 0 || fi.Mode().Perm() != 0600 {
+				t.Fatalf("%d: bad mode: %s", tc.Policy, fi.Mode())
+			}
+		} else if !os.IsNotExist(err) {
+			t.Fatalf("%d: fifo should not be copied: %v", tc.Policy, err)
+		}
+	}
+}
+
+func TestFileGetter_GetFile_special(t *testing.T) {
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	if err := os.MkdirAll(td, 0755); err != nil {
+		t.Fatal(err)
+	}
+	fifo := filepath.Join(td, "fifo")
+	if err := syscall.Mkfifo(fifo, 0600); err != nil {
+		t.Fatal(err)
+	}
+	u := &url.URL{Scheme: "file", Path: fifo}
+
+	g := &FileGetter{Copy: true}
+	if err := g.GetFile(filepath.Join(td, "a"), u); err == nil {
+		t.Fatal("should error")
+	}
+	if _, _, err := g.GetReader(u); err == nil {
+		t.Fatal("should error")
+	}
+
+	g.client = &Client{SpecialFiles: SpecialFilesRecreate}
+	if err := g.GetFile(filepath.Join(td, "b"), u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fi, err := os.Lstat(filepath.Join(td, "b")); err != nil || fi.Mode()&os.ModeNamedPipe == 0 {
+		t.Fatalf("should be a fifo: %v", err)
+	}
+}
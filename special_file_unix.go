@@ -0,0 +1,36 @@
+// +build !windows
+
+package getter
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// mknod creates a special file at dst like the one described by info.
+func mknod(dst string, info os.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("can't recreate special file %s: unknown file info", info.Name())
+	}
+
+	var typ uint32
+	mode := info.Mode()
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		typ = syscall.S_IFIFO
+	case mode&os.ModeSocket != 0:
+		typ = syscall.S_IFSOCK
+	case mode&os.ModeCharDevice != 0:
+		typ = syscall.S_IFCHR
+	default:
+		typ = syscall.S_IFBLK
+	}
+
+	if err := syscall.Mknod(dst, typ|uint32(mode.Perm()), mknodDev(st)); err != nil {
+		return fmt.Errorf("error recreating special file %s: %s", info.Name(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,13 @@
+// +build windows
+
+package getter
+
+import (
+	"fmt"
+	"os"
+)
+
+// mknod creates a special file at dst like the one described by info.
+func mknod(dst string, info os.FileInfo) error {
+	return fmt.Errorf("can't recreate special file %s: not supported on Windows", info.Name())
+}
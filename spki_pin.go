@@ -0,0 +1,111 @@
+package getter
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+// spkiPin returns the pin of the certificate's public key in the form used
+// by HttpGetter.PinnedKeys: "sha256/" followed by the base64 encoded
+// SHA-256 hash of its DER encoded SubjectPublicKeyInfo.
+func spkiPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// pinnedClient returns a copy of client whose requests to the hosts in pins
+// fail unless they are sent over TLS to a host presenting a certificate
+// chain with one of the host's pins.
+func pinnedClient(client *http.Client, pins map[string][]string) *http.Client {
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	// Where possible the pins are checked during the handshake, before
+	// anything is sent. That relies on the server name though, which isn't
+	// set for hosts that are IP addresses, so responses are checked too.
+	if t, ok := transport.(*http.Transport); ok {
+		t = t.Clone()
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = new(tls.Config)
+		}
+		verify := t.TLSClientConfig.VerifyConnection
+		t.TLSClientConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			if verify != nil {
+				if err := verify(cs); err != nil {
+					return err
+				}
+			}
+
+			return verifyPins(cs, cs.ServerName, pins[cs.ServerName])
+		}
+		transport = t
+	}
+
+	c := *client
+	c.Transport = &pinnedTransport{transport: transport, pins: pins}
+	return &c
+}
+
+// pinnedTransport is an http.RoundTripper that checks the certificates of
+// pinned hosts.
+type pinnedTransport struct {
+	transport http.RoundTripper
+	pins      map[string][]string
+}
+
+func (t *pinnedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	pins := t.pins[host]
+	if len(pins) == 0 {
+		return t.transport.RoundTrip(req)
+	}
+	if req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("%s has pinned keys and must be fetched over https", host)
+	}
+
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.TLS == nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s has pinned keys but the response wasn't over TLS", host)
+	}
+	if err := verifyPins(*resp.TLS, host, pins); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// verifyPins checks that a certificate presented to the connection in cs
+// to host has one of the given pins. Hosts without pins aren't checked.
+func verifyPins(cs tls.ConnectionState, host string, pins []string) error {
+	if len(pins) == 0 {
+		return nil
+	}
+
+	// The verified chains include the root that the leaf chains up to,
+	// which isn't necessarily sent by the server.
+	certs := cs.PeerCertificates
+	for _, chain := range cs.VerifiedChains {
+		certs = append(certs, chain...)
+	}
+	for _, cert := range certs {
+		pin := spkiPin(cert)
+		for _, p := range pins {
+			if p == pin {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("certificate of %s doesn't match any of its pinned keys", host)
+}
@@ -0,0 +1,75 @@
+package getter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// sshKnownHostsName returns the host and port in u as they are written in
+// known_hosts files.
+func sshKnownHostsName(u *url.URL) string {
+	host := u.Hostname()
+	if port := u.Port(); port != "" && port != "22" {
+		return "[" + host + "]:" + port
+	}
+
+	return host
+}
+
+// writeSSHKnownHosts writes the host keys pinned for the host in u to a new
+// temporary known_hosts file in dir, returning its path. It returns "" if
+// the host isn't pinned.
+//
+// The keys are in the format of authorized_keys and .pub files, such as
+// "ssh-ed25519 AAAA...", and any comment after them is ignored.
+func writeSSHKnownHosts(dir string, hostKeys map[string][]string, u *url.URL) (string, error) {
+	name := sshKnownHostsName(u)
+	keys, ok := hostKeys[name]
+	if !ok {
+		return "", nil
+	}
+	if len(keys) == 0 {
+		return "", fmt.Errorf("no host keys are pinned for %s", name)
+	}
+
+	var buf bytes.Buffer
+	for _, key := range keys {
+		fields := strings.Fields(key)
+		if len(fields) < 2 {
+			return "", fmt.Errorf("invalid host key for %s: %s", name, key)
+		}
+		if _, err := base64.StdEncoding.DecodeString(fields[1]); err != nil {
+			return "", fmt.Errorf("invalid host key for %s: %s", name, key)
+		}
+		fmt.Fprintf(&buf, "%s %s %s\n", name, fields[0], fields[1])
+	}
+
+	fh, err := ioutil.TempFile(dir, "go-getter")
+	if err != nil {
+		return "", err
+	}
+	_, err = fh.Write(buf.Bytes())
+	if err1 := fh.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(fh.Name())
+		return "", err
+	}
+
+	return fh.Name(), nil
+}
+
+// sshKnownHostsArgs returns the ssh options that make file the only
+// known_hosts file host keys are verified against.
+func sshKnownHostsArgs(file string) []string {
+	return []string{
+		"-o", "UserKnownHostsFile=" + file,
+		"-o", "GlobalKnownHostsFile=" + os.DevNull,
+	}
+}
@@ -0,0 +1,55 @@
+package getter
+
+import (
+	"os"
+	"testing"
+)
+
+const testSSHHostKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"
+
+func TestSSHKnownHostsName(t *testing.T) {
+	cases := map[string]string{
+		"ssh://git@example.com/foo":      "example.com",
+		"ssh://git@example.com:22/foo":   "example.com",
+		"ssh://git@example.com:2222/foo": "[example.com]:2222",
+		"sftp://[::1]:2222/foo":          "[::1]:2222",
+	}
+
+	for input, expected := range cases {
+		if actual := sshKnownHostsName(testURL(input)); actual != expected {
+			t.Fatalf("%s: bad: %s", input, actual)
+		}
+	}
+}
+
+func TestWriteSSHKnownHosts(t *testing.T) {
+	hostKeys := map[string][]string{
+		"[example.com]:2222": {testSSHHostKey + " comment"},
+	}
+
+	path, err := writeSSHKnownHosts("", hostKeys, testURL("ssh://example.com:2222/foo"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(path)
+	assertContents(t, path, "[example.com]:2222 "+testSSHHostKey+"\n")
+
+	// Hosts that aren't pinned
+	path, err = writeSSHKnownHosts("", hostKeys, testURL("ssh://example.com/foo"))
+	if err != nil || path != "" {
+		t.Fatalf("bad: %q %v", path, err)
+	}
+}
+
+func TestWriteSSHKnownHosts_invalid(t *testing.T) {
+	for _, keys := range [][]string{
+		{},
+		{"ssh-ed25519"},
+		{"ssh-ed25519 not-base64!"},
+	} {
+		hostKeys := map[string][]string{"example.com": keys}
+		if _, err := writeSSHKnownHosts("", hostKeys, testURL("ssh://example.com/foo")); err == nil {
+			t.Fatalf("%#v: should error", keys)
+		}
+	}
+}
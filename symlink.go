@@ -0,0 +1,204 @@
+package getter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SymlinkPolicy is what is done with symlinks in archives that are
+// unarchived, in directory trees that are copied into the destination, and
+// with local file sources that are copied. Symlinks that point outside of
+// the archive or directory they are in are an error with any policy but
+// SymlinksSkip and, for copied directories, SymlinksDefault, unless a
+// decompressor is Insecure, since recreating or following them could
+// expose files outside of it. The symlinks in a subdirectory of a download
+// may be followed to anywhere in the download.
+type SymlinkPolicy uint
+
+const (
+	// SymlinksDefault preserves the symlinks in archives and dereferences
+	// those in directories and local files that are copied, wherever they
+	// point, as they have always been copied. This is the default.
+	SymlinksDefault SymlinkPolicy = iota
+
+	// SymlinksPreserve recreates symlinks as symlinks to the same target.
+	SymlinksPreserve
+
+	// SymlinksDereference copies what symlinks point to in their place,
+	// so that the destination doesn't contain any symlinks.
+	SymlinksDereference
+
+	// SymlinksSkip leaves symlinks out.
+	SymlinksSkip
+
+	// SymlinksError fails the download if there are any symlinks.
+	SymlinksError
+)
+
+// symlinkDecompressor is implemented by the decompressors that apply a
+// SymlinkPolicy, so that the client's can be applied to them.
+type symlinkDecompressor interface {
+	withSymlinks(SymlinkPolicy) Decompressor
+}
+
+// withSymlinks returns d applying policy, unless it doesn't support a
+// policy or already has one of its own.
+func withSymlinks(d Decompressor, policy SymlinkPolicy) Decompressor {
+	sd, ok := d.(symlinkDecompressor)
+	if !ok || policy == SymlinksDefault {
+		return d
+	}
+
+	return sd.withSymlinks(policy)
+}
+
+// pathInside returns true if path is dir or inside of it.
+func pathInside(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// archiveSymlinks applies a SymlinkPolicy to the symlinks extracted from an
+// archive into dst.
+type archiveSymlinks struct {
+	dst      string
+	policy   SymlinkPolicy
+	insecure bool
+
+	// deref is the symlinks to replace with what they point to once the
+	// whole archive has been extracted, since that may come later.
+	deref []string
+}
+
+// extract applies the policy to the symlink to target from the archive
+// entry called name, which is extracted to path.
+func (a *archiveSymlinks) extract(path, name, target string) error {
+	switch a.policy {
+	case SymlinksSkip:
+		return nil
+	case SymlinksError:
+		return fmt.Errorf("entry is a symlink: %s", name)
+	}
+
+	if err := extractSymlink(path, name, target, a.insecure); err != nil {
+		return err
+	}
+	if a.policy == SymlinksDereference {
+		a.deref = append(a.deref, path)
+	}
+
+	return nil
+}
+
+// finish replaces the symlinks being dereferenced with copies of what they
+// point to.
+func (a *archiveSymlinks) finish() error {
+	if len(a.deref) == 0 {
+		return nil
+	}
+
+	root, err := filepath.EvalSymlinks(a.dst)
+	if err != nil {
+		return err
+	}
+	c := &dirCopier{
+		symlinks: SymlinksDereference,
+		links:    make(hardlinks),
+		copying:  make(map[string]bool),
+	}
+	if !a.insecure {
+		c.root = root
+	}
+	for _, path := range a.deref {
+		if err := c.dereference(path, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copySymlink applies the copier's SymlinkPolicy to the symlink at path,
+// which would be copied to dst.
+func (c *dirCopier) copySymlink(dst, path string) error {
+	switch c.symlinks {
+	case SymlinksSkip:
+		return nil
+	case SymlinksError:
+		return fmt.Errorf("%s is a symlink and can't be copied", path)
+	case SymlinksDefault, SymlinksDereference:
+		return c.dereference(dst, path)
+	}
+
+	target, err := os.Readlink(path)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(c.root, path)
+	if err != nil {
+		return err
+	}
+	if !symlinkInside(filepath.ToSlash(rel), filepath.ToSlash(target)) {
+		return fmt.Errorf("symlink points outside of the directory being copied: %s -> %s", path, target)
+	}
+
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.Symlink(target, dst)
+}
+
+// dereference copies what the symlink at path points to into dst, which
+// may be path itself.
+func (c *dirCopier) dereference(dst, path string) error {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	bound := c.root
+	if c.derefRoot != "" {
+		bound = c.derefRoot
+	}
+	if c.symlinks == SymlinksDefault {
+		// As directories have always been copied, what symlinks point to
+		// is copied wherever it is
+		bound = ""
+	}
+	if bound != "" && !pathInside(bound, real) {
+		return fmt.Errorf("symlink points outside of the directory being copied: %s", path)
+	}
+	fi, err := os.Stat(real)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		dir, err := filepath.EvalSymlinks(filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		if c.copying[real] || pathInside(real, dir) {
+			return fmt.Errorf("symlink to a directory it is in can't be copied: %s", path)
+		}
+	}
+
+	// Nothing may be written through the symlink
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	switch {
+	case fi.IsDir():
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+
+		return c.copy(dst, real)
+	case isSpecialFile(fi.Mode()):
+		return copySpecialFile(dst, real, fi, c.special)
+	default:
+		return c.copyFile(dst, real, fi)
+	}
+}
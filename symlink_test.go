@@ -0,0 +1,266 @@
+// +build !windows
+
+package getter
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCopyDir_symlinks(t *testing.T) {
+	src := tempDir(t)
+	defer os.RemoveAll(src)
+	if err := os.MkdirAll(filepath.Join(src, "dir"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "dir", "a"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for name, target := range map[string]string{
+		"b":       "dir/a",
+		"dirlink": "dir",
+	} {
+		if err := os.Symlink(target, filepath.Join(src, name)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	copyTo := func(policy SymlinkPolicy) (string, error) {
+		dst := tempDir(t)
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		return dst, copyDir(dst, src, false, SpecialFilesSkip, policy)
+	}
+
+	dst, err := copyTo(SymlinksPreserve)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertSymlink(t, filepath.Join(dst, "b"), "dir/a")
+	assertSymlink(t, filepath.Join(dst, "dirlink"), "dir")
+	os.RemoveAll(dst)
+
+	dst, err = copyTo(SymlinksDereference)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, path := range []string{"b", "dirlink", filepath.Join("dirlink", "a")} {
+		fi, err := os.Lstat(filepath.Join(dst, path))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			t.Fatalf("%s shouldn't be a symlink", path)
+		}
+	}
+	assertContents(t, filepath.Join(dst, "b"), "hello")
+	assertContents(t, filepath.Join(dst, "dirlink", "a"), "hello")
+	os.RemoveAll(dst)
+
+	// By default they are dereferenced, as they always have been
+	dst, err = copyTo(SymlinksDefault)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fi, err := os.Lstat(filepath.Join(dst, "b")); err != nil || fi.Mode()&os.ModeSymlink != 0 {
+		t.Fatalf("b shouldn't be a symlink: %v", err)
+	}
+	assertContents(t, filepath.Join(dst, "b"), "hello")
+	os.RemoveAll(dst)
+
+	dst, err = copyTo(SymlinksSkip)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, path := range []string{"b", "dirlink"} {
+		if _, err := os.Lstat(filepath.Join(dst, path)); !os.IsNotExist(err) {
+			t.Fatalf("%s shouldn't exist: %v", path, err)
+		}
+	}
+	os.RemoveAll(dst)
+
+	dst, err = copyTo(SymlinksError)
+	if err == nil || !strings.Contains(err.Error(), "symlink") {
+		t.Fatalf("bad: %v", err)
+	}
+	os.RemoveAll(dst)
+
+	// Symlinks may not point outside of the directory, or to a directory
+	// they are in
+	for _, target := range []string{"../../secret", "/etc/passwd", "."} {
+		if err := os.Symlink(target, filepath.Join(src, "dir", "bad")); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		for _, policy := range []SymlinkPolicy{SymlinksPreserve, SymlinksDereference} {
+			if target == "." && policy == SymlinksPreserve {
+				continue
+			}
+			dst, err := copyTo(policy)
+			if err == nil {
+				t.Fatalf("%s (%d): should error", target, policy)
+			}
+			os.RemoveAll(dst)
+		}
+		if err := os.Remove(filepath.Join(src, "dir", "bad")); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+}
+
+func TestTar_symlinkPolicy(t *testing.T) {
+	archive := testTar(t, []*tar.Header{
+		{Name: "dir/a", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "b", Typeflag: tar.TypeSymlink, Linkname: "dir/a"},
+		{Name: "c", Typeflag: tar.TypeSymlink, Linkname: "dir"},
+	})
+
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	if err := untar(bytes.NewReader(archive), td, "test", true, nil, nil, false, SymlinksDereference, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(td, "b"), "hello")
+	assertContents(t, filepath.Join(td, "c", "a"), "hello")
+	if fi, err := os.Lstat(filepath.Join(td, "c")); err != nil || !fi.IsDir() {
+		t.Fatalf("c should be a directory: %v", err)
+	}
+
+	td2 := tempDir(t)
+	defer os.RemoveAll(td2)
+	if err := untar(bytes.NewReader(archive), td2, "test", true, nil, nil, false, SymlinksSkip, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Lstat(filepath.Join(td2, "b")); !os.IsNotExist(err) {
+		t.Fatalf("b shouldn't exist: %v", err)
+	}
+	assertContents(t, filepath.Join(td2, "dir", "a"), "hello")
+
+	td3 := tempDir(t)
+	defer os.RemoveAll(td3)
+	err := untar(bytes.NewReader(archive), td3, "test", true, nil, nil, false, SymlinksError, nil)
+	if err == nil || !strings.Contains(err.Error(), "symlink") {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// A symlink to a directory it is in can't be dereferenced
+	loop := testTar(t, []*tar.Header{
+		{Name: "dir/a", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "dir/b", Typeflag: tar.TypeSymlink, Linkname: "."},
+	})
+	td4 := tempDir(t)
+	defer os.RemoveAll(td4)
+	if err := untar(bytes.NewReader(loop), td4, "test", true, nil, nil, false, SymlinksDereference, nil); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestClient_symlinksSubdir(t *testing.T) {
+	src := tempDir(t)
+	defer os.RemoveAll(src)
+	if err := os.MkdirAll(filepath.Join(src, "sub", "mod"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "common.tf"), []byte("common"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.Symlink("../../common.tf", filepath.Join(src, "sub", "mod", "x.tf")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A symlink out of the subdirectory into the rest of the download is
+	// followed by default
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+	client := &Client{
+		Src:  src + "//sub",
+		Dst:  dst,
+		Mode: ClientModeDir,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "mod", "x.tf"), "common")
+
+	// but can't be preserved, since it would point outside of Dst
+	client.Symlinks = SymlinksPreserve
+	if err := client.Get(); err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// A symlink out of the download altogether is followed by default too
+	other := tempDir(t)
+	defer os.RemoveAll(other)
+	if err := os.MkdirAll(other, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(other, "shared.tf"), []byte("shared"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.Symlink(filepath.Join(other, "shared.tf"), filepath.Join(src, "sub", "mod", "y.tf")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	client.Symlinks = SymlinksDefault
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "mod", "y.tf"), "shared")
+
+	// but not if they are to be dereferenced explicitly
+	client.Symlinks = SymlinksDereference
+	if err := client.Get(); err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestClient_symlinks(t *testing.T) {
+	// The client's policy applies to the default decompressors, unless
+	// they have their own
+	d := withSymlinks(new(TarGzipDecompressor), SymlinksSkip)
+	if actual := d.(*TarGzipDecompressor).Symlinks; actual != SymlinksSkip {
+		t.Fatalf("bad: %d", actual)
+	}
+	d = withSymlinks(&ZipDecompressor{Symlinks: SymlinksError}, SymlinksSkip)
+	if actual := d.(*ZipDecompressor).Symlinks; actual != SymlinksError {
+		t.Fatalf("bad: %d", actual)
+	}
+	if d := withSymlinks(new(GzipDecompressor), SymlinksSkip); d.(*GzipDecompressor) == nil {
+		t.Fatal("should be unchanged")
+	}
+
+	// A local file that is a symlink isn't copied if symlinks aren't
+	// allowed
+	td := tempDir(t)
+	defer os.RemoveAll(td)
+	if err := os.MkdirAll(td, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	target, err := filepath.Abs(filepath.Join(fixtureDir, "basic", "main.tf"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	link := filepath.Join(td, "link")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	u, err := url.Parse("file://" + link)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	g := &FileGetter{Copy: true}
+	g.client = &Client{Symlinks: SymlinksError}
+	if err := g.GetFile(filepath.Join(td, "a"), u); err == nil {
+		t.Fatal("should error")
+	}
+	g.client = &Client{Symlinks: SymlinksDereference}
+	if err := g.GetFile(filepath.Join(td, "b"), u); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -0,0 +1,32 @@
+package getter
+
+import (
+	"crypto/tls"
+	"net/http"
+)
+
+// sessionClient returns a copy of client whose TLS connections store
+// their sessions in cache, so that later connections to the same host can
+// resume them. Clients whose transport isn't an *http.Transport, or that
+// already have a session cache, are returned as they are.
+func sessionClient(client *http.Client, cache tls.ClientSessionCache) *http.Client {
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	t, ok := transport.(*http.Transport)
+	if !ok || (t.TLSClientConfig != nil && t.TLSClientConfig.ClientSessionCache != nil) {
+		return client
+	}
+
+	t = t.Clone()
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = new(tls.Config)
+	}
+	t.TLSClientConfig.ClientSessionCache = cache
+
+	c := *client
+	c.Transport = t
+	return &c
+}
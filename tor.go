@@ -0,0 +1,62 @@
+package getter
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// isOnion returns true if host, without a port, is a Tor onion service.
+// These can only be reached through Tor, and must never be looked up in
+// DNS (RFC 7686).
+func isOnion(host string) bool {
+	return strings.HasSuffix(strings.ToLower(strings.TrimSuffix(host, ".")), ".onion")
+}
+
+// torClient returns a copy of client whose requests are sent through the
+// Tor SOCKS5 proxy at proxy, either all of them or, if onionOnly is true,
+// only those to onion services. If proxy is empty, requests to onion
+// services fail rather than being sent anywhere else.
+func torClient(client *http.Client, proxy string, onionOnly bool) (*http.Client, error) {
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	t, ok := transport.(*http.Transport)
+	if !ok {
+		if proxy == "" {
+			return client, nil
+		}
+		return nil, fmt.Errorf(
+			"TorProxy requires the client's transport to be an *http.Transport, got %T", transport)
+	}
+
+	// The proxy is chosen for each request, including redirects. Host
+	// names are sent to Tor to resolve so that nothing about the request
+	// is leaked to the local resolver.
+	torURL := &url.URL{Scheme: "socks5", Host: proxy}
+	next := t.Proxy
+	t = t.Clone()
+	t.Proxy = func(req *http.Request) (*url.URL, error) {
+		if isOnion(req.URL.Hostname()) {
+			if proxy == "" {
+				return nil, fmt.Errorf(
+					"%s is an onion service and can only be fetched through a TorProxy", req.URL.Hostname())
+			}
+			return torURL, nil
+		}
+		if proxy != "" && !onionOnly {
+			return torURL, nil
+		}
+		if next != nil {
+			return next(req)
+		}
+		return nil, nil
+	}
+
+	c := *client
+	c.Transport = t
+	return &c, nil
+}
@@ -0,0 +1,224 @@
+package getter
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestHttpGetter_torProxy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Hello\n"))
+	}))
+	defer server.Close()
+
+	proxy := newTestSOCKSProxy(t, server.Listener.Addr().String())
+	defer proxy.Close()
+
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	// Onion services are reached through Tor, which resolves them
+	g := &HttpGetter{TorProxy: proxy.Addr()}
+	if err := g.GetFile(filepath.Join(dst, "a"), testURL("http://example.onion/file")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "a"), "Hello\n")
+	if actual := proxy.Requests(); len(actual) != 1 || actual[0] != "example.onion:80" {
+		t.Fatalf("bad: %v", actual)
+	}
+
+	// So are other hosts
+	if err := g.GetFile(filepath.Join(dst, "b"), testURL(server.URL+"/file")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual := proxy.Requests(); len(actual) != 2 {
+		t.Fatalf("bad: %v", actual)
+	}
+
+	// Unless only onion services should be
+	g = &HttpGetter{TorProxy: proxy.Addr(), TorOnionOnly: true}
+	if err := g.GetFile(filepath.Join(dst, "c"), testURL(server.URL+"/file")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual := proxy.Requests(); len(actual) != 2 {
+		t.Fatalf("bad: %v", actual)
+	}
+	if err := g.GetFile(filepath.Join(dst, "d"), testURL("http://example.onion/file")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual := proxy.Requests(); len(actual) != 3 {
+		t.Fatalf("bad: %v", actual)
+	}
+}
+
+func TestHttpGetter_torProxyAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Hello\n"))
+	}))
+	defer server.Close()
+
+	proxy := newTestSOCKSProxy(t, server.Listener.Addr().String())
+	defer proxy.Close()
+
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	// The TorProxy is connected to, though it is on loopback, and onion
+	// services are left to Tor
+	g := &HttpGetter{TorProxy: proxy.Addr()}
+	g.client = &Client{Addresses: DefaultAddressPolicy()}
+	if err := g.GetFile(filepath.Join(dst, "a"), testURL("http://example.onion/file")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	assertContents(t, filepath.Join(dst, "a"), "Hello\n")
+
+	// but other hosts are checked
+	err := g.GetFile(filepath.Join(dst, "b"), testURL(server.URL+"/file"))
+	if reason := AbortReasonOf(err); reason != AbortPolicy {
+		t.Fatalf("bad reason %q: %v", reason, err)
+	}
+	if actual := proxy.Requests(); len(actual) != 1 {
+		t.Fatalf("bad: %v", actual)
+	}
+}
+
+func TestHttpGetter_onionWithoutTor(t *testing.T) {
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	g := new(HttpGetter)
+	err := g.GetFile(filepath.Join(dst, "a"), testURL("http://example.onion/file"))
+	if err == nil || !strings.Contains(err.Error(), "onion service") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+type testRoundTripper struct{}
+
+func (testRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	panic("shouldn't be used")
+}
+
+func TestHttpGetter_torProxyTransport(t *testing.T) {
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	g := &HttpGetter{
+		Client:   &http.Client{Transport: testRoundTripper{}},
+		TorProxy: "127.0.0.1:9050",
+	}
+	err := g.GetFile(filepath.Join(dst, "a"), testURL("http://example.com/file"))
+	if err == nil || !strings.Contains(err.Error(), "*http.Transport") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+// testSOCKSProxy is a SOCKS5 proxy that connects every request to the same
+// address, recording the addresses that were requested.
+type testSOCKSProxy struct {
+	net.Listener
+
+	target   string
+	mu       sync.Mutex
+	requests []string
+}
+
+func newTestSOCKSProxy(t *testing.T, target string) *testSOCKSProxy {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	p := &testSOCKSProxy{Listener: l, target: target}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(conn)
+		}
+	}()
+
+	return p
+}
+
+func (p *testSOCKSProxy) Addr() string {
+	return p.Listener.Addr().String()
+}
+
+func (p *testSOCKSProxy) Requests() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.requests...)
+}
+
+func (p *testSOCKSProxy) serve(conn net.Conn) {
+	defer conn.Close()
+
+	// Greeting, to which no authentication is chosen
+	buf := make([]byte, 262)
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+		return
+	}
+	if _, err := conn.Write([]byte{5, 0}); err != nil {
+		return
+	}
+
+	// Connect request
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		return
+	}
+	var host string
+	switch buf[3] {
+	case 1:
+		if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+			return
+		}
+		host = net.IP(buf[:4]).String()
+	case 3:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return
+		}
+		n := int(buf[0])
+		if _, err := io.ReadFull(conn, buf[:n]); err != nil {
+			return
+		}
+		host = string(buf[:n])
+	default:
+		return
+	}
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	port := binary.BigEndian.Uint16(buf[:2])
+
+	p.mu.Lock()
+	p.requests = append(p.requests, net.JoinHostPort(host, strconv.Itoa(int(port))))
+	p.mu.Unlock()
+
+	target, err := net.Dial("tcp", p.target)
+	if err != nil {
+		conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		return
+	}
+
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
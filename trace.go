@@ -0,0 +1,97 @@
+package getter
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Trace records the decisions that Clients make while downloading, to
+// answer why a source was fetched the way it was: the detector that matched
+// it, the getter that was forced, the subdirectory split off of it, the
+// getter and mode used, the decompressor chosen, the redirects followed and
+// the mirrors tried. It is set with the Client's Trace and read once Get
+// returns, and is shared with the downloads that a getter makes for a
+// source, such as the sources of a manifest. A Trace may be used by several
+// Clients at once.
+type Trace struct {
+	mu     sync.Mutex
+	events []TraceEvent
+}
+
+// TraceEvent is a decision recorded by a Trace.
+type TraceEvent struct {
+	Time time.Time `json:"time"`
+
+	// Step is what was decided: "detect", "force", "subdir", "getter",
+	// "mode", "decompress", "peer", "redirect" or "mirror".
+	Step string `json:"step"`
+
+	// Source is the source being downloaded, without any credentials.
+	Source string `json:"source"`
+
+	// Detail says what was decided.
+	Detail string `json:"detail"`
+}
+
+// Events returns the decisions recorded so far, in the order they were
+// made.
+func (t *Trace) Events() []TraceEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]TraceEvent(nil), t.events...)
+}
+
+// String returns the decisions recorded so far, one to a line.
+func (t *Trace) String() string {
+	var b strings.Builder
+	for _, e := range t.Events() {
+		fmt.Fprintf(&b, "%s %s: %s\n", e.Step, e.Source, e.Detail)
+	}
+
+	return b.String()
+}
+
+func (t *Trace) add(step, source, detail string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.events = append(t.events, TraceEvent{
+		Time:   time.Now(),
+		Step:   step,
+		Source: redactURLCredentials(source),
+		Detail: detail,
+	})
+}
+
+// trace records a decision made while downloading source, if the client
+// has a Trace.
+func (c *Client) trace(step, source, format string, args ...interface{}) {
+	if c.Trace != nil {
+		c.Trace.add(step, source, fmt.Sprintf(format, args...))
+	}
+}
+
+// trace records a decision made by the getter, if the client using it has
+// a Trace.
+func (g *getter) trace(step, format string, args ...interface{}) {
+	if g.client != nil {
+		g.client.trace(step, g.client.Src, format, args...)
+	}
+}
+
+// modeName returns the name of mode in the form used by manifests.
+func modeName(mode ClientMode) string {
+	switch mode {
+	case ClientModeAny:
+		return "any"
+	case ClientModeFile:
+		return "file"
+	case ClientModeDir:
+		return "dir"
+	}
+
+	return "invalid"
+}
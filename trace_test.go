@@ -0,0 +1,81 @@
+package getter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClient_trace(t *testing.T) {
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	trace := new(Trace)
+	client := &Client{
+		Src:   "file::" + filepath.Join("test-fixtures", "archive.tar.gz"),
+		Dst:   dst,
+		Pwd:   pwd,
+		Mode:  ClientModeAny,
+		Trace: trace,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var steps []string
+	for _, e := range trace.Events() {
+		steps = append(steps, e.Step)
+		if e.Source != client.Src {
+			t.Fatalf("bad source: %#v", e)
+		}
+	}
+	expected := []string{"detect", "force", "getter", "decompress"}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Fatalf("bad: %#v\n\n%s", steps, trace)
+	}
+	for _, detail := range []string{"*getter.FileDetector", "forced to file", "*getter.FileGetter", "as tar.gz"} {
+		if !strings.Contains(trace.String(), detail) {
+			t.Fatalf("missing %q:\n\n%s", detail, trace)
+		}
+	}
+}
+
+func TestClient_traceMirrors(t *testing.T) {
+	dst := tempDir(t)
+	defer os.RemoveAll(dst)
+
+	trace := new(Trace)
+	client := &Client{
+		Src:   testModule("missing") + "?mirrors=" + testModule("basic") + "//subdir",
+		Dst:   dst,
+		Dir:   true,
+		Trace: trace,
+	}
+	if err := client.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var mirrors, subdirs []string
+	for _, e := range trace.Events() {
+		switch e.Step {
+		case "mirror":
+			mirrors = append(mirrors, e.Detail)
+		case "subdir":
+			subdirs = append(subdirs, e.Detail)
+		}
+	}
+	expected := []string{"trying source 1 of 2", "trying source 2 of 2"}
+	if !reflect.DeepEqual(mirrors, expected) {
+		t.Fatalf("bad: %#v\n\n%s", mirrors, trace)
+	}
+	if len(subdirs) != 1 || !strings.HasPrefix(subdirs[0], "subdirectory subdir ") {
+		t.Fatalf("bad: %#v\n\n%s", subdirs, trace)
+	}
+}